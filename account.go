@@ -0,0 +1,322 @@
+package rrl
+
+import (
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/markdingo/rrl/cache"
+)
+
+// AccountKind identifies the purpose of an account in the RRL table.
+type AccountKind uint8
+
+const (
+	AccountResponse    AccountKind = iota // A "Response Tuple" account for a Client Network
+	AccountRequests                       // A requests-per-second account for a Client Network
+	AccountAggregate                      // A requests account for an ipv6 aggregate network
+	AccountMinimum                        // A minimum-responses-per-second account
+	AccountTCPRequests                    // A requests-by-transport account for non-UDP requests
+	AccountLast
+)
+
+// AccountKey uniquely identifies an account in the RRL table. It is the decoded form of
+// the internal account token.
+//
+// Network is always set. It is the Client Network or, for AccountAggregate, the
+// aggregate network in CIDR notation.
+// The remaining fields are only set for AccountResponse keys and reflect the fields of
+// the [ResponseTuple] which contribute to the account. For example Type is only set for
+// AllowanceAnswer and AllowanceReferral accounts and Class is only set when
+// "include-class" is configured and the class is not ClassINET. For AllowanceError
+// accounts, SalientName is the matching "error-suffixes" name, if any.
+type AccountKey struct {
+	Kind    AccountKind
+	Network string
+	AllowanceCategory
+	Class       uint16
+	Type        uint16
+	SalientName string
+}
+
+// AccountInfo describes the state of a single account at the time it was examined.
+type AccountInfo struct {
+	Key AccountKey
+
+	// Balance is the credit remaining in the account. A negative Balance means the
+	// account is being rate limited.
+	Balance time.Duration
+
+	// LimitedFor is how long the account has been continuously rate limited, that is,
+	// how long since it last went from credit into debit. It is zero if Balance is not
+	// negative.
+	LimitedFor time.Duration
+
+	// Age is how long since the account was created, or since it was imported by
+	// [RRL.PrimeFrom].
+	Age time.Duration
+}
+
+// newAccountInfo returns the AccountInfo of account t at time now.
+func newAccountInfo(t string, ra *responseAccount, balance, now int64) AccountInfo {
+	ai := AccountInfo{Key: parseAccountKey(t), Balance: time.Duration(balance),
+		Age: time.Duration(now - ra.created)}
+	if balance < 0 && ra.limited {
+		ai.LimitedFor = time.Duration(now - ra.limitedSince)
+	}
+
+	return ai
+}
+
+// parseAccountKey decodes an internal account token into an AccountKey. Tokens are
+// formulated by addrPrefixes, minimumGuaranteed and buildToken.
+func parseAccountKey(t string) (key AccountKey) {
+	parts := strings.SplitN(t, "/", 4)
+	key.Network = parts[0]
+	switch len(parts) {
+	case 1:
+		key.Kind = AccountRequests
+	case 2:
+		switch parts[1] {
+		case "min":
+			key.Kind = AccountMinimum
+		case "tcp":
+			key.Kind = AccountTCPRequests
+		default:
+			key.Kind = AccountAggregate
+			key.Network = t
+		}
+	case 4:
+		key.Kind = AccountResponse
+		ac, _ := strconv.ParseUint(parts[1], 10, 8)
+		key.AllowanceCategory = AllowanceCategory(ac)
+		qType, qClass, _ := strings.Cut(parts[2], ":")
+		t, _ := strconv.ParseUint(qType, 10, 16)
+		key.Type = uint16(t)
+		c, _ := strconv.ParseUint(qClass, 10, 16)
+		key.Class = uint16(c)
+		key.SalientName = parts[3]
+	}
+
+	return
+}
+
+// token returns the internal account token of the AccountKey.
+func (key *AccountKey) token(rrl *RRL) string {
+	switch key.Kind {
+	case AccountRequests, AccountAggregate:
+		return key.Network
+	case AccountMinimum:
+		return key.Network + "/min"
+	case AccountTCPRequests:
+		return key.Network + "/tcp"
+	}
+
+	return rrl.buildToken(key.AllowanceCategory, key.Class, key.Type, key.SalientName, key.Network)
+}
+
+// accountCopy is a copy of an account taken under its shard read lock.
+type accountCopy struct {
+	t  string
+	ra responseAccount
+}
+
+// copyShard appends a copy of every account in shard ix of table to copies.
+func copyShard(table *cache.Cache, ix int, copies []accountCopy) []accountCopy {
+	table.WalkShard(ix, func(t string, el interface{}) bool {
+		if ra, ok := (el).(*responseAccount); ok {
+			copies = append(copies, accountCopy{t, *ra})
+		}
+		return true
+	})
+
+	return copies
+}
+
+// walkAccounts calls fn for each account in all tables until fn returns false. The
+// balance is relative to now.
+//
+// So that a walk of a large table cannot stall concurrent debits, accounts are copied a
+// shard at a time under the shard read lock and fn is called with the copies once the
+// lock is released. Changes fn makes to ra are thus discarded.
+//
+// If deadline is non-zero the walk also stops at the first shard boundary after deadline,
+// in which case walkAccounts returns false.
+func (rrl *RRL) walkAccounts(deadline int64, fn func(t string, ra *responseAccount, balance int64) bool) bool {
+	var copies []accountCopy
+	now := rrl.now()
+	for _, table := range rrl.distinctTables() {
+		for ix := 0; ix < table.Shards(); ix++ {
+			if deadline != 0 && rrl.now() > deadline {
+				return false
+			}
+			copies = copyShard(table, ix, copies[:0])
+			for cx := range copies {
+				ac := &copies[cx]
+				if !fn(ac.t, &ac.ra, ac.ra.balance(now)) {
+					return true
+				}
+			}
+		}
+	}
+
+	return true
+}
+
+// distinctTables returns the main table and any separate category tables.
+func (rrl *RRL) distinctTables() []*cache.Cache {
+	ret := []*cache.Cache{rrl.table}
+	for _, table := range rrl.tables {
+		if table != nil && table != rrl.table {
+			ret = append(ret, table)
+		}
+	}
+
+	return ret
+}
+
+// prefix returns the network of the AccountKey as a netip.Prefix using the configured
+// prefix lengths.
+func (key *AccountKey) prefix(rrl *RRL) (netip.Prefix, error) {
+	if key.Kind == AccountAggregate {
+		return netip.ParsePrefix(key.Network)
+	}
+	addr, err := netip.ParseAddr(key.Network)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	if addr.Is4() {
+		return addr.Prefix(rrl.cfg.ipv4PrefixLength)
+	}
+
+	return addr.Prefix(rrl.cfg.ipv6PrefixLength)
+}
+
+// reaggregate returns the account token t with its network re-masked by the configured
+// prefix lengths. It allows accounts created under different prefix lengths, such as
+// those imported by PrimeFrom from a differently configured peer, to be merged into the
+// local accounts. reaggregate returns false if the account has no local equivalent,
+// which is the case for aggregate accounts when ipv6 aggregation is not configured and
+// for TCP requests accounts when requests-by-transport is not configured.
+func (rrl *RRL) reaggregate(t string) (string, bool) {
+	key := parseAccountKey(t)
+	if key.Kind == AccountTCPRequests && !rrl.cfg.requestsByTransport {
+		return "", false
+	}
+	if key.Kind == AccountAggregate {
+		if rrl.cfg.ipv6AggregateInterval == 0 {
+			return "", false
+		}
+		p, err := netip.ParsePrefix(key.Network)
+		if err == nil {
+			p, err = p.Addr().Prefix(rrl.cfg.ipv6AggregatePrefixLength)
+		}
+		if err != nil {
+			return "", false
+		}
+		key.Network = p.String()
+		return key.token(rrl), true
+	}
+
+	p, err := key.prefix(rrl)
+	if err != nil {
+		return "", false
+	}
+	key.Network = p.Addr().String()
+
+	return key.token(rrl), true
+}
+
+// LimitedNetworks returns the Client Networks (and ipv6 aggregate networks) which have at
+// least one request or response account with a balance at or below -below. In other
+// words the networks which are heavily rate-limited. The returned prefixes are sorted.
+//
+// LimitedNetworks examines all accounts so it should be called sparingly on large tables.
+// It is subject to max-inspections-per-second and inspection-budget, so the result may be
+// incomplete if the budget is exhausted.
+func (rrl *RRL) LimitedNetworks(below time.Duration) []netip.Prefix {
+	limited := make(map[netip.Prefix]struct{})
+	rrl.inspect(func(t string, ra *responseAccount, balance int64) bool {
+		if balance >= 0 || balance > -int64(below) {
+			return true
+		}
+		key := parseAccountKey(t)
+		if key.Kind == AccountMinimum {
+			return true
+		}
+		if p, err := key.prefix(rrl); err == nil {
+			limited[p] = struct{}{}
+		}
+		return true
+	})
+
+	var ret []netip.Prefix
+	for p := range limited {
+		ret = append(ret, p)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if c := ret[i].Addr().Compare(ret[j].Addr()); c != 0 {
+			return c < 0
+		}
+		return ret[i].Bits() < ret[j].Bits()
+	})
+
+	return ret
+}
+
+// DumpLimited returns all request and response accounts which have been continuously rate
+// limited for at least olderThan, longest limited first. This is the natural input for
+// firewall exports and abuse reports as transiently limited accounts, which are normal
+// for busy resolvers, are excluded. An account which recovers into credit starts afresh
+// when it is next limited.
+//
+// DumpLimited examines all accounts so it should be called sparingly on large tables.
+// It is subject to max-inspections-per-second and inspection-budget, so the result may be
+// incomplete if the budget is exhausted.
+func (rrl *RRL) DumpLimited(olderThan time.Duration) []AccountInfo {
+	var ret []AccountInfo
+	var now int64 // Set on the first visit as inspect may delay the walk
+	rrl.inspect(func(t string, ra *responseAccount, balance int64) bool {
+		if now == 0 {
+			now = rrl.now()
+		}
+		if balance >= 0 || !ra.limited {
+			return true
+		}
+		ai := newAccountInfo(t, ra, balance, now)
+		if ai.Key.Kind != AccountMinimum && ai.LimitedFor >= olderThan {
+			ret = append(ret, ai)
+		}
+		return true
+	})
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].LimitedFor != ret[j].LimitedFor {
+			return ret[i].LimitedFor > ret[j].LimitedFor
+		}
+		return ret[i].Key.String() < ret[j].Key.String()
+	})
+
+	return ret
+}
+
+// BalanceOf returns the current balance of the account identified by key, such as the
+// Key of an [AccountInfo] returned by [RRL.DumpLimited]. The second return value is false
+// if the account does not exist.
+//
+// Unlike the functions which examine all accounts, BalanceOf only examines the one
+// account under its shard read lock. It never competes with the write path of other
+// shards and is not subject to max-inspections-per-second, so it is suitable for
+// dashboards which frequently poll a handful of watched accounts.
+//
+// BalanceOf is concurrency safe.
+func (rrl *RRL) BalanceOf(key AccountKey) (time.Duration, bool) {
+	table := rrl.table
+	if key.Kind == AccountResponse {
+		table = rrl.tableFor(key.AllowanceCategory)
+	}
+	b, found := rrl.balance(table, 0, key.token(rrl))
+
+	return time.Duration(b), found
+}
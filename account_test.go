@@ -0,0 +1,165 @@
+package rrl
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestAccountKeyRoundTrip(t *testing.T) {
+	R := NewRRL(NewConfig())
+	testCases := []struct {
+		token string
+		key   AccountKey
+	}{
+		{"10.0.0.0", AccountKey{Kind: AccountRequests, Network: "10.0.0.0"}},
+		{"2001:db8::/48", AccountKey{Kind: AccountAggregate, Network: "2001:db8::/48"}},
+		{"10.0.0.0/min", AccountKey{Kind: AccountMinimum, Network: "10.0.0.0"}},
+		{"10.0.0.0/tcp", AccountKey{Kind: AccountTCPRequests, Network: "10.0.0.0"}},
+		{"10.0.0.0/0/1/example.com.", AccountKey{Kind: AccountResponse, Network: "10.0.0.0",
+			AllowanceCategory: AllowanceAnswer, Type: 1, SalientName: "example.com."}},
+		{"::/3//a/b.example.", AccountKey{Kind: AccountResponse, Network: "::",
+			AllowanceCategory: AllowanceNXDomain, SalientName: "a/b.example."}},
+		{"::/4//", AccountKey{Kind: AccountResponse, Network: "::", AllowanceCategory: AllowanceError}},
+		{"10.0.0.0/0/16:3/version.bind.", AccountKey{Kind: AccountResponse, Network: "10.0.0.0",
+			AllowanceCategory: AllowanceAnswer, Class: 3, Type: 16, SalientName: "version.bind."}},
+		{"::/2/:3/example.", AccountKey{Kind: AccountResponse, Network: "::",
+			AllowanceCategory: AllowanceNoData, Class: 3, SalientName: "example."}},
+	}
+
+	for ix, tc := range testCases {
+		key := parseAccountKey(tc.token)
+		if key != tc.key {
+			t.Errorf("%d parseAccountKey(%s) expected %+v got %+v", ix, tc.token, tc.key, key)
+		}
+		if got := key.token(R); got != tc.token {
+			t.Error(ix, "token round trip expected", tc.token, "got", got)
+		}
+	}
+}
+
+func TestLimitedNetworks(t *testing.T) {
+	cfg := NewConfig()
+	cfg.SetValue("responses-per-second", "1")
+	cfg.SetValue("ipv6-aggregate-requests-per-second", "1")
+	cfg.SetNowFunc(func() time.Time {
+		return time.Time{}
+	})
+	R := NewRRL(cfg)
+
+	tuple := newTuple(1, 1, "example.com.", AllowanceAnswer)
+	for ix := 0; ix < 5; ix++ {
+		R.Debit(newAddr("udp", "10.0.1.1:53"), tuple)      // Heavily limited
+		R.Debit(newAddr("udp", "[2001:db8::1]:53"), tuple) // Heavily limited aggregate
+	}
+	R.Debit(newAddr("udp", "10.0.0.1:53"), tuple) // In credit
+	R.Debit(newAddr("udp", "10.0.2.1:53"), tuple)
+	R.Debit(newAddr("udp", "10.0.2.1:53"), tuple) // Mildly limited
+
+	got := fmt.Sprint(R.LimitedNetworks(3 * time.Second))
+	exp := "[10.0.1.0/24 2001:db8::/48]"
+	if got != exp {
+		t.Error("LimitedNetworks(3s) expected", exp, "got", got)
+	}
+	got = fmt.Sprint(R.LimitedNetworks(0))
+	exp = "[10.0.1.0/24 10.0.2.0/24 2001:db8::/48]"
+	if got != exp {
+		t.Error("LimitedNetworks(0) expected", exp, "got", got)
+	}
+}
+
+func TestDumpLimited(t *testing.T) {
+	var now time.Time
+	cfg := NewConfig()
+	cfg.SetValue("responses-per-second", "1")
+	cfg.SetNowFunc(func() time.Time {
+		return now
+	})
+	R := NewRRL(cfg)
+
+	tuple := newTuple(1, 1, "example.com.", AllowanceAnswer)
+	persistent := newAddr("udp", "10.0.1.1:53")
+	transient := newAddr("udp", "10.0.2.1:53")
+	for ix := 0; ix < 10; ix++ {
+		R.Debit(persistent, tuple)
+	}
+	now = now.Add(5 * time.Second)
+	R.Debit(persistent, tuple) // Still limited so LimitedFor continues from the first debit
+	R.Debit(transient, tuple)
+	R.Debit(transient, tuple)
+	R.Debit(transient, tuple)
+
+	all := R.DumpLimited(0)
+	if len(all) != 2 {
+		t.Fatal("Expected two limited accounts, not", all)
+	}
+	if all[0].Key.Network != "10.0.1.0" || all[0].LimitedFor != 5*time.Second {
+		t.Error("Persistent account should be first and limited for 5s", all[0])
+	}
+	if all[1].Key.Network != "10.0.2.0" || all[1].LimitedFor != 0 {
+		t.Error("Transient account should be second and only just limited", all[1])
+	}
+
+	old := R.DumpLimited(time.Second)
+	if len(old) != 1 || old[0].Key.Network != "10.0.1.0" {
+		t.Error("Only the persistent account should be limited for 1s", old)
+	}
+
+	// Once the transient account recovers it starts afresh when limited again
+	now = now.Add(20 * time.Second)
+	if l := R.DumpLimited(0); len(l) != 0 {
+		t.Error("All accounts should have recovered", l)
+	}
+	R.Debit(transient, tuple)
+	R.Debit(transient, tuple)
+	R.Debit(transient, tuple)
+	if l := R.DumpLimited(time.Nanosecond); len(l) != 0 {
+		t.Error("Recovered account should not carry its earlier LimitedFor", l)
+	}
+}
+
+func TestBalanceOf(t *testing.T) {
+	var now time.Time
+	cfg := NewConfig()
+	cfg.SetValue("responses-per-second", "1")
+	cfg.SetValue("requests-per-second", "10")
+	cfg.SetValue("nxdomains-table-size", "1024") // Partitioned table
+	cfg.SetNowFunc(func() time.Time {
+		return now
+	})
+	R := NewRRL(cfg)
+
+	src := newAddr("udp", "10.0.1.1:53")
+	R.Debit(src, newTuple(1, 1, "example.com.", AllowanceAnswer))
+	R.Debit(src, newTuple(1, 1, "example.com.", AllowanceAnswer))
+	R.Debit(src, newTuple(1, 1, "nx.example.com.", AllowanceNXDomain))
+
+	answer := AccountKey{Kind: AccountResponse, Network: "10.0.1.0", AllowanceCategory: AllowanceAnswer,
+		Type: 1, SalientName: "example.com."}
+	if b, found := R.BalanceOf(answer); !found || b != -time.Second {
+		t.Error("Answer account should be at -1s, not", b, found)
+	}
+	nx := AccountKey{Kind: AccountResponse, Network: "10.0.1.0", AllowanceCategory: AllowanceNXDomain,
+		SalientName: "nx.example.com."}
+	if b, found := R.BalanceOf(nx); !found || b != 0 {
+		t.Error("NXDomain account should be found in its own table at 0s, not", b, found)
+	}
+	if b, found := R.BalanceOf(AccountKey{Kind: AccountRequests, Network: "10.0.1.0"}); !found ||
+		b != 700*time.Millisecond {
+		t.Error("Requests account should be at 0.7s, not", b, found)
+	}
+	if _, found := R.BalanceOf(AccountKey{Kind: AccountRequests, Network: "10.0.2.0"}); found {
+		t.Error("Unknown account should not be found")
+	}
+
+	// Balance reflects recovery without a debit
+	now = now.Add(500 * time.Millisecond)
+	if b, _ := R.BalanceOf(answer); b != -500*time.Millisecond {
+		t.Error("Answer account should have recovered to -0.5s, not", b)
+	}
+	for _, ai := range R.DumpLimited(0) {
+		if b, found := R.BalanceOf(ai.Key); !found || b != ai.Balance {
+			t.Error("BalanceOf should agree with DumpLimited", ai, b, found)
+		}
+	}
+}
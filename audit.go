@@ -0,0 +1,92 @@
+package rrl
+
+import (
+	"sync"
+	"time"
+)
+
+// DecisionRecord is the full context of a single non-Send decision as returned by
+// [RRL.RecentDecisions].
+type DecisionRecord struct {
+	Time    time.Time
+	Network string // The Client Network
+	Tag     string // The tag passed to DebitTagged, if any
+
+	// Tuple is a copy of the ResponseTuple. It is the zero value for decisions made by
+	// DebitRequest as the response is not known at that stage.
+	Tuple ResponseTuple
+
+	Decision
+
+	// Balance is the balance of the account which caused the decision, immediately
+	// after the debit. It is zero if the decision was not caused by an account balance,
+	// such as IPCacheFull.
+	Balance time.Duration
+}
+
+// decisionLog is a bounded ring of recent DecisionRecords.
+type decisionLog struct {
+	mu      sync.Mutex
+	records []DecisionRecord
+	next    int // Index of the next record to write
+	count   int // Number of valid records
+}
+
+// recordDecision adds the non-Send decision to the decision log, if configured. ac is the
+// effective category of tuple, as returned by categoryOf.
+func (rrl *RRL) recordDecision(tag, ipPrefix, aggPrefix string, tuple *ResponseTuple, ac AllowanceCategory, d Decision) {
+	dl := rrl.decisions
+	if dl == nil {
+		return
+	}
+
+	dr := DecisionRecord{Time: rrl.clock.wall(), Network: ipPrefix, Tag: tag, Decision: d}
+	if tuple != nil {
+		dr.Tuple = *tuple
+	}
+
+	var b int64
+	switch {
+	case d.IPReason == IPRateLimit:
+		b, _ = rrl.balance(rrl.table, 0, ipPrefix)
+	case d.IPReason == IPAggregateLimit:
+		b, _ = rrl.balance(rrl.table, 0, aggPrefix)
+	case d.RTReason == RTRateLimit && tuple != nil:
+		t := rrl.accountToken(ipPrefix, tuple.Class, tuple.Type, rrl.lowerName(tuple.SalientName), ac)
+		b, _ = rrl.balance(rrl.tableFor(ac), 0, t)
+	}
+	dr.Balance = time.Duration(b)
+
+	dl.mu.Lock()
+	dl.records[dl.next] = dr
+	dl.next = (dl.next + 1) % len(dl.records)
+	if dl.count < len(dl.records) {
+		dl.count++
+	}
+	dl.mu.Unlock()
+}
+
+// RecentDecisions returns up to n of the most recent non-Send decisions, most recent
+// first. It gives support staff immediate answers to questions such as "why was my
+// resolver blocked at 14:03?".
+// RecentDecisions returns nil if "decision-log-size" is not configured.
+//
+// RecentDecisions is concurrency safe.
+func (rrl *RRL) RecentDecisions(n int) []DecisionRecord {
+	dl := rrl.decisions
+	if dl == nil || n <= 0 {
+		return nil
+	}
+
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	if n > dl.count {
+		n = dl.count
+	}
+	ret := make([]DecisionRecord, 0, n)
+	for ix := 1; ix <= n; ix++ {
+		ret = append(ret, dl.records[(dl.next-ix+len(dl.records))%len(dl.records)])
+	}
+
+	return ret
+}
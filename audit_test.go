@@ -0,0 +1,81 @@
+package rrl
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecentDecisions(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cfg := NewConfig()
+	cfg.SetValue("responses-per-second", "1")
+	cfg.SetValue("requests-per-second", "2")
+	cfg.SetValue("decision-log-size", "3")
+	cfg.SetNowFunc(func() time.Time { return now })
+	R := NewRRL(cfg)
+
+	if d := R.RecentDecisions(5); len(d) != 0 {
+		t.Error("Expected no decisions at start", d)
+	}
+
+	tuple := newTuple(1, 1, "example.com.", AllowanceAnswer)
+	R.Debit(newAddr("udp", "10.0.0.1:53"), tuple)               // Send - not recorded
+	R.DebitTagged("eth0", newAddr("udp", "10.0.0.1:53"), tuple) // RTRateLimit
+	now = now.Add(time.Millisecond)
+	R.Debit(newAddr("udp", "10.0.0.1:53"), tuple) // IPRateLimit
+
+	d := R.RecentDecisions(5)
+	if len(d) != 2 {
+		t.Fatal("Expected 2 decisions, got", len(d), d)
+	}
+	if d[0].IPReason != IPRateLimit || d[0].Action == Send || d[0].Balance >= 0 {
+		t.Error("Unexpected most recent decision", d[0].Decision, d[0].Balance)
+	}
+	if d[1].RTReason != RTRateLimit || d[1].Tag != "eth0" || d[1].Network != "10.0.0.0" ||
+		d[1].Tuple.SalientName != "example.com." || d[1].Balance >= 0 {
+		t.Error("Unexpected earlier decision", d[1])
+	}
+	if !d[0].Time.After(d[1].Time) {
+		t.Error("Decisions not most recent first", d[0].Time, d[1].Time)
+	}
+
+	// DebitRequest decisions have no tuple and the ring is bounded
+	for ix := 0; ix < 5; ix++ {
+		R.DebitRequest(newAddr("udp", "10.0.0.1:53"))
+	}
+	d = R.RecentDecisions(10)
+	if len(d) != 3 {
+		t.Fatal("Expected ring to be bounded to 3, got", len(d))
+	}
+	if d[0].Tuple != (ResponseTuple{}) || d[0].RTReason != RTNotReached {
+		t.Error("Unexpected DebitRequest decision", d[0])
+	}
+	if d = R.RecentDecisions(1); len(d) != 1 {
+		t.Error("RecentDecisions(1) returned", len(d))
+	}
+
+	if NewRRL(NewConfig()).RecentDecisions(5) != nil {
+		t.Error("Expected nil when not configured")
+	}
+}
+
+// The balance is that of the account actually debited, which for UDP transfers and mixed
+// case names differs from the tuple as supplied
+func TestRecentDecisionsBalance(t *testing.T) {
+	cfg := NewConfig()
+	cfg.SetValue("responses-per-second", "1")
+	cfg.SetValue("decision-log-size", "3")
+	cfg.SetNowFunc(func() time.Time { return time.Unix(1000, 0) })
+	R := NewRRL(cfg)
+
+	tuple := newTuple(1, typeAXFR, "Example.COM.", AllowanceAnswer)
+	R.Debit(newAddr("udp", "10.0.0.1:53"), tuple)
+	R.Debit(newAddr("udp", "10.0.0.1:53"), tuple)
+	d := R.RecentDecisions(1)
+	if len(d) != 1 || d[0].RTReason != RTRateLimit {
+		t.Fatal("Expected a rate limited transfer", d)
+	}
+	if d[0].Balance >= 0 {
+		t.Error("Expected the negative balance of the transfer account, not", d[0].Balance)
+	}
+}
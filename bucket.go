@@ -0,0 +1,90 @@
+package rrl
+
+import (
+	"sync"
+	"time"
+)
+
+// TokenBucket is a simple concurrency safe token bucket which uses the same "allowTime"
+// arithmetic as accounts. Tokens accrue at a fixed rate with a burst of up to one
+// second's worth of tokens, or one token at rates below one per second, and a new
+// TokenBucket starts with a full burst.
+//
+// The RRL uses TokenBucket to bound its own output during floods, such as Slips with
+// max-slips-per-second and exported Events with max-events-per-second. It is exported so
+// callers can bound their own RRL-adjacent logging in the same way. For example:
+//
+//	logLimit := rrl.NewTokenBucket(10)
+//	...
+//	if act != rrl.Send && logLimit.Allow() {
+//		log.Println("Rate limited", src, tuple)
+//	}
+type TokenBucket struct {
+	mu        sync.Mutex
+	interval  int64 // Nanoseconds per token. Zero means unlimited
+	burst     int64 // Nanoseconds of accrued tokens which can be taken at once
+	allowTime int64 // A token is available if now >= allowTime + interval
+	denied    uint64
+	clock     clock
+}
+
+// NewTokenBucket returns a TokenBucket which allows perSecond tokens per second. There is
+// no minimum rate: a perSecond below one allows one token every 1/perSecond seconds. A
+// perSecond of zero or less means the TokenBucket is unlimited.
+func NewTokenBucket(perSecond float64) *TokenBucket {
+	var interval int64
+	if perSecond > 0 {
+		interval = int64(second / perSecond)
+	}
+
+	return newTokenBucket(interval, newClock(time.Now))
+}
+
+// newTokenBucket returns a full TokenBucket with one token per interval which reads time
+// from c.
+func newTokenBucket(interval int64, c clock) *TokenBucket {
+	b := &TokenBucket{interval: interval, burst: second, clock: c}
+	if interval > second { // At least one token must be able to accrue
+		b.burst = interval
+	}
+	b.allowTime = c.now() - b.burst
+
+	return b
+}
+
+// Allow consumes a token and returns true if one is available.
+func (b *TokenBucket) Allow() bool {
+	if b.interval == 0 {
+		return true
+	}
+
+	return b.take(b.clock.now())
+}
+
+// Denied returns the number of calls to Allow which returned false. It is intended for
+// periodic "N messages suppressed" reporting.
+func (b *TokenBucket) Denied() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.denied
+}
+
+// take consumes a token and returns true if one is available at time now.
+func (b *TokenBucket) take(now int64) bool {
+	if b.interval == 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.allowTime < now-b.burst { // Burst is capped at one second or one token
+		b.allowTime = now - b.burst
+	}
+	if b.allowTime+b.interval > now {
+		b.denied++
+		return false
+	}
+	b.allowTime += b.interval
+
+	return true
+}
@@ -0,0 +1,76 @@
+package rrl
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	var now time.Time
+	b := newTokenBucket(second/4, newClock(func() time.Time { return now })) // 4 per second
+
+	allowed := 0
+	for ix := 0; ix < 10; ix++ {
+		if b.Allow() {
+			allowed++
+		}
+	}
+	if allowed != 4 || b.Denied() != 6 {
+		t.Error("A new bucket should allow a one second burst", allowed, b.Denied())
+	}
+
+	now = now.Add(time.Second / 2)
+	if !b.Allow() || !b.Allow() || b.Allow() {
+		t.Error("Two tokens should have accrued in half a second")
+	}
+
+	now = now.Add(time.Hour)
+	allowed = 0
+	for ix := 0; ix < 10; ix++ {
+		if b.Allow() {
+			allowed++
+		}
+	}
+	if allowed != 4 {
+		t.Error("Burst should be capped at one second", allowed)
+	}
+
+	// Below one token per second the burst is a single token
+	now = time.Time{}
+	s := newTokenBucket(2*second, newClock(func() time.Time { return now })) // 0.5 per second
+	if !s.Allow() || s.Allow() {
+		t.Error("A new slow bucket should allow a burst of one")
+	}
+	now = now.Add(time.Second)
+	if s.Allow() {
+		t.Error("A token should not accrue in one second")
+	}
+	now = now.Add(time.Second)
+	if !s.Allow() || s.Allow() {
+		t.Error("A token should accrue in two seconds")
+	}
+	now = now.Add(time.Hour)
+	if !s.Allow() || s.Allow() {
+		t.Error("Slow burst should be capped at one token")
+	}
+
+	u := NewTokenBucket(0)
+	for ix := 0; ix < 1000; ix++ {
+		if !u.Allow() {
+			t.Fatal("Unlimited bucket denied at", ix)
+		}
+	}
+	if u.Denied() != 0 {
+		t.Error("Unlimited bucket should never deny", u.Denied())
+	}
+
+	r := NewTokenBucket(2)
+	if !r.Allow() || !r.Allow() || r.Allow() {
+		t.Error("NewTokenBucket should start with a full burst of two")
+	}
+
+	h := NewTokenBucket(0.5)
+	if !h.Allow() || h.Allow() {
+		t.Error("NewTokenBucket below one per second should start with a burst of one")
+	}
+}
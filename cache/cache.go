@@ -1,12 +1,15 @@
 package cache
 
 import (
+	"crypto/rand"
+	"encoding/binary"
 	"errors"
 	"hash/fnv"
 	"sync"
 )
 
-// Hash returns the FNV hash of what.
+// Hash returns the FNV hash of what. Hash is no longer used for shard selection as FNV
+// values are predictable, see SetHashKey.
 func Hash(what []byte) uint64 {
 	h := fnv.New64()
 	h.Write(what)
@@ -16,6 +19,7 @@ func Hash(what []byte) uint64 {
 // Cache is cache with a customizable eviction policy.
 type Cache struct {
 	shards [numShards]*shard
+	k0, k1 uint64 // SipHash key for shard selection
 }
 
 type EvictFn func(interface{}) bool
@@ -35,12 +39,12 @@ type shard struct {
 
 // New returns a new cache.
 func New(size int) *Cache {
-	ssize := size / numShards
-	if ssize < 4 {
-		ssize = 4
-	}
+	ssize := shardSize(size)
 
 	c := &Cache{}
+	var key [16]byte
+	rand.Read(key[:]) // A per-instance random key unless replaced by SetHashKey
+	c.SetHashKey(key)
 
 	// Initialize all the shards
 	for i := 0; i < numShards; i++ {
@@ -55,27 +59,79 @@ func (c *Cache) SetEvict(e EvictFn) {
 	}
 }
 
-func keyShard(key string) uint64 {
-	return Hash([]byte(key)) & (numShards - 1)
+// SetHashKey replaces the per-instance random key used to select the shard of each
+// element. Shard selection is keyed so that attackers cannot craft keys which all
+// collide into a single shard and thus cause it to fill.
+// SetHashKey must be called before any elements are added.
+func (c *Cache) SetHashKey(key [16]byte) {
+	c.k0 = binary.LittleEndian.Uint64(key[:8])
+	c.k1 = binary.LittleEndian.Uint64(key[8:])
+}
+
+func (c *Cache) keyShard(key string) uint64 {
+	return sipHash(c.k0, c.k1, key) & (numShards - 1)
 }
 
 // Add adds a new element to the cache. If the element already exists it is overwritten.
 func (c *Cache) Add(key string, el interface{}) error {
-	return c.shards[keyShard(key)].Add(key, el)
+	return c.shards[c.keyShard(key)].Add(key, el)
 }
 
 func (c *Cache) UpdateAdd(key string, update func(interface{}) interface{}, add func() interface{}) interface{} {
-	return c.shards[keyShard(key)].UpdateAdd(key, update, add)
+	return c.shards[c.keyShard(key)].UpdateAdd(key, update, add)
 }
 
 // Get looks up element index under key.
 func (c *Cache) Get(key string) (interface{}, bool) {
-	return c.shards[keyShard(key)].Get(key)
+	return c.shards[c.keyShard(key)].Get(key)
+}
+
+// View executes the function `view` on the element indexed under key while holding the
+// shard read lock. View returns the result of `view` and true if key exists, otherwise
+// nil and false.
+func (c *Cache) View(key string, view func(interface{}) interface{}) (interface{}, bool) {
+	return c.shards[c.keyShard(key)].View(key, view)
 }
 
 // Remove removes the element indexed with key.
 func (c *Cache) Remove(key string) {
-	c.shards[keyShard(key)].Remove(key)
+	c.shards[c.keyShard(key)].Remove(key)
+}
+
+// Walk calls fn for each element in the cache until fn returns false. Each shard is read
+// locked in turn while fn is called for the elements of that shard so fn must not call
+// any other Cache methods. As with Len, elements may be added or removed in other shards
+// while the walk is in progress.
+func (c *Cache) Walk(fn func(key string, el interface{}) bool) {
+	for _, s := range c.shards {
+		if !s.Walk(fn) {
+			return
+		}
+	}
+}
+
+// Shards returns the number of shards in the cache for use with WalkShard.
+func (c *Cache) Shards() int {
+	return numShards
+}
+
+// WalkShard is like Walk except that it only visits the elements of shard ix. It allows
+// callers to do work between shards without holding any shard lock.
+func (c *Cache) WalkShard(ix int, fn func(key string, el interface{}) bool) bool {
+	return c.shards[ix].Walk(fn)
+}
+
+// ShardCapacity returns the number of elements each shard can hold.
+func (c *Cache) ShardCapacity() int {
+	return c.shards[0].size
+}
+
+// ShardElement calls fn with the k'th element of shard ix, in map iteration order, under
+// the read lock and returns true. If the shard holds k or fewer elements, fn is not
+// called and ShardElement returns false. Together with ShardCapacity it allows callers
+// to sample the cache uniformly without walking it.
+func (c *Cache) ShardElement(ix, k int, fn func(key string, el interface{})) bool {
+	return c.shards[ix].element(k, fn)
 }
 
 // Len returns an estimate number of elements in the cache.
@@ -89,6 +145,23 @@ func (c *Cache) Len() int {
 	return l
 }
 
+// shardSize returns the size of each shard of a cache of size. Every shard holds at least
+// four elements.
+func shardSize(size int) int {
+	ssize := size / numShards
+	if ssize < 4 {
+		ssize = 4
+	}
+	return ssize
+}
+
+// Capacity returns the number of elements a cache created with New(size) can actually
+// hold. It differs from size when size is not a multiple of the number of shards or is
+// too small to give every shard at least four elements.
+func Capacity(size int) int {
+	return shardSize(size) * numShards
+}
+
 // newShard returns a new shard with size.
 func newShard(size int) *shard {
 	return &shard{
@@ -106,7 +179,7 @@ func (s *shard) Add(key string, el interface{}) error {
 		return errors.New("failed to add item, shard full")
 	}
 
-	s.items[key] = &el
+	s.items[key] = el
 	return nil
 }
 
@@ -145,6 +218,17 @@ func (s *shard) Get(key string) (interface{}, bool) {
 	return nil, false
 }
 
+// View executes the function `view` on the element indexed under key under the read lock.
+func (s *shard) View(key string, view func(interface{}) interface{}) (interface{}, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	el, found := s.items[key]
+	if !found {
+		return nil, false
+	}
+	return view(el), true
+}
+
 // UpdateAdd executes the function `update` on the element indexed under key.
 // If key does not exist, then it is added, with a value equal to the result of function `add`.
 func (s *shard) UpdateAdd(key string, update func(interface{}) interface{}, add func() interface{}) interface{} {
@@ -166,6 +250,37 @@ func (s *shard) UpdateAdd(key string, update func(interface{}) interface{}, add
 	return nil
 }
 
+// Walk calls fn for each element in the shard under the read lock until fn returns
+// false, in which case Walk also returns false.
+func (s *shard) Walk(fn func(key string, el interface{}) bool) bool {
+	s.RLock()
+	defer s.RUnlock()
+	for key, el := range s.items {
+		if !fn(key, el) {
+			return false
+		}
+	}
+	return true
+}
+
+// element calls fn with the k'th element of the shard under the read lock and returns
+// true, or returns false if there is no k'th element.
+func (s *shard) element(k int, fn func(key string, el interface{})) bool {
+	s.RLock()
+	defer s.RUnlock()
+	if k < 0 || k >= len(s.items) {
+		return false
+	}
+	for key, el := range s.items {
+		if k == 0 {
+			fn(key, el)
+			return true
+		}
+		k--
+	}
+	return false
+}
+
 // Len returns the current length of the cache.
 func (s *shard) Len() int {
 	s.RLock()
@@ -17,6 +17,16 @@ func TestCacheAddGetRemove(t *testing.T) {
 	}
 }
 
+// Add stores the element itself so Get returns the same value as after UpdateAdd
+func TestCacheAddGetValue(t *testing.T) {
+	c := New(4)
+	c.Add("1", 1)
+
+	if el, found := c.Get("1"); !found || el != 1 {
+		t.Fatalf("expected to get the inserted value 1, got %v", el)
+	}
+}
+
 func TestCacheUpdateAdd(t *testing.T) {
 	c := New(4)
 
@@ -90,3 +100,132 @@ func BenchmarkCache(b *testing.B) {
 		c.Get("1")
 	}
 }
+
+func TestCacheView(t *testing.T) {
+	c := New(4)
+	i := 7
+	c.Add("a", &i)
+	c.UpdateAdd("b", nil, func() interface{} { return &i })
+
+	_, found := c.View("x", func(el interface{}) interface{} { return el })
+	if found {
+		t.Fatal("View should not find a missing key")
+	}
+	res, found := c.View("b", func(el interface{}) interface{} { return *(el.(*int)) + 1 })
+	if !found {
+		t.Fatal("View failed to find inserted record")
+	}
+	if res.(int) != 8 {
+		t.Fatalf("expected View to return 8, got %v", res)
+	}
+}
+
+func TestCacheWalk(t *testing.T) {
+	c := New(4096) // Ample room so no shard evicts
+	for i := 0; i < 100; i++ {
+		c.UpdateAdd(string(rune('a'+i)), nil, func() interface{} { return 1 })
+	}
+
+	count := 0
+	c.Walk(func(key string, el interface{}) bool {
+		count += el.(int)
+		return true
+	})
+	if count != 100 {
+		t.Fatalf("expected Walk to visit 100 elements, got %d", count)
+	}
+
+	count = 0
+	c.Walk(func(key string, el interface{}) bool {
+		count++
+		return count < 10
+	})
+	if count != 10 {
+		t.Fatalf("expected Walk to stop after 10 elements, got %d", count)
+	}
+}
+
+func TestCacheWalkShard(t *testing.T) {
+	c := New(4096) // Ample room so no shard evicts
+	for i := 0; i < 100; i++ {
+		c.UpdateAdd(string(rune('a'+i)), nil, func() interface{} { return 1 })
+	}
+
+	count := 0
+	for ix := 0; ix < c.Shards(); ix++ {
+		c.WalkShard(ix, func(key string, el interface{}) bool {
+			if c.keyShard(key) != uint64(ix) {
+				t.Errorf("WalkShard(%d) visited %s from shard %d", ix, key, c.keyShard(key))
+			}
+			count += el.(int)
+			return true
+		})
+	}
+	if count != 100 {
+		t.Fatalf("expected WalkShard to visit 100 elements, got %d", count)
+	}
+}
+
+func TestCacheShardElement(t *testing.T) {
+	c := New(4096)
+	if c.ShardCapacity() != 16 {
+		t.Error("Expected ShardCapacity of 16, not", c.ShardCapacity())
+	}
+	for i := 0; i < 100; i++ {
+		c.UpdateAdd(string(rune('a'+i)), nil, func() interface{} { return i })
+	}
+
+	found := 0
+	for ix := 0; ix < c.Shards(); ix++ {
+		for k := 0; k < c.ShardCapacity(); k++ {
+			c.ShardElement(ix, k, func(key string, el interface{}) {
+				if c.keyShard(key) != uint64(ix) {
+					t.Errorf("ShardElement(%d) returned %s from shard %d", ix, key, c.keyShard(key))
+				}
+				found++
+			})
+		}
+		if c.ShardElement(ix, c.ShardCapacity(), func(string, interface{}) {}) {
+			t.Error("ShardElement should return false beyond the shard length")
+		}
+	}
+	if found != 100 {
+		t.Fatalf("expected ShardElement to succeed for 100 positions, got %d", found)
+	}
+}
+
+func TestCacheHashKey(t *testing.T) {
+	c1 := New(1000)
+	c2 := New(1000)
+	differ := false
+	for ix := 0; ix < 100 && !differ; ix++ {
+		key := string(rune('a' + ix%26))
+		differ = c1.keyShard(key+key) != c2.keyShard(key+key)
+	}
+	if !differ {
+		t.Error("Random keys should produce different shard selections")
+	}
+
+	var key [16]byte
+	copy(key[:], "0123456789abcdef")
+	c1.SetHashKey(key)
+	c2.SetHashKey(key)
+	for _, k := range []string{"", "a", "10.0.0.0/0/1/example.com."} {
+		if c1.keyShard(k) != c2.keyShard(k) {
+			t.Error("Same key should produce the same shard for", k)
+		}
+	}
+}
+
+func TestCacheCapacity(t *testing.T) {
+	for _, tc := range []struct{ size, capacity int }{
+		{0, 4 * numShards},
+		{1, 4 * numShards},
+		{100000, 390 * numShards},
+		{4096, 4096},
+	} {
+		if got := Capacity(tc.size); got != tc.capacity {
+			t.Error("Capacity of", tc.size, "expected", tc.capacity, "got", got)
+		}
+	}
+}
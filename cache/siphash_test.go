@@ -0,0 +1,39 @@
+package cache
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// Test vectors are from Appendix A of the SipHash paper which uses the key 00..0f and
+// messages of the form 00..(n-1).
+func TestSipHash(t *testing.T) {
+	var key [16]byte
+	for ix := range key {
+		key[ix] = byte(ix)
+	}
+	k0 := binary.LittleEndian.Uint64(key[:8])
+	k1 := binary.LittleEndian.Uint64(key[8:])
+
+	msg := make([]byte, 64)
+	for ix := range msg {
+		msg[ix] = byte(ix)
+	}
+
+	testCases := []struct {
+		length int
+		exp    uint64
+	}{
+		{0, 0x726fdb47dd0e0e31},
+		{1, 0x74f839c593dc67fd},
+		{7, 0xab0200f58b01d137},
+		{8, 0x93f5f5799a932462},
+		{15, 0xa129ca6149be45e5},
+		{63, 0x958a324ceb064572},
+	}
+	for _, tc := range testCases {
+		if got := sipHash(k0, k1, string(msg[:tc.length])); got != tc.exp {
+			t.Errorf("sipHash of %d bytes expected %x got %x", tc.length, tc.exp, got)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package rrl
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// calibrationAccount tracks the observed request rate of a single account when
+// calibration is enabled. Rates are measured as the number of Debits in each whole
+// second, with the peak retained for reporting.
+type calibrationAccount struct {
+	second int64             // The second in which count accumulated
+	count  int64             // Number of Debits seen in second
+	peak   int64             // Highest count seen for any second
+	ac     AllowanceCategory // AllowanceLast identifies a requests account
+}
+
+// CalibrationPercentiles summarizes the peak per-second rates observed across all
+// accounts of one type.
+type CalibrationPercentiles struct {
+	Accounts int   // Number of accounts which contributed to the percentiles
+	P50      int64 // Median peak rate
+	P90      int64
+	P99      int64
+	P999     int64 // 99.9th percentile peak rate
+	Max      int64
+}
+
+// CalibrationReport is returned by [RRL.Calibration]. It contains the percentiles of
+// peak per-second rates observed for requests and for each [AllowanceCategory].
+type CalibrationReport struct {
+	Requests  CalibrationPercentiles
+	Responses [AllowanceLast]CalibrationPercentiles
+}
+
+// initCalibration creates the calibration table if calibration is configured. Idle
+// calibration accounts are evicted once they have not been seen for a window.
+func (rrl *RRL) initCalibration() {
+	if !rrl.cfg.calibrate {
+		return
+	}
+	rrl.calibration = rrl.newCache(rrl.cfg.maxTableSize)
+	rrl.calibration.SetEvict(func(el interface{}) bool {
+		ca, ok := (el).(*calibrationAccount)
+		if !ok {
+			return true
+		}
+		return (rrl.now()/second-ca.second)*second >= rrl.cfg.window
+	})
+}
+
+// calibrate records a Debit against the calibration account for the token.
+func (rrl *RRL) calibrate(t string, ac AllowanceCategory) {
+	if rrl.calibration == nil {
+		return
+	}
+	now := rrl.now() / second
+	rrl.calibration.UpdateAdd(t,
+		func(el interface{}) interface{} {
+			ca := (el).(*calibrationAccount)
+			if ca.second != now {
+				ca.second = now
+				ca.count = 0
+			}
+			ca.count++
+			if ca.count > ca.peak {
+				ca.peak = ca.count
+			}
+			return nil
+		},
+		func() interface{} {
+			return &calibrationAccount{second: now, count: 1, peak: 1, ac: ac}
+		})
+}
+
+// Calibration returns a report of the peak per-second rates observed across all accounts
+// since the RRL was created. Operators can use this report to set allowances at, e.g.,
+// the 99.9th percentile of normal traffic.
+//
+// Calibration is only available when "calibrate" is configured, otherwise a zero
+// report is returned.
+// Calibration walks the entire calibration table so it should be called sparingly.
+func (rrl *RRL) Calibration() (report CalibrationReport) {
+	if rrl.calibration == nil {
+		return
+	}
+
+	var requests []int64
+	var responses [AllowanceLast][]int64
+	rrl.calibration.Walk(func(key string, el interface{}) bool {
+		ca, ok := (el).(*calibrationAccount)
+		if !ok {
+			return true
+		}
+		if ca.ac < AllowanceLast {
+			responses[ca.ac] = append(responses[ca.ac], ca.peak)
+		} else {
+			requests = append(requests, ca.peak)
+		}
+		return true
+	})
+
+	report.Requests = newCalibrationPercentiles(requests)
+	for ac, peaks := range responses {
+		report.Responses[ac] = newCalibrationPercentiles(peaks)
+	}
+
+	return
+}
+
+// newCalibrationPercentiles sorts the peaks and extracts the percentiles.
+func newCalibrationPercentiles(peaks []int64) (cp CalibrationPercentiles) {
+	cp.Accounts = len(peaks)
+	if cp.Accounts == 0 {
+		return
+	}
+	sort.Slice(peaks, func(i, j int) bool { return peaks[i] < peaks[j] })
+	pick := func(p float64) int64 {
+		ix := int(p*float64(len(peaks))+0.999999) - 1 // ceil(p*n)-1
+		if ix < 0 {
+			ix = 0
+		}
+		return peaks[ix]
+	}
+	cp.P50 = pick(0.50)
+	cp.P90 = pick(0.90)
+	cp.P99 = pick(0.99)
+	cp.P999 = pick(0.999)
+	cp.Max = peaks[len(peaks)-1]
+
+	return
+}
+
+// String returns a single line of text containing the percentiles.
+func (cp *CalibrationPercentiles) String() string {
+	return fmt.Sprintf("accounts=%d p50=%d p90=%d p99=%d p99.9=%d max=%d",
+		cp.Accounts, cp.P50, cp.P90, cp.P99, cp.P999, cp.Max)
+}
+
+// String returns a multi-line report with one line per configuration keyword.
+func (cr *CalibrationReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "requests-per-second %s\n", cr.Requests.String())
+	for ac := AllowanceCategory(0); ac < AllowanceLast; ac++ {
+		fmt.Fprintf(&b, "%s %s\n", ac.keyword(), cr.Responses[ac].String())
+	}
+
+	return b.String()
+}
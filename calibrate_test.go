@@ -0,0 +1,58 @@
+package rrl
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCalibration(t *testing.T) {
+	cfg := NewConfig()
+	if cfg.IsActive() {
+		t.Fatal("Default config should not be active")
+	}
+	cfg.SetValue("calibrate", "true")
+	if !cfg.IsActive() {
+		t.Fatal("Calibrating config should be active")
+	}
+	clock := time.Time{}
+	cfg.SetNowFunc(func() time.Time {
+		return clock
+	})
+	R := NewRRL(cfg)
+
+	// 100 Client Networks each send 1 to 100 queries per second for the same name
+	for ix := 1; ix <= 100; ix++ {
+		src := newAddr("udp", fmt.Sprintf("10.0.%d.1:53", ix))
+		for q := 0; q < ix; q++ {
+			act, _, rtr := R.Debit(src, newTuple(1, 1, "example.com.", AllowanceAnswer))
+			if act != Send || rtr != RTNotConfigured {
+				t.Fatal("Calibration should not limit", act, rtr)
+			}
+		}
+	}
+	clock = clock.Add(time.Second) // A new second should not affect peaks
+	R.Debit(newAddr("udp", "10.0.100.1:53"), newTuple(1, 1, "example.com.", AllowanceAnswer))
+
+	report := R.Calibration()
+	exp := CalibrationPercentiles{Accounts: 100, P50: 50, P90: 90, P99: 99, P999: 100, Max: 100}
+	if report.Responses[AllowanceAnswer] != exp {
+		t.Error("Answer percentiles expected", exp.String(), "got", report.Responses[AllowanceAnswer].String())
+	}
+	if report.Requests != exp {
+		t.Error("Request percentiles expected", exp.String(), "got", report.Requests.String())
+	}
+	if report.Responses[AllowanceNXDomain].Accounts != 0 {
+		t.Error("Expected no NXDomain accounts, got", report.Responses[AllowanceNXDomain].String())
+	}
+	if s := report.String(); !strings.Contains(s, "responses-per-second accounts=100 p50=50") {
+		t.Error("Report missing responses-per-second line", s)
+	}
+
+	R = NewRRL(NewConfig())
+	report = R.Calibration()
+	if report.Requests.Accounts != 0 {
+		t.Error("Calibration should be empty when not configured", report.String())
+	}
+}
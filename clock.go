@@ -0,0 +1,60 @@
+package rrl
+
+import (
+	"time"
+)
+
+// clock is the single time source of an RRL. All time readings, whether for balance
+// arithmetic or for timestamps in Events, DecisionRecords and Snapshots, are derived from
+// the nowFunc supplied via [Config.SetNowFunc], so that function remains the one place
+// where time is injected regardless of platform.
+//
+// The resolution contract with nowFunc is deliberately weak so that the same logic works
+// on Linux, macOS, Windows and the wasm ports used by test tooling, where time.Now is
+// respectively nanosecond, microsecond, sub-millisecond and as coarse as a millisecond or
+// more:
+//
+//   - nowFunc must be safe for concurrent use
+//   - successive readings must not go backwards except as a deliberate step
+//   - readings may repeat; all accounting is by accumulated allowTime rather than elapsed
+//     time between calls, so a coarse clock delays recovery by at most one tick and never
+//     loses or invents credit
+//   - readings need not carry a monotonic component; if they do, it is preferred
+//
+// Nothing in the RRL depends on a resolution finer than [clockResolution].
+type clock struct {
+	fn         func() time.Time
+	epoch      time.Time // Reference point of the monotonic timebase used by now()
+	epochNanos int64     // Wall clock nanoseconds of epoch
+}
+
+// clockResolution is the coarsest clock granularity the RRL is designed for. It is
+// the historical default Windows timer tick, which is also larger than the clamped
+// timers of browser-hosted wasm.
+const clockResolution = 16 * time.Millisecond
+
+// newClock returns a clock which reads time from fn.
+func newClock(fn func() time.Time) clock {
+	c := clock{fn: fn, epoch: fn()}
+	c.epochNanos = c.epoch.UnixNano()
+
+	return c
+}
+
+// now returns the current time in nanoseconds for all balance arithmetic.
+//
+// The timebase is the wall clock at the time the clock was created advanced by the
+// monotonic clock reading of fn, so wall clock steps, such as NTP corrections and VM
+// migrations, have no effect on balances. Without this, a backwards step inflates
+// negative balances and can penalize clients for the duration of the step.
+//
+// Times returned by a test nowFunc typically lack a monotonic reading in which case
+// time.Time.Sub falls back to the wall clock and now behaves exactly as before.
+func (c *clock) now() int64 {
+	return c.epochNanos + int64(c.fn().Sub(c.epoch))
+}
+
+// wall returns the current time for timestamps presented to callers.
+func (c *clock) wall() time.Time {
+	return c.fn()
+}
@@ -0,0 +1,225 @@
+/*
+bindrecord records the rate limiting behaviour of a running named so that it can be
+replayed against the rrl package by the scenario harness in arm_test.go.
+
+bindrecord reads a scenario in the format described in testdata/arm/README.md, sends
+each query to named at its scenario time from its scenario source address and writes the
+scenario back out with the action named actually took: "send" for a response, "slip" for
+a truncated response without answers and "drop" if no response arrives within -timeout.
+The action fields of the input are ignored.
+
+The config lines of the scenario are copied to the output but are not applied to named,
+so they must match the rate-limit clause of the named.conf in use. As named only sees
+the source addresses of queries, every source address must be a local address such as
+one within 127.0.0.0/8.
+
+bindrecord is a separate module so that the rrl package itself has no dependencies.
+
+Usage:
+
+	bindrecord [options] scenario
+
+e.g.:
+
+	bindrecord -server 127.0.0.1:5300 -version 9.18.33 responses.scenario
+*/
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+type options struct {
+	server  string
+	version string
+	timeout time.Duration
+}
+
+// query is a query line of the scenario.
+type query struct {
+	line     int
+	ms       int64
+	src      string
+	qClass   uint16
+	qType    uint16
+	name     string
+	category string
+	action   string // As recorded
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	var opts options
+	fs := flag.NewFlagSet("bindrecord", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&opts.server, "server", "127.0.0.1:5300", "Address of named")
+	fs.StringVar(&opts.version, "version", "", "Version of named, as reported by named -v")
+	fs.DurationVar(&opts.timeout, "timeout", 200*time.Millisecond, "Time to wait for each response")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: bindrecord [options] scenario")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 || len(opts.version) == 0 || opts.timeout <= 0 {
+		fmt.Fprintln(stderr, "Error: a scenario, -version and a positive -timeout are required")
+		return 2
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 2
+	}
+	config, queries, err := parse(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %s:%s\n", fs.Arg(0), err)
+		return 2
+	}
+
+	if err := record(&opts, queries); err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 1
+	}
+
+	fmt.Fprintln(stdout, "# Recorded by bindrecord from", fs.Arg(0))
+	fmt.Fprintln(stdout, "# named-version", opts.version)
+	for _, c := range config {
+		fmt.Fprintln(stdout, c)
+	}
+	fmt.Fprintln(stdout)
+	for _, q := range queries {
+		fmt.Fprintln(stdout, q.ms, q.src, q.qClass, q.qType, q.name, q.category, q.action)
+	}
+
+	return 0
+}
+
+// parse returns the config lines and queries of the scenario.
+func parse(r io.Reader) (config []string, queries []*query, err error) {
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		if ix := strings.Index(line, "#"); ix >= 0 {
+			line = line[:ix]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "config" {
+			if len(fields) != 3 || len(queries) > 0 {
+				return nil, nil, fmt.Errorf("%d config must precede queries and have one value", lineNo)
+			}
+			config = append(config, strings.Join(fields, " "))
+			continue
+		}
+		if len(fields) != 7 {
+			return nil, nil, fmt.Errorf("%d expected 7 fields, not %d", lineNo, len(fields))
+		}
+		q := &query{line: lineNo, src: fields[1], name: dns.Fqdn(fields[4]), category: fields[5]}
+		ms, err1 := strconv.ParseInt(fields[0], 10, 64)
+		qClass, err2 := strconv.ParseUint(fields[2], 10, 16)
+		qType, err3 := strconv.ParseUint(fields[3], 10, 16)
+		if err1 != nil || err2 != nil || err3 != nil || net.ParseIP(q.src) == nil {
+			return nil, nil, fmt.Errorf("%d malformed query line", lineNo)
+		}
+		if len(queries) > 0 && ms < queries[len(queries)-1].ms {
+			return nil, nil, fmt.Errorf("%d queries must be in time order", lineNo)
+		}
+		q.ms, q.qClass, q.qType = ms, uint16(qClass), uint16(qType)
+		queries = append(queries, q)
+	}
+
+	return config, queries, scanner.Err()
+}
+
+// record sends each query at its scenario time and sets its action from the response of
+// named. Queries are sent in scenario order while their responses are awaited
+// concurrently.
+func record(opts *options, queries []*query) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(queries))
+	start := time.Now()
+	for ix, q := range queries {
+		time.Sleep(time.Until(start.Add(time.Duration(q.ms) * time.Millisecond)))
+		conn, err := net.DialUDP("udp", &net.UDPAddr{IP: net.ParseIP(q.src)}, udpAddr(opts.server))
+		if err != nil {
+			return fmt.Errorf("line %d: %w", q.line, err)
+		}
+		co := &dns.Conn{Conn: conn}
+		m := new(dns.Msg)
+		m.SetQuestion(q.name, q.qType)
+		m.Question[0].Qclass = q.qClass
+		if err := co.WriteMsg(m); err != nil {
+			conn.Close()
+			return fmt.Errorf("line %d: %w", q.line, err)
+		}
+		wg.Add(1)
+		go func(ix int, q *query) {
+			defer wg.Done()
+			defer co.Close()
+			co.SetReadDeadline(time.Now().Add(opts.timeout))
+			resp, err := co.ReadMsg()
+			var ne net.Error
+			switch {
+			case errors.As(err, &ne) && ne.Timeout():
+				q.action = "drop"
+			case err != nil:
+				errs[ix] = fmt.Errorf("line %d: %w", q.line, err)
+			case resp.Truncated && len(resp.Answer) == 0:
+				q.action = "slip"
+			default:
+				q.action = "send"
+				if c := category(resp); c != q.category {
+					errs[ix] = fmt.Errorf("line %d: expected a %s response from named, not %s",
+						q.line, q.category, c)
+				}
+			}
+		}(ix, q)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// udpAddr returns the UDP address of server, or nil if it cannot be resolved in which
+// case DialUDP reports the error.
+func udpAddr(server string) *net.UDPAddr {
+	addr, _ := net.ResolveUDPAddr("udp", server)
+	return addr
+}
+
+// category returns the scenario category of the response, which is how named classifies
+// the response for rate limiting.
+func category(resp *dns.Msg) string {
+	switch {
+	case resp.Rcode == dns.RcodeNameError:
+		return "nxdomain"
+	case resp.Rcode != dns.RcodeSuccess:
+		return "error"
+	case len(resp.Answer) > 0:
+		return "answer"
+	case !resp.Authoritative && len(resp.Ns) > 0:
+		return "referral"
+	}
+
+	return "nodata"
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+// fakeNamed answers www.example.com, slips slip.example.com, drops drop.example.com and
+// returns NXDOMAIN for all other names.
+func fakeNamed(t *testing.T) string {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("Cannot listen on loopback", err)
+	}
+	server := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetReply(req)
+		resp.Authoritative = true
+		switch req.Question[0].Name {
+		case "drop.example.com.":
+			return
+		case "slip.example.com.":
+			resp.Truncated = true
+		case "www.example.com.":
+			rr, _ := dns.NewRR("www.example.com. 300 IN A 192.0.2.1")
+			resp.Answer = append(resp.Answer, rr)
+		default:
+			resp.Rcode = dns.RcodeNameError
+		}
+		w.WriteMsg(resp)
+	})}
+	go server.ActivateAndServe()
+	t.Cleanup(func() { server.Shutdown() })
+
+	return pc.LocalAddr().String()
+}
+
+func TestRun(t *testing.T) {
+	server := fakeNamed(t)
+	scenario := filepath.Join(t.TempDir(), "test.scenario")
+	os.WriteFile(scenario, []byte(`# Actions are ignored
+config responses-per-second 5
+config slip 2
+
+0 127.0.0.1 1 1 www.example.com. answer drop
+0 127.0.0.1 1 1 slip.example.com. answer ?
+10 127.0.0.2 1 1 drop.example.com. answer ?
+20 127.0.1.1 1 28 nope.example.com nxdomain ?
+`), 0644)
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"-server", server, "-version", "9.99.0", "-timeout", "100ms", scenario}
+	if rc := run(args, &stdout, &stderr); rc != 0 {
+		t.Fatal("Unexpected exit code", rc, stderr.String())
+	}
+	exp := `config responses-per-second 5
+config slip 2
+
+0 127.0.0.1 1 1 www.example.com. answer send
+0 127.0.0.1 1 1 slip.example.com. answer slip
+10 127.0.0.2 1 1 drop.example.com. answer drop
+20 127.0.1.1 1 28 nope.example.com. nxdomain send
+`
+	if got := stdout.String(); !strings.Contains(got, "# named-version 9.99.0\n") || !strings.HasSuffix(got, exp) {
+		t.Error("Unexpected recording", got)
+	}
+
+	// A response of a different category means the scenario does not match the zone
+	os.WriteFile(scenario, []byte("0 127.0.0.1 1 1 www.example.com. nodata send\n"), 0644)
+	stderr.Reset()
+	if rc := run(args, &stdout, &stderr); rc != 1 || !strings.Contains(stderr.String(), "not answer") {
+		t.Error("Expected a category mismatch", rc, stderr.String())
+	}
+
+	for _, args := range [][]string{{scenario}, {"-version", "9", "a", "b"}, {"-bogus"}} {
+		if rc := run(args, &stdout, &stderr); rc != 2 {
+			t.Error("Expected exit code 2 for", args, "got", rc)
+		}
+	}
+}
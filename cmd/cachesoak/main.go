@@ -0,0 +1,359 @@
+/*
+cachesoak hammers a cache.Cache with concurrent UpdateAdd, Get, Remove, eviction and
+walks for a long period while continuously checking its invariants. It is intended to give
+maintainers and users confidence in cache changes before deployment, so it is typically
+run for hours rather than as part of the regular tests.
+
+The invariants checked are:
+
+  - no lost updates: a set of protected keys, which the eviction function always
+    refuses, are incremented by all goroutines and their counts must match the number of
+    increments made
+  - elements refused by the eviction function are never evicted
+  - every element is stored under its own key
+  - Len never exceeds the capacity of the cache and, once quiescent, matches the number
+    of elements visited by Walk
+  - no deadlocks: if no goroutine makes progress within -stall, all goroutine stacks
+    are written to stderr and cachesoak exits
+
+Usage:
+
+	cachesoak [options]
+
+e.g.:
+
+	cachesoak -duration 4h -goroutines 32 -size 100000 -keys 1000000
+
+cachesoak exits with 0 if no invariant was violated, 1 if any were and 2 for usage
+errors.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/markdingo/rrl/cache"
+)
+
+type options struct {
+	goroutines int
+	duration   time.Duration
+	size       int // Cache size passed to cache.New
+	keys       int // Distinct unprotected keys
+	protected  int // Distinct protected keys
+	report     time.Duration
+	stall      time.Duration
+}
+
+// element is the value stored in the cache. count is only modified by UpdateAdd and thus
+// always under the shard lock.
+type element struct {
+	key       string
+	protected bool
+	count     int64
+}
+
+// soak holds the state shared by all goroutines.
+type soak struct {
+	opts  *options
+	c     *cache.Cache
+	pkeys []string
+
+	increments []atomic.Int64 // Successful increments of each protected key
+	ops        atomic.Uint64  // Total operations, also used to detect stalls
+	walks      atomic.Uint64
+	evictions  atomic.Uint64
+	full       atomic.Uint64 // UpdateAdds which failed as the shard was full
+
+	mu         sync.Mutex
+	violations []string
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	var opts options
+	fs := flag.NewFlagSet("cachesoak", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.IntVar(&opts.goroutines, "goroutines", runtime.GOMAXPROCS(0), "Number of concurrent goroutines updating the cache")
+	fs.DurationVar(&opts.duration, "duration", time.Hour, "Duration of the soak")
+	fs.IntVar(&opts.size, "size", 10000, "Size of the cache")
+	fs.IntVar(&opts.keys, "keys", 100000, "Number of distinct evictable keys")
+	fs.IntVar(&opts.protected, "protected", 64, "Number of distinct protected keys checked for lost updates")
+	fs.DurationVar(&opts.report, "report", time.Minute, "Interval between progress reports")
+	fs.DurationVar(&opts.stall, "stall", 30*time.Second, "Interval without progress which is deemed a deadlock")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: cachesoak [options]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintln(stderr, "Error: unexpected arguments", fs.Args())
+		return 2
+	}
+	if opts.goroutines < 1 || opts.size < 1 || opts.keys < 1 || opts.protected < 0 ||
+		opts.report <= 0 || opts.stall <= 0 {
+		fmt.Fprintln(stderr, "Error: -goroutines, -size, -keys, -report and -stall must be positive")
+		return 2
+	}
+	capacity := cache.Capacity(opts.size)
+	if opts.protected > capacity/4 {
+		fmt.Fprintf(stderr, "Error: -protected must be no more than a quarter of the cache capacity of %d\n",
+			capacity)
+		return 2
+	}
+
+	s := newSoak(&opts)
+	if s == nil {
+		fmt.Fprintln(stderr, "Error: protected keys could not be added. Increase -size")
+		return 2
+	}
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	start := time.Now()
+	for ix := 0; ix < opts.goroutines; ix++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			s.hammer(rand.New(rand.NewSource(seed)), stop)
+		}(int64(ix))
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.walk(stop)
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	if !s.monitor(stdout, stderr, start, stop, done) {
+		return 1 // Deadlocked goroutines cannot be waited for
+	}
+
+	s.quiescent()
+	s.report(stdout, time.Since(start))
+	for _, v := range s.violations {
+		fmt.Fprintln(stderr, "Violation:", v)
+	}
+	if len(s.violations) > 0 {
+		return 1
+	}
+
+	return 0
+}
+
+// newSoak creates the cache and its protected keys. It returns nil if the protected keys
+// could not all be added.
+func newSoak(opts *options) *soak {
+	s := &soak{opts: opts, c: cache.New(opts.size), increments: make([]atomic.Int64, opts.protected)}
+	s.c.SetEvict(s.evictable)
+	for ix := 0; ix < opts.protected; ix++ {
+		key := fmt.Sprintf("protected-%d", ix)
+		if s.c.Add(key, &element{key: key, protected: true}) != nil {
+			return nil
+		}
+		s.pkeys = append(s.pkeys, key)
+	}
+
+	return s
+}
+
+// evictable is the eviction function of the cache. Unprotected elements are always
+// evictable.
+func (s *soak) evictable(el interface{}) bool {
+	if el.(*element).protected {
+		return false
+	}
+	s.evictions.Add(1)
+
+	return true
+}
+
+// violation records an invariant violation.
+func (s *soak) violation(format string, args ...interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.violations) < 100 { // Enough to diagnose without drowning the report
+		s.violations = append(s.violations, fmt.Sprintf(format, args...))
+	}
+}
+
+// hammer applies a random mix of operations to the cache until stop is closed.
+func (s *soak) hammer(rng *rand.Rand, stop chan struct{}) {
+	increment := func(el interface{}) interface{} {
+		el.(*element).count++
+		return nil
+	}
+	for ix := 0; ; ix++ {
+		if ix%1024 == 0 {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+		}
+		s.ops.Add(1)
+		op := rng.Intn(100)
+		if op < 20 && len(s.pkeys) > 0 {
+			px := rng.Intn(len(s.pkeys))
+			key := s.pkeys[px]
+			if _, isErr := s.c.UpdateAdd(key, increment, func() interface{} {
+				s.violation("protected key %s was missing", key)
+				return &element{key: key, protected: true}
+			}).(error); !isErr {
+				s.increments[px].Add(1)
+			}
+			continue
+		}
+
+		key := fmt.Sprintf("key-%d", rng.Intn(s.opts.keys))
+		switch {
+		case op < 70:
+			if _, isErr := s.c.UpdateAdd(key, increment,
+				func() interface{} { return &element{key: key, count: 1} }).(error); isErr {
+				s.full.Add(1)
+			}
+		case op < 90:
+			s.c.View(key, func(el interface{}) interface{} {
+				if e := el.(*element); e.key != key {
+					s.violation("element %s found under key %s", e.key, key)
+				}
+				return nil
+			})
+		case op < 98:
+			s.c.Remove(key)
+		default:
+			shard := rng.Intn(s.c.Shards())
+			s.c.ShardElement(shard, rng.Intn(s.c.ShardCapacity()), func(k string, el interface{}) {
+				s.check(k, el)
+			})
+		}
+	}
+}
+
+// walk repeatedly walks the cache, checking every element, until stop is closed.
+func (s *soak) walk(stop chan struct{}) {
+	capacity := s.c.Shards() * s.c.ShardCapacity()
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		s.c.Walk(func(key string, el interface{}) bool {
+			s.check(key, el)
+			return true
+		})
+		if l := s.c.Len(); l > capacity {
+			s.violation("Len %d exceeds capacity %d", l, capacity)
+		}
+		s.walks.Add(1)
+		s.ops.Add(1)
+	}
+}
+
+// check verifies that the element is stored under its own key. It is called with the
+// shard read locked.
+func (s *soak) check(key string, el interface{}) {
+	e, ok := el.(*element)
+	if !ok {
+		s.violation("unexpected element type %T under key %s", el, key)
+		return
+	}
+	if e.key != key {
+		s.violation("element %s found under key %s", e.key, key)
+	}
+	if e.count < 0 {
+		s.violation("element %s has a negative count %d", key, e.count)
+	}
+}
+
+// monitor reports progress every -report interval and stops the goroutines once
+// -duration has elapsed. It returns false if no progress is made within -stall, in
+// which case all goroutine stacks are written to stderr.
+func (s *soak) monitor(stdout, stderr io.Writer, start time.Time, stop, done chan struct{}) bool {
+	deadline := time.NewTimer(s.opts.duration)
+	defer deadline.Stop()
+	report := time.NewTicker(s.opts.report)
+	defer report.Stop()
+	stall := time.NewTicker(s.opts.stall)
+	defer stall.Stop()
+
+	stopped := false
+	lastOps := s.ops.Load()
+	for {
+		select {
+		case <-deadline.C:
+			close(stop)
+			stopped = true
+		case <-report.C:
+			s.report(stdout, time.Since(start))
+		case <-stall.C:
+			ops := s.ops.Load()
+			if ops == lastOps {
+				s.violation("no progress in %s", s.opts.stall)
+				fmt.Fprintln(stderr, "Error: deadlock suspected. Goroutines:")
+				pprof.Lookup("goroutine").WriteTo(stderr, 2)
+				if !stopped {
+					close(stop)
+				}
+				return false
+			}
+			lastOps = ops
+		case <-done:
+			return true
+		}
+	}
+}
+
+// quiescent checks the invariants which can only be checked once all goroutines have
+// stopped.
+func (s *soak) quiescent() {
+	for px, key := range s.pkeys {
+		el, found := s.c.Get(key)
+		if !found {
+			s.violation("protected key %s was lost", key)
+			continue
+		}
+		if got, exp := el.(*element).count, s.increments[px].Load(); got != exp {
+			s.violation("protected key %s has count %d, expected %d", key, got, exp)
+		}
+	}
+
+	walked := 0
+	s.c.Walk(func(key string, el interface{}) bool {
+		s.check(key, el)
+		walked++
+		return true
+	})
+	if l := s.c.Len(); l != walked {
+		s.violation("Len %d does not match the %d elements walked", l, walked)
+	}
+}
+
+func (s *soak) report(out io.Writer, elapsed time.Duration) {
+	ops := s.ops.Load()
+	s.mu.Lock()
+	violations := len(s.violations)
+	s.mu.Unlock()
+	fmt.Fprintf(out, "Elapsed: %s Ops: %d (%.0f/s) Walks: %d Evictions: %d Full: %d Len: %d Violations: %d\n",
+		elapsed.Round(time.Second), ops, float64(ops)/elapsed.Seconds(), s.walks.Load(),
+		s.evictions.Load(), s.full.Load(), s.c.Len(), violations)
+}
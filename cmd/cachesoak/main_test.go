@@ -0,0 +1,28 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	rc := run([]string{"-duration", "200ms", "-goroutines", "4", "-size", "1000", "-keys", "5000",
+		"-report", "50ms"}, &stdout, &stderr)
+	if rc != 0 {
+		t.Fatal("Unexpected exit code", rc, stderr.String())
+	}
+	for _, exp := range []string{"Ops:", "Walks:", "Evictions:", "Violations: 0"} {
+		if !strings.Contains(stdout.String(), exp) {
+			t.Error("Report missing", exp, stdout.String())
+		}
+	}
+
+	for _, args := range [][]string{{"-goroutines", "0"}, {"-protected", "100000"}, {"extra"}, {"-bogus"}} {
+		stderr.Reset()
+		if rc := run(args, &stdout, &stderr); rc != 2 {
+			t.Error("Expected exit code 2 for", args, "got", rc)
+		}
+	}
+}
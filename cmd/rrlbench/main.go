@@ -0,0 +1,198 @@
+/*
+rrlbench drives a configurable mix of goroutines, Client Networks and Response Tuple
+cardinality against an RRL and reports throughput, allocation rates and Debit latency
+percentiles. It is intended to let operators validate capacity on their own hardware
+before deployment.
+
+Usage:
+
+	rrlbench [options] [keyword=value...]
+
+Trailing keyword=value arguments are passed to rrl.Config.SetValue, e.g.:
+
+	rrlbench -goroutines 16 -duration 30s responses-per-second=10 window=15
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/markdingo/rrl"
+)
+
+type options struct {
+	goroutines int
+	duration   time.Duration
+	networks   int     // Distinct Client Networks
+	names      int     // Distinct SalientNames
+	ipv6       float64 // Proportion of queries from ipv6 sources
+	sample     int     // Record the latency of one in every sample Debits
+}
+
+type worker struct {
+	debits    uint64
+	actions   [rrl.ActionLast]uint64
+	latencies []time.Duration
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	var opts options
+	fs := flag.NewFlagSet("rrlbench", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.IntVar(&opts.goroutines, "goroutines", runtime.GOMAXPROCS(0), "Number of concurrent goroutines calling Debit")
+	fs.DurationVar(&opts.duration, "duration", 10*time.Second, "Duration of the run")
+	fs.IntVar(&opts.networks, "networks", 10000, "Number of distinct Client Networks")
+	fs.IntVar(&opts.names, "names", 1000, "Number of distinct SalientNames (tuple cardinality)")
+	fs.Float64Var(&opts.ipv6, "ipv6", 0.2, "Proportion of queries from ipv6 sources")
+	fs.IntVar(&opts.sample, "sample", 16, "Record latency of one in every N Debits")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: rrlbench [options] [keyword=value...]")
+		fs.PrintDefaults()
+		fmt.Fprintln(stderr, "\nConfig keywords:")
+		rrl.NewConfig().Describe(stderr, rrl.FormatText)
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if opts.goroutines < 1 || opts.networks < 1 || opts.names < 1 || opts.sample < 1 {
+		fmt.Fprintln(stderr, "Error: -goroutines, -networks, -names and -sample must be positive")
+		return 2
+	}
+
+	cfg := rrl.NewConfig()
+	for _, kv := range fs.Args() {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			fmt.Fprintf(stderr, "Error: '%s' is not a keyword=value pair\n", kv)
+			return 2
+		}
+		if err := cfg.SetValue(k, v); err != nil {
+			fmt.Fprintln(stderr, "Error:", err)
+			return 2
+		}
+	}
+
+	R := rrl.NewRRL(cfg)
+	workers := make([]worker, opts.goroutines)
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	start := time.Now()
+	for ix := range workers {
+		wg.Add(1)
+		go func(w *worker, seed int64) {
+			defer wg.Done()
+			drive(R, w, &opts, rand.New(rand.NewSource(seed)), stop)
+		}(&workers[ix], int64(ix))
+	}
+	time.Sleep(opts.duration)
+	close(stop)
+	wg.Wait()
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	report(stdout, R, workers, elapsed, &before, &after)
+
+	return 0
+}
+
+// drive calls Debit with random sources and tuples until stop is closed.
+func drive(R *rrl.RRL, w *worker, opts *options, rng *rand.Rand, stop chan struct{}) {
+	srcs := make([]net.Addr, 0, 1024) // Pre-generate to keep generation out of the measurements
+	tuples := make([]*rrl.ResponseTuple, 0, 1024)
+	for ix := 0; ix < cap(srcs); ix++ {
+		srcs = append(srcs, source(rng.Intn(opts.networks), rng.Float64() < opts.ipv6))
+		tuples = append(tuples, &rrl.ResponseTuple{Class: 1, Type: 1,
+			AllowanceCategory: rrl.AllowanceCategory(rng.Intn(int(rrl.AllowanceTransfer))), // Caller settable only
+			SalientName:       fmt.Sprintf("host%d.example.net.", rng.Intn(opts.names))})
+	}
+
+	for ix := 0; ; ix++ {
+		if ix%1024 == 0 {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+		}
+		src := srcs[ix%len(srcs)]
+		tuple := tuples[(ix/len(srcs)+ix)%len(tuples)]
+		if ix%opts.sample == 0 {
+			t0 := time.Now()
+			act, _, _ := R.Debit(src, tuple)
+			w.latencies = append(w.latencies, time.Since(t0))
+			w.actions[act]++
+		} else {
+			act, _, _ := R.Debit(src, tuple)
+			w.actions[act]++
+		}
+		w.debits++
+	}
+}
+
+// source returns a UDP address within Client Network n.
+func source(n int, ipv6 bool) net.Addr {
+	if ipv6 {
+		ip := net.ParseIP(fmt.Sprintf("2001:db8:%x:%x::1", n>>16&0xffff, n&0xffff))
+		return &net.UDPAddr{IP: ip, Port: 53}
+	}
+	return &net.UDPAddr{IP: net.IPv4(10, byte(n>>16), byte(n>>8), 1), Port: 53}
+}
+
+func report(out io.Writer, R *rrl.RRL, workers []worker, elapsed time.Duration, before, after *runtime.MemStats) {
+	var debits uint64
+	var actions [rrl.ActionLast]uint64
+	var latencies []time.Duration
+	for ix := range workers {
+		debits += workers[ix].debits
+		for act, c := range workers[ix].actions {
+			actions[act] += c
+		}
+		latencies = append(latencies, workers[ix].latencies...)
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	fmt.Fprintf(out, "Goroutines: %d Elapsed: %s\n", len(workers), elapsed.Round(time.Millisecond))
+	fmt.Fprintf(out, "Debits:     %d (%.0f/s)\n", debits, float64(debits)/elapsed.Seconds())
+	fmt.Fprintf(out, "Actions:    Send=%d Drop=%d Slip=%d\n",
+		actions[rrl.Send], actions[rrl.Drop], actions[rrl.Slip])
+	if debits > 0 {
+		allocs := after.Mallocs - before.Mallocs
+		bytes := after.TotalAlloc - before.TotalAlloc
+		fmt.Fprintf(out, "Allocs:     %.2f/debit %.1f bytes/debit (%.0f allocs/s)\n",
+			float64(allocs)/float64(debits), float64(bytes)/float64(debits),
+			float64(allocs)/elapsed.Seconds())
+	}
+	fmt.Fprintf(out, "Latency:    p50=%s p90=%s p99=%s p999=%s max=%s (%d samples)\n",
+		percentile(latencies, 0.5), percentile(latencies, 0.9), percentile(latencies, 0.99),
+		percentile(latencies, 0.999), percentile(latencies, 1), len(latencies))
+	stats := R.GetStats(false)
+	fmt.Fprintln(out, "Stats:     ", stats.String())
+}
+
+// percentile returns the p'th percentile of the sorted durations.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	ix := int(p * float64(len(sorted)-1))
+	return sorted[ix]
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	rc := run([]string{"-duration", "50ms", "-goroutines", "2", "responses-per-second=5"}, &stdout, &stderr)
+	if rc != 0 {
+		t.Fatal("Unexpected exit code", rc, stderr.String())
+	}
+	for _, exp := range []string{"Debits:", "Allocs:", "p99=", "Stats:"} {
+		if !strings.Contains(stdout.String(), exp) {
+			t.Error("Report missing", exp, stdout.String())
+		}
+	}
+
+	for _, args := range [][]string{{"-goroutines", "0"}, {"window"}, {"windox=1"}, {"-bogus"}} {
+		stderr.Reset()
+		if rc := run(args, &stdout, &stderr); rc != 2 {
+			t.Error("Expected exit code 2 for", args, "got", rc)
+		}
+	}
+}
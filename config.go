@@ -1,9 +1,14 @@
 package rrl
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/markdingo/rrl/cache"
 )
 
 const second = 1000000000 // Equals time.Second - maybe config variables should be time.Duration?
@@ -21,7 +26,8 @@ const second = 1000000000 // Equals time.Second - maybe config variables should
 // [NewRRL].
 //
 // All values are either an unsigned int (as accepted by [strconv.ParseUint]) an unsigned
-// float (as accepted by [strconv.ParseFloat]).
+// float (as accepted by [strconv.ParseFloat]), a bool (as accepted by
+// [strconv.ParseBool]) or a string.
 //
 // The following keywords are accepted:
 //
@@ -29,6 +35,29 @@ const second = 1000000000 // Equals time.Second - maybe config variables should
 // tracked.
 // Default 15.
 //
+// responses-window, referrals-window, nodata-window, nxdomains-window, errors-window and
+// transfers-window int SECONDS - the rolling window in SECONDS for response accounts of the corresponding
+// [AllowanceCategory].
+// A longer window allows, e.g., NXDomain floods to be penalized for longer than
+// legitimate answer overruns.
+// Defaults to window.
+//
+// recovery string CURVE - the CURVE by which rate limited requests accounts, and response
+// accounts without a per-category CURVE, recover their credit.
+// A CURVE of "linear" repays one second of debt per second regardless of the depth of
+// the debt, favouring quick forgiveness.
+// A CURVE of "exponential" repays debt at a rate which falls exponentially with its
+// depth, such that an account with a debt of its window, which for response accounts is
+// the window of their category, recovers at about 37% (1/e) of the linear rate, favouring
+// sustained punishment of persistent offenders.
+// Minimum accounts always recover linearly.
+// Default "linear".
+//
+// responses-recovery, referrals-recovery, nodata-recovery, nxdomains-recovery,
+// errors-recovery and transfers-recovery string CURVE - the recovery CURVE for response
+// accounts of the corresponding [AllowanceCategory].
+// Defaults to recovery.
+//
 // ipv4-prefix-length int LENGTH - the prefix LENGTH in bits to use for identifying a ipv4
 // client CIDR.
 // Default 24.
@@ -37,6 +66,17 @@ const second = 1000000000 // Equals time.Second - maybe config variables should
 // client CIDR.
 // Default 56.
 //
+// ipv6-aggregate-prefix-length int LENGTH - the prefix LENGTH in bits of the coarser
+// aggregate network used by ipv6-aggregate-requests-per-second.
+// Default 48.
+//
+// ipv6-aggregate-requests-per-second float ALLOWANCE - the number of requests allowed per
+// second from the ipv6 aggregate network containing the source IP.
+// The aggregate account is debited in addition to the requests-per-second account as
+// attackers can trivially hop across the networks within their allocation.
+// An ALLOWANCE of 0 disables aggregate rate limiting.
+// Default 0.
+//
 // responses-per-second float ALLOWANCE - the number AllowanceAnswer responses allowed per
 // second.
 // An ALLOWANCE of 0 disables rate limiting.
@@ -61,6 +101,23 @@ const second = 1000000000 // Equals time.Second - maybe config variables should
 // An ALLOWANCE of 0 disables rate limiting.
 // Defaults to responses-per-second.
 //
+// error-suffixes string NAMES - a comma separated list of domain NAMES by which
+// AllowanceError responses are accounted.
+// By default all AllowanceError responses to a Client Network share the one account.
+// When NAMES is configured, AllowanceError responses with a SalientName at or below one
+// of the NAMES are instead accounted by the closest of the NAMES. For an authoritative
+// server the bulk of errors are REFUSED responses to names in zones which are not hosted,
+// so NAMES such as "com,net,org" group the names being sprayed at the server by their
+// parent zone, thus identifying which non-hosted names are being abused. SalientNames not
+// at or below any of the NAMES continue to share the one account.
+// Default "".
+//
+// transfers-per-second float ALLOWANCE - the number of AllowanceTransfer responses, that
+// is AXFR and IXFR queries over UDP, allowed per second.
+// As these queries are never legitimate over UDP, a small ALLOWANCE is recommended.
+// An ALLOWANCE of 0 disables rate limiting.
+// Defaults to errors-per-second.
+//
 // requests-per-second float ALLOWANCE - the number of requests allowed per second from source
 // IP.
 // An ALLOWANCE of 0 disables rate limiting of requests.
@@ -68,10 +125,102 @@ const second = 1000000000 // Equals time.Second - maybe config variables should
 // settings apply to response details.
 // Default 0.
 //
+// ipv4:KEYWORD and ipv6:KEYWORD float ALLOWANCE - where KEYWORD is one of
+// requests-per-second, responses-per-second, referrals-per-second, nodata-per-second,
+// nxdomains-per-second, errors-per-second or transfers-per-second, set the ALLOWANCE of
+// KEYWORD for clients of that address family only, e.g. "ipv6:responses-per-second".
+// A family keyword takes the place of the unqualified keyword for clients of the family,
+// after which the defaults between keywords apply as usual, so with
+// "ipv6:responses-per-second" set, ipv6 NoData responses are limited to that ALLOWANCE
+// unless nodata-per-second or ipv6:nodata-per-second is also set. This allows operators
+// who see different abuse profiles for each family to use a single RRL.
+// Default is the unqualified KEYWORD.
+//
+// requests-by-transport bool ENABLE - when true, requests-per-second is accounted
+// separately for requests arriving over UDP and over all other transports, such as TCP.
+// Request floods over TCP call for different mitigation, such as connection limits, and
+// as their source addresses cannot be spoofed, they should not consume the budget which
+// protects against spoofed UDP requests. The ipv6 aggregate networks are always shared.
+// Default false.
+//
+// minimum-responses-per-second float ALLOWANCE - the number of responses per second
+// which are sent to each Client Network regardless of any other rate limits.
+// This guarantees that small, legitimate resolvers which share infrastructure with
+// attackers are never completely starved of responses.
+// An ALLOWANCE of 0 disables the guarantee.
+// Default 0.
+//
 // max-table-size int SIZE - the maximum number of responses to be tracked at one time.
 // When exceeded, rrl stops rate limiting new responses.
 // Defaults to 100000.
 //
+// in-credit-eviction-age int SECONDS - the idle age in SECONDS after which an account
+// which was in credit at its most recent debit becomes eligible for eviction when the
+// table is full.
+// Accounts which were rate limited at their most recent debit always remain until they
+// have been idle for window.
+// A SECONDS less than window biases the table towards tracking offenders whereas a SECONDS
+// greater than window retains legitimate accounts for longer.
+// A SECONDS of 0 means window.
+// Default 0.
+//
+// max-slips-per-second float ALLOWANCE - the maximum number of Slip actions returned per
+// second across all accounts.
+// Even truncated responses have some amplification value so once this ALLOWANCE is
+// exhausted, Slip actions are downgraded to Drop actions and counted in the
+// SlipDowngrades [Stats].
+// An ALLOWANCE below 1 allows a burst of a single Slip.
+// An ALLOWANCE of 0 means Slip actions are unlimited.
+// Default 0.
+//
+// max-events-per-second float ALLOWANCE - the maximum number of account events exported
+// per second by [RRL.ExportEvents].
+// During a flood every new Client Network creates an account and many go into debit, so
+// without a limit the event export can become a flood in its own right. Once this
+// ALLOWANCE is exhausted, account events are dropped and counted by
+// [EventExporter.Dropped]. The degraded and normal events are never dropped by this limit.
+// An ALLOWANCE below 1 allows a burst of a single event.
+// An ALLOWANCE of 0 means events are unlimited.
+// Default 0.
+//
+// responses-table-size, referrals-table-size, nodata-table-size, nxdomains-table-size,
+// errors-table-size and transfers-table-size int SIZE - the maximum number of response accounts of the
+// corresponding [AllowanceCategory] to be tracked in a separate partition of the table.
+// Partitioning ensures that, e.g., an NXDomain random-subdomain flood cannot evict the
+// accounts of legitimate steady-state answer traffic.
+// A SIZE of 0 means the category shares the main table limited by max-table-size.
+// Default 0.
+//
+// degraded-after int SECONDS - the number of SECONDS for which new accounts must
+// persistently fail to be created because the table is full before the RRL is considered
+// degraded. See [RRL.Health].
+// Default 5.
+//
+// overload-drop-ratio float RATIO - the fraction of Drop Actions over the
+// overload-interval above which the RRL is considered overloaded. See [RRL.Overloaded].
+// A RATIO of 0 disables overload tracking.
+// Default 0.
+//
+// overload-interval int SECONDS - the sliding interval over which the fraction of Drop
+// Actions is measured.
+// Default 10.
+//
+// validator-boost float FACTOR - the FACTOR by which the request and response rates
+// allowed to a Client Network are multiplied while it has a validator reputation granted
+// by [RRL.ReportValidator]. A FACTOR of 1 disables validator reputations.
+// Default 1.
+//
+// validator-reputation-age int SECONDS - the time a validator reputation lasts after the
+// most recent report.
+// Default 3600.
+//
+// degraded-mode string MODE - the MODE determining the [Action] returned for debits
+// which cannot be accounted for while the RRL is degraded.
+// A MODE of "closed" returns Drop, which favours protecting third parties, whereas "open"
+// returns Send, which favours availability to legitimate clients.
+// Outside of the degraded state, such debits always return Drop.
+// Default "closed".
+//
 // slip-ratio int RATIO - the ratio of rate-limited responses which are given a truncated
 // response over a dropped response.
 // A RATIO of 0 disables slip processing and thus all rate-limited responses will be dropped.
@@ -80,6 +229,140 @@ const second = 1000000000 // Equals time.Second - maybe config variables should
 // the remaining 9 being dropped.
 // Default is 2.
 //
+// soft-limit-percent int PERCENT - the PERCENT of an account's one second credit which
+// can be consumed before [Debit] starts returning the advisory IPSoftLimit or
+// RTSoftLimit reasons.
+// The Action is still Send, the reason is simply an early warning that the account is
+// approaching its limit.
+// A PERCENT of 0 disables soft limit warnings.
+// Default 0.
+//
+// account-hash-key string KEY - the secret KEY used by [RRL.AccountID] to derive stable,
+// keyed identifiers for accounts.
+// Multiple servers configured with the same KEY and prefix lengths produce identical
+// identifiers for the same Client Network and Response Tuple without revealing the
+// underlying names.
+// An empty KEY disables account identifiers.
+// Default "".
+//
+// shard-hash-key string KEY - the secret KEY used to select the table shard of each
+// account.
+// Shard selection is always keyed with SipHash so that attackers cannot craft names which
+// collide into a single shard and evict a victim's accounts.
+// An empty KEY means each RRL uses its own random key, which is recommended unless
+// deterministic shard selection is needed, such as for reproducible benchmarks.
+// Default "".
+//
+// state-transfer-key string KEY - the shared secret KEY which authenticates state
+// transfers between [RRL.ServeState] and [RRL.PrimeFrom].
+// Both peers must be configured with the same KEY and the same prefix lengths.
+// An empty KEY disables state transfer.
+// Default "".
+//
+// include-class bool ENABLE - when true, the query Class of the [ResponseTuple]
+// contributes to response accounts so that, e.g., CHAOS or HESIOD class probing does not
+// share accounts with ClassINET traffic for the same name.
+// ClassINET accounts are unaffected by this setting.
+// Default false.
+//
+// calibrate bool ENABLE - when true, [Debit] records the peak per-second rate of every
+// account regardless of whether limiting is configured.
+// The percentiles of these rates are available via [RRL.Calibration] so that operators
+// can run with limiting disabled and then set allowances based on observed traffic.
+// Default false.
+//
+// track-uniques bool ENABLE - when true, the approximate number of distinct Client
+// Networks and SalientNames seen by [Debit] in the current window are tracked and made
+// available via the ClientNetworks and SalientNames [Stats] gauges.
+// A sudden increase in either is an early indicator of randomized-source or
+// random-subdomain attacks.
+// Default false.
+//
+// family-stats bool ENABLE - when true, [Stats] are additionally accumulated separately for
+// ipv4 and ipv6 Client Networks and made available via [RRL.GetFamilyStats].
+// Comparing the Actions and IPReasons of each family shows dual-stack operators whether
+// their ipv6-prefix-length is over-aggregating, with disproportionate Drops, or
+// under-aggregating, with few Drops despite an attack, relative to ipv4.
+// Default false.
+//
+// history-depth int MINUTES - the number of per-minute [Stats] slices retained by the RRL
+// and returned by [RRL.History].
+// This gives dashboards and tuning tools short-term temporal context without an external
+// time series database.
+// A MINUTES of 0 disables history.
+// Default 0.
+//
+// name-cache-size int SIZE - the maximum number of mixed case SalientNames whose
+// lowercase form is cached.
+// Resolvers which randomize the case of query names, e.g. with 0x20 encoding, cause a
+// lowercase copy of the SalientName to be made for every [Debit]. Caching the hot set of
+// names avoids this repeated work during steady-state traffic.
+// A SIZE of 0 disables the cache.
+// Default 0.
+//
+// decision-log-size int ENTRIES - the number of recent non-Send decisions retained in
+// memory and returned by [RRL.RecentDecisions].
+// This gives support staff immediate answers as to why a particular client was rate
+// limited without pre-configured logging.
+// An ENTRIES of 0 disables the decision log.
+// Default 0.
+//
+// max-inspections-per-second float ALLOWANCE - the maximum number of admin operations
+// which examine every account, such as [RRL.Snapshot], [RRL.LimitedNetworks] and
+// [RRL.DumpLimited], started per second.
+// These operations always run one at a time and never hold a table lock for longer than
+// it takes to copy one shard, but on a table of millions of accounts each one still
+// consumes significant CPU. Once this ALLOWANCE is exhausted, callers block until their
+// operation is due.
+// An ALLOWANCE of 0 means these operations are not paced.
+// Default 0.
+//
+// inspection-budget int MILLISECONDS - the maximum time an admin operation which examines
+// every account may spend walking the table.
+// Once the budget is exhausted the walk stops and the operation returns a partial result,
+// which [Snapshot] reports in its Partial field.
+// A MILLISECONDS of 0 means no budget.
+// Default 0.
+//
+// decision-cache-ttl int MILLISECONDS - how long a Drop decision is remembered for an
+// identical request from the same Client Network.
+// During a flood the same Response Tuple arrives from the same Client Network many times
+// per millisecond. Once its account is deeply negative, i.e., its debt exceeds both half
+// the window and the ttl, the responses to subsequent identical requests are rate limited
+// for the ttl without consulting the response account. Such accounts cannot recover
+// within the ttl, but as cached decisions never debit the account, it recovers sooner than
+// it otherwise would. Requests are still debited as normal and responses continue to slip
+// at the slip-ratio. Cached decisions are otherwise counted as normal. Decisions are not
+// cached if minimum-responses-per-second is configured.
+// A MILLISECONDS of 0 disables the cache.
+// Default 0.
+//
+// probation-count int COUNT - how many times an account must be debited within the
+// probation-period before it is created in the table.
+// Until then the debits are tracked in a small counting Bloom filter and each is treated
+// as the first debit of a new account. This greatly reduces table churn caused by one-off
+// queries and random subdomain floods at the cost of admitting up to COUNT-1 extra
+// responses per account per period. As the filter is shared by all accounts, false
+// positives occasionally create an account early.
+// A COUNT of 0 or 1 creates accounts on their first debit.
+// Default 0.
+//
+// probation-period int MILLISECONDS - the period over which probation-count debits are
+// counted.
+// Default 1000.
+//
+// sketch-width int COUNT - the number of counters in each row of a count-min sketch which
+// approximately accounts for every response account before an exact account is created.
+// Debits are first added to the sketch, and only once the estimated spend of an account
+// exceeds the one second of credit a new account starts with is an exact account created.
+// As the estimate never under-counts, no account is limited by the sketch itself, but a
+// suspected offender is limited up to one second later than it otherwise would be. The
+// sketch occupies 32*COUNT bytes regardless of how many distinct accounts are debited.
+// Collisions cause over-estimates, so COUNT should be several times the number of
+// distinct accounts debited per second in normal operation.
+// A COUNT of 0 disables the sketch.
+// Default 0.
+//
 // For those wishing to examine the internal values, with the String() function, note that
 // while intervals are set as per-second values they are internally converted to the
 // number of nanoseconds to decrement per Debit call, so expect the unexpected.
@@ -87,28 +370,91 @@ const second = 1000000000 // Equals time.Second - maybe config variables should
 // ISC config values not yet supported by this package are: qps-scale and
 // all-per-second. Maybe one day...
 type Config struct {
-	window int64
+	window  int64
+	windows [AllowanceLast]int64 // Per category windows. Unset defaults to window
+
+	recovery   recoveryCurve
+	recoveries [AllowanceLast]recoveryCurve // Per category curves. Unset defaults to recovery
 
 	ipv4PrefixLength int
 	ipv6PrefixLength int
 
-	responsesInterval int64
-	nodataInterval    int64
-	nxdomainsInterval int64
-	referralsInterval int64
-	errorsInterval    int64
-	requestsInterval  int64
+	ipv6AggregatePrefixLength int
+	ipv6AggregateInterval     int64
+
+	responsesInterval   int64
+	nodataInterval      int64
+	nxdomainsInterval   int64
+	referralsInterval   int64
+	errorsInterval      int64
+	transfersInterval   int64
+	errorSuffixes       []string // Canonical names from error-suffixes
+	requestsInterval    int64
+	requestsByTransport bool
+	minimumInterval     int64
+
+	// From the "ipv4:" and "ipv6:" family keywords. Indexed by address family then by
+	// AllowanceCategory, with AllowanceLast being requests-per-second.
+	familyIntervals  [familyLast][AllowanceLast + 1]int64
+	familySet        [familyLast][AllowanceLast + 1]bool
+	familyAllowances [familyLast][AllowanceLast + 1]int64 // Effective intervals set by finalize()
+	familyConfigured bool                                 // Any family keyword is set
+
+	slipRatio     uint
+	slipInterval  int64 // Global Slip interval from max-slips-per-second
+	eventInterval int64 // Global Event interval from max-events-per-second
+	maxTableSize  int
+	tableSizes    [AllowanceLast]int // Zero means use the main table
+
+	inCreditEvictionAge int64 // Zero means use window
 
-	slipRatio    uint
-	maxTableSize int
+	degradedAfter int64
+	failOpen      bool // From degraded-mode
+
+	softLimitBalance int64 // Positive balance below which Debit warns. Zero disables
+
+	accountHashKey   string
+	stateTransferKey string
+	shardHashKey     string
+
+	calibrate    bool
+	includeClass bool
+	trackUniques bool
+	familyStats  bool
+	historyDepth int // Number of per-minute Stats slices retained. Zero disables
+
+	decisionLogSize int // Number of recent non-Send decisions retained. Zero disables
+	nameCacheSize   int // Number of canonicalized SalientNames cached. Zero disables
+
+	inspectionInterval int64 // From max-inspections-per-second. Zero means unpaced
+	inspectionBudget   int64 // Nanoseconds per inspection walk. Zero means no budget
+
+	decisionCacheTTL int64 // Nanoseconds a cached Drop is current. Zero disables
+
+	probationCount  int   // Debits before an account is created. Less than two disables
+	probationPeriod int64 // Nanoseconds over which probationCount is counted
+
+	sketchWidth int // Counters per count-min sketch row. Zero disables
+
+	overloadRatio    float64 // Fraction of Drops which trips Overloaded. Zero disables
+	overloadInterval int64   // Nanoseconds over which overloadRatio is measured
+
+	validatorBoost float64 // Allowance divisor of reputable Client Networks. One disables
+	validatorAge   int64   // Nanoseconds a validator reputation lasts
 
 	// Managed by Set() and checked by finalize()
 	nodataIntervalSet    bool
 	nxdomainsIntervalSet bool
 	referralsIntervalSet bool
 	errorsIntervalSet    bool
+	transfersIntervalSet bool
+	slipRatioSet         bool // Only checked by check()
+	windowsSet           [AllowanceLast]bool
+	recoveriesSet        [AllowanceLast]bool
 
 	nowFunc func() time.Time // Used by tests to control clock
+
+	frozen bool // Set by Freeze. The Config is finalized and immutable
 }
 
 // These defaults largely reflect those recommended by ISC.
@@ -116,9 +462,21 @@ var defaultConfig = Config{
 	window:           15 * second,
 	ipv4PrefixLength: 24,
 	ipv6PrefixLength: 56,
-	slipRatio:        2,
-	maxTableSize:     100000,
-	nowFunc:          time.Now,
+
+	ipv6AggregatePrefixLength: 48,
+
+	slipRatio:     2,
+	maxTableSize:  100000,
+	degradedAfter: 5 * second,
+	recovery:      recoveryLinear,
+	nowFunc:       time.Now,
+
+	probationPeriod: second,
+
+	overloadInterval: 10 * second,
+
+	validatorBoost: 1,
+	validatorAge:   3600 * second,
 }
 
 // NewConfig returns a new Config struct with all the default values set. This is the only
@@ -129,21 +487,90 @@ func NewConfig() *Config {
 	return &c
 }
 
-// IsActive returns true if at least one of the intervals is set and thus causes Debit to
-// evaluate accounts. IOWs it returns !no-op.
+// IsActive returns true if at least one of the intervals is set or calibration is
+// enabled and thus causes Debit to evaluate accounts. IOWs it returns !no-op.
 func (c *Config) IsActive() bool {
-	return c.responsesInterval > 0 || c.nodataInterval > 0 || c.nxdomainsInterval > 0 || c.referralsInterval > 0 || c.errorsInterval > 0 || c.requestsInterval > 0
+	if c.responsesInterval > 0 || c.nodataInterval > 0 || c.nxdomainsInterval > 0 || c.referralsInterval > 0 || c.errorsInterval > 0 || c.transfersInterval > 0 || c.requestsInterval > 0 ||
+		c.ipv6AggregateInterval > 0 || c.calibrate {
+		return true
+	}
+	for fam := range c.familyIntervals {
+		for _, interval := range c.familyIntervals[fam] {
+			if interval > 0 {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// ErrUnknownKeyword is wrapped by the error returned by [Config.SetValue] when the keyword
+// is not recognized.
+var ErrUnknownKeyword = errors.New("unknown Set() keyword")
+
+// ErrSyntax is wrapped by the error returned by [Config.SetValue] when the argument cannot
+// be parsed as the kind of value the keyword expects or is not one of its enumerated
+// values, as listed by [Config.Describe].
+var ErrSyntax = errors.New("invalid syntax")
+
+// ErrOutOfRange is wrapped by the error returned by [Config.SetValue] when a numeric
+// argument parses correctly but is outside the valid range of the keyword. Use
+// errors.As to retrieve the range, e.g. to suggest a valid value. Max is +Inf for
+// keywords without an upper bound.
+type ErrOutOfRange struct {
+	Min, Max float64
+}
+
+func (e *ErrOutOfRange) Error() string {
+	if math.IsInf(e.Max, 1) {
+		if e.Min == 0 {
+			return "cannot be negative"
+		}
+		return fmt.Sprintf("must be at least %g", e.Min)
+	}
+
+	return fmt.Sprintf("must be between %g and %g", e.Min, e.Max)
+}
+
+// argError is the error returned by Set() for an invalid argument. The message includes
+// the keyword and argument whereas Unwrap exposes the category of error.
+type argError struct {
+	keyword, val string
+	msg          string
+	err          error // ErrSyntax or *ErrOutOfRange
+}
+
+func (e *argError) Error() string {
+	return fmt.Sprintf("%s='%s' %s", e.keyword, e.val, e.msg)
+}
+
+func (e *argError) Unwrap() error {
+	return e.err
 }
 
 // argInvalidErr is a helper function for Set() to generate a common error when the
-// argument value supplied is invalid for reasons such as it cannot be parsed as a number
-// or is outside the valid range.
+// argument value supplied cannot be parsed or is not one of the enumerated values. em is
+// either the parse error or a message.
 func argInvalidErr(keyword, val string, em interface{}) error {
-	if t, ok := em.(error); ok {
-		return fmt.Errorf("%s='%s' %w", keyword, val, t)
+	return &argError{keyword: keyword, val: val, msg: fmt.Sprint(em), err: ErrSyntax}
+}
+
+// argRangeErr is a helper function for Set() to generate a common error when the
+// argument value is outside the valid range. The range is taken from the keyword metadata
+// so it always agrees with Describe.
+func argRangeErr(keyword, val string) error {
+	oor := &ErrOutOfRange{Max: math.Inf(1)}
+	if kw := lookupKeyword(keyword); kw != nil {
+		if strings.HasPrefix(kw.valid, ">=") {
+			oor.Min, _ = strconv.ParseFloat(kw.valid[2:], 64)
+		} else if lo, hi, ok := strings.Cut(kw.valid, "-"); ok {
+			oor.Min, _ = strconv.ParseFloat(lo, 64)
+			oor.Max, _ = strconv.ParseFloat(hi, 64)
+		}
 	}
 
-	return fmt.Errorf("%s='%s' %s", keyword, val, em)
+	return &argError{keyword: keyword, val: val, msg: oor.Error(), err: oor}
 }
 
 // SetValue changes the configuration values for the nominated keyword [Config].
@@ -159,13 +586,33 @@ func argInvalidErr(keyword, val string, em interface{}) error {
 // the caller as part of the design goal of decoupling rrl from anything specific to
 // coredns.
 //
-// See [Config] for a full list of valid keywords.
+// See [Config] for a full list of valid keywords or call [Config.Describe] for a summary.
+//
+// Errors wrap [ErrUnknownKeyword], [ErrSyntax] or [ErrOutOfRange] so that front-ends can
+// determine the cause with errors.Is and errors.As rather than by matching messages.
 //
 // Example:
 //
 //	c := NewConfig()
 //	c.SetValue("window", "30")
 func (c *Config) SetValue(keyword string, arg string) error {
+	if lookupKeyword(keyword) == nil {
+		return fmt.Errorf("%w '%v'", ErrUnknownKeyword, keyword)
+	}
+	if c.frozen {
+		return fmt.Errorf("cannot Set() keyword '%v' of a frozen Config", keyword)
+	}
+	if fam, ac, ok := familyKeyword(keyword); ok {
+		i, err := getIntervalArg(keyword, arg)
+		if err != nil {
+			return err
+		}
+		c.familyIntervals[fam][ac] = i
+		c.familySet[fam][ac] = true
+		c.familyConfigured = true
+		return nil
+	}
+
 	switch keyword {
 	case "window":
 		w, err := strconv.Atoi(arg)
@@ -173,17 +620,52 @@ func (c *Config) SetValue(keyword string, arg string) error {
 			return argInvalidErr(keyword, arg, err)
 		}
 		if w <= 0 || w > 3600 { // One second to one hour
-			return argInvalidErr(keyword, arg, "window must be between 1 and 3600")
+			return argRangeErr(keyword, arg)
 		}
 		c.window = int64(w * second)
 
+	case "in-credit-eviction-age":
+		i, err := strconv.Atoi(arg)
+		if err != nil {
+			return argInvalidErr(keyword, arg, err)
+		}
+		if i < 0 || i > 3600 {
+			return argRangeErr(keyword, arg)
+		}
+		c.inCreditEvictionAge = int64(i * second)
+
+	case "responses-window", "referrals-window", "nodata-window", "nxdomains-window",
+		"errors-window", "transfers-window":
+		w, err := strconv.Atoi(arg)
+		if err != nil {
+			return argInvalidErr(keyword, arg, err)
+		}
+		if w <= 0 || w > 3600 { // One second to one hour
+			return argRangeErr(keyword, arg)
+		}
+		c.windows[keywordCategory(keyword)] = int64(w * second)
+		c.windowsSet[keywordCategory(keyword)] = true
+
+	case "recovery", "responses-recovery", "referrals-recovery", "nodata-recovery",
+		"nxdomains-recovery", "errors-recovery", "transfers-recovery":
+		curve, ok := recoveryNames[arg]
+		if !ok {
+			return argInvalidErr(keyword, arg, "must be 'linear' or 'exponential'")
+		}
+		if keyword == "recovery" {
+			c.recovery = curve
+		} else {
+			c.recoveries[keywordCategory(keyword)] = curve
+			c.recoveriesSet[keywordCategory(keyword)] = true
+		}
+
 	case "ipv4-prefix-length":
 		i, err := strconv.Atoi(arg)
 		if err != nil {
 			return argInvalidErr(keyword, arg, err)
 		}
 		if i <= 0 || i > 32 {
-			return argInvalidErr(keyword, arg, "must be between 1 and 32")
+			return argRangeErr(keyword, arg)
 		}
 		c.ipv4PrefixLength = i
 
@@ -193,10 +675,27 @@ func (c *Config) SetValue(keyword string, arg string) error {
 			return argInvalidErr(keyword, arg, err)
 		}
 		if i <= 0 || i > 128 {
-			return argInvalidErr(keyword, arg, "must be between 1 and 128")
+			return argRangeErr(keyword, arg)
 		}
 		c.ipv6PrefixLength = i
 
+	case "ipv6-aggregate-prefix-length":
+		i, err := strconv.Atoi(arg)
+		if err != nil {
+			return argInvalidErr(keyword, arg, err)
+		}
+		if i <= 0 || i > 128 {
+			return argRangeErr(keyword, arg)
+		}
+		c.ipv6AggregatePrefixLength = i
+
+	case "ipv6-aggregate-requests-per-second":
+		i, err := getIntervalArg(keyword, arg)
+		if err != nil {
+			return err
+		}
+		c.ipv6AggregateInterval = i
+
 	case "responses-per-second":
 		i, err := getIntervalArg(keyword, arg)
 		if err != nil {
@@ -236,15 +735,112 @@ func (c *Config) SetValue(keyword string, arg string) error {
 		c.errorsInterval = i
 		c.errorsIntervalSet = true
 
+	case "transfers-per-second":
+		i, err := getIntervalArg(keyword, arg)
+		if err != nil {
+			return err
+		}
+		c.transfersInterval = i
+		c.transfersIntervalSet = true
+
+	case "error-suffixes":
+		var names []string
+		for _, name := range strings.Split(arg, ",") {
+			name = strings.TrimSpace(name)
+			if len(name) == 0 {
+				continue
+			}
+			if strings.ContainsAny(name, "*/") {
+				return argInvalidErr(keyword, arg, "names cannot contain '*' or '/'")
+			}
+			names = append(names, canonicalName(name))
+		}
+		c.errorSuffixes = names
+
+	case "degraded-after":
+		i, err := strconv.Atoi(arg)
+		if err != nil {
+			return argInvalidErr(keyword, arg, err)
+		}
+		if i < 0 || i > 3600 {
+			return argRangeErr(keyword, arg)
+		}
+		c.degradedAfter = int64(i * second)
+
+	case "overload-drop-ratio":
+		f, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return argInvalidErr(keyword, arg, err)
+		}
+		if !(f >= 0 && f <= 1) { // Also rejects NaN
+			return argRangeErr(keyword, arg)
+		}
+		c.overloadRatio = f
+
+	case "overload-interval":
+		i, err := strconv.Atoi(arg)
+		if err != nil {
+			return argInvalidErr(keyword, arg, err)
+		}
+		if i < 1 || i > 3600 {
+			return argRangeErr(keyword, arg)
+		}
+		c.overloadInterval = int64(i * second)
+
+	case "validator-boost":
+		f, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return argInvalidErr(keyword, arg, err)
+		}
+		if !(f >= 1 && f <= 100) { // Also rejects NaN
+			return argRangeErr(keyword, arg)
+		}
+		c.validatorBoost = f
+
+	case "validator-reputation-age":
+		i, err := strconv.Atoi(arg)
+		if err != nil {
+			return argInvalidErr(keyword, arg, err)
+		}
+		if i < 1 || i > 86400 {
+			return argRangeErr(keyword, arg)
+		}
+		c.validatorAge = int64(i) * second
+
+	case "degraded-mode":
+		switch arg {
+		case "closed":
+			c.failOpen = false
+		case "open":
+			c.failOpen = true
+		default:
+			return argInvalidErr(keyword, arg, "must be 'open' or 'closed'")
+		}
+
 	case "slip-ratio":
 		i, err := strconv.Atoi(arg)
 		if err != nil {
 			return argInvalidErr(keyword, arg, err)
 		}
 		if i < 0 || i > 10 {
-			return argInvalidErr(keyword, arg, "must be between 0 and 10")
+			return argRangeErr(keyword, arg)
 		}
 		c.slipRatio = uint(i)
+		c.slipRatioSet = true
+
+	case "max-slips-per-second":
+		i, err := getIntervalArg(keyword, arg)
+		if err != nil {
+			return err
+		}
+		c.slipInterval = i
+
+	case "max-events-per-second":
+		i, err := getIntervalArg(keyword, arg)
+		if err != nil {
+			return err
+		}
+		c.eventInterval = i
 
 	case "requests-per-second":
 		i, err := getIntervalArg(keyword, arg)
@@ -253,34 +849,221 @@ func (c *Config) SetValue(keyword string, arg string) error {
 		}
 		c.requestsInterval = i
 
+	case "responses-table-size", "referrals-table-size", "nodata-table-size",
+		"nxdomains-table-size", "errors-table-size", "transfers-table-size":
+		i, err := strconv.Atoi(arg)
+		if err != nil {
+			return argInvalidErr(keyword, arg, err)
+		}
+		if i < 0 {
+			return argRangeErr(keyword, arg)
+		}
+		c.tableSizes[keywordCategory(keyword)] = i
+
+	case "soft-limit-percent":
+		i, err := strconv.Atoi(arg)
+		if err != nil {
+			return argInvalidErr(keyword, arg, err)
+		}
+		if i < 0 || i > 99 {
+			return argRangeErr(keyword, arg)
+		}
+		c.softLimitBalance = int64(second * (100 - i) / 100)
+		if i == 0 {
+			c.softLimitBalance = 0
+		}
+
+	case "account-hash-key":
+		c.accountHashKey = arg
+
+	case "shard-hash-key":
+		c.shardHashKey = arg
+
+	case "state-transfer-key":
+		c.stateTransferKey = arg
+
+	case "minimum-responses-per-second":
+		i, err := getIntervalArg(keyword, arg)
+		if err != nil {
+			return err
+		}
+		c.minimumInterval = i
+
+	case "calibrate":
+		b, err := strconv.ParseBool(arg)
+		if err != nil {
+			return argInvalidErr(keyword, arg, err)
+		}
+		c.calibrate = b
+
+	case "include-class":
+		b, err := strconv.ParseBool(arg)
+		if err != nil {
+			return argInvalidErr(keyword, arg, err)
+		}
+		c.includeClass = b
+
+	case "requests-by-transport":
+		b, err := strconv.ParseBool(arg)
+		if err != nil {
+			return argInvalidErr(keyword, arg, err)
+		}
+		c.requestsByTransport = b
+
+	case "track-uniques":
+		b, err := strconv.ParseBool(arg)
+		if err != nil {
+			return argInvalidErr(keyword, arg, err)
+		}
+		c.trackUniques = b
+
+	case "family-stats":
+		b, err := strconv.ParseBool(arg)
+		if err != nil {
+			return argInvalidErr(keyword, arg, err)
+		}
+		c.familyStats = b
+
+	case "name-cache-size":
+		i, err := strconv.Atoi(arg)
+		if err != nil {
+			return argInvalidErr(keyword, arg, err)
+		}
+		if i < 0 {
+			return argRangeErr(keyword, arg)
+		}
+		c.nameCacheSize = i
+
+	case "decision-log-size":
+		i, err := strconv.Atoi(arg)
+		if err != nil {
+			return argInvalidErr(keyword, arg, err)
+		}
+		if i < 0 || i > 1000000 {
+			return argRangeErr(keyword, arg)
+		}
+		c.decisionLogSize = i
+
+	case "max-inspections-per-second":
+		i, err := getIntervalArg(keyword, arg)
+		if err != nil {
+			return err
+		}
+		c.inspectionInterval = i
+
+	case "inspection-budget":
+		i, err := strconv.Atoi(arg)
+		if err != nil {
+			return argInvalidErr(keyword, arg, err)
+		}
+		if i < 0 || i > 60000 {
+			return argRangeErr(keyword, arg)
+		}
+		c.inspectionBudget = int64(i) * int64(time.Millisecond)
+
+	case "decision-cache-ttl":
+		i, err := strconv.Atoi(arg)
+		if err != nil {
+			return argInvalidErr(keyword, arg, err)
+		}
+		if i < 0 || i > 1000 {
+			return argRangeErr(keyword, arg)
+		}
+		c.decisionCacheTTL = int64(i) * int64(time.Millisecond)
+
+	case "probation-count":
+		i, err := strconv.Atoi(arg)
+		if err != nil {
+			return argInvalidErr(keyword, arg, err)
+		}
+		if i < 0 || i > 100 {
+			return argRangeErr(keyword, arg)
+		}
+		c.probationCount = i
+
+	case "probation-period":
+		i, err := strconv.Atoi(arg)
+		if err != nil {
+			return argInvalidErr(keyword, arg, err)
+		}
+		if i < 1 || i > 60000 {
+			return argRangeErr(keyword, arg)
+		}
+		c.probationPeriod = int64(i) * int64(time.Millisecond)
+
+	case "sketch-width":
+		i, err := strconv.Atoi(arg)
+		if err != nil {
+			return argInvalidErr(keyword, arg, err)
+		}
+		if i < 0 || i > 1<<24 {
+			return argRangeErr(keyword, arg)
+		}
+		c.sketchWidth = i
+
+	case "history-depth":
+		i, err := strconv.Atoi(arg)
+		if err != nil {
+			return argInvalidErr(keyword, arg, err)
+		}
+		if i < 0 || i > 1440 { // Up to one day
+			return argRangeErr(keyword, arg)
+		}
+		c.historyDepth = i
+
 	case "max-table-size":
 		i, err := strconv.Atoi(arg)
 		if err != nil {
 			return argInvalidErr(keyword, arg, err)
 		}
 		if i < 0 {
-			return argInvalidErr(keyword, arg, "cannot be negative")
+			return argRangeErr(keyword, arg)
 		}
 		c.maxTableSize = i
 
 	default:
-		return fmt.Errorf("unknown Set() keyword '%v'", keyword)
+		return fmt.Errorf("%w '%v'", ErrUnknownKeyword, keyword)
 	}
 
 	return nil
 }
 
 // SetNowFunc is intended for testing purposes only. It replaces the time.Now() function
-// used in the cache eviction logic.
+// which is the single source of time for the RRL, including balance arithmetic, cache
+// eviction and event timestamps. fn must be safe for concurrent use and should not go
+// backwards, but it may be as coarse as the platform clock: readings may repeat and need
+// not carry a monotonic component. See clock.go for the full resolution contract.
+//
+// SetNowFunc has no effect on a frozen Config.
 func (c *Config) SetNowFunc(fn func() time.Time) {
-	c.nowFunc = fn
+	if !c.frozen {
+		c.nowFunc = fn
+	}
+}
+
+// Freeze finalizes the Config, setting all default values exactly as [NewRRL] would, and
+// makes it immutable. Subsequent calls to [Config.SetValue] return an error and calls to
+// [Config.SetNowFunc] are ignored.
+//
+// A frozen Config is never modified by [NewRRL] or [NewRRLChecked], so it is safe to pass
+// the same frozen Config to multiple concurrent calls, which is otherwise undefined as
+// both functions finalize the caller's Config. Freeze itself is not concurrency safe and
+// should be called once the Config is complete and before it is shared.
+func (c *Config) Freeze() {
+	c.finalize()
+	c.frozen = true
 }
 
 // finalize is called after all config values have been set as part of the config being
 // imported into the RRL. If any allowance intervals are not set, default them to
 // responsesInterval which may itself not be set...
 // Also set the now func if that has not already been set.
+//
+// A frozen Config is already finalized so it is never written.
 func (c *Config) finalize() {
+	if c.frozen {
+		return
+	}
 	if !c.nodataIntervalSet {
 		c.nodataInterval = c.responsesInterval
 	}
@@ -293,12 +1076,127 @@ func (c *Config) finalize() {
 	if !c.errorsIntervalSet {
 		c.errorsInterval = c.responsesInterval
 	}
+	if !c.transfersIntervalSet {
+		c.transfersInterval = c.errorsInterval
+	}
+	for fam := range c.familyAllowances {
+		for ac := range c.familyAllowances[fam] {
+			c.familyAllowances[fam][ac] = c.familyInterval(fam, AllowanceCategory(ac))
+		}
+	}
+
+	for ac, set := range c.windowsSet {
+		if !set {
+			c.windows[ac] = c.window
+		}
+	}
+	if c.recovery == recoveryUnset {
+		c.recovery = recoveryLinear
+	}
+	for ac, set := range c.recoveriesSet {
+		if !set {
+			c.recoveries[ac] = c.recovery
+		}
+	}
 
 	if c.nowFunc == nil {
 		c.nowFunc = time.Now
 	}
 }
 
+// check returns an error describing all cross-field inconsistencies in a finalized
+// Config which would otherwise result in surprising effective settings. It is used by
+// [NewRRLChecked].
+func (c *Config) check() error {
+	var problems []string
+
+	intervals := [AllowanceLast]int64{c.responsesInterval, c.referralsInterval, c.nodataInterval,
+		c.nxdomainsInterval, c.errorsInterval, c.transfersInterval}
+	var responseLimits bool
+	for ac, interval := range intervals {
+		responseLimits = responseLimits || interval > 0
+		if interval > c.windows[ac] {
+			problems = append(problems,
+				fmt.Sprintf("%s=%s is less than one per %s second window so accounts never recover",
+					AllowanceCategory(ac).keyword(), rateString(interval),
+					secondsString(c.windows[ac])))
+		}
+	}
+	for fam, prefix := range familyPrefixes {
+		for ac, set := range c.familySet[fam] {
+			interval, window := c.familyIntervals[fam][ac], c.window
+			if ac < int(AllowanceLast) {
+				window = c.windows[ac]
+				responseLimits = responseLimits || interval > 0
+			}
+			if set && interval > window {
+				problems = append(problems,
+					fmt.Sprintf("%s%s=%s is less than one per %s second window so accounts never recover",
+						prefix, familyKeywordBase(AllowanceCategory(ac)), rateString(interval),
+						secondsString(window)))
+			}
+		}
+	}
+	for _, ri := range []struct {
+		keyword  string
+		interval int64
+	}{
+		{"requests-per-second", c.requestsInterval},
+		{"ipv6-aggregate-requests-per-second", c.ipv6AggregateInterval},
+		{"minimum-responses-per-second", c.minimumInterval},
+	} {
+		if ri.interval > c.window {
+			problems = append(problems,
+				fmt.Sprintf("%s=%s is less than one per %s second window so accounts never recover",
+					ri.keyword, rateString(ri.interval), secondsString(c.window)))
+		}
+	}
+
+	if c.slipRatioSet && c.slipRatio > 0 && !responseLimits {
+		problems = append(problems,
+			fmt.Sprintf("slip-ratio=%d has no effect as no response allowances are configured",
+				c.slipRatio))
+	}
+
+	if capacity := cache.Capacity(c.maxTableSize); capacity > c.maxTableSize {
+		problems = append(problems,
+			fmt.Sprintf("max-table-size=%d is effectively %d as each table shard holds at least four accounts",
+				c.maxTableSize, capacity))
+	}
+	for ac, size := range c.tableSizes {
+		if capacity := cache.Capacity(size); size > 0 && capacity > size {
+			problems = append(problems,
+				fmt.Sprintf("%s-table-size=%d is effectively %d as each table shard holds at least four accounts",
+					strings.TrimSuffix(AllowanceCategory(ac).keyword(), "-per-second"), size, capacity))
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	return errors.New("rrl: inconsistent Config: " + strings.Join(problems, "; "))
+}
+
+// keywordCategory returns the AllowanceCategory associated with a per-category keyword
+// such as nxdomains-table-size or nxdomains-window.
+func keywordCategory(keyword string) AllowanceCategory {
+	switch {
+	case strings.HasPrefix(keyword, "responses-"):
+		return AllowanceAnswer
+	case strings.HasPrefix(keyword, "referrals-"):
+		return AllowanceReferral
+	case strings.HasPrefix(keyword, "nodata-"):
+		return AllowanceNoData
+	case strings.HasPrefix(keyword, "nxdomains-"):
+		return AllowanceNXDomain
+	case strings.HasPrefix(keyword, "transfers-"):
+		return AllowanceTransfer
+	}
+
+	return AllowanceError
+}
+
 // getIntervalArg is a helper function to convert a string into a loating point which in
 // turn is converted into the number of nanoseconds to add to the allowTime for each query.
 func getIntervalArg(keyword string, arg string) (int64, error) {
@@ -307,7 +1205,7 @@ func getIntervalArg(keyword string, arg string) (int64, error) {
 		return 0, argInvalidErr(keyword, arg, err)
 	}
 	if rps < 0 {
-		return 0, argInvalidErr(keyword, arg, "cannot be negative")
+		return 0, argRangeErr(keyword, arg)
 	}
 	if rps == 0.0 {
 		return 0, nil
@@ -39,3 +39,81 @@ func TestNowFunc(t *testing.T) {
 		t.Error("Our timeFunc is not ticking by one second per call", diff)
 	}
 }
+
+// The internal timebase should start at the wall clock and advance with nowFunc whether
+// or not the times it returns carry a monotonic reading.
+func TestMonotonicNow(t *testing.T) {
+	r := NewRRL(NewConfig())
+	diff := time.Duration(r.now() - time.Now().UnixNano()).Abs()
+	if diff > time.Second {
+		t.Error("Monotonic timebase differs from system time", diff)
+	}
+
+	var wall time.Time
+	cfg := NewConfig()
+	cfg.SetNowFunc(func() time.Time { return wall })
+	r = NewRRL(cfg)
+	start := r.now()
+	wall = wall.Add(3 * time.Second)
+	if got := r.now() - start; got != int64(3*time.Second) {
+		t.Error("Timebase did not follow nowFunc without monotonic reading", got)
+	}
+}
+
+// A clock as coarse as clockResolution should neither lose nor invent credit compared
+// to a fine-grained clock, it merely batches recovery into ticks.
+func TestCoarseClock(t *testing.T) {
+	run := func(tick, gap time.Duration) (sent int) {
+		var fine, coarse time.Time
+		cfg := NewConfig()
+		cfg.SetValue("responses-per-second", "50")
+		cfg.SetValue("window", "1")
+		cfg.SetNowFunc(func() time.Time { return coarse })
+		r := NewRRL(cfg)
+		src := newAddr("udp", "10.0.0.1:53")
+		tuple := newTuple(1, 1, "example.net.", AllowanceAnswer)
+		for fine.Before(time.Time{}.Add(2 * time.Second)) {
+			fine = fine.Add(gap)
+			coarse = fine.Truncate(tick)
+			if act, _, _ := r.Debit(src, tuple); act == Send {
+				sent++
+			}
+		}
+		return
+	}
+
+	for _, gap := range []time.Duration{time.Millisecond, 25 * time.Millisecond} {
+		fine := run(time.Nanosecond, gap)
+		coarse := run(clockResolution, gap)
+		if diff := fine - coarse; diff < -1 || diff > 1 {
+			t.Error(gap, "Coarse clock sent", coarse, "but fine clock sent", fine)
+		}
+	}
+	if sent := run(clockResolution, 25*time.Millisecond); sent != 80 {
+		t.Error("Coarse clock should send all queries below the allowance, not", sent)
+	}
+}
+
+// Per category values derived by finalize must track later changes to their defaults
+func TestConfigRefinalize(t *testing.T) {
+	c := NewConfig()
+	c.SetValue("window", "5")
+	c.SetValue("nxdomains-window", "60")
+	c.SetValue("errors-recovery", "linear")
+	NewRRL(c)
+	c.SetValue("window", "20")
+	c.SetValue("recovery", "exponential")
+	r := NewRRL(c)
+	if w := r.windowFor(AllowanceAnswer); w != 20*second {
+		t.Error("Derived window should follow window, not", w)
+	}
+	if w := r.windowFor(AllowanceNXDomain); w != 60*second {
+		t.Error("Explicit window should be retained, not", w)
+	}
+	if rc := r.recoveryFor(AllowanceAnswer); rc != recoveryExponential {
+		t.Error("Derived recovery should follow recovery, not", rc)
+	}
+	if rc := r.recoveryFor(AllowanceError); rc != recoveryLinear {
+		t.Error("Explicit recovery should be retained, not", rc)
+	}
+}
@@ -3,7 +3,9 @@ package rrl_test
 import (
 	"fmt"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/markdingo/rrl"
 )
@@ -57,6 +59,11 @@ func TestConfigSet(t *testing.T) {
 		{"window", "-1", "between"},
 		{"window", "1", ""},
 
+		{"recovery", "quadratic", "linear"},
+		{"recovery", "exponential", ""},
+		{"nxdomains-recovery", "slow", "exponential"},
+		{"nxdomains-recovery", "linear", ""},
+
 		{"ipv4-prefix-length", "-1", "be between"},
 		{"ipv4-prefix-length", "33", "be between"},
 		{"ipv4-prefix-length", "24", ""},
@@ -67,6 +74,13 @@ func TestConfigSet(t *testing.T) {
 		{"ipv6-prefix-length", "xx129", "syntax"},
 		{"ipv6-prefix-length", "64", ""},
 
+		{"ipv6-aggregate-prefix-length", "0", "be between"},
+		{"ipv6-aggregate-prefix-length", "x", "syntax"},
+		{"ipv6-aggregate-prefix-length", "48", ""},
+
+		{"ipv6-aggregate-requests-per-second", "-1", "negative"},
+		{"ipv6-aggregate-requests-per-second", "10", ""},
+
 		{"responses-per-second", "-1", "negative"},
 		{"responses-per-second", "xxy", "invalid syntax"},
 		{"responses-per-second", "0", ""},
@@ -90,14 +104,143 @@ func TestConfigSet(t *testing.T) {
 		{"errors-per-second", "6.001", ""},
 		{"errors-per-second", "6", ""},
 
+		{"in-credit-eviction-age", "-1", "be between"},
+		{"in-credit-eviction-age", "3601", "be between"},
+		{"in-credit-eviction-age", "x", "syntax"},
+		{"in-credit-eviction-age", "5", ""},
+
+		{"transfers-per-second", "-1", "negative"},
+		{"transfers-per-second", "xyz", "syntax"},
+		{"transfers-per-second", "0.5", ""},
+
+		{"max-slips-per-second", "-1", "negative"},
+		{"max-slips-per-second", "x", "syntax"},
+		{"max-slips-per-second", "100", ""},
+
+		{"max-events-per-second", "-1", "negative"},
+		{"max-events-per-second", "x", "syntax"},
+		{"max-events-per-second", "1000", ""},
+
+		{"error-suffixes", "com,*.net", "cannot contain"},
+		{"error-suffixes", "a/b", "cannot contain"},
+		{"error-suffixes", "com, Example.NET.,", ""},
+
 		{"requests-per-second", "-1", "negative"},
 		{"requests-per-second", "xx", "syntax"},
 		{"requests-per-second", "7", ""},
 
+		{"degraded-after", "-1", "be between"},
+		{"degraded-after", "x", "syntax"},
+		{"degraded-after", "10", ""},
+
+		{"degraded-mode", "ajar", "open"},
+		{"degraded-mode", "open", ""},
+		{"degraded-mode", "closed", ""},
+
 		{"slip-ratio", "-1", "be between"},
 		{"slip-ratio", "ccc", "syntax"},
 		{"slip-ratio", "8", ""},
 
+		{"nxdomains-window", "0", "be between"},
+		{"errors-window", "3601", "be between"},
+		{"referrals-window", "x", "syntax"},
+		{"nxdomains-window", "60", ""},
+		{"transfers-window", "0", "be between"},
+		{"transfers-window", "60", ""},
+
+		{"nxdomains-table-size", "-1", "negative"},
+		{"errors-table-size", "x", "syntax"},
+		{"responses-table-size", "100", ""},
+		{"transfers-table-size", "-1", "negative"},
+
+		{"soft-limit-percent", "-1", "be between"},
+		{"soft-limit-percent", "100", "be between"},
+		{"soft-limit-percent", "x", "syntax"},
+		{"soft-limit-percent", "80", ""},
+
+		{"account-hash-key", "secret", ""},
+		{"state-transfer-key", "secret", ""},
+		{"shard-hash-key", "secret", ""},
+
+		{"requests-by-transport", "maybe", "syntax"},
+		{"requests-by-transport", "true", ""},
+
+		{"minimum-responses-per-second", "-1", "negative"},
+		{"minimum-responses-per-second", "x", "syntax"},
+		{"minimum-responses-per-second", "1", ""},
+
+		{"calibrate", "maybe", "syntax"},
+		{"calibrate", "false", ""},
+
+		{"include-class", "maybe", "syntax"},
+		{"include-class", "true", ""},
+
+		{"track-uniques", "maybe", "syntax"},
+		{"track-uniques", "false", ""},
+
+		{"family-stats", "maybe", "syntax"},
+		{"family-stats", "true", ""},
+
+		{"history-depth", "-1", "be between"},
+		{"history-depth", "1441", "be between"},
+		{"history-depth", "x", "syntax"},
+		{"history-depth", "60", ""},
+
+		{"name-cache-size", "-1", "negative"},
+		{"name-cache-size", "x", "syntax"},
+		{"name-cache-size", "1000", ""},
+
+		{"decision-log-size", "-1", "be between"},
+		{"decision-log-size", "x", "syntax"},
+		{"decision-log-size", "100", ""},
+
+		{"max-inspections-per-second", "-1", "negative"},
+		{"max-inspections-per-second", "x", "syntax"},
+		{"max-inspections-per-second", "2", ""},
+
+		{"inspection-budget", "-1", "be between"},
+		{"inspection-budget", "60001", "be between"},
+		{"inspection-budget", "x", "syntax"},
+		{"inspection-budget", "100", ""},
+
+		{"decision-cache-ttl", "-1", "be between"},
+		{"decision-cache-ttl", "1001", "be between"},
+		{"decision-cache-ttl", "x", "syntax"},
+		{"decision-cache-ttl", "5", ""},
+
+		{"probation-count", "-1", "be between"},
+		{"probation-count", "101", "be between"},
+		{"probation-count", "x", "syntax"},
+		{"probation-count", "3", ""},
+		{"probation-period", "0", "be between"},
+		{"probation-period", "60001", "be between"},
+		{"probation-period", "x", "syntax"},
+		{"probation-period", "500", ""},
+
+		{"sketch-width", "-1", "be between"},
+		{"sketch-width", "16777217", "be between"},
+		{"sketch-width", "x", "syntax"},
+		{"sketch-width", "65536", ""},
+
+		{"overload-drop-ratio", "-0.1", "be between"},
+		{"overload-drop-ratio", "1.1", "be between"},
+		{"overload-drop-ratio", "NaN", "be between"},
+		{"overload-drop-ratio", "x", "syntax"},
+		{"overload-drop-ratio", "0.5", ""},
+		{"overload-interval", "0", "be between"},
+		{"overload-interval", "3601", "be between"},
+		{"overload-interval", "x", "syntax"},
+		{"overload-interval", "30", ""},
+		{"validator-boost", "0.5", "be between"},
+		{"validator-boost", "101", "be between"},
+		{"validator-boost", "NaN", "be between"},
+		{"validator-boost", "x", "syntax"},
+		{"validator-boost", "4", ""},
+		{"validator-reputation-age", "0", "be between"},
+		{"validator-reputation-age", "86401", "be between"},
+		{"validator-reputation-age", "x", "syntax"},
+		{"validator-reputation-age", "600", ""},
+
 		{"max-table-size", "-1", "negative"},
 		{"max-table-size", "xx", "syntax"},
 		{"max-table-size", "9", ""},
@@ -133,3 +276,94 @@ func TestConfigSet(t *testing.T) {
 		t.Error("Config is", got, "but expected", exp)
 	}
 }
+
+func TestNewRRLChecked(t *testing.T) {
+	testCases := []struct {
+		settings [][2]string
+		emsgs    []string
+	}{
+		{nil, nil},
+		{[][2]string{{"responses-per-second", "10"}, {"slip-ratio", "3"}}, nil},
+		{[][2]string{{"responses-per-second", "0.05"}},
+			[]string{"responses-per-second=0.05", "nodata-per-second=0.05", "15 second window"}},
+		{[][2]string{{"responses-per-second", "1"}, {"nxdomains-window", "1"},
+			{"nxdomains-per-second", "0.5"}},
+			[]string{"nxdomains-per-second=0.5 is less than one per 1 second window"}},
+		{[][2]string{{"requests-per-second", "0.01"}}, []string{"requests-per-second=0.01"}},
+		{[][2]string{{"ipv6:nxdomains-per-second", "0.05"}, {"ipv4:requests-per-second", "0.01"}},
+			[]string{"ipv6:nxdomains-per-second=0.05 is less than one per 15 second window",
+				"ipv4:requests-per-second=0.01"}},
+		{[][2]string{{"ipv4:responses-per-second", "10"}, {"slip-ratio", "3"}}, nil},
+		{[][2]string{{"requests-per-second", "10"}, {"slip-ratio", "2"}},
+			[]string{"slip-ratio=2 has no effect"}},
+		{[][2]string{{"max-table-size", "100"}, {"errors-table-size", "10"}},
+			[]string{"max-table-size=100 is effectively 1024", "errors-table-size=10 is effectively 1024"}},
+	}
+
+	for ix, tc := range testCases {
+		cfg := rrl.NewConfig()
+		for _, kv := range tc.settings {
+			if err := cfg.SetValue(kv[0], kv[1]); err != nil {
+				t.Fatal(ix, "Unexpected SetValue error", err)
+			}
+		}
+		R, err := rrl.NewRRLChecked(cfg)
+		if len(tc.emsgs) == 0 {
+			if err != nil || R == nil {
+				t.Error(ix, "Unexpected error", err)
+			}
+			continue
+		}
+		if err == nil || R != nil {
+			t.Error(ix, "Expected an error and no RRL")
+			continue
+		}
+		for _, emsg := range tc.emsgs {
+			if !strings.Contains(err.Error(), emsg) {
+				t.Errorf("%d Expected '%s' in %s", ix, emsg, err)
+			}
+		}
+	}
+}
+
+func TestConfigFreeze(t *testing.T) {
+	cfg := rrl.NewConfig()
+	cfg.SetValue("responses-per-second", "7")
+	cfg.Freeze()
+
+	exp := "15000000000 24-56 142857142/142857142/142857142/142857142/142857142/0 2/100000 false/false/false/false"
+	if got := cfg.String(); got != exp {
+		t.Error("Frozen Config should be finalized", got)
+	}
+	if err := cfg.SetValue("responses-per-second", "9"); err == nil || !strings.Contains(err.Error(), "frozen") {
+		t.Error("SetValue of a frozen Config should fail, not", err)
+	}
+	if err := cfg.SetValue("windox", "9"); err == nil || !strings.Contains(err.Error(), "unknown") {
+		t.Error("Unknown keyword should still be reported as such, not", err)
+	}
+	cfg.SetNowFunc(func() time.Time { return time.Time{} })
+
+	// Run with -race to check that NewRRL never writes a frozen Config
+	var wg sync.WaitGroup
+	for ix := 0; ix < 8; ix++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			R := rrl.NewRRL(cfg)
+			if R == nil {
+				t.Error("NewRRL failed unexpectedly")
+			}
+			if _, err := rrl.NewRRLChecked(cfg); err != nil {
+				t.Error("NewRRLChecked failed unexpectedly", err)
+			}
+			if ss := R.Snapshot(); ss.Time.IsZero() {
+				t.Error("SetNowFunc should have been ignored by the frozen Config")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := cfg.String(); got != exp {
+		t.Error("Frozen Config changed", got)
+	}
+}
@@ -0,0 +1,193 @@
+/*
+Package corednsadapter converts between the github.com/miekg/dns message types used by
+coredns, and most other Go DNS servers, and the types of the rrl package. It also
+provides a drop-in middleware which applies an RRL to the responses of any coredns
+plugin chain, so the coredns/rrl integration test corpus can be run against this
+implementation.
+
+It is a separate module so that the rrl package itself remains free of dependencies.
+coredns itself is not imported: [Handler] has the same method set as coredns's
+plugin.Handler so any coredns plugin can be used as the Next Handler of a [Middleware]
+and a Middleware can be used wherever coredns expects a plugin.Handler.
+*/
+package corednsadapter
+
+import (
+	"context"
+
+	"github.com/markdingo/rrl"
+	"github.com/miekg/dns"
+)
+
+// NewResponseTuple creates the rrl.ResponseTuple of the response message by applying the
+// AllowanceCategory rules and the SalientName Selection Rules of rrl.ResponseTuple.
+//
+// origin is the origin of the records which synthesized the response, such as a
+// wildcard, otherwise it must be empty. The caller must supply it as it cannot be
+// determined from the message.
+//
+// NewResponseTuple returns nil if the message does not have exactly one question, as
+// Debit expects for malformed queries.
+func NewResponseTuple(resp *dns.Msg, origin string) *rrl.ResponseTuple {
+	if len(resp.Question) != 1 {
+		return nil
+	}
+	q := resp.Question[0]
+	rt := &rrl.ResponseTuple{Class: q.Qclass, Type: q.Qtype, AllowanceCategory: Category(resp),
+		SalientName: dns.CanonicalName(q.Name)}
+	switch {
+	case rt.AllowanceCategory == rrl.AllowanceNXDomain || rt.AllowanceCategory == rrl.AllowanceReferral:
+		rt.SalientName = ""
+		if len(resp.Ns) > 0 {
+			rt.SalientName = dns.CanonicalName(resp.Ns[0].Header().Name)
+		}
+	case len(origin) > 0:
+		rt.SalientName = "*." + dns.CanonicalName(origin)
+	}
+
+	return rt
+}
+
+// Category returns the rrl.AllowanceCategory of the response message. Unlike
+// rrl.NewAllowanceCategory, which only has the section counts to go by, a response with
+// an empty answer section is only a referral if the authority section holds NS RRs and
+// no SOA, otherwise it is NoData.
+func Category(resp *dns.Msg) rrl.AllowanceCategory {
+	switch {
+	case resp.Rcode == dns.RcodeNameError:
+		return rrl.AllowanceNXDomain
+	case resp.Rcode != dns.RcodeSuccess:
+		return rrl.AllowanceError
+	case len(resp.Answer) > 0:
+		return rrl.AllowanceAnswer
+	}
+	referral := false
+	for _, rr := range resp.Ns {
+		switch rr.(type) {
+		case *dns.SOA:
+			return rrl.AllowanceNoData
+		case *dns.NS:
+			referral = true
+		}
+	}
+	if referral {
+		return rrl.AllowanceReferral
+	}
+
+	return rrl.AllowanceNoData
+}
+
+// NewResponse is the inverse of NewResponseTuple. It creates a minimal response to the
+// query req for which NewResponseTuple returns tuple, apart from synthesized
+// SalientNames. It allows test harnesses which record ResponseTuples to replay them as
+// messages. The answer RR is empty of data.
+func NewResponse(req *dns.Msg, tuple *rrl.ResponseTuple) *dns.Msg {
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	if tuple == nil || len(req.Question) != 1 {
+		resp.Rcode = dns.RcodeFormatError
+		return resp
+	}
+	qName := req.Question[0].Name
+	hdr := func(name string, rrType uint16) dns.RR_Header {
+		return dns.RR_Header{Name: name, Rrtype: rrType, Class: tuple.Class, Ttl: 300}
+	}
+	soa := func(name string) dns.RR {
+		return &dns.SOA{Hdr: hdr(name, dns.TypeSOA), Ns: "ns." + name, Mbox: "hostmaster." + name,
+			Serial: 1, Refresh: 3600, Retry: 600, Expire: 86400, Minttl: 300}
+	}
+
+	switch tuple.AllowanceCategory {
+	case rrl.AllowanceAnswer:
+		resp.Answer = []dns.RR{&dns.RFC3597{Hdr: hdr(qName, tuple.Type)}}
+	case rrl.AllowanceReferral:
+		resp.Ns = []dns.RR{&dns.NS{Hdr: hdr(tuple.SalientName, dns.TypeNS), Ns: "ns." + tuple.SalientName}}
+	case rrl.AllowanceNoData:
+		resp.Ns = []dns.RR{soa(qName)}
+	case rrl.AllowanceNXDomain:
+		resp.Rcode = dns.RcodeNameError
+		resp.Ns = []dns.RR{soa(tuple.SalientName)}
+	default:
+		resp.Rcode = dns.RcodeServerFailure
+	}
+
+	return resp
+}
+
+// Truncate returns the response sent in place of resp when Debit returns Slip. It is a
+// copy of resp with TC=1 and empty sections, apart from any EDNS0 OPT RR, which prompts
+// a genuine client to retry over TCP.
+func Truncate(resp *dns.Msg) *dns.Msg {
+	tc := resp.Copy()
+	tc.Truncated = true
+	tc.Answer, tc.Ns, tc.Extra = nil, nil, nil
+	if opt := resp.IsEdns0(); opt != nil {
+		tc.Extra = []dns.RR{opt}
+	}
+
+	return tc
+}
+
+// ResponseWriter wraps a dns.ResponseWriter so that every response written is subject to
+// RRL. Responses are dropped, truncated or sent as recommended by Debit.
+//
+// ResponseWriter does not check DNS Cookies. Responses to queries with a valid server
+// cookie should be written to the wrapped dns.ResponseWriter directly.
+type ResponseWriter struct {
+	dns.ResponseWriter
+	RRL *rrl.RRL
+
+	// Origin, if set, is passed to NewResponseTuple for responses synthesized from a
+	// wildcard or similar.
+	Origin string
+}
+
+// WriteMsg debits the RRL and writes resp, its truncated form or nothing, according to
+// the recommended Action.
+func (w *ResponseWriter) WriteMsg(resp *dns.Msg) error {
+	act, _, _ := w.RRL.Debit(w.RemoteAddr(), NewResponseTuple(resp, w.Origin))
+	switch act {
+	case rrl.Drop:
+		return nil
+	case rrl.Slip:
+		resp = Truncate(resp)
+	}
+
+	return w.ResponseWriter.WriteMsg(resp)
+}
+
+// Write unpacks the wire format response so that it is subject to RRL in the same way as
+// WriteMsg. A response which cannot be unpacked is accounted with a nil ResponseTuple.
+func (w *ResponseWriter) Write(buf []byte) (int, error) {
+	resp := new(dns.Msg)
+	if err := resp.Unpack(buf); err == nil {
+		return len(buf), w.WriteMsg(resp)
+	}
+	if act, _, _ := w.RRL.Debit(w.RemoteAddr(), nil); act != rrl.Send {
+		return len(buf), nil // A malformed response has no truncated form
+	}
+
+	return w.ResponseWriter.Write(buf)
+}
+
+// Handler has the same method set as coredns's plugin.Handler.
+type Handler interface {
+	ServeDNS(context.Context, dns.ResponseWriter, *dns.Msg) (int, error)
+	Name() string
+}
+
+// Middleware is a Handler which applies RRL to all responses written by Next. It is the
+// equivalent of the coredns/rrl plugin.
+type Middleware struct {
+	Next Handler
+	RRL  *rrl.RRL
+}
+
+// ServeDNS implements Handler by passing the query to Next with a wrapped
+// dns.ResponseWriter.
+func (m *Middleware) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	return m.Next.ServeDNS(ctx, &ResponseWriter{ResponseWriter: w, RRL: m.RRL}, r)
+}
+
+// Name implements Handler.
+func (m *Middleware) Name() string { return "rrl" }
@@ -0,0 +1,147 @@
+package corednsadapter
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/markdingo/rrl"
+	"github.com/miekg/dns"
+)
+
+func TestNewResponseTuple(t *testing.T) {
+	req := new(dns.Msg)
+	req.SetQuestion("WWW.Example.COM.", dns.TypeA)
+	ns := &dns.NS{Hdr: dns.RR_Header{Name: "sub.example.com.", Rrtype: dns.TypeNS, Class: dns.ClassINET},
+		Ns: "ns.sub.example.com."}
+	soa := &dns.SOA{Hdr: dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeSOA, Class: dns.ClassINET}}
+	a := &dns.A{Hdr: dns.RR_Header{Name: "www.example.com.", Rrtype: dns.TypeA, Class: dns.ClassINET},
+		A: net.IPv4(192, 0, 2, 1)}
+
+	testCases := []struct {
+		rcode   int
+		answer  []dns.RR
+		ns      []dns.RR
+		origin  string
+		ac      rrl.AllowanceCategory
+		salient string
+	}{
+		{dns.RcodeSuccess, []dns.RR{a}, nil, "", rrl.AllowanceAnswer, "www.example.com."},
+		{dns.RcodeSuccess, []dns.RR{a}, nil, "Example.com.", rrl.AllowanceAnswer, "*.example.com."},
+		{dns.RcodeSuccess, nil, []dns.RR{soa}, "", rrl.AllowanceNoData, "www.example.com."},
+		{dns.RcodeSuccess, nil, []dns.RR{ns}, "", rrl.AllowanceReferral, "sub.example.com."},
+		{dns.RcodeSuccess, nil, []dns.RR{soa, ns}, "", rrl.AllowanceNoData, "www.example.com."},
+		{dns.RcodeNameError, nil, []dns.RR{soa}, "", rrl.AllowanceNXDomain, "example.com."},
+		{dns.RcodeNameError, nil, nil, "", rrl.AllowanceNXDomain, ""},
+		{dns.RcodeServerFailure, nil, nil, "", rrl.AllowanceError, "www.example.com."},
+	}
+
+	for ix, tc := range testCases {
+		resp := new(dns.Msg)
+		resp.SetRcode(req, tc.rcode)
+		resp.Answer, resp.Ns = tc.answer, tc.ns
+		rt := NewResponseTuple(resp, tc.origin)
+		if rt == nil {
+			t.Fatal(ix, "Unexpected nil tuple")
+		}
+		if rt.AllowanceCategory != tc.ac || rt.SalientName != tc.salient {
+			t.Error(ix, "Expected", tc.ac, tc.salient, "got", rt.AllowanceCategory, rt.SalientName)
+		}
+		if rt.Class != dns.ClassINET || rt.Type != dns.TypeA {
+			t.Error(ix, "Class/Type not copied from question", rt.Class, rt.Type)
+		}
+	}
+
+	if rt := NewResponseTuple(new(dns.Msg), ""); rt != nil {
+		t.Error("Expected nil tuple for a message without a question, not", rt)
+	}
+}
+
+func TestNewResponse(t *testing.T) {
+	req := new(dns.Msg)
+	req.SetQuestion("www.example.com.", dns.TypeAAAA)
+	for _, tuple := range []*rrl.ResponseTuple{
+		{Class: dns.ClassINET, Type: dns.TypeAAAA, AllowanceCategory: rrl.AllowanceAnswer,
+			SalientName: "www.example.com."},
+		{Class: dns.ClassINET, Type: dns.TypeAAAA, AllowanceCategory: rrl.AllowanceNoData,
+			SalientName: "www.example.com."},
+		{Class: dns.ClassINET, Type: dns.TypeAAAA, AllowanceCategory: rrl.AllowanceReferral,
+			SalientName: "example.com."},
+		{Class: dns.ClassINET, Type: dns.TypeAAAA, AllowanceCategory: rrl.AllowanceNXDomain,
+			SalientName: "example.com."},
+		{Class: dns.ClassINET, Type: dns.TypeAAAA, AllowanceCategory: rrl.AllowanceError,
+			SalientName: "www.example.com."},
+	} {
+		resp := NewResponse(req, tuple)
+		if _, err := resp.Pack(); err != nil {
+			t.Error(tuple.AllowanceCategory, "Response does not pack", err)
+		}
+		if rt := NewResponseTuple(resp, ""); rt == nil || *rt != *tuple {
+			t.Error("Round trip failed. Expected", *tuple, "got", rt)
+		}
+	}
+
+	if resp := NewResponse(req, nil); resp.Rcode != dns.RcodeFormatError {
+		t.Error("Expected FORMERR for a nil tuple, not", resp.Rcode)
+	}
+}
+
+// recorder is a dns.ResponseWriter which records the messages written.
+type recorder struct {
+	dns.ResponseWriter
+	msgs []*dns.Msg
+}
+
+func (r *recorder) RemoteAddr() net.Addr {
+	return &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 53}
+}
+
+func (r *recorder) WriteMsg(m *dns.Msg) error {
+	r.msgs = append(r.msgs, m)
+	return nil
+}
+
+// answerer is a Handler which always answers.
+type answerer struct{}
+
+func (answerer) Name() string { return "answerer" }
+
+func (answerer) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	resp := new(dns.Msg)
+	resp.SetReply(r)
+	resp.Answer = []dns.RR{&dns.A{Hdr: dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeA,
+		Class: dns.ClassINET}, A: net.IPv4(192, 0, 2, 2)}}
+	resp.SetEdns0(1232, false)
+
+	return dns.RcodeSuccess, w.WriteMsg(resp)
+}
+
+func TestMiddleware(t *testing.T) {
+	cfg := rrl.NewConfig()
+	cfg.SetValue("responses-per-second", "1")
+	cfg.SetValue("slip-ratio", "2")
+	var h Handler = &Middleware{Next: answerer{}, RRL: rrl.NewRRL(cfg)}
+	if h.Name() != "rrl" {
+		t.Error("Unexpected Name", h.Name())
+	}
+
+	w := &recorder{}
+	req := new(dns.Msg)
+	req.SetQuestion("www.example.com.", dns.TypeA)
+	for ix := 0; ix < 5; ix++ {
+		h.ServeDNS(context.Background(), w, req)
+	}
+
+	// Send, then Slip on every second Drop
+	if len(w.msgs) != 3 {
+		t.Fatal("Expected 3 messages written, not", len(w.msgs))
+	}
+	if w.msgs[0].Truncated || len(w.msgs[0].Answer) != 1 {
+		t.Error("First response should be sent intact", w.msgs[0])
+	}
+	for _, m := range w.msgs[1:] {
+		if !m.Truncated || len(m.Answer) != 0 || m.IsEdns0() == nil {
+			t.Error("Slipped response should be truncated with only the OPT RR", m)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+/*
+Package dashboard provides an optional, self-contained web dashboard for an [rrl.RRL].
+
+The dashboard is a single page served from embedded assets which visualizes live Send,
+Drop and Slip rates, the top offending accounts and table occupancy. It is intended for
+small operators who want observability without standing up Prometheus and Grafana.
+
+The dashboard is mounted on the embedding server's admin listener, e.g.:
+
+	mux.Handle("/rrl/", http.StripPrefix("/rrl", dashboard.Handler(R)))
+
+No access control is applied by the dashboard itself. Since it reveals Client Networks
+and SalientNames, it should only be exposed on an administrative interface.
+*/
+package dashboard
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/markdingo/rrl"
+)
+
+//go:embed index.html
+var indexHTML []byte
+
+// state is the JSON document polled by the dashboard page.
+type state struct {
+	Time         time.Time                `json:"time"`
+	Actions      [rrl.ActionLast]int64    `json:"actions"`
+	RPS          [rrl.AllowanceLast]int64 `json:"rps"`
+	CacheLength  int                      `json:"cacheLength"`
+	MaxTableSize int                      `json:"maxTableSize"`
+	Limited      []string                 `json:"limited"`
+	TopTalkers   []talker                 `json:"topTalkers"`
+	Partial      bool                     `json:"partial"` // Limited and TopTalkers are incomplete
+}
+
+type talker struct {
+	Account string  `json:"account"`
+	Balance float64 `json:"balance"` // Seconds
+}
+
+// Handler returns an http.Handler serving the dashboard page at "/" and its live data
+// at "/state.json". The counters in state.json are cumulative so the page derives rates
+// from successive polls; Handler never zeroes the RRL stats.
+func Handler(r *rrl.RRL) http.Handler {
+	maxTableSize, partitions := r.TableSizes()
+	for _, size := range partitions {
+		maxTableSize += size // CacheLength counts the accounts of every partition
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/" {
+			http.NotFound(w, req)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(indexHTML)
+	})
+	mux.HandleFunc("/state.json", func(w http.ResponseWriter, req *http.Request) {
+		ss := r.Snapshot()
+		st := &state{Time: ss.Time, Actions: ss.Stats.Actions, RPS: ss.Stats.RPS,
+			CacheLength: ss.Stats.CacheLength, MaxTableSize: maxTableSize,
+			Limited: ss.Limited, TopTalkers: []talker{}, Partial: ss.Partial}
+		for _, ai := range ss.TopTalkers {
+			st.TopTalkers = append(st.TopTalkers,
+				talker{Account: ai.Key.String(), Balance: ai.Balance.Seconds()})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(st)
+	})
+
+	return mux
+}
@@ -0,0 +1,62 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/markdingo/rrl"
+)
+
+func TestHandler(t *testing.T) {
+	cfg := rrl.NewConfig()
+	cfg.SetValue("responses-per-second", "1")
+	cfg.SetValue("max-table-size", "5000")
+	cfg.SetValue("nxdomains-table-size", "1000")
+	cfg.SetNowFunc(func() time.Time { return time.Unix(1000, 0) })
+	R := rrl.NewRRL(cfg)
+
+	tuple := &rrl.ResponseTuple{Class: 1, Type: 1, AllowanceCategory: rrl.AllowanceAnswer,
+		SalientName: "example.com."}
+	src := &net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 53}
+	for ix := 0; ix < 5; ix++ {
+		R.Debit(src, tuple)
+	}
+
+	h := Handler(R)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "rrl dashboard") {
+		t.Error("Dashboard page not served", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/state.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatal("state.json not served", rec.Code)
+	}
+	var st state
+	if err := json.Unmarshal(rec.Body.Bytes(), &st); err != nil {
+		t.Fatal("Invalid state.json", err)
+	}
+	if st.Actions[rrl.Send] != 1 || st.Actions[rrl.Send]+st.Actions[rrl.Drop]+st.Actions[rrl.Slip] != 5 {
+		t.Error("Unexpected Actions", st.Actions)
+	}
+	if st.MaxTableSize != 6000 || st.CacheLength != 1 {
+		t.Error("Unexpected occupancy", st.CacheLength, st.MaxTableSize)
+	}
+	if len(st.Limited) != 1 || st.Limited[0] != "192.0.2.0" || len(st.TopTalkers) != 1 {
+		t.Error("Unexpected offenders", st.Limited, st.TopTalkers)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/bogus", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Error("Expected 404 for unknown path, got", rec.Code)
+	}
+}
@@ -13,6 +13,11 @@ import (
 // The following table represents all categories and the selection rules which are
 // evaluated in order from top to bottom with AllowanceError being the default if no other
 // rules apply.
+// AllowanceTransfer is not in the table as it is never set by the caller. Rather, Debit
+// assigns AllowanceTransfer to AXFR and IXFR queries arriving over UDP, regardless of the
+// supplied AllowanceCategory, as these are always errors or refusals and are a common
+// scanning signature. Giving them a dedicated account stops them diluting the
+// AllowanceError account of the Client Network.
 //
 //	  AllowanceCategory  rCode   len(Answers)   len(Ns)
 //	+-------------------+------+--------------+---------+
@@ -32,6 +37,7 @@ import (
 //	| AllowanceNoData   | nodata-per-second    |
 //	| AllowanceNXDomain | nxdomains-per-second |
 //	| AllowanceError    | errors-per-second    |
+//	| AllowanceTransfer | transfers-per-second |
 //	+-------------------+----------------------+
 type AllowanceCategory uint8
 
@@ -41,9 +47,18 @@ const (
 	AllowanceNoData
 	AllowanceNXDomain
 	AllowanceError
+	AllowanceTransfer
 	AllowanceLast
 )
 
+// DNS qtypes of zone transfer requests which are only meaningful over TCP.
+const (
+	typeIXFR = 251
+	typeAXFR = 252
+)
+
+const classINET = 1
+
 // NewAllowanceCategory is a helper function which creates an AllowanceCategory
 func NewAllowanceCategory(rCode, answerCount, nsCount int) AllowanceCategory {
 	switch {
@@ -60,6 +75,32 @@ func NewAllowanceCategory(rCode, answerCount, nsCount int) AllowanceCategory {
 	return AllowanceError
 }
 
+// categoryOf returns the AllowanceCategory used to account for the tuple. This is the
+// tuple's AllowanceCategory except for zone transfer requests over UDP which are
+// accounted as AllowanceTransfer and invalid categories which are accounted as
+// AllowanceError.
+func categoryOf(src net.Addr, tuple *ResponseTuple) AllowanceCategory {
+	if tuple.AllowanceCategory >= AllowanceLast {
+		return AllowanceError
+	}
+	if (tuple.Type == typeAXFR || tuple.Type == typeIXFR) && strings.HasPrefix(src.Network(), "udp") {
+		return AllowanceTransfer
+	}
+
+	return tuple.AllowanceCategory
+}
+
+// malformedTuple is used in place of a nil ResponseTuple. It must never be modified.
+var malformedTuple = ResponseTuple{AllowanceCategory: AllowanceError}
+
+// orMalformed returns tuple or, if tuple is nil, the tuple of a malformed query.
+func orMalformed(tuple *ResponseTuple) *ResponseTuple {
+	if tuple == nil {
+		return &malformedTuple
+	}
+	return tuple
+}
+
 // Action is the resulting recommendation returned by [Debit].
 // Callers should act accordingly.
 //
@@ -78,15 +119,19 @@ const (
 // It is intended for diagnostic and statistical purposes only.
 // Callers should expect that the range of reasons may increase or change over time.
 //
-// Values are: IPOk, IPNotConfigured, IPRateLimit and IPCacheFull.
+// Values are: IPOk, IPNotConfigured, IPRateLimit, IPCacheFull, IPSoftLimit,
+// IPAggregateLimit and IPMinimum.
 type IPReason int
 
 const (
-	IPOk            IPReason = iota // IP CIDR is within rate limits
-	IPNotConfigured                 // Config entry is zero
-	IPNotReached                    // Not possible at this stage, but allow for possibility
-	IPRateLimit                     // Ran out of credits
-	IPCacheFull                     // RRL cache failed to create a new account
+	IPOk             IPReason = iota // IP CIDR is within rate limits
+	IPNotConfigured                  // Config entry is zero
+	IPNotReached                     // Not possible at this stage, but allow for possibility
+	IPRateLimit                      // Ran out of credits
+	IPCacheFull                      // RRL cache failed to create a new account
+	IPSoftLimit                      // Within rate limits but past the soft limit
+	IPAggregateLimit                 // The ipv6 aggregate network ran out of credits
+	IPMinimum                        // Ran out of credits but sent due to minimum guarantee
 	IPLast
 )
 
@@ -95,7 +140,8 @@ const (
 // It is intended for diagnostic and statistical purposes only.
 // Callers should expect that the range of reasons may increase or change over time.
 //
-// Values are: RTOk, RTNotConfigured, RTNotReached, RTRateLimit, RTNotUDP and RTCacheFull.
+// Values are: RTOk, RTNotConfigured, RTNotReached, RTRateLimit, RTNotUDP, RTCacheFull,
+// RTSoftLimit, RTMinimum, RTDisabled and RTForced.
 type RTReason int
 
 const (
@@ -105,6 +151,10 @@ const (
 	RTRateLimit                     // Ran out of credits
 	RTNotUDP                        // Debit is only applicable to UDP queries
 	RTCacheFull                     // RRL cache failed to create a new account
+	RTSoftLimit                     // Account is in credit but past the soft limit
+	RTMinimum                       // Ran out of credits but sent due to minimum guarantee
+	RTDisabled                      // Category disabled by DisableCategory
+	RTForced                        // Action latched by ForceAction
 	RTLast
 )
 
@@ -131,7 +181,8 @@ const (
 //     subset which are of most interest to rrl.
 //
 //     Values are: AllowanceAnswer, AllowanceReferral, AllowanceNoData, AllowanceNXDomain and the catchall
-//     AllowanceError when none of the other AllowanceCategorys apply.
+//     AllowanceError when none of the other AllowanceCategorys apply. AllowanceTransfer is
+//     assigned internally and should not be set by the caller.
 //     The [AllowanceCategory] type documents the rules for setting these values.
 //
 //   - SalientName the name to use for the purpose of uniquely identifying the query.
@@ -170,6 +221,19 @@ const (
 // In the very unlikely event that the response message only contains a COOKIE OPT as
 // allowed in RFC7873#5.4, none of the ResponseTuple fields should be populated except
 // [AllowanceCategory].
+//
+// ### Malformed Queries
+//
+// Responses to queries which cannot be represented by a ResponseTuple, such as queries
+// with zero or multiple question RRs, queries which fail to parse beyond the header, or
+// queries with an unknown opcode, are invariably errors such as FORMERR or NOTIMP. For
+// these responses the caller should pass a nil ResponseTuple to [Debit] and related
+// functions. A nil ResponseTuple is accounted as AllowanceError, which is identical
+// regardless of Class, Type and SalientName, so malformed queries share the error account
+// of the Client Network.
+//
+// Similarly, a ResponseTuple with an AllowanceCategory outside the defined range is
+// accounted as AllowanceError.
 type ResponseTuple struct {
 	Class uint16
 	Type  uint16
@@ -197,38 +261,184 @@ type ResponseTuple struct {
 //
 // [IPReason] and [RTReason] provide insights as to why the action was recommended.
 // They may be useful details for statistics and logging purposes.
+// If "soft-limit-percent" is configured, IPSoftLimit and RTSoftLimit are returned with a
+// Send action to warn that an account is approaching its limit.
 //
 // Debit is concurrency safe.
 func (rrl *RRL) Debit(src net.Addr, tuple *ResponseTuple) (act Action, ipr IPReason, rtr RTReason) {
+	return rrl.DebitTagged("", src, tuple)
+}
+
+// DebitV is identical to [Debit] except that the Response Tuple is passed by value. No
+// reference to the tuple is retained by any of the Debit functions, so a ResponseTuple
+// passed to DebitV remains on the caller's stack. High-QPS callers can thus reuse or
+// stack allocate tuples rather than creating one on the heap for each query.
+//
+// DebitV is concurrency safe.
+func (rrl *RRL) DebitV(src net.Addr, tuple ResponseTuple) (act Action, ipr IPReason, rtr RTReason) {
+	return rrl.DebitTagged("", src, &tuple)
+}
+
+// DebitTagged is identical to [Debit] except that the debit carries an opaque tag, such
+// as a listener name, interface or view. Non-empty tags are propagated into the
+// per-tag statistics returned by [RRL.GetTagStats] and into exported events so that
+// multi-homed servers can attribute attack traffic to the interface or anycast instance
+// it arrived on.
+//
+// Per-tag statistics are retained for every distinct tag so tags should be drawn from a
+// small, fixed set of values. Never use values derived from the request as a tag.
+//
+// DebitTagged is concurrency safe.
+func (rrl *RRL) DebitTagged(tag string, src net.Addr, tuple *ResponseTuple) (act Action, ipr IPReason, rtr RTReason) {
 	act = Send
 	ipr = IPNotConfigured
 	rtr = RTNotReached
+	tuple = orMalformed(tuple)
 
 	// Must use pointers to return values as otherwise defer takes a copy of the
 	// values at the defer call site, which is as they are now rather than at the end
 	// of the function. This is common knowledge, but easily forgotten.
 
-	defer rrl.incrementDebitStats(&act, &ipr, &rtr, tuple.AllowanceCategory)
+	ipPrefix, aggPrefix := rrl.addrPrefixes(src.String()) // Need this for both rate limiting tests
+
+	ac := categoryOf(src, tuple)
+	defer rrl.incrementDebitStats(tag, ipPrefix, &act, &ipr, &rtr, ac)
 
-	ipPrefix := rrl.addrPrefix(src.String()) // Need this for both rate limiting tests
+	act, ipr = rrl.debitRequest(tag, src.Network(), ipPrefix, aggPrefix)
+	if act == Send {
+		act, rtr = rrl.debitResponse(tag, src, ipPrefix, tuple)
+	}
+	if act != Send {
+		rrl.recordDecision(tag, ipPrefix, aggPrefix, tuple, ac, NewDecision(act, ipr, rtr))
+	}
+
+	return
+}
+
+// DebitRequest is the request-stage half of [Debit]. It decrements the source address
+// "accounts" associated with the Client Network of src and returns a recommended action.
+// DebitRequest is intended to be called as soon as a request is received and before any
+// database lookups so that requests-per-second limiting occurs prior to the caller
+// expending effort on formulating a response.
+//
+// If the returned [Action] is Send, the caller should subsequently call [DebitResponse]
+// once the response has been formulated. Otherwise the caller should act on the
+// returned [Action] and not call [DebitResponse].
+//
+// In terms of statistics, DebitRequest counts the [IPReason] and, if the request is not
+// to be sent, the [Action]. [DebitResponse] counts the remaining statistics so the
+// combination results in the same statistics as [Debit], with the exception that
+// RTNotReached and the [AllowanceCategory] are not counted for dropped requests as the
+// response is unknown at that stage.
+//
+// DebitRequest is concurrency safe.
+func (rrl *RRL) DebitRequest(src net.Addr) (act Action, ipr IPReason) {
+	ipPrefix, aggPrefix := rrl.addrPrefixes(src.String())
+	act, ipr = rrl.debitRequest("", src.Network(), ipPrefix, aggPrefix)
+	rrl.incrementRequestStats(ipPrefix, act, ipr)
+	if act != Send {
+		rrl.recordDecision("", ipPrefix, aggPrefix, nil, AllowanceLast, NewDecision(act, ipr, RTNotReached))
+	}
+
+	return
+}
+
+// DebitResponse is the response-stage half of [Debit]. It decrements the "account"
+// associated with the Client Network of src and the "Response Tuple" and returns a
+// recommended action. DebitResponse should only be called if [DebitRequest] returned a
+// Send [Action] for the same request.
+//
+// DebitResponse is concurrency safe.
+func (rrl *RRL) DebitResponse(src net.Addr, tuple *ResponseTuple) (act Action, rtr RTReason) {
+	tuple = orMalformed(tuple)
+	ipPrefix := rrl.addrPrefix(src.String())
+	act, rtr = rrl.debitResponse("", src, ipPrefix, tuple)
+	ac := categoryOf(src, tuple)
+	rrl.incrementResponseStats(ipPrefix, act, rtr, ac)
+	if act != Send {
+		rrl.recordDecision("", ipPrefix, "", tuple, ac, NewDecision(act, IPOk, rtr))
+	}
+
+	return
+}
+
+// debitRequest applies the source address rate limits to the Client Network and
+// aggregate network. network is the transport of the request and tag is only used for
+// events.
+func (rrl *RRL) debitRequest(tag, network, ipPrefix, aggPrefix string) (act Action, ipr IPReason) {
+	act = Send
+	ipr = IPNotConfigured
+
+	rrl.checkDegraded()
+	rrl.calibrate(ipPrefix, AllowanceLast)
+	rrl.addNetwork(ipPrefix)
+
+	penalized := rrl.penalizedNetwork(ipPrefix)
 
 	// Rate limit on a source-address basis regardless of whether it's TCP or UDP
-	if rrl.cfg.requestsInterval != 0 {
-		b, _, err := rrl.debit(rrl.cfg.requestsInterval, ipPrefix) // ignore slip for IP limits
+	if requests := rrl.requestsAllowance(ipPrefix); requests != 0 {
+		allowance := rrl.applyReputation(rrl.applyPressure(requests), ipPrefix)
+		if penalized {
+			allowance = penaltyAllowance(rrl.cfg.window)
+		}
+		// ignore slip for IP limits
+		b, _, err := rrl.debit(rrl.table, allowance, rrl.cfg.window, rrl.cfg.recovery,
+			rrl.requestsToken(network, ipPrefix), tag)
 		if err != nil {
-			act = Drop
+			act = rrl.cacheFullAction()
 			ipr = IPCacheFull
 			return
 		}
+		if penalized && b >= 0 {
+			b = -rrl.cfg.window // New accounts are otherwise sent their first response
+		}
 		// if the balance is negative, drop the request (don't write response to client)
 		if b < 0 {
 			act = Drop
 			ipr = IPRateLimit
+			if !penalized && rrl.minimumGuaranteed(tag, ipPrefix) {
+				act = Send
+				ipr = IPMinimum
+			}
 			return
 		}
 		ipr = IPOk
+		if b < rrl.cfg.softLimitBalance {
+			ipr = IPSoftLimit
+		}
 	}
 
+	// Rate limit the coarser ipv6 aggregate network which contains the source address
+	if len(aggPrefix) > 0 {
+		b, _, err := rrl.debit(rrl.table, rrl.applyPressure(rrl.cfg.ipv6AggregateInterval), rrl.cfg.window,
+			rrl.cfg.recovery,
+			aggPrefix, tag)
+		if err != nil {
+			act = rrl.cacheFullAction()
+			ipr = IPCacheFull
+			return
+		}
+		if b < 0 {
+			act = Drop
+			ipr = IPAggregateLimit
+			if rrl.minimumGuaranteed(tag, ipPrefix) {
+				act = Send
+				ipr = IPMinimum
+			}
+			return
+		}
+		if ipr == IPNotConfigured {
+			ipr = IPOk
+		}
+	}
+
+	return
+}
+
+// debitResponse applies the "Response Tuple" rate limits. tag is only used for events.
+func (rrl *RRL) debitResponse(tag string, src net.Addr, ipPrefix string, tuple *ResponseTuple) (act Action, rtr RTReason) {
+	act = Send
+
 	// RRL on query only applies to udp. All other transports are assumed to be
 	// resistant to source address spoofing. Filter on all types of udp, such as udp,
 	// udp4 & udp6.
@@ -237,36 +447,214 @@ func (rrl *RRL) Debit(src net.Addr, tuple *ResponseTuple) (act Action, ipr IPRea
 		return
 	}
 
-	allowance := rrl.allowanceForRtype(tuple.AllowanceCategory) // What is the configured cost for this query type?
-	if allowance == 0 {
+	rrl.addName(tuple.SalientName)
+
+	ac := categoryOf(src, tuple)
+	if forced, ok := rrl.forcedAction(ac); ok {
+		act, rtr = forced, RTForced
+		return
+	}
+	if rrl.CategoryDisabled(ac) {
+		rtr = RTDisabled
+		return
+	}
+	allowance := rrl.allowanceForRtype(ac, ipPrefix) // What is the configured cost for this query type?
+	if allowance == 0 && rrl.calibration == nil {
 		rtr = RTNotConfigured
 		return
 	}
 
 	// Insulate against unbound/use-caps-for-id et al when generating cache key
-	name := strings.ToLower(tuple.SalientName)
-	t := rrl.accountToken(ipPrefix, tuple.Type, name, tuple.AllowanceCategory)
+	name := rrl.lowerName(tuple.SalientName)
+	t := rrl.accountToken(ipPrefix, tuple.Class, tuple.Type, name, ac)
+	rrl.calibrate(t, ac)
+	if allowance == 0 {
+		rtr = RTNotConfigured
+		return
+	}
+
+	allowance = rrl.applyReputation(rrl.applyPressure(allowance), ipPrefix)
+	penalized := rrl.penalizedNetwork(ipPrefix) || rrl.penalizedName(name)
+	if penalized {
+		allowance = penaltyAllowance(rrl.windowFor(ac))
+	}
+
+	// A recent Drop decision shows the account cannot have recovered, so skip the account
+	if cd := rrl.cachedDrop(ipPrefix, tuple, ac); cd != nil {
+		act, rtr = rrl.slipOrDrop(cd.slip(rrl.cfg.slipRatio)), RTRateLimit
+		return
+	}
 
 	// Debit account and get results
-	b, slip, err := rrl.debit(allowance, t)
+	b, slip, err := rrl.debit(rrl.tableFor(ac), allowance, rrl.windowFor(ac), rrl.recoveryFor(ac), t, tag)
 	if err != nil {
-		act = Drop
+		act = rrl.cacheFullAction()
 		rtr = RTCacheFull
 		return
 	}
+	if penalized && b >= 0 {
+		b = -rrl.windowFor(ac) // New accounts are otherwise sent their first response
+	}
 
 	// If the balance is negative, rate limit the response
 	if b < 0 {
 		rtr = RTRateLimit
-		if slip {
-			act = Slip
-		} else {
-			act = Drop
+		if !penalized && rrl.minimumGuaranteed(tag, ipPrefix) {
+			rtr = RTMinimum
+			return
+		}
+		act = rrl.slipOrDrop(slip)
+		if act == Drop && rrl.cfg.minimumInterval == 0 {
+			rrl.cacheDrop(rrl.tableFor(ac), t, ipPrefix, tuple, ac, b, rrl.windowFor(ac))
 		}
 		return
 	}
 
 	rtr = RTOk // Yeah, we're all good to go
+	if b < rrl.cfg.softLimitBalance {
+		rtr = RTSoftLimit // But getting close
+	}
+
+	return
+}
+
+// slipOrDrop returns the Action for a rate limited response. A response selected to slip
+// by the slip countdown of its account is downgraded to a Drop once
+// max-slips-per-second is exhausted.
+func (rrl *RRL) slipOrDrop(slip bool) Action {
+	if !slip {
+		return Drop
+	}
+	if rrl.slips.Allow() {
+		return Slip
+	}
+	rrl.incrementSlipDowngrade()
+
+	return Drop
+}
+
+// minimumGuaranteed returns true if the Client Network is still within its
+// minimum-responses-per-second guarantee, in which case a rate-limited response should be
+// sent regardless. The guarantee account is only debited for responses which would
+// otherwise be rate-limited.
+func (rrl *RRL) minimumGuaranteed(tag, ipPrefix string) bool {
+	if rrl.cfg.minimumInterval == 0 {
+		return false
+	}
+	b, _, err := rrl.debit(rrl.table, rrl.cfg.minimumInterval, rrl.cfg.window, recoveryLinear,
+		ipPrefix+"/min", tag)
+	if err != nil {
+		rrl.cacheFullAction() // Only for accounting as the guarantee cannot be honoured
+		return false
+	}
+
+	return b >= 0
+}
+
+// CheapCheck consults the source address rate limits to determine whether a request from
+// src would be dropped by [Debit]. It is intended to be called as soon as a request is
+// received and before the caller spends any effort parsing and resolving the query, so
+// that load can be shed as early as possible during request floods.
+//
+// CheapCheck returns Drop if the Client Network or ipv6 aggregate network of src has
+// already exhausted its credits, otherwise it returns Send.
+// No accounts are debited and no statistics are updated, so callers which proceed with
+// the request should still call [Debit] once the response is formulated.
+//
+// CheapCheck is concurrency safe.
+func (rrl *RRL) CheapCheck(src net.Addr) Action {
+	ipPrefix, aggPrefix := rrl.addrPrefixes(src.String())
+	if requests := rrl.requestsAllowance(ipPrefix); requests != 0 {
+		if rrl.penalizedNetwork(ipPrefix) {
+			return Drop
+		}
+		if b, found := rrl.balance(rrl.table, rrl.applyReputation(rrl.applyPressure(requests), ipPrefix),
+			rrl.requestsToken(src.Network(), ipPrefix)); found && b < 0 {
+			return Drop
+		}
+	}
+	if len(aggPrefix) > 0 {
+		if b, found := rrl.balance(rrl.table, rrl.applyPressure(rrl.cfg.ipv6AggregateInterval), aggPrefix); found && b < 0 {
+			return Drop
+		}
+	}
+
+	return Send
+}
+
+// Checker is the interface offered to response caching layers of the embedding server.
+// It allows them to ask whether a response would currently be rate-limited so they can
+// skip cache lookups entirely for penalized accounts or mark cache entries with their
+// limiting state. [RRL] implements Checker.
+type Checker interface {
+	Check(src net.Addr, tuple *ResponseTuple) (Action, IPReason, RTReason)
+}
+
+// Check returns the [Action] [Debit] would likely recommend for src and tuple, without
+// debiting any accounts or updating any statistics. Rate-limited responses are always
+// reported as Drop since whether a response slips is only determined by [Debit].
+// Similarly minimum-responses-per-second is not considered.
+//
+// Accounts which do not yet exist are reported as Send with RTOk (or IPOk) as they will
+// be created with a full credit by [Debit].
+// Configured limits of Client Networks and SalientNames penalized by [RRL.Penalize] and
+// [RRL.PenalizeName] are reported as Drop.
+//
+// Check is concurrency safe.
+func (rrl *RRL) Check(src net.Addr, tuple *ResponseTuple) (act Action, ipr IPReason, rtr RTReason) {
+	act = Send
+	ipr = IPNotConfigured
+	rtr = RTNotReached
+	tuple = orMalformed(tuple)
+
+	ipPrefix, aggPrefix := rrl.addrPrefixes(src.String())
+	penalized := rrl.penalizedNetwork(ipPrefix)
+	if requests := rrl.requestsAllowance(ipPrefix); requests != 0 {
+		ipr = IPOk
+		b, found := rrl.balance(rrl.table, rrl.applyReputation(rrl.applyPressure(requests), ipPrefix),
+			rrl.requestsToken(src.Network(), ipPrefix))
+		if penalized || (found && b < 0) {
+			act = Drop
+			ipr = IPRateLimit
+			return
+		}
+	}
+	if len(aggPrefix) > 0 {
+		ipr = IPOk
+		if b, found := rrl.balance(rrl.table, rrl.applyPressure(rrl.cfg.ipv6AggregateInterval), aggPrefix); found && b < 0 {
+			act = Drop
+			ipr = IPAggregateLimit
+			return
+		}
+	}
+
+	if !strings.HasPrefix(src.Network(), "udp") {
+		rtr = RTNotUDP
+		return
+	}
+	ac := categoryOf(src, tuple)
+	if forced, ok := rrl.forcedAction(ac); ok {
+		act, rtr = forced, RTForced
+		return
+	}
+	if rrl.CategoryDisabled(ac) {
+		rtr = RTDisabled
+		return
+	}
+	allowance := rrl.allowanceForRtype(ac, ipPrefix)
+	if allowance == 0 {
+		rtr = RTNotConfigured
+		return
+	}
+
+	name := rrl.lowerName(tuple.SalientName)
+	t := rrl.accountToken(ipPrefix, tuple.Class, tuple.Type, name, ac)
+	rtr = RTOk
+	b, found := rrl.balance(rrl.tableFor(ac), rrl.applyReputation(rrl.applyPressure(allowance), ipPrefix), t)
+	if penalized || rrl.penalizedName(name) || (found && b < 0) {
+		act = Drop
+		rtr = RTRateLimit
+	}
 
 	return
 }
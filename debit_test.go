@@ -2,6 +2,8 @@ package rrl_test
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -383,3 +385,555 @@ func TestDebitWindow(t *testing.T) {
 		t.Fatal("Clock+ 3s gave too many credits")
 	}
 }
+
+// Check that soft limits warn while still sending
+func TestDebitSoftLimit(t *testing.T) {
+	cfg := rrl.NewConfig()
+	cfg.SetValue("responses-per-second", "10") // Each Debit costs 100ms of credit
+	cfg.SetValue("requests-per-second", "10")
+	cfg.SetValue("soft-limit-percent", "80") // Warn when less than 200ms credit remains
+	cfg.SetValue("slip-ratio", "0")
+	cfg.SetNowFunc(func() time.Time {
+		return time.Time{}
+	})
+	R := rrl.NewRRL(cfg)
+
+	src := newAddr("udp", "127.0.0.1:53")
+	tuple := newTuple(1, 1, "example.com.", rrl.AllowanceAnswer)
+	for ix := 0; ix < 8; ix++ {
+		act, ipr, rtr := R.Debit(src, tuple)
+		if act != rrl.Send || ipr != rrl.IPOk || rtr != rrl.RTOk {
+			t.Fatal(ix, "Expected Send, IPOk & RTOk, not", act, ipr, rtr)
+		}
+	}
+	for ix := 8; ix < 10; ix++ {
+		act, ipr, rtr := R.Debit(src, tuple)
+		if act != rrl.Send || ipr != rrl.IPSoftLimit || rtr != rrl.RTSoftLimit {
+			t.Fatal(ix, "Expected Send, IPSoftLimit & RTSoftLimit, not", act, ipr, rtr)
+		}
+	}
+	act, ipr, rtr := R.Debit(src, tuple)
+	if act != rrl.Drop || ipr != rrl.IPRateLimit {
+		t.Error("Expected Drop & IPRateLimit after soft limit, not", act, ipr, rtr)
+	}
+
+	c := R.GetStats(false)
+	if c.IPReasons[rrl.IPSoftLimit] != 2 || c.RTReasons[rrl.RTSoftLimit] != 2 {
+		t.Error("Expected soft limit stats of 2/2, not", c.String())
+	}
+}
+
+// At rates below one per second the first response of a new account is still sent
+func TestDebitSlowRate(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cfg := rrl.NewConfig()
+	cfg.SetValue("responses-per-second", "0.5") // Each Debit costs 2s of credit
+	cfg.SetValue("slip-ratio", "0")
+	cfg.SetNowFunc(func() time.Time { return now })
+	R := rrl.NewRRL(cfg)
+
+	src := newAddr("udp", "127.0.0.1:53")
+	tuple := newTuple(1, 1, "example.com.", rrl.AllowanceAnswer)
+	if act, _, rtr := R.Debit(src, tuple); act != rrl.Send || rtr != rrl.RTOk {
+		t.Fatal("First response should be sent, not", act, rtr)
+	}
+	if act, _, _ := R.Debit(src, tuple); act != rrl.Drop {
+		t.Error("Second response within the interval should be dropped, not", act)
+	}
+}
+
+// Hopping across /64s within a /48 should exhaust the aggregate account
+func TestDebitIPv6Aggregate(t *testing.T) {
+	cfg := rrl.NewConfig()
+	cfg.SetValue("ipv6-prefix-length", "64")
+	cfg.SetValue("requests-per-second", "10")
+	cfg.SetValue("ipv6-aggregate-prefix-length", "48")
+	cfg.SetValue("ipv6-aggregate-requests-per-second", "20")
+	cfg.SetNowFunc(func() time.Time {
+		return time.Time{}
+	})
+	R := rrl.NewRRL(cfg)
+
+	tuple := newTuple(1, 1, "example.com.", rrl.AllowanceAnswer)
+	for ix := 0; ix < 20; ix++ {
+		src := newAddr("udp", fmt.Sprintf("[2001:db8:0:%x::1]:53", ix))
+		act, ipr, _ := R.Debit(src, tuple)
+		if act != rrl.Send || ipr != rrl.IPOk {
+			t.Fatal(ix, "Expected Send & IPOk, not", act, ipr)
+		}
+	}
+
+	src := newAddr("udp", "[2001:db8:0:ffff::1]:53") // A fresh /64 in the same /48
+	act, ipr, _ := R.Debit(src, tuple)
+	if act != rrl.Drop || ipr != rrl.IPAggregateLimit {
+		t.Error("Expected Drop & IPAggregateLimit, not", act, ipr)
+	}
+
+	src = newAddr("udp", "[2001:db8:1::1]:53") // A different /48 is unaffected
+	act, ipr, _ = R.Debit(src, tuple)
+	if act != rrl.Send || ipr != rrl.IPOk {
+		t.Error("Expected Send & IPOk from different /48, not", act, ipr)
+	}
+}
+
+func TestCheapCheck(t *testing.T) {
+	cfg := rrl.NewConfig()
+	cfg.SetValue("requests-per-second", "2")
+	cfg.SetNowFunc(func() time.Time {
+		return time.Time{}
+	})
+	R := rrl.NewRRL(cfg)
+
+	src := newAddr("udp", "127.0.0.1:53")
+	tuple := newTuple(1, 1, "example.com.", rrl.AllowanceAnswer)
+	if act := R.CheapCheck(src); act != rrl.Send {
+		t.Fatal("CheapCheck should Send for an unknown source, not", act)
+	}
+	R.Debit(src, tuple)
+	if act := R.CheapCheck(src); act != rrl.Send {
+		t.Fatal("CheapCheck should Send while in credit, not", act)
+	}
+	R.Debit(src, tuple)
+	if act := R.CheapCheck(src); act != rrl.Drop {
+		t.Error("CheapCheck should Drop once credits are exhausted, not", act)
+	}
+
+	c := R.GetStats(false)
+	if c.Actions[rrl.Send] != 2 || c.Actions[rrl.Drop] != 0 {
+		t.Error("CheapCheck should not affect stats", c.String())
+	}
+}
+
+// Check that the split API matches the combined Debit
+func TestDebitRequestResponse(t *testing.T) {
+	cfg := rrl.NewConfig()
+	cfg.SetValue("requests-per-second", "2")
+	cfg.SetValue("responses-per-second", "1")
+	cfg.SetValue("slip-ratio", "0")
+	cfg.SetNowFunc(func() time.Time {
+		return time.Time{}
+	})
+	R := rrl.NewRRL(cfg)
+
+	src := newAddr("udp", "127.0.0.1:53")
+	tuple := newTuple(1, 1, "example.com.", rrl.AllowanceAnswer)
+
+	act, ipr := R.DebitRequest(src)
+	if act != rrl.Send || ipr != rrl.IPOk {
+		t.Fatal("First DebitRequest should be Send & IPOk, not", act, ipr)
+	}
+	act, rtr := R.DebitResponse(src, tuple)
+	if act != rrl.Send || rtr != rrl.RTOk {
+		t.Fatal("First DebitResponse should be Send & RTOk, not", act, rtr)
+	}
+
+	act, ipr = R.DebitRequest(src)
+	if act != rrl.Send || ipr != rrl.IPOk {
+		t.Fatal("Second DebitRequest should be Send & IPOk, not", act, ipr)
+	}
+	act, rtr = R.DebitResponse(src, tuple)
+	if act != rrl.Drop || rtr != rrl.RTRateLimit {
+		t.Fatal("Second DebitResponse should be Drop & RTRateLimit, not", act, rtr)
+	}
+
+	act, ipr = R.DebitRequest(src)
+	if act != rrl.Drop || ipr != rrl.IPRateLimit {
+		t.Fatal("Third DebitRequest should be Drop & IPRateLimit, not", act, ipr)
+	}
+
+	c := R.GetStats(false)
+	exp := "RPS 2/0/0/0/0/0 Actions 1/2/0 IPR 2/0/0/1/0/0/0/0 RTR 1/0/0/1/0/0/0/0/0/0 L=2/0 U=0/0 SD=0"
+	if got := c.String(); got != exp {
+		t.Error("Stats expected", exp, "got", got)
+	}
+}
+
+// Check that minimum-responses-per-second overrides rate limiting
+func TestDebitMinimum(t *testing.T) {
+	cfg := rrl.NewConfig()
+	cfg.SetValue("responses-per-second", "1")
+	cfg.SetValue("minimum-responses-per-second", "3")
+	cfg.SetValue("slip-ratio", "0")
+	cfg.SetNowFunc(func() time.Time {
+		return time.Time{}
+	})
+	R := rrl.NewRRL(cfg)
+
+	src := newAddr("udp", "127.0.0.1:53")
+	tuple := newTuple(1, 1, "example.com.", rrl.AllowanceAnswer)
+	act, _, rtr := R.Debit(src, tuple)
+	if act != rrl.Send || rtr != rrl.RTOk {
+		t.Fatal("First Debit should be Send & RTOk, not", act, rtr)
+	}
+	for ix := 0; ix < 3; ix++ {
+		act, _, rtr = R.Debit(src, tuple)
+		if act != rrl.Send || rtr != rrl.RTMinimum {
+			t.Fatal(ix, "Expected Send & RTMinimum, not", act, rtr)
+		}
+	}
+	act, _, rtr = R.Debit(src, tuple)
+	if act != rrl.Drop || rtr != rrl.RTRateLimit {
+		t.Error("Expected Drop & RTRateLimit once minimum is consumed, not", act, rtr)
+	}
+
+	// Requests limits are also subject to the minimum
+	cfg = rrl.NewConfig()
+	cfg.SetValue("requests-per-second", "1")
+	cfg.SetValue("minimum-responses-per-second", "1")
+	cfg.SetNowFunc(func() time.Time {
+		return time.Time{}
+	})
+	R = rrl.NewRRL(cfg)
+	R.Debit(src, tuple)
+	act, ipr, _ := R.Debit(src, tuple)
+	if act != rrl.Send || ipr != rrl.IPMinimum {
+		t.Error("Expected Send & IPMinimum, not", act, ipr)
+	}
+	act, ipr, _ = R.Debit(src, tuple)
+	if act != rrl.Drop || ipr != rrl.IPRateLimit {
+		t.Error("Expected Drop & IPRateLimit once minimum is consumed, not", act, ipr)
+	}
+}
+
+// An NXDomain flood should not affect answers when the table is partitioned
+func TestDebitPartitionedTable(t *testing.T) {
+	cfg := rrl.NewConfig()
+	cfg.SetValue("responses-per-second", "1")
+	cfg.SetValue("nxdomains-table-size", "1") // Becomes 4 per shard
+	cfg.SetNowFunc(func() time.Time {
+		return time.Time{}
+	})
+	R := rrl.NewRRL(cfg)
+
+	src := newAddr("udp", "127.0.0.1:53")
+	var rtr rrl.RTReason
+	for ix := 0; ix < 2000 && rtr != rrl.RTCacheFull; ix++ {
+		_, _, rtr = R.Debit(src, newTuple(1, 1, fmt.Sprintf("%d.example.com.", ix), rrl.AllowanceNXDomain))
+	}
+	if rtr != rrl.RTCacheFull {
+		t.Fatal("Expected NXDomain partition to fill, not", rtr)
+	}
+	nxLen := R.GetStats(false).CacheLength
+
+	act, _, rtr := R.Debit(src, newTuple(1, 1, "example.com.", rrl.AllowanceAnswer))
+	if act != rrl.Send || rtr != rrl.RTOk {
+		t.Error("Answer should be unaffected by NXDomain flood, not", act, rtr)
+	}
+	if l := R.GetStats(false).CacheLength; l != nxLen+1 {
+		t.Error("CacheLength should include all partitions", nxLen, l)
+	}
+}
+
+// Check that max-slips-per-second downgrades excess slips to drops
+func TestDebitMaxSlips(t *testing.T) {
+	cfg := rrl.NewConfig()
+	cfg.SetValue("responses-per-second", "1")
+	cfg.SetValue("slip-ratio", "1") // Every rate-limited response slips
+	cfg.SetValue("max-slips-per-second", "2")
+	cfg.SetNowFunc(func() time.Time {
+		return time.Time{}
+	})
+	R := rrl.NewRRL(cfg)
+
+	src := newAddr("udp", "127.0.0.1:53")
+	tuple := newTuple(1, 1, "example.com.", rrl.AllowanceAnswer)
+	R.Debit(src, tuple) // Consume the credit
+	for ix := 0; ix < 2; ix++ {
+		if act, _, _ := R.Debit(src, tuple); act != rrl.Slip {
+			t.Fatal(ix, "Expected Slip within max-slips-per-second, not", act)
+		}
+	}
+	act, _, rtr := R.Debit(src, tuple)
+	if act != rrl.Drop || rtr != rrl.RTRateLimit {
+		t.Error("Expected Slip to be downgraded to Drop, not", act, rtr)
+	}
+	if c := R.GetStats(false); c.SlipDowngrades != 1 {
+		t.Error("Expected one SlipDowngrade, not", c.String())
+	}
+}
+
+func TestCheck(t *testing.T) {
+	cfg := rrl.NewConfig()
+	cfg.SetValue("responses-per-second", "1")
+	cfg.SetValue("slip-ratio", "1")
+	cfg.SetNowFunc(func() time.Time {
+		return time.Time{}
+	})
+	R := rrl.NewRRL(cfg)
+	var checker rrl.Checker = R
+
+	src := newAddr("udp", "127.0.0.1:53")
+	tuple := newTuple(1, 1, "example.com.", rrl.AllowanceAnswer)
+	for ix := 0; ix < 3; ix++ { // Repeated checks must not debit
+		act, ipr, rtr := checker.Check(src, tuple)
+		if act != rrl.Send || ipr != rrl.IPNotConfigured || rtr != rrl.RTOk {
+			t.Fatal(ix, "Check of new account should be Send, IPNotConfigured & RTOk, not", act, ipr, rtr)
+		}
+	}
+	R.Debit(src, tuple)
+	R.Debit(src, tuple)
+	act, _, rtr := checker.Check(src, tuple)
+	if act != rrl.Drop || rtr != rrl.RTRateLimit {
+		t.Error("Check of limited account should be Drop & RTRateLimit, not", act, rtr)
+	}
+	act, _, rtr = checker.Check(newAddr("tcp", "127.0.0.1:53"), tuple)
+	if act != rrl.Send || rtr != rrl.RTNotUDP {
+		t.Error("Check of TCP should be Send & RTNotUDP, not", act, rtr)
+	}
+	if c := R.GetStats(false); c.Actions[rrl.Send]+c.Actions[rrl.Slip] != 2 {
+		t.Error("Check should not affect stats", c.String())
+	}
+}
+
+// Check that a per-category window allows a longer penalty for that category
+func TestDebitCategoryWindow(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cfg := rrl.NewConfig()
+	cfg.SetValue("responses-per-second", "1")
+	cfg.SetValue("nxdomains-window", "60")
+	cfg.SetNowFunc(func() time.Time {
+		return now
+	})
+	R := rrl.NewRRL(cfg)
+
+	src := newAddr("udp", "127.0.0.1:53")
+	answer := newTuple(1, 1, "example.com.", rrl.AllowanceAnswer)
+	nxdomain := newTuple(1, 1, "example.com.", rrl.AllowanceNXDomain)
+	for ix := 0; ix < 100; ix++ { // Drive both accounts to their most negative balance
+		R.Debit(src, answer)
+		R.Debit(src, nxdomain)
+	}
+
+	now = now.Add(20 * time.Second) // Past the default window but not nxdomains-window
+	if act, _, rtr := R.Debit(src, answer); act != rrl.Send {
+		t.Error("Answer should have recovered after default window", act, rtr)
+	}
+	if act, _, rtr := R.Debit(src, nxdomain); act == rrl.Send {
+		t.Error("NXDomain should still be penalized within nxdomains-window", act, rtr)
+	}
+
+	now = now.Add(60 * time.Second)
+	if act, _, rtr := R.Debit(src, nxdomain); act != rrl.Send {
+		t.Error("NXDomain should have recovered after nxdomains-window", act, rtr)
+	}
+}
+
+// Check that tagged debits are broken down by tag
+func TestDebitTagged(t *testing.T) {
+	cfg := rrl.NewConfig()
+	cfg.SetValue("responses-per-second", "1")
+	cfg.SetNowFunc(func() time.Time {
+		return time.Time{}
+	})
+	R := rrl.NewRRL(cfg)
+
+	src := newAddr("udp", "127.0.0.1:53")
+	tuple := newTuple(1, 1, "example.com.", rrl.AllowanceAnswer)
+	R.DebitTagged("eth0", src, tuple)
+	R.DebitTagged("eth0", src, tuple)
+	R.DebitTagged("eth1", src, tuple)
+	R.Debit(src, tuple)
+
+	ts := R.GetTagStats(true)
+	if len(ts) != 2 {
+		t.Fatal("Expected two tags, got", ts)
+	}
+	if s := ts["eth0"]; s.Actions[rrl.Send] != 1 || s.RTReasons[rrl.RTRateLimit] != 1 {
+		t.Error("Unexpected eth0 stats", s.String())
+	}
+	if s := ts["eth1"]; s.RPS[rrl.AllowanceAnswer] != 1 || s.RTReasons[rrl.RTRateLimit] != 1 {
+		t.Error("Unexpected eth1 stats", s.String())
+	}
+	if s := R.GetStats(false); s.RPS[rrl.AllowanceAnswer] != 4 {
+		t.Error("Tagged debits should also be in global stats", s.String())
+	}
+	if s := R.GetTagStats(false)["eth0"]; s.RPS[rrl.AllowanceAnswer] != 0 {
+		t.Error("GetTagStats(true) should have zeroed tag stats", s.String())
+	}
+}
+
+// Check that zone transfer probes over UDP are accounted separately from other errors
+func TestDebitTransferProbe(t *testing.T) {
+	cfg := rrl.NewConfig()
+	cfg.SetValue("errors-per-second", "10")
+	cfg.SetValue("transfers-per-second", "1")
+	cfg.SetNowFunc(func() time.Time {
+		return time.Time{}
+	})
+	R := rrl.NewRRL(cfg)
+
+	udp := newAddr("udp", "127.0.0.1:53")
+	axfr := newTuple(1, 252, "example.com.", rrl.AllowanceError)
+	ixfr := newTuple(1, 251, "example.net.", rrl.AllowanceError)
+	if act, _, rtr := R.Debit(udp, axfr); act != rrl.Send || rtr != rrl.RTOk {
+		t.Error("First AXFR should be sent, not", act, rtr)
+	}
+	if act, _, rtr := R.Debit(udp, ixfr); act == rrl.Send || rtr != rrl.RTRateLimit {
+		t.Error("IXFR should share the exhausted transfer account, not", act, rtr)
+	}
+
+	// Regular errors are unaffected by the probes
+	for ix := 0; ix < 5; ix++ {
+		if act, _, rtr := R.Debit(udp, newTuple(1, 1, "example.com.", rrl.AllowanceError)); act != rrl.Send {
+			t.Fatal("Error", ix, "should be sent, not", act, rtr)
+		}
+	}
+
+	// Transfers over TCP are not probes
+	if act, _, rtr := R.Debit(newAddr("tcp", "127.0.0.1:53"), axfr); act != rrl.Send || rtr != rrl.RTNotUDP {
+		t.Error("TCP AXFR should be sent, not", act, rtr)
+	}
+
+	stats := R.GetStats(false)
+	if stats.RPS[rrl.AllowanceTransfer] != 2 || stats.RPS[rrl.AllowanceError] != 6 {
+		t.Error("Unexpected RPS", stats.RPS)
+	}
+}
+
+// Check that include-class separates non-ClassINET accounts from ClassINET accounts
+func TestDebitIncludeClass(t *testing.T) {
+	for _, include := range []bool{false, true} {
+		cfg := rrl.NewConfig()
+		cfg.SetValue("responses-per-second", "1")
+		cfg.SetValue("include-class", fmt.Sprint(include))
+		cfg.SetNowFunc(func() time.Time {
+			return time.Time{}
+		})
+		R := rrl.NewRRL(cfg)
+
+		src := newAddr("udp", "127.0.0.1:53")
+		R.Debit(src, newTuple(3, 16, "example.com.", rrl.AllowanceAnswer)) // CHAOS
+		act, _, rtr := R.Debit(src, newTuple(1, 16, "example.com.", rrl.AllowanceAnswer))
+		if include && (act != rrl.Send || rtr != rrl.RTOk) {
+			t.Error("ClassINET should not share the CHAOS account, not", act, rtr)
+		}
+		if !include && rtr != rrl.RTRateLimit {
+			t.Error("Without include-class, classes should share an account, not", act, rtr)
+		}
+	}
+}
+
+// Check that nil tuples and invalid categories are accounted as AllowanceError
+func TestDebitMalformed(t *testing.T) {
+	cfg := rrl.NewConfig()
+	cfg.SetValue("responses-per-second", "10")
+	cfg.SetValue("errors-per-second", "1")
+	cfg.SetNowFunc(func() time.Time {
+		return time.Time{}
+	})
+	R := rrl.NewRRL(cfg)
+
+	src := newAddr("udp", "127.0.0.1:53")
+	if act, _, rtr := R.Debit(src, nil); act != rrl.Send || rtr != rrl.RTOk {
+		t.Error("First malformed response should be sent, not", act, rtr)
+	}
+	if act, _, rtr := R.Debit(src, newTuple(1, 1, "example.com.", rrl.AllowanceLast+7)); act == rrl.Send || rtr != rrl.RTRateLimit {
+		t.Error("Invalid category should share the exhausted error account, not", act, rtr)
+	}
+	if act, rtr := R.DebitResponse(src, nil); rtr != rrl.RTRateLimit {
+		t.Error("DebitResponse should accept a nil tuple", act, rtr)
+	}
+	if act, _, rtr := R.Check(src, nil); act != rrl.Drop || rtr != rrl.RTRateLimit {
+		t.Error("Check should accept a nil tuple", act, rtr)
+	}
+	if stats := R.GetStats(false); stats.RPS[rrl.AllowanceError] != 3 {
+		t.Error("Malformed responses should be counted as errors", stats.RPS)
+	}
+}
+
+func TestDebitV(t *testing.T) {
+	cfg := rrl.NewConfig()
+	cfg.SetValue("responses-per-second", "1")
+	cfg.SetValue("slip-ratio", "0")
+	cfg.SetNowFunc(func() time.Time { return time.Time{} })
+	R := rrl.NewRRL(cfg)
+
+	src := newAddr("udp", "127.0.0.1:53")
+	tuple := rrl.ResponseTuple{Class: 1, Type: 1, AllowanceCategory: rrl.AllowanceAnswer,
+		SalientName: "example.com."}
+	if act, _, rtr := R.DebitV(src, tuple); act != rrl.Send || rtr != rrl.RTOk {
+		t.Error("First DebitV should be sent, not", act, rtr)
+	}
+	if act, _, rtr := R.Debit(src, &tuple); act != rrl.Drop || rtr != rrl.RTRateLimit {
+		t.Error("DebitV should have debited the same account as Debit", act, rtr)
+	}
+
+	// Passing by value should not cost an allocation over passing an existing pointer
+	byPointer := testing.AllocsPerRun(100, func() { R.Debit(src, &tuple) })
+	byValue := testing.AllocsPerRun(100, func() { R.DebitV(src, tuple) })
+	if byValue > byPointer {
+		t.Error("DebitV allocates more than Debit", byValue, byPointer)
+	}
+}
+
+func TestDebitErrorSuffixes(t *testing.T) {
+	cfg := rrl.NewConfig()
+	cfg.SetValue("errors-per-second", "1")
+	cfg.SetValue("error-suffixes", "com,victim.com")
+	cfg.SetNowFunc(func() time.Time { return time.Time{} })
+	R := rrl.NewRRL(cfg)
+
+	src := newAddr("udp", "127.0.0.1:53")
+	refused := func(name string) rrl.Action {
+		act, _, _ := R.Debit(src, newTuple(1, 1, name, rrl.AllowanceError))
+		return act
+	}
+	if refused("www.victim.com.") != rrl.Send || refused("WWW.Victim.COM") != rrl.Drop {
+		t.Error("Names below victim.com should share an account")
+	}
+	if refused("www.other.com.") != rrl.Send || refused("other.com.") != rrl.Drop {
+		t.Error("Names below com should share an account")
+	}
+	if refused("example.net.") != rrl.Send || refused("") != rrl.Drop {
+		t.Error("Names without a suffix should share the one error account")
+	}
+
+	var names []string
+	for _, ai := range R.DumpLimited(0) {
+		names = append(names, ai.Key.SalientName)
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != ",com.,victim.com." {
+		t.Error("Accounts should be keyed by closest suffix, not", names)
+	}
+}
+
+func TestDebitRequestsByTransport(t *testing.T) {
+	cfg := rrl.NewConfig()
+	cfg.SetValue("requests-per-second", "2")
+	cfg.SetValue("requests-by-transport", "true")
+	cfg.SetNowFunc(func() time.Time { return time.Time{} })
+	R := rrl.NewRRL(cfg)
+
+	udp := newAddr("udp", "10.0.0.1:53")
+	tcp := newAddr("tcp", "10.0.0.2:53")
+	for ix := 0; ix < 2; ix++ {
+		if act, ipr := R.DebitRequest(tcp); act != rrl.Send || ipr != rrl.IPOk {
+			t.Error(ix, "TCP request should be sent", act, ipr)
+		}
+	}
+	if act, ipr := R.DebitRequest(tcp); act != rrl.Drop || ipr != rrl.IPRateLimit {
+		t.Error("TCP flood should be limited", act, ipr)
+	}
+	if act := R.CheapCheck(tcp); act != rrl.Drop {
+		t.Error("CheapCheck should report the TCP account", act)
+	}
+	if act := R.CheapCheck(udp); act != rrl.Send {
+		t.Error("CheapCheck should report the UDP account", act)
+	}
+	if act, ipr, _ := R.Debit(udp, newTuple(1, 1, "example.com.", rrl.AllowanceAnswer)); act != rrl.Send ||
+		ipr != rrl.IPOk {
+		t.Error("TCP flood should not consume the UDP budget", act, ipr)
+	}
+	if act, ipr, _ := R.Check(newAddr("tcp4", "10.0.0.3:53"), nil); act != rrl.Drop || ipr != rrl.IPRateLimit {
+		t.Error("Check should report the TCP account", act, ipr)
+	}
+
+	kinds := make(map[rrl.AccountKind]bool)
+	for _, ai := range R.DumpLimited(0) {
+		kinds[ai.Key.Kind] = true
+	}
+	if len(kinds) != 1 || !kinds[rrl.AccountTCPRequests] {
+		t.Error("Only the TCP requests account should be limited", kinds)
+	}
+}
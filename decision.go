@@ -0,0 +1,88 @@
+package rrl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Decision combines the [Action], [IPReason] and [RTReason] returned by [Debit] into a
+// single comparable value. It is intended for log schemas and wire protocols which prefer
+// to reference one taxonomy rather than three parallel enums.
+//
+// The most convenient way to create a Decision is to pass the results of [Debit]
+// directly to [NewDecision], e.g.:
+//
+//	d := rrl.NewDecision(R.Debit(src, tuple))
+//
+// Each Decision has a stable numeric code returned by [Decision.Code] and a stable name
+// returned by [Decision.String]. Codes and names remain valid as reasons are added
+// because new reasons are only ever appended to their enums.
+type Decision struct {
+	Action
+	IPReason
+	RTReason
+}
+
+// NewDecision is a helper function which creates a Decision.
+func NewDecision(act Action, ipr IPReason, rtr RTReason) Decision {
+	return Decision{Action: act, IPReason: ipr, RTReason: rtr}
+}
+
+// Code returns the stable numeric code of the Decision. The Action occupies bits 16-23,
+// the IPReason bits 8-15 and the RTReason bits 0-7.
+func (d Decision) Code() uint32 {
+	return uint32(d.Action&0xff)<<16 | uint32(d.IPReason&0xff)<<8 | uint32(d.RTReason&0xff)
+}
+
+// DecisionFromCode is the inverse of [Decision.Code]. It returns false if the code
+// contains an unknown Action or reason.
+func DecisionFromCode(code uint32) (Decision, bool) {
+	d := Decision{Action: Action(code >> 16 & 0xff),
+		IPReason: IPReason(code >> 8 & 0xff),
+		RTReason: RTReason(code & 0xff)}
+	if code>>24 != 0 || !d.valid() {
+		return Decision{}, false
+	}
+
+	return d, true
+}
+
+// String returns the stable name of the Decision in the form "Action/IPReason/RTReason",
+// e.g. "Drop/IPOk/RTRateLimit".
+func (d Decision) String() string {
+	return d.Action.String() + "/" + d.IPReason.String() + "/" + d.RTReason.String()
+}
+
+// ParseDecision is the inverse of [Decision.String].
+func ParseDecision(s string) (Decision, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) == 3 {
+		d := Decision{Action: ActionLast, IPReason: IPLast, RTReason: RTLast}
+		for act := Send; act < ActionLast; act++ {
+			if act.String() == parts[0] {
+				d.Action = act
+			}
+		}
+		for ipr := IPOk; ipr < IPLast; ipr++ {
+			if ipr.String() == parts[1] {
+				d.IPReason = ipr
+			}
+		}
+		for rtr := RTOk; rtr < RTLast; rtr++ {
+			if rtr.String() == parts[2] {
+				d.RTReason = rtr
+			}
+		}
+		if d.valid() {
+			return d, nil
+		}
+	}
+
+	return Decision{}, fmt.Errorf("rrl: invalid Decision name '%s'", s)
+}
+
+func (d Decision) valid() bool {
+	return d.Action >= Send && d.Action < ActionLast &&
+		d.IPReason >= IPOk && d.IPReason < IPLast &&
+		d.RTReason >= RTOk && d.RTReason < RTLast
+}
@@ -0,0 +1,60 @@
+package rrl_test
+
+import (
+	"testing"
+
+	"github.com/markdingo/rrl"
+)
+
+func TestDecision(t *testing.T) {
+	cfg := rrl.NewConfig()
+	cfg.SetValue("responses-per-second", "1")
+	R := rrl.NewRRL(cfg)
+	src := newAddr("udp", "127.0.0.1:53")
+	tuple := newTuple(1, 1, "example.com.", rrl.AllowanceAnswer)
+
+	d := rrl.NewDecision(R.Debit(src, tuple))
+	if d != rrl.NewDecision(rrl.Send, rrl.IPNotConfigured, rrl.RTOk) {
+		t.Error("Expected Send/IPNotConfigured/RTOk, got", d)
+	}
+	d = rrl.NewDecision(R.Debit(src, tuple))
+	if d.Action != rrl.Drop || d.RTReason != rrl.RTRateLimit {
+		t.Error("Expected Drop/IPNotConfigured/RTRateLimit, got", d)
+	}
+
+	testCases := []struct {
+		d    rrl.Decision
+		code uint32
+		name string
+	}{
+		{rrl.NewDecision(rrl.Send, rrl.IPOk, rrl.RTOk), 0x000000, "Send/IPOk/RTOk"},
+		{rrl.NewDecision(rrl.Drop, rrl.IPOk, rrl.RTRateLimit), 0x010003, "Drop/IPOk/RTRateLimit"},
+		{rrl.NewDecision(rrl.Slip, rrl.IPRateLimit, rrl.RTNotReached), 0x020302, "Slip/IPRateLimit/RTNotReached"},
+		{rrl.NewDecision(rrl.Send, rrl.IPMinimum, rrl.RTMinimum), 0x000707, "Send/IPMinimum/RTMinimum"},
+	}
+	for ix, tc := range testCases {
+		if got := tc.d.Code(); got != tc.code {
+			t.Errorf("%d Code() expected %06x got %06x", ix, tc.code, got)
+		}
+		if got := tc.d.String(); got != tc.name {
+			t.Errorf("%d String() expected %s got %s", ix, tc.name, got)
+		}
+		if got, ok := rrl.DecisionFromCode(tc.code); !ok || got != tc.d {
+			t.Errorf("%d DecisionFromCode() expected %v got %v", ix, tc.d, got)
+		}
+		if got, err := rrl.ParseDecision(tc.name); err != nil || got != tc.d {
+			t.Errorf("%d ParseDecision() expected %v got %v %v", ix, tc.d, got, err)
+		}
+	}
+
+	for _, code := range []uint32{0x030000, 0x00ff00, 0x0000ff, 0x01000000} {
+		if _, ok := rrl.DecisionFromCode(code); ok {
+			t.Errorf("DecisionFromCode(%x) should have failed", code)
+		}
+	}
+	for _, name := range []string{"", "Send", "Send/IPOk", "Send/IPOk/RTBogus", "Bogus/IPOk/RTOk"} {
+		if _, err := rrl.ParseDecision(name); err == nil {
+			t.Errorf("ParseDecision(%s) should have failed", name)
+		}
+	}
+}
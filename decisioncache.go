@@ -0,0 +1,115 @@
+package rrl
+
+import (
+	"hash/maphash"
+	"sync/atomic"
+
+	"github.com/markdingo/rrl/cache"
+)
+
+const decisionCacheSlots = 4096 // Must be a power of two
+
+// decisionKey identifies an identical request from the same Client Network. The
+// SalientName is used as-is so that lookups need neither lowercasing nor allocation. Thus
+// a resolver using 0x20 encoding misses the cache, which is merely slower.
+type decisionKey struct {
+	ipPrefix string
+	class    uint16
+	qType    uint16
+	name     string
+	ac       AllowanceCategory
+}
+
+// cachedDrop is a remembered Drop decision.
+type cachedDrop struct {
+	key       decisionKey
+	expires   int64
+	countdown atomic.Uint32 // Slip countdown continued from the account. Zero never slips
+}
+
+// slip advances the slip countdown of the entry in the same way as debit advances that of
+// an account and returns true if the response should slip rather than be dropped.
+func (cd *cachedDrop) slip(ratio uint) bool {
+	for {
+		countdown := cd.countdown.Load()
+		if countdown == 0 {
+			return false
+		}
+		next, slip := countdown-1, false
+		if countdown == 1 {
+			next, slip = uint32(ratio), true
+		}
+		if cd.countdown.CompareAndSwap(countdown, next) {
+			return slip
+		}
+	}
+}
+
+// decisionCache is a direct-mapped cache of recent Drop decisions for response accounts
+// which are so deeply negative that they cannot possibly recover before the entry
+// expires. During a flood the same (Client Network, tuple) arrives many times per
+// millisecond, so a cache hit avoids the shard lock of the account for the bulk of the
+// flood. Slots are replaced without locks, so colliding keys simply evict each other.
+type decisionCache struct {
+	seed  maphash.Seed
+	ttl   int64
+	slots [decisionCacheSlots]atomic.Pointer[cachedDrop]
+}
+
+// initDecisionCache creates the decision cache if decision-cache-ttl is configured.
+func (rrl *RRL) initDecisionCache() {
+	if rrl.cfg.decisionCacheTTL == 0 {
+		return
+	}
+	rrl.decisionCache = &decisionCache{seed: maphash.MakeSeed(), ttl: rrl.cfg.decisionCacheTTL}
+}
+
+func (dc *decisionCache) slot(k *decisionKey) *atomic.Pointer[cachedDrop] {
+	var h maphash.Hash
+	h.SetSeed(dc.seed)
+	h.WriteString(k.ipPrefix)
+	h.WriteByte(byte(k.class))
+	h.WriteByte(byte(k.class >> 8))
+	h.WriteByte(byte(k.qType))
+	h.WriteByte(byte(k.qType >> 8))
+	h.WriteByte(byte(k.ac))
+	h.WriteString(k.name)
+
+	return &dc.slots[h.Sum64()&(decisionCacheSlots-1)]
+}
+
+// cachedDrop returns the entry of a Drop decision for the Client Network and tuple, or
+// nil if there is no current entry. It is only consulted for udp responses of enabled
+// categories which are not forced, as those are the only ones which are debited.
+func (rrl *RRL) cachedDrop(ipPrefix string, tuple *ResponseTuple, ac AllowanceCategory) *cachedDrop {
+	dc := rrl.decisionCache
+	if dc == nil {
+		return nil
+	}
+	k := decisionKey{ipPrefix, tuple.Class, tuple.Type, tuple.SalientName, ac}
+	if cd := dc.slot(&k).Load(); cd != nil && cd.key == k && rrl.now() < cd.expires {
+		return cd
+	}
+
+	return nil
+}
+
+// cacheDrop remembers a Drop decision if the account balance, b, is deeply negative.
+// An account is deeply negative if its debt exceeds half the window and exceeds the
+// ttl. As no curve recovers faster than linear, such an account remains negative for
+// the lifetime of the entry even without further debits. The entry continues the slip
+// countdown of the account t in table.
+func (rrl *RRL) cacheDrop(table *cache.Cache, t, ipPrefix string, tuple *ResponseTuple, ac AllowanceCategory, b, window int64) {
+	dc := rrl.decisionCache
+	if dc == nil || -b <= window/2 || -b <= dc.ttl {
+		return
+	}
+	k := decisionKey{ipPrefix, tuple.Class, tuple.Type, tuple.SalientName, ac}
+	cd := &cachedDrop{key: k, expires: rrl.now() + dc.ttl}
+	if countdown, ok := table.View(t, func(el interface{}) interface{} {
+		return el.(*responseAccount).slipCountdown
+	}); ok {
+		cd.countdown.Store(uint32(countdown.(uint)))
+	}
+	dc.slot(&k).Store(cd)
+}
@@ -0,0 +1,148 @@
+package rrl
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDecisionCache(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cfg := NewConfig()
+	cfg.SetValue("responses-per-second", "1")
+	cfg.SetValue("slip-ratio", "0")
+	cfg.SetValue("decision-cache-ttl", "10")
+	cfg.SetNowFunc(func() time.Time { return now })
+	R := NewRRL(cfg)
+
+	src := newAddr("udp", "10.0.0.1:53")
+	tuple := newTuple(1, 1, "example.com.", AllowanceAnswer)
+	allowTime := func() int64 {
+		el, found := R.table.Get(R.accountToken("10.0.0.0", 1, 1, "example.com.", AllowanceAnswer))
+		if !found {
+			t.Fatal("Account should exist")
+		}
+		return el.(*responseAccount).allowTime
+	}
+
+	// Shallow debt is never cached
+	for ix := 0; ix < 3; ix++ {
+		R.Debit(src, tuple)
+	}
+	before := allowTime()
+	if act, _, _ := R.Debit(src, tuple); act != Drop || allowTime() == before {
+		t.Fatal("Shallow debt should still be debited", act)
+	}
+
+	// Go deeply negative, after which the account is no longer touched
+	for ix := 0; ix < 10; ix++ {
+		R.Debit(src, tuple)
+	}
+	before = allowTime()
+	for ix := 0; ix < 5; ix++ {
+		if act, ipr, rtr := R.Debit(src, tuple); act != Drop || ipr != IPNotConfigured || rtr != RTRateLimit {
+			t.Error("Cached decision should be Drop", act, ipr, rtr)
+		}
+	}
+	if act, rtr := R.DebitResponse(src, tuple); act != Drop || rtr != RTRateLimit {
+		t.Error("DebitResponse should also use the cache", act, rtr)
+	}
+	if allowTime() != before {
+		t.Error("Cached decisions should not debit the account")
+	}
+	if stats := R.GetStats(false); stats.Actions[Drop] != 19 || stats.RPS[AllowanceAnswer] != 20 {
+		t.Error("Cached decisions should be counted", stats.String())
+	}
+
+	// Other transports and other tuples are unaffected
+	if act, _, rtr := R.Debit(newAddr("tcp", "10.0.0.1:53"), tuple); act != Send || rtr != RTNotUDP {
+		t.Error("tcp should never match the cache", act, rtr)
+	}
+	if act, _, _ := R.Debit(src, newTuple(1, 1, "example.net.", AllowanceAnswer)); act != Send {
+		t.Error("Different tuple should not match the cache", act)
+	}
+
+	// Once expired the account is debited again
+	now = now.Add(11 * time.Millisecond)
+	if R.Debit(src, tuple); allowTime() == before {
+		t.Error("Expired cache entry should have been ignored")
+	}
+
+	// Never cached with a minimum guarantee
+	cfg = NewConfig()
+	cfg.SetValue("responses-per-second", "1")
+	cfg.SetValue("minimum-responses-per-second", "0.1")
+	cfg.SetValue("decision-cache-ttl", "10")
+	cfg.SetNowFunc(func() time.Time { return now })
+	R = NewRRL(cfg)
+	for ix := 0; ix < 20; ix++ {
+		R.Debit(src, tuple)
+	}
+	for ix := range R.decisionCache.slots {
+		if R.decisionCache.slots[ix].Load() != nil {
+			t.Fatal("Decision should not be cached with minimum-responses-per-second")
+		}
+	}
+}
+
+// Cached decisions only skip the response account. Requests are still debited and
+// responses still slip.
+func TestDecisionCacheRequestsAndSlip(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cfg := NewConfig()
+	cfg.SetValue("responses-per-second", "1")
+	cfg.SetValue("requests-per-second", "1000")
+	cfg.SetValue("slip-ratio", "2")
+	cfg.SetValue("decision-cache-ttl", "10")
+	cfg.SetNowFunc(func() time.Time { return now })
+	R := NewRRL(cfg)
+
+	src := newAddr("udp", "10.0.0.1:53")
+	tuple := newTuple(1, 1, "example.com.", AllowanceAnswer)
+	requests := func() int64 {
+		el, found := R.table.Get(R.requestsToken("udp", "10.0.0.0"))
+		if !found {
+			t.Fatal("Requests account should exist")
+		}
+		return el.(*responseAccount).allowTime
+	}
+	for ix := 0; ix < 20; ix++ { // Deeply negative and cached
+		R.Debit(src, tuple)
+	}
+	if !func() bool {
+		for ix := range R.decisionCache.slots {
+			if R.decisionCache.slots[ix].Load() != nil {
+				return true
+			}
+		}
+		return false
+	}() {
+		t.Fatal("Decision should have been cached")
+	}
+
+	before := requests()
+	var slips, drops int
+	for ix := 0; ix < 10; ix++ {
+		switch act, ipr, rtr := R.Debit(src, tuple); {
+		case ipr != IPOk || rtr != RTRateLimit:
+			t.Fatal(ix, "Unexpected cached decision", act, ipr, rtr)
+		case act == Slip:
+			slips++
+		case act == Drop:
+			drops++
+		}
+	}
+	if slips != 5 || drops != 5 {
+		t.Error("Cached decisions should slip every second response, not", slips, drops)
+	}
+	if requests() == before {
+		t.Error("Cached decisions should still debit the requests account")
+	}
+
+	// The request stage still applies its own limits
+	for ix := 0; ix < 2000; ix++ {
+		R.Debit(src, tuple)
+	}
+	if act, ipr, _ := R.Debit(src, tuple); act != Drop || ipr != IPRateLimit {
+		t.Error("Requests should be rate limited despite the cached decision", act, ipr)
+	}
+}
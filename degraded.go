@@ -0,0 +1,89 @@
+package rrl
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Health describes whether an RRL is operating normally. It is returned by [RRL.Health].
+//
+// An RRL is degraded when it is persistently unable to create new accounts because the
+// table is full, that is, when account creation has failed at least once a second for
+// "degraded-after" seconds. While degraded, the "degraded-mode" configuration determines
+// whether debits which cannot be accounted for fail open (Send) or fail closed (Drop) so
+// that the overall mitigation posture under stress is deliberate rather than emergent.
+//
+// As the RRL has no backend and no background janitor, a persistently full table is
+// currently the only cause of degradation.
+type Health struct {
+	Degraded bool
+	Since    time.Time // When the current degraded state started. Zero if not degraded
+	Reason   string    // Why the RRL is degraded. Empty if not degraded
+
+	CacheFullFailures int64 // Total account creation failures since the RRL was created
+}
+
+// degradation tracks the state used to determine Health.
+type degradation struct {
+	failures  atomic.Int64
+	fullSince atomic.Int64 // Start of the current run of cache full failures
+	lastFull  atomic.Int64 // Time of the most recent cache full failure
+	degraded  atomic.Bool
+}
+
+// initDegradation sets the initial state such that the first failure starts a new run.
+func (rrl *RRL) initDegradation() {
+	rrl.degradation.lastFull.Store(rrl.now() - 2*second)
+}
+
+// cacheFullAction records an account creation failure and returns the Action to take
+// for the failed debit. Outside of the degraded state, and while degraded in the default
+// fail closed mode, the Action is Drop. While degraded in fail open mode it is Send.
+func (rrl *RRL) cacheFullAction() Action {
+	dg := &rrl.degradation
+	now := rrl.now()
+	dg.failures.Add(1)
+	if now-dg.lastFull.Swap(now) > second { // Start of a new run of failures
+		dg.fullSince.Store(now)
+	}
+
+	if !dg.degraded.Load() && now-dg.fullSince.Load() >= rrl.cfg.degradedAfter {
+		if dg.degraded.CompareAndSwap(false, true) {
+			rrl.emitEvent(EventDegraded, "", "", 0)
+		}
+	}
+	if dg.degraded.Load() && rrl.cfg.failOpen {
+		return Send
+	}
+
+	return Drop
+}
+
+// checkDegraded leaves the degraded state once a second has passed without a failure.
+func (rrl *RRL) checkDegraded() {
+	dg := &rrl.degradation
+	if !dg.degraded.Load() {
+		return
+	}
+	if rrl.now()-dg.lastFull.Load() > second && dg.degraded.CompareAndSwap(true, false) {
+		rrl.emitEvent(EventNormal, "", "", 0)
+	}
+}
+
+// Health returns the current [Health] of the RRL. The transitions into and out of the
+// degraded state are also exported as EventDegraded and EventNormal events by
+// [RRL.ExportEvents].
+//
+// Health is concurrency safe.
+func (rrl *RRL) Health() (h Health) {
+	rrl.checkDegraded()
+	dg := &rrl.degradation
+	h.CacheFullFailures = dg.failures.Load()
+	if dg.degraded.Load() {
+		h.Degraded = true
+		h.Since = time.Unix(0, dg.fullSince.Load())
+		h.Reason = "table full"
+	}
+
+	return
+}
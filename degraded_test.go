@@ -0,0 +1,111 @@
+package rrl
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDegraded(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cfg := NewConfig()
+	cfg.SetValue("nxdomains-per-second", "1")
+	cfg.SetValue("nxdomains-table-size", "1") // Becomes 4 per shard
+	cfg.SetValue("degraded-after", "2")
+	cfg.SetValue("degraded-mode", "open")
+	cfg.SetNowFunc(func() time.Time { return now })
+	R := NewRRL(cfg)
+
+	var out bytes.Buffer
+	ee := R.ExportEvents(&out, 10000) // Filling the table creates many events
+
+	src := newAddr("udp", "10.0.0.1:53")
+	var name string
+	debit := func() (act Action, rtr RTReason) {
+		act, _, rtr = R.Debit(src, newTuple(1, 1, name, AllowanceNXDomain))
+		return
+	}
+
+	var act Action
+	var rtr RTReason
+	for ix := 0; ix < 2000 && rtr != RTCacheFull; ix++ {
+		name = fmt.Sprintf("%d.example.com.", ix) // Retained once its shard is full
+		act, _, rtr = R.Debit(src, newTuple(1, 1, name, AllowanceNXDomain))
+	}
+	if rtr != RTCacheFull || act != Drop {
+		t.Fatal("Expected table to fill with Drop, not", act, rtr)
+	}
+	if h := R.Health(); h.Degraded || h.CacheFullFailures != 1 {
+		t.Error("Should not be degraded after first failure", h)
+	}
+
+	for i := 0; i < 2; i++ { // Persistent failures
+		now = now.Add(time.Second)
+		act, rtr = debit()
+	}
+	h := R.Health()
+	if !h.Degraded || h.Reason == "" || !h.Since.Equal(time.Unix(1000, 0)) {
+		t.Error("Expected degraded since start of run", h)
+	}
+	if act != Send || rtr != RTCacheFull {
+		t.Error("Expected fail open while degraded, not", act, rtr)
+	}
+
+	now = now.Add(2 * time.Second) // No failures so should recover
+	if h := R.Health(); h.Degraded || !h.Since.IsZero() || h.CacheFullFailures != 3 {
+		t.Error("Expected recovery", h)
+	}
+
+	// A gap in failures starts a new run
+	debit()
+	now = now.Add(2 * time.Second)
+	debit()
+	if h := R.Health(); h.Degraded {
+		t.Error("Interrupted run should not be degraded", h)
+	}
+
+	ee.Close()
+	var got []string
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		var ev eventJSON
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			t.Fatal("Bad JSON", err, scanner.Text())
+		}
+		if ev.Event == "degraded" || ev.Event == "normal" {
+			if len(ev.Network) > 0 || len(ev.Kind) > 0 {
+				t.Error("Degraded events should not have account fields", scanner.Text())
+			}
+			got = append(got, ev.Event)
+		}
+	}
+	if fmt.Sprint(got) != "[degraded normal]" {
+		t.Error("Unexpected degraded events", got)
+	}
+}
+
+// The default mode fails closed while degraded
+func TestDegradedClosed(t *testing.T) {
+	cfg := NewConfig()
+	cfg.SetValue("nxdomains-per-second", "1")
+	cfg.SetValue("nxdomains-table-size", "1")
+	cfg.SetValue("degraded-after", "0")
+	cfg.SetNowFunc(func() time.Time { return time.Unix(1000, 0) })
+	R := NewRRL(cfg)
+
+	src := newAddr("udp", "10.0.0.1:53")
+	var act Action
+	var rtr RTReason
+	for ix := 0; ix < 2000 && rtr != RTCacheFull; ix++ {
+		act, _, rtr = R.Debit(src, newTuple(1, 1, fmt.Sprintf("%d.example.com.", ix), AllowanceNXDomain))
+	}
+	if rtr != RTCacheFull || act != Drop {
+		t.Error("Expected fail closed, not", act, rtr)
+	}
+	if !R.Health().Degraded {
+		t.Error("degraded-after 0 should degrade immediately")
+	}
+}
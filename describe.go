@@ -0,0 +1,359 @@
+package rrl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Format nominates the output format of [Config.Describe].
+type Format int
+
+const (
+	FormatText     Format = iota // Aligned columns suitable for --help output
+	FormatMarkdown               // A markdown table suitable for admin documentation
+	FormatJSON                   // A JSON array of objects suitable for tooling
+	FormatLast
+)
+
+// keyword is the metadata describing a single [Config.SetValue] keyword. The keywords
+// table is the authority on which keywords are valid; SetValue rejects any keyword not
+// present in the table so the documentation cannot drift from the code.
+type keyword struct {
+	name        string
+	kind        string // int, float, bool or string
+	valid       string // Human readable range of valid values
+	dflt        string
+	description string
+	current     func(c *Config) string
+}
+
+var keywords = []keyword{
+	{"window", "int", "1-3600", "15",
+		"Rolling window in seconds during which response rates are tracked",
+		func(c *Config) string { return secondsString(c.window) }},
+	{"responses-window", "int", "1-3600", "window",
+		"Rolling window in seconds for AllowanceAnswer accounts",
+		func(c *Config) string { return c.windowString(AllowanceAnswer) }},
+	{"referrals-window", "int", "1-3600", "window",
+		"Rolling window in seconds for AllowanceReferral accounts",
+		func(c *Config) string { return c.windowString(AllowanceReferral) }},
+	{"nodata-window", "int", "1-3600", "window",
+		"Rolling window in seconds for AllowanceNoData accounts",
+		func(c *Config) string { return c.windowString(AllowanceNoData) }},
+	{"nxdomains-window", "int", "1-3600", "window",
+		"Rolling window in seconds for AllowanceNXDomain accounts",
+		func(c *Config) string { return c.windowString(AllowanceNXDomain) }},
+	{"errors-window", "int", "1-3600", "window",
+		"Rolling window in seconds for AllowanceError accounts",
+		func(c *Config) string { return c.windowString(AllowanceError) }},
+	{"transfers-window", "int", "1-3600", "window",
+		"Rolling window in seconds for AllowanceTransfer accounts",
+		func(c *Config) string { return c.windowString(AllowanceTransfer) }},
+	{"recovery", "string", "linear/exponential", "linear",
+		"Credit recovery curve of requests and response accounts",
+		func(c *Config) string { return c.recovery.String() }},
+	{"responses-recovery", "string", "linear/exponential", "recovery",
+		"Credit recovery curve of AllowanceAnswer accounts",
+		func(c *Config) string { return c.recoveryString(AllowanceAnswer) }},
+	{"referrals-recovery", "string", "linear/exponential", "recovery",
+		"Credit recovery curve of AllowanceReferral accounts",
+		func(c *Config) string { return c.recoveryString(AllowanceReferral) }},
+	{"nodata-recovery", "string", "linear/exponential", "recovery",
+		"Credit recovery curve of AllowanceNoData accounts",
+		func(c *Config) string { return c.recoveryString(AllowanceNoData) }},
+	{"nxdomains-recovery", "string", "linear/exponential", "recovery",
+		"Credit recovery curve of AllowanceNXDomain accounts",
+		func(c *Config) string { return c.recoveryString(AllowanceNXDomain) }},
+	{"errors-recovery", "string", "linear/exponential", "recovery",
+		"Credit recovery curve of AllowanceError accounts",
+		func(c *Config) string { return c.recoveryString(AllowanceError) }},
+	{"transfers-recovery", "string", "linear/exponential", "recovery",
+		"Credit recovery curve of AllowanceTransfer accounts",
+		func(c *Config) string { return c.recoveryString(AllowanceTransfer) }},
+	{"ipv4-prefix-length", "int", "1-32", "24",
+		"Prefix length in bits identifying an ipv4 Client Network",
+		func(c *Config) string { return strconv.Itoa(c.ipv4PrefixLength) }},
+	{"ipv6-prefix-length", "int", "1-128", "56",
+		"Prefix length in bits identifying an ipv6 Client Network",
+		func(c *Config) string { return strconv.Itoa(c.ipv6PrefixLength) }},
+	{"ipv6-aggregate-prefix-length", "int", "1-128", "48",
+		"Prefix length in bits of the ipv6 aggregate network",
+		func(c *Config) string { return strconv.Itoa(c.ipv6AggregatePrefixLength) }},
+	{"ipv6-aggregate-requests-per-second", "float", ">=0", "0",
+		"Requests allowed per second from an ipv6 aggregate network",
+		func(c *Config) string { return rateString(c.ipv6AggregateInterval) }},
+	{"responses-per-second", "float", ">=0", "0",
+		"AllowanceAnswer responses allowed per second",
+		func(c *Config) string { return rateString(c.responsesInterval) }},
+	{"nodata-per-second", "float", ">=0", "responses-per-second",
+		"AllowanceNoData responses allowed per second",
+		func(c *Config) string { return c.defaultedRateString(c.nodataInterval, c.nodataIntervalSet) }},
+	{"nxdomains-per-second", "float", ">=0", "responses-per-second",
+		"AllowanceNXDomain responses allowed per second",
+		func(c *Config) string { return c.defaultedRateString(c.nxdomainsInterval, c.nxdomainsIntervalSet) }},
+	{"referrals-per-second", "float", ">=0", "responses-per-second",
+		"AllowanceReferral responses allowed per second",
+		func(c *Config) string { return c.defaultedRateString(c.referralsInterval, c.referralsIntervalSet) }},
+	{"errors-per-second", "float", ">=0", "responses-per-second",
+		"AllowanceError responses allowed per second",
+		func(c *Config) string { return c.defaultedRateString(c.errorsInterval, c.errorsIntervalSet) }},
+	{"transfers-per-second", "float", ">=0", "errors-per-second",
+		"AllowanceTransfer (AXFR/IXFR over UDP) responses allowed per second",
+		func(c *Config) string {
+			if !c.transfersIntervalSet {
+				return c.defaultedRateString(c.errorsInterval, c.errorsIntervalSet)
+			}
+			return rateString(c.transfersInterval)
+		}},
+	{"error-suffixes", "string", "names", "",
+		"Comma separated names by which AllowanceError responses are accounted",
+		func(c *Config) string { return strings.Join(c.errorSuffixes, ",") }},
+	{"requests-per-second", "float", ">=0", "0",
+		"Requests allowed per second from a Client Network",
+		func(c *Config) string { return rateString(c.requestsInterval) }},
+	{"requests-by-transport", "bool", "true/false", "false",
+		"Account requests-per-second separately for UDP and other transports",
+		func(c *Config) string { return strconv.FormatBool(c.requestsByTransport) }},
+	{"minimum-responses-per-second", "float", ">=0", "0",
+		"Responses per second always sent to a Client Network",
+		func(c *Config) string { return rateString(c.minimumInterval) }},
+	{"max-table-size", "int", ">=0", "100000",
+		"Maximum number of accounts tracked at one time",
+		func(c *Config) string { return strconv.Itoa(c.maxTableSize) }},
+	{"in-credit-eviction-age", "int", "0-3600", "window",
+		"Idle age in seconds before in-credit accounts can be evicted",
+		func(c *Config) string { return secondsString(c.inCreditEvictionAge) }},
+	{"max-slips-per-second", "float", ">=0", "0",
+		"Maximum Slip actions per second across all accounts",
+		func(c *Config) string { return rateString(c.slipInterval) }},
+	{"max-events-per-second", "float", ">=0", "0",
+		"Maximum account events exported per second",
+		func(c *Config) string { return rateString(c.eventInterval) }},
+	{"responses-table-size", "int", ">=0", "0",
+		"Size of the AllowanceAnswer table partition",
+		func(c *Config) string { return strconv.Itoa(c.tableSizes[AllowanceAnswer]) }},
+	{"referrals-table-size", "int", ">=0", "0",
+		"Size of the AllowanceReferral table partition",
+		func(c *Config) string { return strconv.Itoa(c.tableSizes[AllowanceReferral]) }},
+	{"nodata-table-size", "int", ">=0", "0",
+		"Size of the AllowanceNoData table partition",
+		func(c *Config) string { return strconv.Itoa(c.tableSizes[AllowanceNoData]) }},
+	{"nxdomains-table-size", "int", ">=0", "0",
+		"Size of the AllowanceNXDomain table partition",
+		func(c *Config) string { return strconv.Itoa(c.tableSizes[AllowanceNXDomain]) }},
+	{"errors-table-size", "int", ">=0", "0",
+		"Size of the AllowanceError table partition",
+		func(c *Config) string { return strconv.Itoa(c.tableSizes[AllowanceError]) }},
+	{"transfers-table-size", "int", ">=0", "0",
+		"Size of the AllowanceTransfer table partition",
+		func(c *Config) string { return strconv.Itoa(c.tableSizes[AllowanceTransfer]) }},
+	{"degraded-after", "int", "0-3600", "5",
+		"Seconds of persistent table full failures before degraded",
+		func(c *Config) string { return secondsString(c.degradedAfter) }},
+	{"overload-drop-ratio", "float", "0-1", "0",
+		"Fraction of Drop actions over overload-interval above which the RRL is overloaded",
+		func(c *Config) string { return strconv.FormatFloat(c.overloadRatio, 'g', -1, 64) }},
+	{"overload-interval", "int", "1-3600", "10",
+		"Sliding interval in seconds over which overload-drop-ratio is measured",
+		func(c *Config) string { return secondsString(c.overloadInterval) }},
+	{"validator-boost", "float", "1-100", "1",
+		"Rate multiplier of Client Networks reported by ReportValidator",
+		func(c *Config) string { return strconv.FormatFloat(c.validatorBoost, 'g', -1, 64) }},
+	{"validator-reputation-age", "int", "1-86400", "3600",
+		"Seconds a validator reputation lasts after the most recent report",
+		func(c *Config) string { return secondsString(c.validatorAge) }},
+	{"degraded-mode", "string", "open/closed", "closed",
+		"Action for unaccountable debits while degraded",
+		func(c *Config) string {
+			if c.failOpen {
+				return "open"
+			}
+			return "closed"
+		}},
+	{"slip-ratio", "int", "0-10", "2",
+		"Ratio of rate-limited responses which Slip rather than Drop",
+		func(c *Config) string { return strconv.FormatUint(uint64(c.slipRatio), 10) }},
+	{"soft-limit-percent", "int", "0-99", "0",
+		"Percent of an account's credit consumed before soft limit reasons",
+		func(c *Config) string { return c.softLimitString() }},
+	{"account-hash-key", "string", "any", "",
+		"Secret key used to derive AccountID identifiers",
+		func(c *Config) string { return redactedString(c.accountHashKey) }},
+	{"shard-hash-key", "string", "any", "random",
+		"SipHash key used to select the table shard of each account",
+		func(c *Config) string { return redactedString(c.shardHashKey) }},
+	{"state-transfer-key", "string", "any", "",
+		"Shared key authenticating ServeState and PrimeFrom",
+		func(c *Config) string { return redactedString(c.stateTransferKey) }},
+	{"include-class", "bool", "true/false", "false",
+		"Include the non-ClassINET query class in response accounts",
+		func(c *Config) string { return strconv.FormatBool(c.includeClass) }},
+	{"calibrate", "bool", "true/false", "false",
+		"Record the peak per-second rate of every account",
+		func(c *Config) string { return strconv.FormatBool(c.calibrate) }},
+	{"track-uniques", "bool", "true/false", "false",
+		"Track approximate distinct Client Networks and SalientNames",
+		func(c *Config) string { return strconv.FormatBool(c.trackUniques) }},
+	{"family-stats", "bool", "true/false", "false",
+		"Accumulate Stats separately for ipv4 and ipv6 Client Networks",
+		func(c *Config) string { return strconv.FormatBool(c.familyStats) }},
+	{"history-depth", "int", "0-1440", "0",
+		"Number of per-minute Stats slices retained for History",
+		func(c *Config) string { return strconv.Itoa(c.historyDepth) }},
+	{"name-cache-size", "int", ">=0", "0",
+		"Number of mixed case SalientNames with a cached lowercase form",
+		func(c *Config) string { return strconv.Itoa(c.nameCacheSize) }},
+	{"decision-log-size", "int", "0-1000000", "0",
+		"Number of recent non-Send decisions retained for RecentDecisions",
+		func(c *Config) string { return strconv.Itoa(c.decisionLogSize) }},
+	{"max-inspections-per-second", "float", ">=0", "0",
+		"Maximum admin operations examining every account started per second",
+		func(c *Config) string { return rateString(c.inspectionInterval) }},
+	{"inspection-budget", "int", "0-60000", "0",
+		"Maximum milliseconds an admin operation may spend walking the table",
+		func(c *Config) string { return millisecondsString(c.inspectionBudget) }},
+	{"decision-cache-ttl", "int", "0-1000", "0",
+		"Milliseconds a Drop is remembered for an identical request from a deeply negative account",
+		func(c *Config) string { return millisecondsString(c.decisionCacheTTL) }},
+	{"probation-count", "int", "0-100", "0",
+		"Debits within probation-period before an account is created",
+		func(c *Config) string { return strconv.Itoa(c.probationCount) }},
+	{"probation-period", "int", "1-60000", "1000",
+		"Milliseconds over which probation-count debits are counted",
+		func(c *Config) string { return millisecondsString(c.probationPeriod) }},
+	{"sketch-width", "int", "0-16777216", "0",
+		"Counters per row of the count-min sketch consulted before accounts are created",
+		func(c *Config) string { return strconv.Itoa(c.sketchWidth) }},
+}
+
+// lookupKeyword returns the metadata for the named keyword or nil if it is unknown.
+func lookupKeyword(name string) *keyword {
+	for ix := range keywords {
+		if keywords[ix].name == name {
+			return &keywords[ix]
+		}
+	}
+
+	return nil
+}
+
+// Describe writes the table of [Config.SetValue] keywords to w in the nominated format.
+// Each keyword is described by its name, type, valid range, default value and the current
+// value in this Config. Embedding servers can use Describe to generate --help output and
+// administrator documentation which never drifts from the code.
+//
+// The current values of "account-hash-key", "shard-hash-key" and "state-transfer-key"
+// are never revealed.
+func (c *Config) Describe(w io.Writer, format Format) error {
+	switch format {
+	case FormatText:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "Keyword\tType\tRange\tDefault\tCurrent\tDescription")
+		for _, kw := range keywords {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				kw.name, kw.kind, kw.valid, quoteEmpty(kw.dflt), quoteEmpty(kw.current(c)), kw.description)
+		}
+		return tw.Flush()
+
+	case FormatMarkdown:
+		_, err := fmt.Fprintln(w, "| Keyword | Type | Range | Default | Current | Description |\n"+
+			"|---------|------|-------|---------|---------|-------------|")
+		for _, kw := range keywords {
+			if err != nil {
+				break
+			}
+			_, err = fmt.Fprintf(w, "| `%s` | %s | %s | %s | %s | %s |\n",
+				kw.name, kw.kind, kw.valid, quoteEmpty(kw.dflt), quoteEmpty(kw.current(c)), kw.description)
+		}
+		return err
+
+	case FormatJSON:
+		type jsonKeyword struct {
+			Name        string `json:"name"`
+			Type        string `json:"type"`
+			Range       string `json:"range"`
+			Default     string `json:"default"`
+			Current     string `json:"current"`
+			Description string `json:"description"`
+		}
+		out := make([]jsonKeyword, 0, len(keywords))
+		for _, kw := range keywords {
+			out = append(out, jsonKeyword{kw.name, kw.kind, kw.valid, kw.dflt, kw.current(c), kw.description})
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	}
+
+	return fmt.Errorf("rrl: unknown Describe format %d", format)
+}
+
+// millisecondsString returns the nanosecond duration as whole milliseconds.
+func millisecondsString(ns int64) string {
+	return strconv.FormatInt(ns/(second/1000), 10)
+}
+
+// secondsString returns the nanosecond duration as whole seconds.
+func secondsString(ns int64) string {
+	return strconv.FormatInt(ns/second, 10)
+}
+
+// windowString returns the window of the AllowanceCategory which defaults to window.
+func (c *Config) windowString(ac AllowanceCategory) string {
+	if !c.windowsSet[ac] {
+		return secondsString(c.window)
+	}
+	return secondsString(c.windows[ac])
+}
+
+// recoveryString returns the recovery curve of the AllowanceCategory, or of recovery if
+// not set.
+func (c *Config) recoveryString(ac AllowanceCategory) string {
+	if !c.recoveriesSet[ac] {
+		return c.recovery.String()
+	}
+	return c.recoveries[ac].String()
+}
+
+// rateString converts an interval back into the per-second value used to set it.
+func rateString(interval int64) string {
+	if interval == 0 {
+		return "0"
+	}
+	return strconv.FormatFloat(second/float64(interval), 'g', 6, 64)
+}
+
+// defaultedRateString returns the rate of an interval which defaults to
+// responses-per-second if it has not been set.
+func (c *Config) defaultedRateString(interval int64, set bool) string {
+	if !set {
+		interval = c.responsesInterval
+	}
+	return rateString(interval)
+}
+
+// softLimitString converts the soft limit balance back into a percentage.
+func (c *Config) softLimitString() string {
+	if c.softLimitBalance == 0 {
+		return "0"
+	}
+	return strconv.FormatInt(100-c.softLimitBalance*100/second, 10)
+}
+
+func redactedString(s string) string {
+	if len(s) == 0 {
+		return ""
+	}
+	return "<redacted>"
+}
+
+func quoteEmpty(s string) string {
+	if len(s) == 0 {
+		return `""`
+	}
+	return s
+}
@@ -0,0 +1,138 @@
+package rrl
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Every keyword in the metadata table must be accepted by SetValue otherwise the table
+// and the SetValue switch have drifted apart.
+func TestKeywordsSettable(t *testing.T) {
+	samples := map[string]string{"int": "1", "float": "1", "bool": "true", "string": "x"}
+	for _, kw := range keywords {
+		cfg := NewConfig()
+		arg := samples[kw.kind]
+		if kw.kind == "string" && strings.Contains(kw.valid, "/") { // Enumerated strings only accept listed values
+			arg, _, _ = strings.Cut(kw.valid, "/")
+		}
+		if err := cfg.SetValue(kw.name, arg); err != nil {
+			t.Error("Keyword", kw.name, "in metadata but not accepted by SetValue", err)
+		}
+		if kw.current == nil || len(kw.description) == 0 {
+			t.Error("Keyword", kw.name, "has incomplete metadata")
+		}
+	}
+}
+
+// The ranges enforced by SetValue must agree with the metadata table and be reported
+// with the typed errors.
+func TestKeywordsRange(t *testing.T) {
+	for _, kw := range keywords {
+		if kw.kind != "int" && kw.kind != "float" {
+			continue
+		}
+		cfg := NewConfig()
+		lo, hi, bounded := strings.Cut(strings.TrimPrefix(kw.valid, ">="), "-")
+		below, _ := strconv.Atoi(lo)
+		args := []string{strconv.Itoa(below - 1)}
+		if bounded {
+			above, _ := strconv.Atoi(hi)
+			args = append(args, strconv.Itoa(above+1))
+			if err := cfg.SetValue(kw.name, hi); err != nil {
+				t.Error(kw.name, "Maximum", hi, "should be valid", err)
+			}
+		}
+		if err := cfg.SetValue(kw.name, lo); err != nil {
+			t.Error(kw.name, "Minimum", lo, "should be valid", err)
+		}
+		for _, arg := range args {
+			var oor *ErrOutOfRange
+			err := cfg.SetValue(kw.name, arg)
+			if !errors.As(err, &oor) {
+				t.Error(kw.name, arg, "Expected ErrOutOfRange, not", err)
+				continue
+			}
+			if oor.Min != float64(below) || (bounded != !math.IsInf(oor.Max, 1)) {
+				t.Error(kw.name, "ErrOutOfRange does not match", kw.valid, oor.Min, oor.Max)
+			}
+		}
+		if err := cfg.SetValue(kw.name, "x"); !errors.Is(err, ErrSyntax) {
+			t.Error(kw.name, "Expected ErrSyntax, not", err)
+		}
+	}
+
+	cfg := NewConfig()
+	if err := cfg.SetValue("windox", "1"); !errors.Is(err, ErrUnknownKeyword) {
+		t.Error("Expected ErrUnknownKeyword, not", err)
+	}
+	if err := cfg.SetValue("degraded-mode", "ajar"); !errors.Is(err, ErrSyntax) {
+		t.Error("Expected ErrSyntax for an unlisted value, not", err)
+	}
+	err := cfg.SetValue("window", "0")
+	if err == nil || err.Error() != "window='0' must be between 1 and 3600" {
+		t.Error("Unexpected range message", err)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	cfg := NewConfig()
+	cfg.SetValue("responses-per-second", "3")
+	cfg.SetValue("nxdomains-window", "60")
+	cfg.SetValue("soft-limit-percent", "80")
+	cfg.SetValue("account-hash-key", "secret")
+
+	var out bytes.Buffer
+	if err := cfg.Describe(&out, FormatJSON); err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	var kws []struct{ Name, Type, Range, Default, Current, Description string }
+	if err := json.Unmarshal(out.Bytes(), &kws); err != nil {
+		t.Fatal("JSON did not unmarshal", err)
+	}
+	if len(kws) != len(keywords) {
+		t.Fatal("Expected", len(keywords), "JSON keywords, got", len(kws))
+	}
+	current := make(map[string]string)
+	for _, kw := range kws {
+		current[kw.Name] = kw.Current
+	}
+	for name, exp := range map[string]string{
+		"window": "15", "nxdomains-window": "60", "errors-window": "15",
+		"responses-per-second": "3", "nodata-per-second": "3", "requests-per-second": "0",
+		"soft-limit-percent": "80", "account-hash-key": "<redacted>", "calibrate": "false",
+	} {
+		if current[name] != exp {
+			t.Error(name, "current expected", exp, "got", current[name])
+		}
+	}
+
+	out.Reset()
+	if err := cfg.Describe(&out, FormatMarkdown); err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != len(keywords)+2 || !strings.HasPrefix(lines[2], "| `window` | int | 1-3600 | 15 | 15 |") {
+		t.Error("Unexpected markdown", lines[:3])
+	}
+
+	out.Reset()
+	if err := cfg.Describe(&out, FormatText); err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if strings.Contains(out.String(), "secret") {
+		t.Error("Describe revealed account-hash-key")
+	}
+	lines = strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != len(keywords)+1 || !strings.HasPrefix(lines[0], "Keyword") {
+		t.Error("Unexpected text", lines[0])
+	}
+
+	if err := cfg.Describe(&out, FormatLast); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+}
@@ -0,0 +1,41 @@
+package rrl
+
+// DisableCategory switches off response rate limiting of the [AllowanceCategory] without
+// changing its configured allowance. While disabled, responses of the category are
+// always sent with an RTReason of RTDisabled and their accounts are neither debited nor
+// penalized. Request rate limiting is unaffected. This allows an operator to suspend,
+// say, referral limiting during a delegation migration and later restore it with
+// [RRL.EnableCategory] without reconstructing the RRL.
+//
+// Invalid categories are ignored. DisableCategory is concurrency safe.
+func (rrl *RRL) DisableCategory(ac AllowanceCategory) {
+	if ac < AllowanceLast {
+		rrl.updateDisabled(func(mask uint32) uint32 { return mask | 1<<ac })
+	}
+}
+
+// EnableCategory reverses [RRL.DisableCategory]. Accounts of the category resume from
+// where they were, less any recovery which has accrued in the meantime.
+//
+// Invalid categories are ignored. EnableCategory is concurrency safe.
+func (rrl *RRL) EnableCategory(ac AllowanceCategory) {
+	if ac < AllowanceLast {
+		rrl.updateDisabled(func(mask uint32) uint32 { return mask &^ (1 << ac) })
+	}
+}
+
+// CategoryDisabled returns true if the [AllowanceCategory] has been disabled by
+// [RRL.DisableCategory].
+func (rrl *RRL) CategoryDisabled(ac AllowanceCategory) bool {
+	return ac < AllowanceLast && rrl.disabled.Load()&(1<<ac) != 0
+}
+
+// updateDisabled atomically replaces the mask of disabled categories with fn(mask).
+func (rrl *RRL) updateDisabled(fn func(mask uint32) uint32) {
+	for {
+		old := rrl.disabled.Load()
+		if rrl.disabled.CompareAndSwap(old, fn(old)) {
+			return
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package rrl
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDisableCategory(t *testing.T) {
+	cfg := NewConfig()
+	cfg.SetValue("responses-per-second", "1")
+	cfg.SetValue("slip-ratio", "0")
+	cfg.SetNowFunc(func() time.Time { return time.Time{} })
+	R := NewRRL(cfg)
+
+	src := newAddr("udp", "10.0.0.1:53")
+	referral := newTuple(1, 1, "example.com.", AllowanceReferral)
+	answer := newTuple(1, 1, "example.com.", AllowanceAnswer)
+	R.Debit(src, referral)
+	R.Debit(src, answer)
+
+	R.DisableCategory(AllowanceReferral)
+	R.DisableCategory(AllowanceLast) // Ignored
+	if !R.CategoryDisabled(AllowanceReferral) || R.CategoryDisabled(AllowanceAnswer) ||
+		R.CategoryDisabled(AllowanceLast) {
+		t.Error("Only referrals should be disabled")
+	}
+	for ix := 0; ix < 3; ix++ {
+		if act, _, rtr := R.Debit(src, referral); act != Send || rtr != RTDisabled {
+			t.Error(ix, "Disabled category should be sent, not", act, rtr)
+		}
+	}
+	if act, _, rtr := R.Check(src, referral); act != Send || rtr != RTDisabled {
+		t.Error("Check should report the disabled category", act, rtr)
+	}
+	if act, _, rtr := R.Debit(src, answer); act != Drop || rtr != RTRateLimit {
+		t.Error("Other categories should still be limited", act, rtr)
+	}
+	if stats := R.GetStats(false); stats.RTReasons[RTDisabled] != 3 {
+		t.Error("RTDisabled should be counted", stats.String())
+	}
+
+	// The referral account was not debited while disabled
+	R.EnableCategory(AllowanceReferral)
+	if R.CategoryDisabled(AllowanceReferral) {
+		t.Error("Referrals should be enabled")
+	}
+	if act, _, rtr := R.Debit(src, referral); act != Drop || rtr != RTRateLimit {
+		t.Error("Re-enabled category should be limited again", act, rtr)
+	}
+	b, _ := R.BalanceOf(AccountKey{Kind: AccountResponse, Network: "10.0.0.0",
+		AllowanceCategory: AllowanceReferral, Type: 1, SalientName: "example.com."})
+	if b != -time.Second {
+		t.Error("Referral account should only have two debits, not", b)
+	}
+}
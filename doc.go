@@ -33,6 +33,10 @@ system and the DNS-specific rrl.
 Note that requests with valid server cookies are never rate-limited so a BADCOOKIE
 response is always valid in the presence of a client cookie.
 
+Servers which prefer to apply the requests-per-second limit as soon as a request arrives
+can instead call [DebitRequest] on receipt and [DebitResponse] once the response is
+formulated; the combination is equivalent to a single [Debit] call.
+
 # Sample Code
 
 The follow example demonstrates the expected pattern of use.
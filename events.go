@@ -0,0 +1,244 @@
+package rrl
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EventKind identifies the account transitions exported by [RRL.ExportEvents].
+type EventKind int
+
+const (
+	EventCreate     EventKind = iota // A new account was created
+	EventLimit                       // An account in credit went into debit and is now rate limited
+	EventRecover                     // A rate limited account is back in credit
+	EventDegraded                    // The RRL entered the degraded state. See Health
+	EventNormal                      // The RRL left the degraded state
+	EventOverloaded                  // The RRL became overloaded. See Overloaded
+	EventRelieved                    // The RRL is no longer overloaded
+	EventForced                      // An Action was latched by ForceAction
+	EventReleased                    // A latched Action expired or was released
+	EventLast
+)
+
+// eventNames are the wire format names of each EventKind.
+var eventNames = [EventLast]string{"create", "limit", "recover", "degraded", "normal", "overloaded",
+	"relieved", "forced", "released"}
+
+// global returns true if the EventKind is a transition of the RRL as a whole rather than
+// of an account.
+func (kind EventKind) global() bool {
+	switch kind {
+	case EventDegraded, EventNormal, EventOverloaded, EventRelieved, EventForced, EventReleased:
+		return true
+	}
+
+	return false
+}
+
+// Event describes a single account transition. For EventDegraded, EventNormal,
+// EventOverloaded, EventRelieved, EventForced and EventReleased, which are transitions of
+// the RRL as a whole, Key, Tag and Balance are zero values, except that the Key of
+// EventForced and EventReleased carries the latched AllowanceCategory.
+type Event struct {
+	Time    time.Time
+	Kind    EventKind
+	Key     AccountKey
+	Tag     string        // The tag passed to DebitTagged, if any
+	Balance time.Duration // Balance immediately after the transition
+	Action  Action        // The latched Action of EventForced and EventReleased
+	Until   time.Time     // The expiry of EventForced
+}
+
+// eventJSON is the wire format of an Event. Field names are short as exports can be
+// voluminous. Response Tuple fields are omitted for non-response accounts and all account
+// fields are omitted for events which are not about an account.
+type eventJSON struct {
+	Time     string  `json:"t"`
+	Event    string  `json:"ev"`
+	Kind     string  `json:"kind,omitempty"`
+	Network  string  `json:"net,omitempty"`
+	Category string  `json:"cat,omitempty"`
+	Class    uint16  `json:"class,omitempty"`
+	Type     uint16  `json:"type,omitempty"`
+	Name     string  `json:"name,omitempty"`
+	ID       string  `json:"id,omitempty"`
+	Tag      string  `json:"tag,omitempty"`
+	Balance  float64 `json:"bal"`
+	Action   string  `json:"act,omitempty"`
+	Until    string  `json:"until,omitempty"`
+}
+
+// EventExporter streams account events as newline delimited JSON (NDJSON) suitable for
+// ingestion into analytics databases such as ClickHouse or BigQuery. It is created by
+// [RRL.ExportEvents].
+//
+// Events are queued by [Debit] and written by a separate goroutine so a slow writer never
+// delays Debit. If the queue is full, events are dropped and counted rather than
+// blocking.
+type EventExporter struct {
+	rrl     *RRL
+	queue   chan Event
+	w       *bufio.Writer
+	done    chan struct{}
+	dropped atomic.Uint64
+
+	mu     sync.RWMutex // Protects closed and guards sends on queue
+	closed bool
+	err    error // First write error. Written only by the writer goroutine
+}
+
+// ExportEvents starts exporting account create, limit and recover events, as well as the
+// degraded, normal, overloaded, relieved, forced and released transitions of the RRL
+// itself, to w as NDJSON. Each line is a JSON object with the following fields:
+//
+//	t     - RFC3339Nano timestamp
+//	ev    - "create", "limit", "recover", "degraded", "normal", "overloaded", "relieved",
+//	        "forced" or "released"
+//	kind  - the AccountKind, e.g. "AccountResponse" (account events only)
+//	net   - the Client Network or ipv6 aggregate network (account events only)
+//	cat   - the AllowanceCategory (response accounts, forced and released only). "all"
+//	        if the latch applies to all categories
+//	class - the query class (response accounts only, when included and not ClassINET)
+//	type  - the query type (response accounts only, when applicable)
+//	name  - the SalientName (response accounts only, when applicable)
+//	id    - the keyed account identifier when "account-hash-key" is configured
+//	tag   - the tag passed to [RRL.DebitTagged], if any
+//	bal   - the balance in seconds immediately after the transition
+//	act   - the latched Action (forced and released only)
+//	until - RFC3339Nano expiry of the latch (forced only)
+//
+// depth is the number of events which can be queued before events are dropped. Any
+// previously active EventExporter is closed. The caller must call [EventExporter.Close]
+// to stop the export and flush w.
+func (rrl *RRL) ExportEvents(w io.Writer, depth int) *EventExporter {
+	if depth < 1 {
+		depth = 1
+	}
+	ee := &EventExporter{
+		rrl:   rrl,
+		queue: make(chan Event, depth),
+		w:     bufio.NewWriter(w),
+		done:  make(chan struct{}),
+	}
+	go ee.run()
+	if old := rrl.events.Swap(ee); old != nil {
+		old.Close()
+	}
+
+	return ee
+}
+
+// emitEvent reports a transition to the StatsSink and queues an event for the active
+// EventExporter, if any. The Event is not constructed if neither wants it.
+func (rrl *RRL) emitEvent(kind EventKind, t, tag string, balance int64) {
+	if _, ok := rrl.statsSink().(internalSink); ok && rrl.events.Load() == nil {
+		return
+	}
+	rrl.publishEvent(&Event{Time: rrl.clock.wall(), Kind: kind, Key: parseAccountKey(t), Tag: tag,
+		Balance: time.Duration(balance)})
+}
+
+// publishEvent reports ev to the StatsSink and queues it for the active EventExporter, if
+// any.
+func (rrl *RRL) publishEvent(ev *Event) {
+	rrl.stateChange(ev)
+	ee := rrl.events.Load()
+	if ee == nil {
+		return
+	}
+
+	ee.mu.RLock()
+	defer ee.mu.RUnlock()
+	if ee.closed {
+		return
+	}
+	if !ev.Kind.global() && !rrl.emits.Allow() {
+		ee.dropped.Add(1)
+		return
+	}
+	select {
+	case ee.queue <- *ev:
+	default:
+		ee.dropped.Add(1)
+	}
+}
+
+// run writes queued events until the queue is closed.
+func (ee *EventExporter) run() {
+	defer close(ee.done)
+	enc := json.NewEncoder(ee.w)
+	for ev := range ee.queue {
+		if ee.err == nil {
+			ee.err = enc.Encode(ee.rrl.eventJSON(&ev))
+		}
+		if len(ee.queue) == 0 && ee.err == nil {
+			ee.err = ee.w.Flush() // Flush when idle so events are not held back
+		}
+	}
+	if ee.err == nil {
+		ee.err = ee.w.Flush()
+	}
+}
+
+// eventJSON converts an Event to its wire format.
+func (rrl *RRL) eventJSON(ev *Event) *eventJSON {
+	ej := &eventJSON{
+		Time:    ev.Time.UTC().Format(time.RFC3339Nano),
+		Event:   eventNames[ev.Kind],
+		Tag:     ev.Tag,
+		Balance: ev.Balance.Seconds(),
+	}
+	if ev.Kind == EventForced || ev.Kind == EventReleased {
+		ej.Category = "all"
+		if ev.Key.AllowanceCategory < AllowanceLast {
+			ej.Category = ev.Key.AllowanceCategory.String()
+		}
+		ej.Action = ev.Action.String()
+		if !ev.Until.IsZero() {
+			ej.Until = ev.Until.UTC().Format(time.RFC3339Nano)
+		}
+	}
+	if ev.Kind.global() {
+		return ej
+	}
+	ej.Kind = ev.Key.Kind.String()
+	ej.Network = ev.Key.Network
+	if ev.Key.Kind == AccountResponse {
+		ej.Category = ev.Key.AllowanceCategory.String()
+		ej.Class = ev.Key.Class
+		ej.Type = ev.Key.Type
+		ej.Name = ev.Key.SalientName
+	}
+	if len(rrl.cfg.accountHashKey) > 0 {
+		ej.ID = rrl.hashToken(ev.Key.token(rrl))
+	}
+
+	return ej
+}
+
+// Dropped returns the number of events dropped because the queue was full or
+// max-events-per-second was exceeded.
+func (ee *EventExporter) Dropped() uint64 {
+	return ee.dropped.Load()
+}
+
+// Close stops the export, writes all queued events and flushes the writer. Close
+// returns the first error encountered writing events. Close can be called multiple
+// times.
+func (ee *EventExporter) Close() error {
+	ee.rrl.events.CompareAndSwap(ee, nil)
+	ee.mu.Lock()
+	if !ee.closed {
+		ee.closed = true
+		close(ee.queue)
+	}
+	ee.mu.Unlock()
+	<-ee.done
+
+	return ee.err
+}
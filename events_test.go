@@ -0,0 +1,150 @@
+package rrl
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestExportEvents(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cfg := NewConfig()
+	cfg.SetValue("responses-per-second", "1")
+	cfg.SetValue("account-hash-key", "k")
+	cfg.SetNowFunc(func() time.Time { return now })
+	R := NewRRL(cfg)
+
+	var out bytes.Buffer
+	ee := R.ExportEvents(&out, 100)
+
+	src := newAddr("udp", "10.0.0.1:53")
+	tuple := newTuple(1, 1, "example.com.", AllowanceAnswer)
+	R.DebitTagged("eth0", src, tuple) // Create
+	R.Debit(src, tuple)               // Limit
+	R.Debit(src, tuple)               // Still limited, no event
+	now = now.Add(10 * time.Second)
+	R.Debit(src, tuple) // Recover
+
+	if err := ee.Close(); err != nil {
+		t.Fatal("Unexpected Close error", err)
+	}
+	R.Debit(newAddr("udp", "10.0.1.1:53"), tuple) // Closed so no event
+
+	var events []eventJSON
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		var ev eventJSON
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			t.Fatal("Invalid NDJSON line", scanner.Text(), err)
+		}
+		events = append(events, ev)
+	}
+	if len(events) != 3 {
+		t.Fatal("Expected 3 events, got", len(events), out.String())
+	}
+	for ix, exp := range []string{"create", "limit", "recover"} {
+		ev := events[ix]
+		if ev.Event != exp {
+			t.Error(ix, "Expected event", exp, "got", ev.Event)
+		}
+		if ev.Kind != "AccountResponse" || ev.Network != "10.0.0.0" || ev.Name != "example.com." ||
+			ev.Category != "AllowanceAnswer" || ev.Type != 1 || len(ev.ID) != 32 {
+			t.Error(ix, "Unexpected event fields", ev)
+		}
+	}
+	if events[0].Tag != "eth0" || events[1].Tag != "" {
+		t.Error("Unexpected tags", events[0].Tag, events[1].Tag)
+	}
+	if events[1].Balance >= 0 || events[2].Balance < 0 {
+		t.Error("Unexpected balances", events[1].Balance, events[2].Balance)
+	}
+	if events[0].Time != "1970-01-01T00:16:40Z" {
+		t.Error("Unexpected time", events[0].Time)
+	}
+	if ee.Close() != nil {
+		t.Error("Second Close should also succeed")
+	}
+}
+
+type failWriter struct{}
+
+func (failWriter) Write([]byte) (int, error) { return 0, errors.New("write failed") }
+
+func TestExportEventsLimit(t *testing.T) {
+	for _, tc := range []struct {
+		rate    string
+		allowed int
+	}{
+		{"5", 5},
+		{"0.5", 1}, // Rates below one per second have a burst of one
+	} {
+		cfg := NewConfig()
+		cfg.SetValue("responses-per-second", "1")
+		cfg.SetValue("max-events-per-second", tc.rate)
+		cfg.SetNowFunc(func() time.Time { return time.Unix(1000, 0) })
+		R := NewRRL(cfg)
+
+		var out bytes.Buffer
+		ee := R.ExportEvents(&out, 100)
+		tuple := newTuple(1, 1, "example.com.", AllowanceAnswer)
+		for ix := 0; ix < 20; ix++ {
+			R.Debit(newAddr("udp", fmt.Sprintf("10.0.%d.1:53", ix)), tuple) // Create
+		}
+		if err := ee.Close(); err != nil {
+			t.Fatal("Unexpected Close error", err)
+		}
+		if lines := bytes.Count(out.Bytes(), []byte("\n")); lines != tc.allowed {
+			t.Error(tc.rate, "Expected max-events-per-second to allow", tc.allowed, "events, not", lines)
+		}
+		if ee.Dropped() != uint64(20-tc.allowed) {
+			t.Error(tc.rate, "Expected", 20-tc.allowed, "events to be dropped, not", ee.Dropped())
+		}
+	}
+}
+
+func TestExportEventsErrors(t *testing.T) {
+	cfg := NewConfig()
+	cfg.SetValue("responses-per-second", "1")
+	R := NewRRL(cfg)
+
+	ee := R.ExportEvents(failWriter{}, 1)
+	R.Debit(newAddr("udp", "10.0.0.1:53"), newTuple(1, 1, "example.com.", AllowanceAnswer))
+	if err := ee.Close(); err == nil {
+		t.Error("Expected write error from Close")
+	}
+
+	// A blocked writer causes the queue to fill and events to be dropped
+	bw := &blockWriter{release: make(chan struct{})}
+	first := R.ExportEvents(bw, 1)
+	for ix := 0; ix < 10; ix++ {
+		R.emitEvent(EventCreate, "10.0.0.0", "", 0)
+	}
+	if first.Dropped() == 0 {
+		t.Error("Expected dropped events with a blocked writer")
+	}
+	close(bw.release)
+
+	// Replacing an exporter closes the previous one
+	second := R.ExportEvents(&bytes.Buffer{}, 1)
+	if err := first.Close(); err != nil {
+		t.Error("Unexpected error", err)
+	}
+	second.Close()
+	if R.events.Load() != nil {
+		t.Error("Close should detach exporter")
+	}
+}
+
+// blockWriter blocks all writes until release is closed
+type blockWriter struct {
+	release chan struct{}
+}
+
+func (bw *blockWriter) Write(b []byte) (int, error) {
+	<-bw.release
+	return len(b), nil
+}
@@ -0,0 +1,17 @@
+module github.com/markdingo/rrl/examples/authserver
+
+go 1.20
+
+require (
+	github.com/markdingo/rrl v0.0.0
+	github.com/miekg/dns v1.1.58
+)
+
+require (
+	golang.org/x/mod v0.14.0 // indirect
+	golang.org/x/net v0.20.0 // indirect
+	golang.org/x/sys v0.16.0 // indirect
+	golang.org/x/tools v0.17.0 // indirect
+)
+
+replace github.com/markdingo/rrl => ../..
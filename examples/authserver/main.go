@@ -0,0 +1,261 @@
+/*
+authserver is a small, runnable authoritative DNS server which demonstrates how the rrl
+package is wired into a server end-to-end. It serves a single built-in zone over UDP and
+TCP and shows:
+
+  - DNS Cookies (RFC7873) and exempting queries with a valid server cookie from RRL
+  - constructing the Response Tuple with rrl.NewAllowanceCategory and rrl.NewZoneTuple
+  - acting on Send, Drop and Slip, where Slip is a BADCOOKIE response if the client
+    sent a cookie and a truncated response otherwise
+  - periodically reporting rrl.Stats
+  - mounting the dashboard on an admin HTTP listener
+
+authserver is a separate module so that the rrl package itself has no dependencies.
+
+Usage:
+
+	authserver [options] [keyword=value...]
+
+Trailing keyword=value arguments are passed to rrl.Config.SetValue, e.g.:
+
+	authserver -listen 127.0.0.1:5353 -admin 127.0.0.1:8053 responses-per-second=5
+
+then query with:
+
+	dig @127.0.0.1 -p 5353 www.example.com
+*/
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/markdingo/rrl"
+	"github.com/markdingo/rrl/dashboard"
+	"github.com/miekg/dns"
+)
+
+type options struct {
+	listen string
+	admin  string
+	origin string
+	stats  time.Duration
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stderr))
+}
+
+func run(args []string, stderr io.Writer) int {
+	var opts options
+	fs := flag.NewFlagSet("authserver", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&opts.listen, "listen", "127.0.0.1:5353", "Address to serve DNS on, UDP and TCP")
+	fs.StringVar(&opts.admin, "admin", "", "Address of the admin HTTP listener serving the dashboard")
+	fs.StringVar(&opts.origin, "origin", "example.com.", "Origin of the built-in zone")
+	fs.DurationVar(&opts.stats, "stats", time.Minute, "Interval between stats reports, zero disables")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: authserver [options] [keyword=value...]")
+		fs.PrintDefaults()
+		fmt.Fprintln(stderr, "\nConfig keywords:")
+		rrl.NewConfig().Describe(stderr, rrl.FormatText)
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg := rrl.NewConfig()
+	for _, kv := range fs.Args() {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			fmt.Fprintf(stderr, "Error: '%s' is not a keyword=value pair\n", kv)
+			return 2
+		}
+		if err := cfg.SetValue(k, v); err != nil {
+			fmt.Fprintln(stderr, "Error:", err)
+			return 2
+		}
+	}
+	R, err := rrl.NewRRLChecked(cfg)
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 2
+	}
+
+	logger := log.New(stderr, "authserver: ", log.LstdFlags)
+	s := newServer(R, newZone(opts.origin))
+
+	pc, err := net.ListenPacket("udp", opts.listen)
+	if err != nil {
+		logger.Println(err)
+		return 1
+	}
+	l, err := net.Listen("tcp", pc.LocalAddr().String())
+	if err != nil {
+		logger.Println(err)
+		return 1
+	}
+	go func() { logger.Println((&dns.Server{Listener: l, Handler: s}).ActivateAndServe()) }()
+
+	if len(opts.admin) > 0 {
+		mux := http.NewServeMux()
+		mux.Handle("/rrl/", http.StripPrefix("/rrl", dashboard.Handler(R)))
+		go func() { logger.Println(http.ListenAndServe(opts.admin, mux)) }()
+	}
+	if opts.stats > 0 {
+		go func() {
+			for range time.Tick(opts.stats) {
+				stats := R.GetStats(true)
+				logger.Println(stats.String())
+			}
+		}()
+	}
+
+	logger.Println("serving", opts.origin, "on", pc.LocalAddr())
+	if err := (&dns.Server{PacketConn: pc, Handler: s}).ActivateAndServe(); err != nil {
+		logger.Println(err)
+		return 1
+	}
+
+	return 0
+}
+
+// server answers queries from its zone subject to the RRL. It is a dns.Handler.
+type server struct {
+	R      *rrl.RRL
+	zone   *zone
+	finder *rrl.ZoneTrie
+	secret []byte // Server cookie secret
+}
+
+func newServer(R *rrl.RRL, z *zone) *server {
+	s := &server{R: R, zone: z, finder: rrl.NewZoneTrie([]string{z.origin}), secret: make([]byte, 32)}
+	rand.Read(s.secret)
+
+	return s
+}
+
+// ServeDNS implements dns.Handler.
+func (s *server) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	if resp := s.handle(req, w.RemoteAddr()); resp != nil {
+		w.WriteMsg(resp)
+	}
+}
+
+// handle returns the response to the query req from src, or nil if the RRL recommends
+// dropping the response.
+func (s *server) handle(req *dns.Msg, src net.Addr) *dns.Msg {
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	resp.Authoritative = true
+	if len(req.Question) != 1 { // Malformed queries are debited with a nil tuple
+		resp.Rcode = dns.RcodeFormatError
+		switch act, _, _ := s.R.Debit(src, nil); act {
+		case rrl.Drop:
+			return nil
+		case rrl.Slip:
+			resp.Truncated = true
+		}
+		return resp
+	}
+	q := req.Question[0]
+	nsCount := s.zone.lookup(resp)
+
+	validServerCookie := false
+	clientCookie, serverCookie, hasCookie := cookies(req)
+	if opt := req.IsEdns0(); opt != nil {
+		resp.SetEdns0(1232, false)
+		if hasCookie {
+			sc := s.serverCookie(clientCookie, src)
+			validServerCookie = serverCookie != nil && hmac.Equal(serverCookie, sc)
+			ropt := resp.IsEdns0()
+			ropt.Option = append(ropt.Option, &dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE,
+				Cookie: hex.EncodeToString(append(append([]byte{}, clientCookie...), sc...))})
+		}
+	}
+
+	// Only rate limit if the source could be spoofed
+	action := rrl.Send
+	if !validServerCookie {
+		ac := rrl.NewAllowanceCategory(resp.Rcode, len(resp.Answer), nsCount)
+		tuple, ok := rrl.NewZoneTuple(q.Qclass, q.Qtype, q.Name, ac, s.finder)
+		if !ok { // Outside our zone so there is no SalientName
+			tuple = &rrl.ResponseTuple{Class: q.Qclass, Type: q.Qtype, AllowanceCategory: ac}
+		}
+		action, _, _ = s.R.Debit(src, tuple)
+	}
+
+	switch action {
+	case rrl.Drop:
+		return nil
+
+	case rrl.Slip:
+		opt := resp.IsEdns0()
+		resp.Answer, resp.Ns, resp.Extra = nil, nil, nil
+		if opt != nil {
+			resp.Extra = []dns.RR{opt}
+		}
+		if hasCookie { // A BADCOOKIE response carrying a fresh server cookie
+			resp.Rcode = dns.RcodeBadCookie
+		} else {
+			resp.Truncated = true
+		}
+		return resp
+	}
+
+	if _, tcp := src.(*net.TCPAddr); !tcp { // Size limits only apply to UDP
+		size := dns.MinMsgSize
+		if opt := req.IsEdns0(); opt != nil && int(opt.UDPSize()) > size {
+			size = int(opt.UDPSize())
+		}
+		resp.Truncate(size)
+	}
+
+	return resp
+}
+
+// cookies returns the client and server cookies of the query. The server cookie is nil if
+// the client only sent a client cookie.
+func cookies(req *dns.Msg) (client, server []byte, ok bool) {
+	opt := req.IsEdns0()
+	if opt == nil {
+		return
+	}
+	for _, o := range opt.Option {
+		if c, isCookie := o.(*dns.EDNS0_COOKIE); isCookie {
+			b, err := hex.DecodeString(c.Cookie)
+			if err != nil || len(b) < 8 {
+				return nil, nil, false
+			}
+			client, server, ok = b[:8], b[8:], true
+			if len(server) == 0 {
+				server = nil
+			}
+			return
+		}
+	}
+
+	return
+}
+
+// serverCookie returns the server cookie for the client cookie and client address.
+func (s *server) serverCookie(clientCookie []byte, src net.Addr) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write(clientCookie)
+	if host, _, err := net.SplitHostPort(src.String()); err == nil {
+		mac.Write([]byte(host))
+	}
+
+	return mac.Sum(nil)[:8]
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/markdingo/rrl"
+	"github.com/miekg/dns"
+)
+
+// makeQuery returns a query for qName and qType, with an OPT RR carrying cookie if cookie
+// is not nil.
+func makeQuery(qName string, qType uint16, cookie []byte) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetQuestion(qName, qType)
+	if cookie != nil {
+		m.SetEdns0(1232, false)
+		opt := m.IsEdns0()
+		opt.Option = append(opt.Option, &dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE,
+			Cookie: hex.EncodeToString(cookie)})
+	}
+
+	return m
+}
+
+// responseCookie returns the cookie of the response, or nil if it has none.
+func responseCookie(t *testing.T, m *dns.Msg) []byte {
+	t.Helper()
+	if opt := m.IsEdns0(); opt != nil {
+		for _, o := range opt.Option {
+			if c, ok := o.(*dns.EDNS0_COOKIE); ok {
+				b, err := hex.DecodeString(c.Cookie)
+				if err != nil {
+					t.Fatal("Invalid cookie", c.Cookie)
+				}
+				return b
+			}
+		}
+	}
+
+	return nil
+}
+
+func TestHandle(t *testing.T) {
+	cfg := rrl.NewConfig()
+	cfg.SetValue("responses-per-second", "1")
+	cfg.SetValue("window", "1")
+	cfg.SetValue("slip-ratio", "1")
+	s := newServer(rrl.NewRRL(cfg), newZone("example.com"))
+	src := &net.UDPAddr{IP: net.ParseIP("192.0.2.10"), Port: 4053}
+
+	testCases := []struct {
+		qName   string
+		qType   uint16
+		rcode   int
+		anCount int
+		nsCount int
+	}{
+		{"www.example.com.", dns.TypeA, dns.RcodeSuccess, 1, 0},
+		{"WWW.Example.COM.", dns.TypeAAAA, dns.RcodeSuccess, 1, 0},
+		{"www.example.com.", dns.TypeSOA, dns.RcodeSuccess, 0, 1}, // NODATA
+		{"nope.example.com.", dns.TypeA, dns.RcodeNameError, 0, 1},
+		{"www.example.net.", dns.TypeA, dns.RcodeRefused, 0, 0},
+	}
+	for ix, tc := range testCases {
+		m := s.handle(makeQuery(tc.qName, tc.qType, nil), src)
+		if m == nil {
+			t.Fatal(ix, "Unexpected nil response")
+		}
+		if m.Rcode != tc.rcode || len(m.Answer) != tc.anCount || len(m.Ns) != tc.nsCount || m.Truncated {
+			t.Error(ix, "Unexpected response", m)
+		}
+		if _, err := m.Pack(); err != nil {
+			t.Error(ix, "Response does not pack", err)
+		}
+	}
+
+	// Malformed queries share the error account of their Client Network, so they are
+	// debited from a network of their own before sharing the account exhausted by the
+	// REFUSED response above.
+	malformed := &net.UDPAddr{IP: net.ParseIP("198.51.100.10"), Port: 4053}
+	if m := s.handle(new(dns.Msg), malformed); m == nil || m.Rcode != dns.RcodeFormatError || m.Truncated {
+		t.Error("Malformed query should get FORMERR", m)
+	}
+	if m := s.handle(new(dns.Msg), src); m == nil || m.Rcode != dns.RcodeFormatError || !m.Truncated {
+		t.Error("Rate limited malformed query should slip as a truncated FORMERR", m)
+	}
+
+	// Once rate limited, responses are truncated with a slip-ratio of one
+	www := makeQuery("www.example.com.", dns.TypeA, nil)
+	if m := s.handle(www, src); m == nil || !m.Truncated || len(m.Answer) != 0 {
+		t.Error("Expected a truncated slip", m)
+	}
+
+	// A client cookie alone slips as BADCOOKIE and returns a server cookie
+	clientCookie := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	m := s.handle(makeQuery("www.example.com.", dns.TypeA, clientCookie), src)
+	if m == nil {
+		t.Fatal("Expected BADCOOKIE, not nil")
+	}
+	cookie := responseCookie(t, m)
+	if m.Rcode != dns.RcodeBadCookie || len(cookie) != 16 || !bytes.Equal(cookie[:8], clientCookie) {
+		t.Fatal("Expected BADCOOKIE with a server cookie", m)
+	}
+	if _, err := m.Pack(); err != nil {
+		t.Error("BADCOOKIE response does not pack", err)
+	}
+
+	// Which exempts the client from rate limiting
+	for ix := 0; ix < 5; ix++ {
+		m := s.handle(makeQuery("www.example.com.", dns.TypeA, cookie), src)
+		if m == nil || m.Rcode != dns.RcodeSuccess || len(m.Answer) != 1 ||
+			!bytes.Equal(responseCookie(t, m), cookie) {
+			t.Fatal(ix, "Valid server cookie should not be rate limited", m)
+		}
+	}
+
+	// But only from the address it was issued to
+	other := &net.UDPAddr{IP: net.ParseIP("192.0.2.11"), Port: 4053}
+	if m := s.handle(makeQuery("www.example.com.", dns.TypeA, cookie), other); m == nil ||
+		m.Rcode != dns.RcodeBadCookie {
+		t.Error("Server cookie from another address should not be valid", m)
+	}
+
+	// TCP is never rate limited
+	tcp := &net.TCPAddr{IP: src.IP, Port: 4053}
+	if m := s.handle(www, tcp); m == nil || len(m.Answer) != 1 {
+		t.Error("TCP should not be rate limited", m)
+	}
+}
+
+func TestServeUDP(t *testing.T) {
+	cfg := rrl.NewConfig()
+	cfg.SetValue("responses-per-second", "1")
+	cfg.SetValue("slip-ratio", "0")
+	s := newServer(rrl.NewRRL(cfg), newZone("example.com"))
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("Cannot listen on UDP", err)
+	}
+	srv := &dns.Server{PacketConn: pc, Handler: s}
+	go srv.ActivateAndServe()
+	defer srv.Shutdown()
+
+	var answers int
+	c := &dns.Client{Timeout: 200 * time.Millisecond}
+	for ix := 0; ix < 5; ix++ {
+		if m, _, err := c.Exchange(makeQuery("www.example.com.", dns.TypeA, nil), pc.LocalAddr().String()); err == nil &&
+			len(m.Answer) == 1 {
+			answers++
+		}
+	}
+	if answers != 1 {
+		t.Error("Expected a single answer before responses were dropped, not", answers)
+	}
+	if stats := s.R.GetStats(false); stats.Actions[rrl.Drop] != 4 {
+		t.Error("Expected four drops", stats.String())
+	}
+}
+
+func TestRun(t *testing.T) {
+	var stderr bytes.Buffer
+	for _, args := range [][]string{{"window"}, {"windox=1"}, {"-bogus"}, {"responses-per-second=0.01"}} {
+		stderr.Reset()
+		if rc := run(args, &stderr); rc != 2 {
+			t.Error("Expected exit code 2 for", args, "got", rc, stderr.String())
+		}
+	}
+}
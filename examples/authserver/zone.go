@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+const zoneTTL = 300
+
+// zone is a tiny built-in authoritative zone holding A and AAAA records.
+type zone struct {
+	origin  string
+	soa     dns.RR
+	records map[string][]dns.RR // Owner -> RRs
+}
+
+// newZone returns the built-in zone at origin. It contains the origin itself, "www" and
+// "ns1".
+func newZone(origin string) *zone {
+	origin = dns.CanonicalName(origin)
+	z := &zone{origin: origin, records: make(map[string][]dns.RR)}
+	z.soa = &dns.SOA{Hdr: z.header(origin, dns.TypeSOA), Ns: "ns1." + origin, Mbox: "hostmaster." + origin,
+		Serial: 1, Refresh: 3600, Retry: 600, Expire: 86400, Minttl: zoneTTL}
+
+	z.add(origin, "192.0.2.1")
+	z.add("www."+origin, "192.0.2.2")
+	z.add("www."+origin, "2001:db8::2")
+	z.add("ns1."+origin, "192.0.2.53")
+
+	return z
+}
+
+func (z *zone) header(owner string, rrType uint16) dns.RR_Header {
+	return dns.RR_Header{Name: owner, Rrtype: rrType, Class: dns.ClassINET, Ttl: zoneTTL}
+}
+
+func (z *zone) add(owner, addr string) {
+	ip := net.ParseIP(addr)
+	var rr dns.RR = &dns.AAAA{Hdr: z.header(owner, dns.TypeAAAA), AAAA: ip}
+	if ip4 := ip.To4(); ip4 != nil {
+		rr = &dns.A{Hdr: z.header(owner, dns.TypeA), A: ip4}
+	}
+	z.records[owner] = append(z.records[owner], rr)
+}
+
+// lookup sets the rcode and sections of resp to answer its question and returns the
+// number of NS RRs in the authority section, as needed by rrl.NewAllowanceCategory. The
+// zone has no delegations so there are never any NS RRs.
+func (z *zone) lookup(resp *dns.Msg) int {
+	q := resp.Question[0]
+	qName := strings.ToLower(q.Name)
+	if qName != z.origin && !strings.HasSuffix(qName, "."+z.origin) {
+		resp.Rcode = dns.RcodeRefused
+		resp.Authoritative = false
+		return 0
+	}
+
+	rrs, found := z.records[qName]
+	if !found {
+		resp.Rcode = dns.RcodeNameError
+		resp.Ns = []dns.RR{z.soa}
+		return 0
+	}
+	for _, rr := range rrs {
+		if rr.Header().Rrtype == q.Qtype {
+			rr = dns.Copy(rr)
+			rr.Header().Name = q.Name // Preserve the case of the question
+			resp.Answer = append(resp.Answer, rr)
+		}
+	}
+	if len(resp.Answer) == 0 { // NODATA
+		resp.Ns = []dns.RR{z.soa}
+	}
+
+	return 0
+}
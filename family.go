@@ -0,0 +1,150 @@
+package rrl
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Address families of Client Networks tracked by family-stats and qualified by the family
+// keywords
+const (
+	familyIPv4 = iota
+	familyIPv6
+	familyLast
+)
+
+// familyPrefixes are the keyword prefixes of each address family, e.g.
+// "ipv6:responses-per-second".
+var familyPrefixes = [familyLast]string{"ipv4:", "ipv6:"}
+
+// The family keywords are added to the keywords table so they are described, settable and
+// captured by Policy like any other keyword.
+func init() {
+	for fam, prefix := range familyPrefixes {
+		for ac := AllowanceAnswer; ac <= AllowanceLast; ac++ {
+			fam, ac := fam, ac
+			base := familyKeywordBase(ac)
+			keywords = append(keywords, keyword{prefix + base, "float", ">=0", base,
+				lookupKeyword(base).description + " (" + prefix[:4] + " only)",
+				func(c *Config) string { return rateString(c.familyInterval(fam, ac)) }})
+		}
+	}
+}
+
+// familyKeywordBase returns the unqualified keyword of a family keyword. AllowanceLast is
+// requests-per-second.
+func familyKeywordBase(ac AllowanceCategory) string {
+	if ac == AllowanceLast {
+		return "requests-per-second"
+	}
+
+	return ac.keyword()
+}
+
+// familyKeyword returns the address family and AllowanceCategory of a family keyword such
+// as "ipv6:nxdomains-per-second". ok is false if keyword is not a family keyword.
+func familyKeyword(keyword string) (fam int, ac AllowanceCategory, ok bool) {
+	for fam, prefix := range familyPrefixes {
+		if !strings.HasPrefix(keyword, prefix) {
+			continue
+		}
+		for ac := AllowanceAnswer; ac <= AllowanceLast; ac++ {
+			if keyword[len(prefix):] == familyKeywordBase(ac) {
+				return fam, ac, true
+			}
+		}
+	}
+
+	return
+}
+
+// familyInterval returns the interval of the AllowanceCategory, or of requests if ac is
+// AllowanceLast, for clients of the address family. A family keyword takes the place of
+// its unqualified keyword, after which the defaults between keywords are applied exactly
+// as finalize does for the unqualified keywords.
+func (c *Config) familyInterval(fam int, ac AllowanceCategory) int64 {
+	if c.familySet[fam][ac] {
+		return c.familyIntervals[fam][ac]
+	}
+	var interval int64
+	var set bool
+	switch ac {
+	case AllowanceAnswer:
+		return c.responsesInterval
+	case AllowanceLast:
+		return c.requestsInterval
+	case AllowanceReferral:
+		interval, set = c.referralsInterval, c.referralsIntervalSet
+	case AllowanceNoData:
+		interval, set = c.nodataInterval, c.nodataIntervalSet
+	case AllowanceNXDomain:
+		interval, set = c.nxdomainsInterval, c.nxdomainsIntervalSet
+	case AllowanceError:
+		interval, set = c.errorsInterval, c.errorsIntervalSet
+	case AllowanceTransfer:
+		if !c.transfersIntervalSet {
+			return c.familyInterval(fam, AllowanceError)
+		}
+		return c.transfersInterval
+	}
+	if !set {
+		return c.familyInterval(fam, AllowanceAnswer)
+	}
+
+	return interval
+}
+
+// familyOf returns the address family of a Client Network.
+func familyOf(ipPrefix string) int {
+	if strings.IndexByte(ipPrefix, ':') >= 0 {
+		return familyIPv6
+	}
+
+	return familyIPv4
+}
+
+// familyStats accumulates Stats separately for each address family when family-stats is
+// configured. It is protected by statsMu.
+type familyStats struct {
+	keys  [familyLast]string // The family and prefix length in use, e.g. "ipv4/24"
+	stats [familyLast]Stats
+}
+
+func newFamilyStats(cfg *Config) *familyStats {
+	fs := &familyStats{}
+	fs.keys[familyIPv4] = "ipv4/" + strconv.Itoa(cfg.ipv4PrefixLength)
+	fs.keys[familyIPv6] = "ipv6/" + strconv.Itoa(cfg.ipv6PrefixLength)
+
+	return fs
+}
+
+// familySlice returns the Stats of the address family of the Client Network or nil if
+// family-stats is not configured. The caller must hold statsMu.
+func (rrl *RRL) familySlice(ipPrefix string) *Stats {
+	if rrl.families == nil || len(ipPrefix) == 0 {
+		return nil
+	}
+
+	return &rrl.families.stats[familyOf(ipPrefix)]
+}
+
+// GetFamilyStats returns a copy of the stats accumulated for ipv4 and ipv6 Client
+// Networks if family-stats is configured, otherwise it returns nil. The map keys are the
+// family and the prefix length in use, "ipv4/24" and "ipv6/56" by default, so that stats
+// gathered under different ipv6-prefix-length settings are not confused. As with
+// [RRL.GetTagStats], the gauges are not tracked per family and are always zero.
+// The caller can optionally request that the stats be zeroed after the copy.
+func (rrl *RRL) GetFamilyStats(zeroAfter bool) map[string]Stats {
+	if rrl.families == nil {
+		return nil
+	}
+	rrl.statsMu.Lock()
+	defer rrl.statsMu.Unlock()
+
+	ret := make(map[string]Stats, familyLast)
+	for ix := range rrl.families.stats {
+		ret[rrl.families.keys[ix]] = rrl.families.stats[ix].Copy(zeroAfter)
+	}
+
+	return ret
+}
@@ -0,0 +1,114 @@
+package rrl_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/markdingo/rrl"
+)
+
+func TestFamilyStats(t *testing.T) {
+	if fs := rrl.NewRRL(rrl.NewConfig()).GetFamilyStats(false); fs != nil {
+		t.Error("Family stats should be nil unless configured", fs)
+	}
+
+	cfg := rrl.NewConfig()
+	cfg.SetValue("responses-per-second", "1")
+	cfg.SetValue("requests-per-second", "100")
+	cfg.SetValue("ipv6-prefix-length", "48")
+	cfg.SetValue("family-stats", "true")
+	R := rrl.NewRRL(cfg)
+
+	tuple := newTuple(1, 1, "example.com.", rrl.AllowanceAnswer)
+	R.Debit(newAddr("udp", "10.0.0.1:53"), tuple)
+	for ix := 0; ix < 3; ix++ {
+		R.Debit(newAddr("udp", "[2001:db8::1]:53"), tuple)
+	}
+	R.DebitRequest(newAddr("udp", "[2001:db8::2]:53"))
+	R.DebitResponse(newAddr("udp", "10.0.0.2:53"), tuple)
+
+	fs := R.GetFamilyStats(true)
+	if len(fs) != 2 {
+		t.Fatal("Expected ipv4 and ipv6 family stats, not", fs)
+	}
+	v4, v6 := fs["ipv4/24"], fs["ipv6/48"]
+	if v4.RPS[rrl.AllowanceAnswer] != 2 || v4.Actions[rrl.Send] != 1 || v4.Actions[rrl.Drop]+v4.Actions[rrl.Slip] != 1 {
+		t.Error("Unexpected ipv4 family stats", v4.String())
+	}
+	if v6.RPS[rrl.AllowanceAnswer] != 3 || v6.Actions[rrl.Send] != 1 || v6.IPReasons[rrl.IPOk] != 4 {
+		t.Error("Unexpected ipv6 family stats", v6.String())
+	}
+	total := R.GetStats(false)
+	if sum := v4.RPS[rrl.AllowanceAnswer] + v6.RPS[rrl.AllowanceAnswer]; sum != total.RPS[rrl.AllowanceAnswer] {
+		t.Error("Family stats should sum to the overall stats", sum, total.String())
+	}
+
+	fs = R.GetFamilyStats(false)
+	if s := fs["ipv6/48"]; s.RPS[rrl.AllowanceAnswer] != 0 {
+		t.Error("GetFamilyStats(true) should have zeroed family stats", s.String())
+	}
+}
+
+func TestFamilyAllowances(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cfg := rrl.NewConfig()
+	cfg.SetNowFunc(func() time.Time { return now })
+	for _, err := range []error{
+		cfg.SetValue("ipv5:responses-per-second", "1"),
+		cfg.SetValue("ipv6:window", "1"),
+	} {
+		if !errors.Is(err, rrl.ErrUnknownKeyword) {
+			t.Error("Expected ErrUnknownKeyword, not", err)
+		}
+	}
+	if err := cfg.SetValue("ipv6:responses-per-second", "-1"); err == nil ||
+		!strings.Contains(err.Error(), "negative") {
+		t.Error("Expected negative range error, not", err)
+	}
+	if cfg.IsActive() {
+		t.Error("Config should not be active without allowances")
+	}
+
+	cfg.SetValue("responses-per-second", "10")
+	cfg.SetValue("nodata-per-second", "5")
+	cfg.SetValue("slip-ratio", "0")
+	cfg.SetValue("ipv6:responses-per-second", "2")
+	cfg.SetValue("ipv6:requests-per-second", "4")
+	R := rrl.NewRRL(cfg)
+
+	sent := func(src string, ac rrl.AllowanceCategory) (count int) {
+		tuple := newTuple(1, 1, "example.com.", ac)
+		for ix := 0; ix < 20; ix++ {
+			if act, _, _ := R.Debit(newAddr("udp", src), tuple); act == rrl.Send {
+				count++
+			}
+		}
+		return
+	}
+	for ix, tc := range []struct {
+		src string
+		ac  rrl.AllowanceCategory
+		exp int
+	}{
+		{"10.0.0.1:53", rrl.AllowanceAnswer, 10},
+		{"10.0.0.1:53", rrl.AllowanceNXDomain, 10}, // Defaults to responses-per-second
+		{"10.0.0.1:53", rrl.AllowanceNoData, 5},
+		{"[2001:db8::1]:53", rrl.AllowanceAnswer, 2},
+		{"[2001:db8::1]:53", rrl.AllowanceNXDomain, 2}, // Defaults to ipv6:responses-per-second
+		{"[2001:db8::1]:53", rrl.AllowanceNoData, 4},   // nodata-per-second but limited by requests
+	} {
+		if got := sent(tc.src, tc.ac); got != tc.exp {
+			t.Error(ix, tc.src, tc.ac, "expected", tc.exp, "sent, not", got)
+		}
+		now = now.Add(time.Minute)
+	}
+
+	var out bytes.Buffer
+	cfg.Describe(&out, rrl.FormatText)
+	if !strings.Contains(out.String(), "ipv6:nodata-per-second") {
+		t.Error("Family keywords should be described", out.String())
+	}
+}
@@ -0,0 +1,83 @@
+//go:build rrlfaults
+
+package rrl
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+func init() {
+	features = append(features, "faults")
+}
+
+// Faults describes the failures injected into an RRL by [RRL.InjectFaults]. Fault
+// injection is only available when built with the "rrlfaults" build tag, e.g.:
+//
+//	go build -tags rrlfaults
+//
+// It is intended to allow operators to chaos-test how their server behaves when the
+// limiter degrades, without crafting millions of synthetic accounts. It should never be
+// enabled in production builds.
+type Faults struct {
+	// CacheFullRate is the proportion, from 0.0 to 1.0, of account debits which fail as
+	// if the shard were full. Debit returns IPCacheFull or RTCacheFull as appropriate.
+	CacheFullRate float64
+
+	// ClockOffset is added to the time used by the RRL, thus simulating clock jumps in
+	// either direction.
+	ClockOffset time.Duration
+
+	// Latency is added to every account debit, simulating a slow backend.
+	Latency time.Duration
+}
+
+// errInjectedCacheFull mimics the error returned by a full cache shard.
+var errInjectedCacheFull = errors.New("failed to add item, shard full (injected)")
+
+// faults holds the currently injected Faults.
+type faults struct {
+	mu      sync.RWMutex
+	current Faults
+}
+
+// InjectFaults replaces the currently injected faults with f. A zero Faults value clears
+// all injected faults. InjectFaults is concurrency safe and can be called while the RRL
+// is in use.
+func (rrl *RRL) InjectFaults(f Faults) {
+	rrl.faults.mu.Lock()
+	rrl.faults.current = f
+	rrl.faults.mu.Unlock()
+}
+
+// get returns a copy of the currently injected Faults.
+func (fs *faults) get() Faults {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	return fs.current
+}
+
+// init wraps the configured clock so that ClockOffset applies to all time calculations.
+// It must be called before the RRL is shared with other goroutines.
+func (fs *faults) init(cfg *Config) {
+	now := cfg.nowFunc
+	cfg.nowFunc = func() time.Time {
+		return now().Add(fs.get().ClockOffset)
+	}
+}
+
+// debit is called prior to every account debit to inject latency and shard-full errors.
+func (fs *faults) debit() error {
+	f := fs.get()
+	if f.Latency > 0 {
+		time.Sleep(f.Latency)
+	}
+	if f.CacheFullRate > 0 && rand.Float64() < f.CacheFullRate {
+		return errInjectedCacheFull
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+//go:build rrlfaults
+
+package rrl
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInjectFaults(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cfg := NewConfig()
+	cfg.SetValue("responses-per-second", "1")
+	cfg.SetValue("requests-per-second", "10")
+	cfg.SetNowFunc(func() time.Time { return now })
+	R := NewRRL(cfg)
+
+	src := newAddr("udp", "127.0.0.1:53")
+	tuple := newTuple(1, 1, "example.com.", AllowanceAnswer)
+
+	R.InjectFaults(Faults{CacheFullRate: 1})
+	if act, ipr, _ := R.Debit(src, tuple); act != Drop || ipr != IPCacheFull {
+		t.Error("Expected injected IPCacheFull, got", act, ipr)
+	}
+
+	R.InjectFaults(Faults{})
+	if act, ipr, rtr := R.Debit(src, tuple); act != Send {
+		t.Error("Expected Send once faults cleared, got", act, ipr, rtr)
+	}
+	if act, _, rtr := R.Debit(src, tuple); act == Send {
+		t.Error("Expected rate limit on second response, got", act, rtr)
+	}
+
+	// Jumping the clock forward should restore credit without real time passing
+	R.InjectFaults(Faults{ClockOffset: time.Minute})
+	if act, _, rtr := R.Debit(src, tuple); act != Send {
+		t.Error("Expected Send after clock jump, got", act, rtr)
+	}
+
+	R.InjectFaults(Faults{Latency: 20 * time.Millisecond})
+	start := time.Now()
+	R.Debit(src, tuple)
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Error("Expected injected latency, Debit took", elapsed)
+	}
+}
+
+func TestFaultsFeature(t *testing.T) {
+	for _, f := range Features() {
+		if f == "faults" {
+			return
+		}
+	}
+	t.Error("rrlfaults build should report the faults feature", Features())
+}
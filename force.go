@@ -0,0 +1,85 @@
+package rrl
+
+import (
+	"time"
+)
+
+// forcedAction is a latch set by ForceAction.
+type forcedAction struct {
+	act   Action
+	until int64 // Expiry in clock.now() nanoseconds
+}
+
+// ForceAction latches the Action of all responses of the [AllowanceCategory] to act
+// until the wall clock time until, e.g., to Drop all AllowanceError responses during a
+// verified attack or to Send all responses during a maintenance window. If ac is
+// AllowanceLast, the latch applies to all categories. A latch of a specific category takes
+// precedence over a latch of all categories.
+//
+// While latched, responses of the category are given an RTReason of RTForced and their
+// accounts are neither debited nor penalized. Request rate limiting is unaffected, so a
+// DebitRequest can still Drop a query regardless of the latch.
+//
+// A new latch replaces any existing latch of the same category. A latch expires
+// automatically at until and an until which is not in the future releases the latch
+// immediately. Setting and releasing a latch are exported as EventForced and
+// EventReleased events by [RRL.ExportEvents] to provide an audit trail.
+//
+// Invalid categories and Actions are ignored. ForceAction is concurrency safe.
+func (rrl *RRL) ForceAction(ac AllowanceCategory, act Action, until time.Time) {
+	if ac < AllowanceAnswer || ac > AllowanceLast || act < Send || act >= ActionLast {
+		return
+	}
+	now := rrl.clock.wall()
+	if !until.After(now) {
+		if old := rrl.forced[ac].Swap(nil); old != nil {
+			rrl.emitForceEvent(EventReleased, ac, old.act, time.Time{})
+		}
+		return
+	}
+	rrl.forced[ac].Store(&forcedAction{act: act, until: rrl.now() + int64(until.Sub(now))})
+	rrl.emitForceEvent(EventForced, ac, act, until)
+}
+
+// ForcedAction returns the Action latched by [RRL.ForceAction] for responses of the
+// [AllowanceCategory] and true, or false if the category is not latched.
+func (rrl *RRL) ForcedAction(ac AllowanceCategory) (Action, bool) {
+	if ac < AllowanceAnswer || ac >= AllowanceLast {
+		return Send, false
+	}
+
+	return rrl.forcedAction(ac)
+}
+
+// forcedAction returns the latched Action of the category, if any, releasing expired
+// latches along the way.
+func (rrl *RRL) forcedAction(ac AllowanceCategory) (Action, bool) {
+	if act, ok := rrl.latched(ac); ok {
+		return act, true
+	}
+
+	return rrl.latched(AllowanceLast)
+}
+
+// latched returns the Action of the latch held in forced[ac], if it has not expired.
+func (rrl *RRL) latched(ac AllowanceCategory) (Action, bool) {
+	fa := rrl.forced[ac].Load()
+	if fa == nil {
+		return Send, false
+	}
+	if rrl.now() < fa.until {
+		return fa.act, true
+	}
+	if rrl.forced[ac].CompareAndSwap(fa, nil) { // Only one caller reports the expiry
+		rrl.emitForceEvent(EventReleased, ac, fa.act, time.Time{})
+	}
+
+	return Send, false
+}
+
+// emitForceEvent publishes an EventForced or EventReleased event. The Key only carries the
+// AllowanceCategory.
+func (rrl *RRL) emitForceEvent(kind EventKind, ac AllowanceCategory, act Action, until time.Time) {
+	rrl.publishEvent(&Event{Time: rrl.clock.wall(), Kind: kind, Key: AccountKey{AllowanceCategory: ac},
+		Action: act, Until: until})
+}
@@ -0,0 +1,92 @@
+package rrl
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestForceAction(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cfg := NewConfig()
+	cfg.SetValue("responses-per-second", "1")
+	cfg.SetValue("slip-ratio", "0")
+	cfg.SetNowFunc(func() time.Time { return now })
+	R := NewRRL(cfg)
+	var out bytes.Buffer
+	ee := R.ExportEvents(&out, 1000)
+
+	src := newAddr("udp", "10.0.0.1:53")
+	answer := newTuple(1, 1, "example.com.", AllowanceAnswer)
+	errors := newTuple(1, 1, "example.com.", AllowanceError)
+
+	R.ForceAction(AllowanceError, Drop, now.Add(10*time.Second))
+	R.ForceAction(AllowanceError, ActionLast, now.Add(time.Hour)) // Ignored
+	R.ForceAction(AllowanceLast+1, Drop, now.Add(time.Hour))      // Ignored
+	if act, ok := R.ForcedAction(AllowanceError); !ok || act != Drop {
+		t.Error("Errors should be latched to Drop", act, ok)
+	}
+	if _, ok := R.ForcedAction(AllowanceAnswer); ok {
+		t.Error("Answers should not be latched")
+	}
+	if act, _, rtr := R.Debit(src, errors); act != Drop || rtr != RTForced {
+		t.Error("Latched category should Drop, not", act, rtr)
+	}
+	if act, _, rtr := R.Check(src, errors); act != Drop || rtr != RTForced {
+		t.Error("Check should report the latch", act, rtr)
+	}
+	if act, _, rtr := R.Debit(src, answer); act != Send || rtr != RTOk {
+		t.Error("Other categories should be unaffected", act, rtr)
+	}
+
+	// A latch of all categories is overridden by a category latch
+	R.ForceAction(AllowanceLast, Send, now.Add(5*time.Second))
+	for ix := 0; ix < 3; ix++ {
+		if act, _, rtr := R.Debit(src, answer); act != Send || rtr != RTForced {
+			t.Error(ix, "All categories should be latched to Send, not", act, rtr)
+		}
+	}
+	if act, _, _ := R.Debit(src, errors); act != Drop {
+		t.Error("Category latch should take precedence", act)
+	}
+	if stats := R.GetStats(false); stats.RTReasons[RTForced] != 5 {
+		t.Error("RTForced should be counted", stats.String())
+	}
+
+	// The latch of all categories expires
+	now = now.Add(5 * time.Second)
+	if act, _, rtr := R.Debit(src, answer); act != Send || rtr != RTOk {
+		t.Error("Expired latch should revert to rate limiting", act, rtr)
+	}
+	R.ForceAction(AllowanceError, Drop, now) // Explicit release
+	if _, ok := R.ForcedAction(AllowanceError); ok {
+		t.Error("Errors should be released")
+	}
+	R.ForceAction(AllowanceError, Drop, now) // Nothing to release
+
+	ee.Close()
+	var got []string
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		var ev eventJSON
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			t.Fatal("Bad JSON", err, scanner.Text())
+		}
+		if ev.Event == "forced" || ev.Event == "released" {
+			if len(ev.Network) > 0 || len(ev.Kind) > 0 {
+				t.Error("Force events should not have account fields", scanner.Text())
+			}
+			if (ev.Event == "forced") != (len(ev.Until) > 0) {
+				t.Error("Only forced events should have an expiry", scanner.Text())
+			}
+			got = append(got, ev.Event+"/"+ev.Category+"/"+ev.Action)
+		}
+	}
+	exp := "[forced/AllowanceError/Drop forced/all/Send released/all/Send released/AllowanceError/Drop]"
+	if fmt.Sprint(got) != exp {
+		t.Error("Unexpected force events", got)
+	}
+}
@@ -0,0 +1,103 @@
+package rrl
+
+import (
+	"time"
+)
+
+const minute = 60 * second
+
+// StatsSlice contains the [Stats] accumulated during one minute.
+//
+// CacheLength is always zero as the table is not examined when a slice completes. The
+// ClientNetworks and SalientNames gauges are the values at the time the slice completed.
+type StatsSlice struct {
+	Start time.Time // The start of the minute
+	Stats Stats
+}
+
+// history is a ring buffer of completed StatsSlices plus the slice currently
+// accumulating. All access is protected by RRL.statsMu.
+type history struct {
+	slices  []StatsSlice // Ring buffer of completed slices
+	next    int          // Index in slices of the next completed slice
+	count   int          // Number of completed slices in the ring
+	current StatsSlice
+	minute  int64 // Minute number of current. Zero means no current slice
+}
+
+func newHistory(depth int) *history {
+	return &history{slices: make([]StatsSlice, depth)}
+}
+
+// historySlice returns the Stats of the current slice after rotating any completed
+// slices into the ring, or nil if history is not configured. The caller must hold
+// statsMu.
+func (rrl *RRL) historySlice() *Stats {
+	if rrl.history == nil {
+		return nil
+	}
+	rrl.rotateHistory()
+
+	return &rrl.history.current.Stats
+}
+
+// rotateHistory completes the current slice if the minute has changed since it started.
+// Empty slices are added for any intervening minutes which saw no activity. The caller
+// must hold statsMu.
+func (rrl *RRL) rotateHistory() {
+	h := rrl.history
+	now := rrl.now() / minute
+	if now == h.minute {
+		return
+	}
+
+	if h.minute != 0 {
+		h.current.Stats.ClientNetworks, h.current.Stats.SalientNames = rrl.uniqueCounts()
+		h.push(h.current)
+		gap := now - h.minute - 1
+		if gap > int64(len(h.slices)) { // No point pushing more than the ring holds
+			gap = int64(len(h.slices))
+		}
+		for m := now - gap; m < now; m++ {
+			h.push(StatsSlice{Start: time.Unix(0, m*minute)})
+		}
+	}
+
+	h.minute = now
+	h.current = StatsSlice{Start: time.Unix(0, now*minute)}
+}
+
+// push adds a completed slice to the ring, overwriting the oldest if full.
+func (h *history) push(ss StatsSlice) {
+	h.slices[h.next] = ss
+	h.next = (h.next + 1) % len(h.slices)
+	if h.count < len(h.slices) {
+		h.count++
+	}
+}
+
+// History returns up to n of the most recently completed per-minute [StatsSlice]s in
+// chronological order, oldest first. The slice for the current, incomplete, minute is
+// not included. History returns nil if "history-depth" is not configured.
+//
+// History is concurrency safe.
+func (rrl *RRL) History(n int) []StatsSlice {
+	if rrl.history == nil || n <= 0 {
+		return nil
+	}
+
+	rrl.statsMu.Lock()
+	defer rrl.statsMu.Unlock()
+
+	rrl.rotateHistory()
+	h := rrl.history
+	if n > h.count {
+		n = h.count
+	}
+	ret := make([]StatsSlice, 0, n)
+	for ix := h.next - n; ix < h.next; ix++ {
+		ret = append(ret, h.slices[(ix+len(h.slices))%len(h.slices)])
+	}
+
+	return ret
+}
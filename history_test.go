@@ -0,0 +1,70 @@
+package rrl
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistory(t *testing.T) {
+	now := time.Unix(6000, 0) // On a minute boundary
+	cfg := NewConfig()
+	cfg.SetValue("responses-per-second", "100")
+	cfg.SetValue("history-depth", "3")
+	cfg.SetNowFunc(func() time.Time { return now })
+	R := NewRRL(cfg)
+
+	if h := R.History(10); len(h) != 0 {
+		t.Error("Expected empty history at start", h)
+	}
+
+	src := newAddr("udp", "10.0.0.1:53")
+	tuple := newTuple(1, 1, "example.com.", AllowanceAnswer)
+	for minute := 1; minute <= 2; minute++ {
+		for ix := 0; ix < minute; ix++ {
+			R.Debit(src, tuple)
+		}
+		now = now.Add(time.Minute)
+	}
+
+	h := R.History(10)
+	if len(h) != 2 {
+		t.Fatal("Expected two slices, got", len(h))
+	}
+	for ix, exp := range []int64{1, 2} {
+		if h[ix].Stats.RPS[AllowanceAnswer] != exp {
+			t.Error(ix, "Expected RPS", exp, "got", h[ix].Stats.RPS[AllowanceAnswer])
+		}
+	}
+	if !h[0].Start.Equal(time.Unix(6000, 0)) || !h[1].Start.Equal(time.Unix(6060, 0)) {
+		t.Error("Unexpected slice start times", h[0].Start, h[1].Start)
+	}
+
+	// A quiet gap produces empty slices and the ring only retains depth slices
+	now = now.Add(5 * time.Minute)
+	R.Debit(src, tuple)
+	now = now.Add(time.Minute)
+	h = R.History(10)
+	if len(h) != 3 {
+		t.Fatal("Expected history to be limited to depth, got", len(h))
+	}
+	if h[1].Stats.RPS[AllowanceAnswer] != 0 || h[2].Stats.RPS[AllowanceAnswer] != 1 {
+		t.Error("Unexpected slices after gap", h)
+	}
+	if !h[2].Start.Equal(time.Unix(6000+7*60, 0)) {
+		t.Error("Unexpected start of latest slice", h[2].Start)
+	}
+
+	if h = R.History(1); len(h) != 1 || h[0].Stats.RPS[AllowanceAnswer] != 1 {
+		t.Error("History(1) should return the most recent slice", h)
+	}
+
+	// GetStats zeroing does not affect history
+	R.GetStats(true)
+	if h = R.History(3); len(h) != 3 {
+		t.Error("GetStats(true) affected history", len(h))
+	}
+
+	if NewRRL(NewConfig()).History(5) != nil {
+		t.Error("Expected nil History when not configured")
+	}
+}
@@ -0,0 +1,52 @@
+package rrl
+
+import (
+	"sync"
+	"time"
+)
+
+// inspection serializes and paces the admin operations which examine every account, such
+// as [RRL.Snapshot], [RRL.LimitedNetworks] and [RRL.DumpLimited], so that an operator
+// refreshing a dashboard during an attack cannot add materially to the load. The cost to
+// concurrent debits of each operation is already bounded by walkAccounts, which never
+// holds a shard lock for longer than it takes to copy the shard.
+type inspection struct {
+	mu   sync.Mutex // Serializes inspections
+	next int64      // Earliest time the next inspection may start
+}
+
+// inspect walks all accounts on behalf of an admin operation. Inspections run one at a
+// time and, if max-inspections-per-second is configured, inspect blocks the caller until
+// the next inspection is due. If inspection-budget is configured the walk stops once the
+// budget is exhausted and inspect returns false to indicate a partial result.
+func (rrl *RRL) inspect(fn func(t string, ra *responseAccount, balance int64) bool) bool {
+	in := &rrl.inspection
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	return rrl.walkAccounts(rrl.startInspection(), fn)
+}
+
+// startInspection blocks until the next inspection is due and returns the deadline of
+// its inspection-budget, or zero if there is no budget. It must be called with the
+// inspection lock held.
+func (rrl *RRL) startInspection() (deadline int64) {
+	in := &rrl.inspection
+	now := rrl.now()
+	if interval := rrl.cfg.inspectionInterval; interval > 0 {
+		if wait := in.next - now; wait > 0 {
+			time.Sleep(time.Duration(wait))
+			now = rrl.now()
+		}
+		if in.next < now {
+			in.next = now
+		}
+		in.next += interval
+	}
+
+	if rrl.cfg.inspectionBudget > 0 {
+		deadline = now + rrl.cfg.inspectionBudget
+	}
+
+	return
+}
@@ -0,0 +1,76 @@
+package rrl
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fn must be able to debit the account it is visiting as walkAccounts holds no shard lock
+// while calling it. If it did, this test would deadlock.
+func TestWalkAccountsUnlocked(t *testing.T) {
+	cfg := NewConfig()
+	cfg.SetValue("responses-per-second", "10")
+	R := NewRRL(cfg)
+	src := newAddr("udp", "10.0.0.1:53")
+	tuple := newTuple(1, 1, "example.com.", AllowanceAnswer)
+	R.Debit(src, tuple)
+
+	visits := 0
+	complete := R.walkAccounts(0, func(t string, ra *responseAccount, balance int64) bool {
+		visits++
+		ra.limited = true // Changes to the copy are discarded
+		R.Debit(src, tuple)
+		return true
+	})
+	if !complete || visits != 1 {
+		t.Error("Expected a complete walk of one account", complete, visits)
+	}
+	if l := R.DumpLimited(0); len(l) != 0 {
+		t.Error("walkAccounts should not have modified the account", l)
+	}
+}
+
+func TestInspectionBudget(t *testing.T) {
+	var now time.Time
+	tick := func() time.Time {
+		now = now.Add(time.Millisecond) // Every reading advances the clock
+		return now
+	}
+
+	for _, budget := range []string{"0", "10"} {
+		cfg := NewConfig()
+		cfg.SetValue("responses-per-second", "0.1") // Remain limited as the clock ticks
+		cfg.SetValue("inspection-budget", budget)
+		cfg.SetNowFunc(tick)
+		R := NewRRL(cfg)
+		tuple := newTuple(1, 1, "example.com.", AllowanceAnswer)
+		for ix := 0; ix < 1000; ix++ {
+			src := newAddr("udp", fmt.Sprintf("10.%d.%d.1:53", ix/256, ix%256))
+			R.Debit(src, tuple)
+			R.Debit(src, tuple)
+		}
+
+		ss := R.Snapshot()
+		if budget == "0" && (ss.Partial || len(ss.Limited) != 1000) {
+			t.Error("Unbudgeted Snapshot should be complete", ss.Partial, len(ss.Limited))
+		}
+		if budget != "0" && (!ss.Partial || len(ss.Limited) >= 1000) {
+			t.Error("Budgeted Snapshot should be partial", ss.Partial, len(ss.Limited))
+		}
+	}
+}
+
+func TestInspectionPacing(t *testing.T) {
+	cfg := NewConfig()
+	cfg.SetValue("max-inspections-per-second", "20") // One every 50ms
+	R := NewRRL(cfg)
+
+	start := time.Now()
+	for ix := 0; ix < 3; ix++ {
+		R.LimitedNetworks(0)
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Error("Three inspections should take at least 100ms, not", elapsed)
+	}
+}
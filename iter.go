@@ -0,0 +1,85 @@
+//go:build go1.23
+
+package rrl
+
+import (
+	"iter"
+	"maps"
+	"slices"
+	"time"
+)
+
+// This file exposes the RRL's enumerating functions as iterators for use with
+// range-over-func and the standard library's iterator helpers. It is only built with Go
+// 1.23 or later; the slice and map returning functions remain available to all.
+
+func init() {
+	features = append(features, "iterators")
+}
+
+// Accounts returns an iterator over every account in the RRL. Accounts are visited in no
+// particular order and the AccountInfo of each is as at the time its shard was copied.
+//
+// Like [RRL.Snapshot], iterating is subject to max-inspections-per-second and
+// inspection-budget. Accounts are copied a shard at a time and neither a shard lock nor
+// the lock serializing inspections is held while the loop body runs, so the loop body
+// may itself call [RRL.Snapshot], [RRL.DumpLimited] and the like. Breaking out of the
+// loop ends the inspection.
+//
+// Accounts is concurrency safe.
+func (rrl *RRL) Accounts() iter.Seq[AccountInfo] {
+	return func(yield func(AccountInfo) bool) {
+		in := &rrl.inspection
+		in.mu.Lock()
+		deadline := rrl.startInspection()
+		in.mu.Unlock()
+
+		var copies []accountCopy
+		for _, table := range rrl.distinctTables() {
+			for ix := 0; ix < table.Shards(); ix++ {
+				in.mu.Lock()
+				if deadline != 0 && rrl.now() > deadline {
+					in.mu.Unlock()
+					return
+				}
+				copies = copyShard(table, ix, copies[:0])
+				in.mu.Unlock()
+
+				now := rrl.now()
+				for cx := range copies {
+					ac := &copies[cx]
+					if !yield(newAccountInfo(ac.t, &ac.ra, ac.ra.balance(now), now)) {
+						return
+					}
+				}
+			}
+		}
+	}
+}
+
+// DumpLimitedSeq is the iterator form of [RRL.DumpLimited].
+func (rrl *RRL) DumpLimitedSeq(olderThan time.Duration) iter.Seq[AccountInfo] {
+	return slices.Values(rrl.DumpLimited(olderThan))
+}
+
+// HistorySeq is the iterator form of [RRL.History].
+func (rrl *RRL) HistorySeq(n int) iter.Seq[StatsSlice] {
+	return slices.Values(rrl.History(n))
+}
+
+// RecentDecisionsSeq is the iterator form of [RRL.RecentDecisions].
+func (rrl *RRL) RecentDecisionsSeq(n int) iter.Seq[DecisionRecord] {
+	return slices.Values(rrl.RecentDecisions(n))
+}
+
+// TagStatsSeq is the iterator form of [RRL.GetTagStats]. Tags are yielded in sorted order.
+func (rrl *RRL) TagStatsSeq(zeroAfter bool) iter.Seq2[string, Stats] {
+	ts := rrl.GetTagStats(zeroAfter)
+	return func(yield func(string, Stats) bool) {
+		for _, tag := range slices.Sorted(maps.Keys(ts)) {
+			if !yield(tag, ts[tag]) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,91 @@
+//go:build go1.23
+
+package rrl_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/markdingo/rrl"
+)
+
+func TestIterators(t *testing.T) {
+	cfg := rrl.NewConfig()
+	cfg.SetValue("responses-per-second", "1")
+	cfg.SetValue("history-depth", "5")
+	cfg.SetValue("decision-log-size", "10")
+	now := time.Unix(1000, 0)
+	cfg.SetNowFunc(func() time.Time { return now })
+	R := rrl.NewRRL(cfg)
+
+	tuple := newTuple(1, 1, "example.com.", rrl.AllowanceAnswer)
+	for _, a := range []string{"10.0.0.1:53", "10.0.1.1:53", "10.0.2.1:53"} {
+		R.DebitTagged("eth0", newAddr("udp", a), tuple)
+	}
+	R.DebitTagged("eth1", newAddr("udp", "10.0.0.1:53"), tuple) // Limited
+
+	count := 0
+	for ai := range R.Accounts() {
+		if ai.Key.Kind != rrl.AccountResponse {
+			t.Error("Unexpected account", ai.Key.String())
+		}
+		count++
+	}
+	if count != 3 {
+		t.Error("Expected Accounts to yield 3 accounts, not", count)
+	}
+	for range R.Accounts() {
+		break // Must not panic or deadlock subsequent inspections
+	}
+	if ss := R.Snapshot(); len(ss.Limited) != 1 {
+		t.Error("Snapshot after break should succeed", ss.Limited)
+	}
+
+	// Inspections within the loop body must not deadlock
+	done := make(chan int)
+	go func() {
+		count := 0
+		for range R.Accounts() {
+			count += len(R.DumpLimited(0)) + len(R.Snapshot().Limited)
+		}
+		done <- count
+	}()
+	select {
+	case count := <-done:
+		if count != 6 {
+			t.Error("Expected each nested inspection to see the limited account, not", count)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Inspection within the Accounts loop deadlocked")
+	}
+
+	limited := slices.Collect(R.DumpLimitedSeq(0))
+	if len(limited) != 1 || limited[0].Key.Network != "10.0.0.0" {
+		t.Error("Unexpected DumpLimitedSeq", limited)
+	}
+	now = now.Add(time.Minute) // Complete the current History slice
+	if h := slices.Collect(R.HistorySeq(5)); len(h) != 1 || h[0].Stats.RPS[rrl.AllowanceAnswer] != 4 {
+		t.Error("Unexpected HistorySeq", h)
+	}
+	if d := slices.Collect(R.RecentDecisionsSeq(5)); len(d) != 1 {
+		t.Error("Unexpected RecentDecisionsSeq", d)
+	}
+
+	var tags []string
+	for tag, s := range R.TagStatsSeq(false) {
+		tags = append(tags, tag)
+		if s.RPS[rrl.AllowanceAnswer] == 0 {
+			t.Error("Tag", tag, "should have stats")
+		}
+	}
+	if !slices.Equal(tags, []string{"eth0", "eth1"}) {
+		t.Error("TagStatsSeq should yield sorted tags, not", tags)
+	}
+}
+
+func TestIteratorsFeature(t *testing.T) {
+	if !slices.Contains(rrl.Features(), "iterators") {
+		t.Error("Go 1.23 build should report the iterators feature", rrl.Features())
+	}
+}
@@ -0,0 +1,226 @@
+package rrl
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// This file provides the canonical text and JSON encodings of ResponseTuple, AccountKey,
+// Decision and DecisionRecord so that logs, exports and downstream consumers all share
+// one representation. The text encodings are compact, single token forms suitable for
+// log lines and map keys. The JSON encodings of ResponseTuple and AccountKey are objects
+// with the same short field names as the events written by [RRL.ExportEvents].
+
+// MarshalText implements [encoding.TextMarshaler]. The encoding is
+// "class/type/category/name", e.g. "1/28/AllowanceAnswer/example.com.".
+func (rt ResponseTuple) MarshalText() ([]byte, error) {
+	return []byte(strconv.FormatUint(uint64(rt.Class), 10) + "/" +
+		strconv.FormatUint(uint64(rt.Type), 10) + "/" +
+		rt.AllowanceCategory.String() + "/" + rt.SalientName), nil
+}
+
+// UnmarshalText implements [encoding.TextUnmarshaler] as the inverse of
+// [ResponseTuple.MarshalText].
+func (rt *ResponseTuple) UnmarshalText(text []byte) error {
+	parts := strings.SplitN(string(text), "/", 4)
+	if len(parts) == 4 {
+		qClass, err1 := strconv.ParseUint(parts[0], 10, 16)
+		qType, err2 := strconv.ParseUint(parts[1], 10, 16)
+		ac, ok := parseAllowanceCategory(parts[2])
+		if err1 == nil && err2 == nil && ok {
+			*rt = ResponseTuple{Class: uint16(qClass), Type: uint16(qType), AllowanceCategory: ac,
+				SalientName: parts[3]}
+			return nil
+		}
+	}
+
+	return fmt.Errorf("rrl: invalid ResponseTuple '%s'", text)
+}
+
+type responseTupleJSON struct {
+	Class    uint16 `json:"class"`
+	Type     uint16 `json:"type"`
+	Category string `json:"cat"`
+	Name     string `json:"name"`
+}
+
+// MarshalJSON implements [json.Marshaler].
+func (rt ResponseTuple) MarshalJSON() ([]byte, error) {
+	return json.Marshal(responseTupleJSON{rt.Class, rt.Type, rt.AllowanceCategory.String(), rt.SalientName})
+}
+
+// UnmarshalJSON implements [json.Unmarshaler] as the inverse of
+// [ResponseTuple.MarshalJSON].
+func (rt *ResponseTuple) UnmarshalJSON(data []byte) error {
+	var rj responseTupleJSON
+	if err := json.Unmarshal(data, &rj); err != nil {
+		return err
+	}
+	ac, ok := parseAllowanceCategory(rj.Category)
+	if !ok {
+		return fmt.Errorf("rrl: invalid AllowanceCategory '%s'", rj.Category)
+	}
+	*rt = ResponseTuple{Class: rj.Class, Type: rj.Type, AllowanceCategory: ac, SalientName: rj.Name}
+
+	return nil
+}
+
+// MarshalText implements [encoding.TextMarshaler]. The encoding is "kind/network" and,
+// for AccountResponse keys, "kind/network/category/class/type/name", e.g.
+// "AccountResponse/10.0.0.0/AllowanceAnswer/0/28/example.com.".
+func (key AccountKey) MarshalText() ([]byte, error) {
+	s := key.Kind.String() + "/" + key.Network
+	if key.Kind == AccountResponse {
+		s += "/" + key.AllowanceCategory.String() + "/" +
+			strconv.FormatUint(uint64(key.Class), 10) + "/" +
+			strconv.FormatUint(uint64(key.Type), 10) + "/" + key.SalientName
+	}
+
+	return []byte(s), nil
+}
+
+// UnmarshalText implements [encoding.TextUnmarshaler] as the inverse of
+// [AccountKey.MarshalText].
+func (key *AccountKey) UnmarshalText(text []byte) error {
+	kindName, rest, _ := strings.Cut(string(text), "/")
+	kind, ok := parseAccountKind(kindName)
+	if ok && kind != AccountResponse && len(rest) > 0 {
+		*key = AccountKey{Kind: kind, Network: rest} // Aggregate networks contain a '/'
+		return nil
+	}
+	if parts := strings.SplitN(rest, "/", 5); ok && len(parts) == 5 {
+		ac, ok := parseAllowanceCategory(parts[1])
+		qClass, err1 := strconv.ParseUint(parts[2], 10, 16)
+		qType, err2 := strconv.ParseUint(parts[3], 10, 16)
+		if ok && err1 == nil && err2 == nil {
+			*key = AccountKey{Kind: kind, Network: parts[0], AllowanceCategory: ac,
+				Class: uint16(qClass), Type: uint16(qType), SalientName: parts[4]}
+			return nil
+		}
+	}
+
+	return fmt.Errorf("rrl: invalid AccountKey '%s'", text)
+}
+
+type accountKeyJSON struct {
+	Kind     string `json:"kind"`
+	Network  string `json:"net"`
+	Category string `json:"cat,omitempty"`
+	Class    uint16 `json:"class,omitempty"`
+	Type     uint16 `json:"type,omitempty"`
+	Name     string `json:"name,omitempty"`
+}
+
+// MarshalJSON implements [json.Marshaler]. Response Tuple fields are omitted for keys
+// which are not AccountResponse keys.
+func (key AccountKey) MarshalJSON() ([]byte, error) {
+	kj := accountKeyJSON{Kind: key.Kind.String(), Network: key.Network}
+	if key.Kind == AccountResponse {
+		kj.Category = key.AllowanceCategory.String()
+		kj.Class = key.Class
+		kj.Type = key.Type
+		kj.Name = key.SalientName
+	}
+
+	return json.Marshal(kj)
+}
+
+// UnmarshalJSON implements [json.Unmarshaler] as the inverse of [AccountKey.MarshalJSON].
+func (key *AccountKey) UnmarshalJSON(data []byte) error {
+	var kj accountKeyJSON
+	if err := json.Unmarshal(data, &kj); err != nil {
+		return err
+	}
+	kind, ok := parseAccountKind(kj.Kind)
+	if !ok {
+		return fmt.Errorf("rrl: invalid AccountKind '%s'", kj.Kind)
+	}
+	*key = AccountKey{Kind: kind, Network: kj.Network}
+	if kind == AccountResponse {
+		ac, ok := parseAllowanceCategory(kj.Category)
+		if !ok {
+			return fmt.Errorf("rrl: invalid AllowanceCategory '%s'", kj.Category)
+		}
+		key.AllowanceCategory = ac
+		key.Class = kj.Class
+		key.Type = kj.Type
+		key.SalientName = kj.Name
+	}
+
+	return nil
+}
+
+// MarshalText implements [encoding.TextMarshaler] using [Decision.String]. As a
+// consequence, the JSON encoding of a Decision is also its name.
+func (d Decision) MarshalText() ([]byte, error) {
+	if !d.valid() {
+		return nil, fmt.Errorf("rrl: invalid Decision %d/%d/%d", d.Action, d.IPReason, d.RTReason)
+	}
+
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText implements [encoding.TextUnmarshaler] using [ParseDecision].
+func (d *Decision) UnmarshalText(text []byte) error {
+	nd, err := ParseDecision(string(text))
+	if err == nil {
+		*d = nd
+	}
+
+	return err
+}
+
+// decisionRecordJSON is needed as otherwise the text encoding of the embedded Decision
+// would be promoted to the whole DecisionRecord.
+type decisionRecordJSON struct {
+	Time     time.Time     `json:"t"`
+	Network  string        `json:"net"`
+	Tag      string        `json:"tag,omitempty"`
+	Tuple    ResponseTuple `json:"tuple"`
+	Decision Decision      `json:"decision"`
+	Balance  float64       `json:"bal"`
+}
+
+// MarshalJSON implements [json.Marshaler]. The Balance is in seconds.
+func (dr DecisionRecord) MarshalJSON() ([]byte, error) {
+	return json.Marshal(decisionRecordJSON{dr.Time, dr.Network, dr.Tag, dr.Tuple, dr.Decision,
+		dr.Balance.Seconds()})
+}
+
+// UnmarshalJSON implements [json.Unmarshaler] as the inverse of
+// [DecisionRecord.MarshalJSON].
+func (dr *DecisionRecord) UnmarshalJSON(data []byte) error {
+	var dj decisionRecordJSON
+	if err := json.Unmarshal(data, &dj); err != nil {
+		return err
+	}
+	*dr = DecisionRecord{Time: dj.Time, Network: dj.Network, Tag: dj.Tag, Tuple: dj.Tuple,
+		Decision: dj.Decision, Balance: time.Duration(dj.Balance * float64(time.Second))}
+
+	return nil
+}
+
+// parseAllowanceCategory is the inverse of AllowanceCategory.String.
+func parseAllowanceCategory(s string) (AllowanceCategory, bool) {
+	for ac := AllowanceAnswer; ac < AllowanceLast; ac++ {
+		if ac.String() == s {
+			return ac, true
+		}
+	}
+
+	return AllowanceLast, false
+}
+
+// parseAccountKind is the inverse of AccountKind.String.
+func parseAccountKind(s string) (AccountKind, bool) {
+	for kind := AccountResponse; kind < AccountLast; kind++ {
+		if kind.String() == s {
+			return kind, true
+		}
+	}
+
+	return AccountLast, false
+}
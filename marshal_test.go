@@ -0,0 +1,118 @@
+package rrl
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestMarshalResponseTuple(t *testing.T) {
+	rt := ResponseTuple{Class: 1, Type: 28, AllowanceCategory: AllowanceNXDomain, SalientName: "a/b.example."}
+	text, _ := rt.MarshalText()
+	if string(text) != "1/28/AllowanceNXDomain/a/b.example." {
+		t.Error("Unexpected text encoding", string(text))
+	}
+	var got ResponseTuple
+	if err := got.UnmarshalText(text); err != nil || got != rt {
+		t.Error("Text round trip failed", got, err)
+	}
+
+	js, _ := json.Marshal(rt)
+	if string(js) != `{"class":1,"type":28,"cat":"AllowanceNXDomain","name":"a/b.example."}` {
+		t.Error("Unexpected JSON encoding", string(js))
+	}
+	got = ResponseTuple{}
+	if err := json.Unmarshal(js, &got); err != nil || got != rt {
+		t.Error("JSON round trip failed", got, err)
+	}
+
+	for _, bad := range []string{"", "1/28/AllowanceNXDomain", "x/28/AllowanceAnswer/a.", "1/1/Answer/a."} {
+		if err := got.UnmarshalText([]byte(bad)); err == nil {
+			t.Error("Expected error parsing", bad)
+		}
+	}
+	if err := json.Unmarshal([]byte(`{"cat":"Answer"}`), &got); err == nil {
+		t.Error("Expected error for invalid JSON category")
+	}
+}
+
+func TestMarshalAccountKey(t *testing.T) {
+	R := NewRRL(NewConfig())
+	testCases := []struct {
+		key  AccountKey
+		text string
+		js   string
+	}{
+		{AccountKey{Kind: AccountRequests, Network: "10.0.0.0"},
+			"AccountRequests/10.0.0.0", `{"kind":"AccountRequests","net":"10.0.0.0"}`},
+		{AccountKey{Kind: AccountAggregate, Network: "2001:db8::/48"},
+			"AccountAggregate/2001:db8::/48", `{"kind":"AccountAggregate","net":"2001:db8::/48"}`},
+		{AccountKey{Kind: AccountResponse, Network: "10.0.0.0", AllowanceCategory: AllowanceAnswer,
+			Class: 3, Type: 1, SalientName: "example.com."},
+			"AccountResponse/10.0.0.0/AllowanceAnswer/3/1/example.com.",
+			`{"kind":"AccountResponse","net":"10.0.0.0","cat":"AllowanceAnswer","class":3,"type":1,"name":"example.com."}`},
+		{AccountKey{Kind: AccountResponse, Network: "::", AllowanceCategory: AllowanceError},
+			"AccountResponse/::/AllowanceError/0/0/",
+			`{"kind":"AccountResponse","net":"::","cat":"AllowanceError"}`},
+	}
+
+	for ix, tc := range testCases {
+		text, _ := tc.key.MarshalText()
+		if string(text) != tc.text {
+			t.Error(ix, "Unexpected text encoding", string(text))
+		}
+		var got AccountKey
+		if err := got.UnmarshalText(text); err != nil || got != tc.key {
+			t.Error(ix, "Text round trip failed", got, err)
+		}
+		js, _ := json.Marshal(tc.key)
+		if string(js) != tc.js {
+			t.Error(ix, "Unexpected JSON encoding", string(js))
+		}
+		got = AccountKey{}
+		if err := json.Unmarshal(js, &got); err != nil || got != tc.key {
+			t.Error(ix, "JSON round trip failed", got, err)
+		}
+		if tc.key.Kind == AccountResponse && tc.key.token(R) != got.token(R) {
+			t.Error(ix, "Decoded key should identify the same account")
+		}
+	}
+
+	for _, bad := range []string{"", "AccountRequests", "AccountRequests/", "Account/10.0.0.0",
+		"AccountResponse/10.0.0.0/AllowanceAnswer/0/1"} {
+		var got AccountKey
+		if err := got.UnmarshalText([]byte(bad)); err == nil {
+			t.Error("Expected error parsing", bad)
+		}
+	}
+}
+
+func TestMarshalDecisionRecord(t *testing.T) {
+	d := NewDecision(Drop, IPOk, RTRateLimit)
+	js, _ := json.Marshal(d)
+	if string(js) != `"Drop/IPOk/RTRateLimit"` {
+		t.Error("Decision should be encoded as its name, not", string(js))
+	}
+	var got Decision
+	if err := json.Unmarshal(js, &got); err != nil || got != d {
+		t.Error("Decision round trip failed", got, err)
+	}
+	if _, err := json.Marshal(Decision{Action: ActionLast}); err == nil {
+		t.Error("Invalid Decision should not be encoded")
+	}
+
+	dr := DecisionRecord{Time: time.Unix(1000, 0).UTC(), Network: "10.0.0.0", Tag: "t1",
+		Tuple:    ResponseTuple{Class: 1, Type: 1, SalientName: "example.com."},
+		Decision: d, Balance: -1500 * time.Millisecond}
+	js, _ = json.Marshal(dr)
+	exp := `{"t":"1970-01-01T00:16:40Z","net":"10.0.0.0","tag":"t1",` +
+		`"tuple":{"class":1,"type":1,"cat":"AllowanceAnswer","name":"example.com."},` +
+		`"decision":"Drop/IPOk/RTRateLimit","bal":-1.5}`
+	if string(js) != exp {
+		t.Error("Unexpected DecisionRecord encoding", string(js))
+	}
+	var gotDR DecisionRecord
+	if err := json.Unmarshal(js, &gotDR); err != nil || gotDR != dr {
+		t.Error("DecisionRecord round trip failed", gotDR, err)
+	}
+}
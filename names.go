@@ -0,0 +1,45 @@
+package rrl
+
+import (
+	"strings"
+)
+
+// initNames creates the SalientName canonicalization cache if name-cache-size is
+// configured. Entries are evicted at random once the cache is full.
+func (rrl *RRL) initNames() {
+	if rrl.cfg.nameCacheSize == 0 {
+		return
+	}
+	rrl.names = rrl.newCache(rrl.cfg.nameCacheSize)
+}
+
+// lowerName returns the canonical lowercase form of a SalientName. Names which are
+// already lowercase are returned as-is by strings.ToLower without allocating, so only
+// mixed case names, as commonly generated by resolvers using 0x20 encoding, are cached.
+func (rrl *RRL) lowerName(name string) string {
+	if rrl.names == nil || !hasUpper(name) {
+		return strings.ToLower(name)
+	}
+	if el, found := rrl.names.Get(name); found {
+		return el.(string)
+	}
+	lower := strings.ToLower(name)
+	rrl.names.UpdateAdd(name, // A full cache is of no consequence
+		func(el interface{}) interface{} { return nil },
+		func() interface{} { return lower })
+
+	return lower
+}
+
+// hasUpper returns true if name contains any upper case ASCII or any non-ASCII
+// characters, either of which may change with strings.ToLower.
+func hasUpper(name string) bool {
+	for ix := 0; ix < len(name); ix++ {
+		c := name[ix]
+		if ('A' <= c && c <= 'Z') || c >= 0x80 {
+			return true
+		}
+	}
+
+	return false
+}
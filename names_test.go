@@ -0,0 +1,52 @@
+package rrl
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestLowerName(t *testing.T) {
+	for _, size := range []string{"0", "10"} {
+		cfg := NewConfig()
+		cfg.SetValue("name-cache-size", size)
+		R := NewRRL(cfg)
+		for _, tc := range []struct{ in, exp string }{
+			{"example.com.", "example.com."},
+			{"ExAmPlE.CoM.", "example.com."},
+			{"ExAmPlE.CoM.", "example.com."}, // Cached the second time around
+			{"ÉCOLE.fr.", "école.fr."},
+			{"", ""},
+		} {
+			if got := R.lowerName(tc.in); got != tc.exp {
+				t.Error(size, tc.in, "Expected", tc.exp, "got", got)
+			}
+		}
+	}
+}
+
+func TestLowerNameCache(t *testing.T) {
+	cfg := NewConfig()
+	cfg.SetValue("name-cache-size", "2048") // A multiple of the number of shards
+	R := NewRRL(cfg)
+
+	R.lowerName("lower.example.com.")
+	if R.names.Len() != 0 {
+		t.Error("Lowercase names should not be cached")
+	}
+	R.lowerName("WWW.Example.COM.")
+	if el, found := R.names.Get("WWW.Example.COM."); !found || el.(string) != "www.example.com." {
+		t.Error("Mixed case name should be cached", found, el)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() { R.lowerName("WWW.Example.COM.") })
+	if allocs != 0 {
+		t.Error("Cached name should not allocate", allocs)
+	}
+
+	for ix := 0; ix < 5000; ix++ {
+		R.lowerName(fmt.Sprintf("Host%d.Example.COM.", ix))
+	}
+	if l := R.names.Len(); l > 2048 {
+		t.Error("Cache should be bounded by name-cache-size, not", l)
+	}
+}
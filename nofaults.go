@@ -0,0 +1,11 @@
+//go:build !rrlfaults
+
+package rrl
+
+// faults is empty in regular builds so fault injection costs nothing. See faults.go for
+// the "rrlfaults" build tag version.
+type faults struct{}
+
+func (fs *faults) init(cfg *Config) {}
+
+func (fs *faults) debit() error { return nil }
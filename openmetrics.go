@@ -0,0 +1,71 @@
+package rrl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// WriteOpenMetrics writes all Stats counters and gauges to w in the OpenMetrics text
+// exposition format, terminated by "# EOF". This allows a minimal deployment to serve a
+// "/metrics" endpoint without depending on a Prometheus client library, e.g.:
+//
+//	http.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+//		w.Header().Set("Content-Type",
+//			"application/openmetrics-text; version=1.0.0; charset=utf-8")
+//		stats := R.GetStats(false)
+//		stats.WriteOpenMetrics(w)
+//	})
+//
+// OpenMetrics counters must never decrease so the Stats should be obtained with
+// GetStats(false). Stats which are periodically zeroed will appear to scrapers as
+// counter resets.
+//
+// All metric names are prefixed with "rrl_" and enumerated values, such as the
+// AllowanceCategory, are exposed as labels using their String() values.
+func (c *Stats) WriteOpenMetrics(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	family := func(name, typ, help string) {
+		fmt.Fprintf(bw, "# TYPE %s %s\n# HELP %s %s\n", name, typ, name, help)
+	}
+	sample := func(name, label string, value fmt.Stringer, v int64) {
+		if value == nil {
+			fmt.Fprintf(bw, "%s %d\n", name, v)
+			return
+		}
+		fmt.Fprintf(bw, "%s{%s=\"%s\"} %d\n", name, label, value.String(), v)
+	}
+
+	family("rrl_responses", "counter", "Debits by AllowanceCategory.")
+	for ac, v := range c.RPS {
+		sample("rrl_responses_total", "category", AllowanceCategory(ac), v)
+	}
+	family("rrl_actions", "counter", "Recommended Actions.")
+	for act, v := range c.Actions {
+		sample("rrl_actions_total", "action", Action(act), v)
+	}
+	family("rrl_ip_reasons", "counter", "IPReasons of Debits.")
+	for ipr, v := range c.IPReasons {
+		sample("rrl_ip_reasons_total", "reason", IPReason(ipr), v)
+	}
+	family("rrl_rt_reasons", "counter", "RTReasons of Debits.")
+	for rtr, v := range c.RTReasons {
+		sample("rrl_rt_reasons_total", "reason", RTReason(rtr), v)
+	}
+	family("rrl_evictions", "counter", "Accounts evicted from the table.")
+	sample("rrl_evictions_total", "", nil, c.Evictions)
+	family("rrl_slip_downgrades", "counter", "Slips downgraded to Drops by max-slips-per-second.")
+	sample("rrl_slip_downgrades_total", "", nil, c.SlipDowngrades)
+
+	family("rrl_cache_length", "gauge", "Accounts currently in the table.")
+	sample("rrl_cache_length", "", nil, int64(c.CacheLength))
+	family("rrl_client_networks", "gauge", "Approximate distinct Client Networks.")
+	sample("rrl_client_networks", "", nil, c.ClientNetworks)
+	family("rrl_salient_names", "gauge", "Approximate distinct SalientNames.")
+	sample("rrl_salient_names", "", nil, c.SalientNames)
+
+	bw.WriteString("# EOF\n")
+
+	return bw.Flush()
+}
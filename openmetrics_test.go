@@ -0,0 +1,64 @@
+package rrl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteOpenMetrics(t *testing.T) {
+	var s Stats
+	s.RPS[AllowanceNXDomain] = 7
+	s.Actions[Drop] = 3
+	s.IPReasons[IPRateLimit] = 2
+	s.RTReasons[RTCacheFull] = 1
+	s.Evictions = 4
+	s.CacheLength = 99
+
+	var out bytes.Buffer
+	if err := s.WriteOpenMetrics(&out); err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	text := out.String()
+	for _, exp := range []string{
+		"# TYPE rrl_responses counter\n",
+		`rrl_responses_total{category="AllowanceNXDomain"} 7` + "\n",
+		`rrl_responses_total{category="AllowanceAnswer"} 0` + "\n",
+		`rrl_actions_total{action="Drop"} 3` + "\n",
+		`rrl_ip_reasons_total{reason="IPRateLimit"} 2` + "\n",
+		`rrl_rt_reasons_total{reason="RTCacheFull"} 1` + "\n",
+		"rrl_evictions_total 4\n",
+		"# TYPE rrl_cache_length gauge\n",
+		"rrl_cache_length 99\n",
+	} {
+		if !strings.Contains(text, exp) {
+			t.Error("Missing", exp)
+		}
+	}
+	if !strings.HasSuffix(text, "\n# EOF\n") {
+		t.Error("Exposition must end with # EOF")
+	}
+
+	// Every sample must belong to the most recently declared family
+	var family string
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		if strings.HasPrefix(line, "# TYPE ") {
+			family = strings.Fields(line)[2]
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !strings.HasPrefix(line, family) || strings.Contains(line, "Unstringable") ||
+			strings.Contains(line, "UnStringable") {
+			t.Error("Bad sample", line, "in family", family)
+		}
+	}
+}
+
+func TestWriteOpenMetricsError(t *testing.T) {
+	var s Stats
+	if err := s.WriteOpenMetrics(failWriter{}); err == nil {
+		t.Error("Expected writer error to be returned")
+	}
+}
@@ -0,0 +1,108 @@
+package rrl
+
+import (
+	"sync/atomic"
+)
+
+// overloadMinimumActions is the fewest Actions within the overload-interval for which
+// the Drop fraction is considered meaningful. It stops a handful of drops from a quiet
+// server tripping the overloaded state.
+const overloadMinimumActions = 100
+
+// overloadBucket counts the Actions recommended during one second.
+type overloadBucket struct {
+	second atomic.Int64
+	total  atomic.Int64
+	drops  atomic.Int64
+}
+
+// overload tracks the fraction of Drop Actions over a sliding interval with a ring of
+// per-second buckets. Buckets are reset by whichever debit first finds them stale, so
+// counts are approximate around the reset, which is of no consequence for a fraction.
+type overload struct {
+	ratio      float64
+	buckets    []overloadBucket
+	overloaded atomic.Bool
+}
+
+// initOverload creates the overload tracker if overload-drop-ratio is configured.
+func (rrl *RRL) initOverload() {
+	if rrl.cfg.overloadRatio == 0 {
+		return
+	}
+	ol := &overload{ratio: rrl.cfg.overloadRatio, buckets: make([]overloadBucket, rrl.cfg.overloadInterval/second)}
+	for ix := range ol.buckets {
+		ol.buckets[ix].second.Store(-1)
+	}
+	rrl.overload = ol
+}
+
+// recordAction counts the final Action of a debit. The overloaded state is re-evaluated
+// at the start of each second.
+func (rrl *RRL) recordAction(act Action) {
+	ol := rrl.overload
+	if ol == nil {
+		return
+	}
+	now := rrl.now() / second
+	b := &ol.buckets[uint64(now)%uint64(len(ol.buckets))]
+	rollover := false
+	if s := b.second.Load(); s != now && b.second.CompareAndSwap(s, now) {
+		b.total.Store(0)
+		b.drops.Store(0)
+		rollover = true
+	}
+	b.total.Add(1)
+	if act == Drop {
+		b.drops.Add(1)
+	}
+	if rollover {
+		rrl.checkOverload()
+	}
+}
+
+// checkOverload evaluates and returns the overloaded state, emitting an event if it
+// changed.
+func (rrl *RRL) checkOverload() bool {
+	ol := rrl.overload
+	now := rrl.now() / second
+	var total, drops int64
+	for ix := range ol.buckets {
+		b := &ol.buckets[ix]
+		if s := b.second.Load(); s <= now && s > now-int64(len(ol.buckets)) {
+			total += b.total.Load()
+			drops += b.drops.Load()
+		}
+	}
+
+	overloaded := total >= overloadMinimumActions && float64(drops) > ol.ratio*float64(total)
+	if ol.overloaded.CompareAndSwap(!overloaded, overloaded) {
+		if overloaded {
+			rrl.emitEvent(EventOverloaded, "", "", 0)
+		} else {
+			rrl.emitEvent(EventRelieved, "", "", 0)
+		}
+	}
+
+	return overloaded
+}
+
+// Overloaded returns true if the fraction of Drop Actions recommended over the most
+// recent "overload-interval" exceeds "overload-drop-ratio". Embedding servers can use it
+// to trigger their own defensive measures, such as requiring cookies or reducing UDP
+// buffer sizes. Transitions into and out of the overloaded state are also exported as
+// EventOverloaded and EventRelieved events by [RRL.ExportEvents].
+//
+// Only the final Action of each query is counted, so a DebitRequest which returns Send
+// is counted by the subsequent DebitResponse. At least 100 Actions are needed within
+// the interval before the RRL can be overloaded.
+//
+// Overloaded always returns false if overload-drop-ratio is not configured. It is
+// concurrency safe.
+func (rrl *RRL) Overloaded() bool {
+	if rrl.overload == nil {
+		return false
+	}
+
+	return rrl.checkOverload()
+}
@@ -0,0 +1,81 @@
+package rrl
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestOverloaded(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cfg := NewConfig()
+	cfg.SetValue("responses-per-second", "1")
+	cfg.SetValue("slip-ratio", "0")
+	cfg.SetNowFunc(func() time.Time { return now })
+	if NewRRL(cfg).Overloaded() {
+		t.Error("Overloaded should be false when not configured")
+	}
+
+	cfg.SetValue("overload-drop-ratio", "0.5")
+	cfg.SetValue("overload-interval", "2")
+	R := NewRRL(cfg)
+	var out bytes.Buffer
+	ee := R.ExportEvents(&out, 1000) // Account creation also creates events
+
+	src := newAddr("udp", "10.0.0.1:53")
+	tuple := newTuple(1, 1, "example.com.", AllowanceAnswer)
+	for ix := 0; ix < 50; ix++ {
+		R.Debit(src, tuple)
+	}
+	if R.Overloaded() {
+		t.Error("Too few actions should not be overloaded")
+	}
+	for ix := 0; ix < 50; ix++ {
+		R.Debit(src, tuple)
+	}
+	if !R.Overloaded() {
+		t.Error("Expected overloaded with 99 of 100 dropped")
+	}
+
+	// A DebitRequest which is sent is only counted by DebitResponse
+	now = now.Add(3 * time.Second)
+	for ix := 0; ix < 100; ix++ {
+		other := newAddr("udp", fmt.Sprintf("10.0.%d.1:53", ix))
+		R.DebitRequest(other)
+		R.DebitResponse(other, tuple)
+	}
+	R.Debit(src, tuple) // Drop
+	if R.Overloaded() {
+		t.Error("Expected relief with 1 of 101 dropped")
+	}
+
+	// Each new second re-evaluates the state without calling Overloaded
+	now = now.Add(3 * time.Second)
+	for ix := 0; ix < 110; ix++ {
+		R.Debit(src, tuple)
+	}
+	now = now.Add(time.Second)
+	R.Debit(src, tuple)
+
+	ee.Close()
+	var got []string
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		var ev eventJSON
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			t.Fatal("Bad JSON", err, scanner.Text())
+		}
+		if ev.Event == "overloaded" || ev.Event == "relieved" {
+			if len(ev.Network) > 0 || len(ev.Kind) > 0 {
+				t.Error("Overload events should not have account fields", scanner.Text())
+			}
+			got = append(got, ev.Event)
+		}
+	}
+	if fmt.Sprint(got) != "[overloaded relieved overloaded]" {
+		t.Error("Unexpected overload events", got)
+	}
+}
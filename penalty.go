@@ -0,0 +1,145 @@
+package rrl
+
+import (
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// penalty is a manually imposed penalty until the expiry time on either all accounts of
+// the Client Networks within prefix or, if prefix is not valid, all response accounts
+// with a SalientName at or below suffix.
+type penalty struct {
+	prefix netip.Prefix
+	suffix string // Lowercase without a trailing dot
+	until  int64
+}
+
+// penaltyList is replaced as a whole by Penalize so that it can be read without locks
+// on the Debit path.
+type penaltyList []penalty
+
+// Penalize forces all accounts of the Client Networks within prefix to the maximum
+// negative balance for the duration d. It allows an operator reacting to external
+// intelligence to punish a source immediately using the same mechanism, and thus the
+// same Actions, events and statistics, as organic rate limiting.
+//
+// While penalized, every debit of an account of a matching Client Network drives the
+// account to -window, so requests and responses are dropped or slipped as if the account
+// had been exhausted, and minimum-responses-per-second no longer applies. Once the
+// penalty expires, accounts recover organically from -window. Only configured limits are
+// affected, e.g., requests are only penalized if requests-per-second is configured.
+//
+// As accounts are per Client Network, a prefix longer than the configured prefix length
+// is widened to the Client Network containing it. The ipv6 aggregate network accounts are
+// never penalized as they are shared with other Client Networks.
+//
+// Penalizing an already penalized prefix replaces its duration and a d of zero or less
+// lifts the penalty. An invalid prefix, such as the zero netip.Prefix, is ignored.
+//
+// Penalize is concurrency safe.
+func (rrl *RRL) Penalize(prefix netip.Prefix, d time.Duration) {
+	if !prefix.IsValid() { // Would otherwise be treated as a name penalty matching all names
+		return
+	}
+	bits := rrl.cfg.ipv6PrefixLength
+	if prefix.Addr().Is4() {
+		bits = rrl.cfg.ipv4PrefixLength
+	}
+	if prefix.Bits() > bits {
+		prefix, _ = prefix.Addr().Prefix(bits)
+	}
+	rrl.setPenalty(penalty{prefix: prefix.Masked()}, d)
+}
+
+// PenalizeName forces all response accounts with a SalientName at or below suffix to the
+// maximum negative balance for the duration d. It is intended for when a single zone is
+// being used as the vehicle of a reflection attack. Names are compared case-insensitively
+// and a trailing dot is optional.
+//
+// Apart from matching on SalientName rather than Client Network, PenalizeName behaves as
+// described for [RRL.Penalize]. Requests accounts are not affected as they have no name.
+//
+// PenalizeName is concurrency safe.
+func (rrl *RRL) PenalizeName(suffix string, d time.Duration) {
+	rrl.setPenalty(penalty{suffix: canonicalSuffix(suffix)}, d)
+}
+
+// setPenalty replaces any existing penalty with the same prefix and suffix with p
+// expiring after d. Expired penalties are discarded at the same time.
+func (rrl *RRL) setPenalty(p penalty, d time.Duration) {
+	rrl.penaltyMu.Lock()
+	defer rrl.penaltyMu.Unlock()
+
+	now := rrl.now()
+	var pl penaltyList
+	if old := rrl.penalties.Load(); old != nil {
+		for _, op := range *old {
+			if op.until > now && (op.prefix != p.prefix || op.suffix != p.suffix) {
+				pl = append(pl, op)
+			}
+		}
+	}
+	if d > 0 {
+		p.until = now + int64(d)
+		pl = append(pl, p)
+	}
+	if len(pl) == 0 {
+		rrl.penalties.Store(nil)
+		return
+	}
+	rrl.penalties.Store(&pl)
+}
+
+// canonicalSuffix returns name in the lowercase, trailing dot free form used by
+// penalties.
+func canonicalSuffix(name string) string {
+	return strings.TrimSuffix(strings.ToLower(name), ".")
+}
+
+// penalizedNetwork returns true if the Client Network is currently penalized.
+func (rrl *RRL) penalizedNetwork(ipPrefix string) bool {
+	pl := rrl.penalties.Load()
+	if pl == nil {
+		return false
+	}
+	addr, err := netip.ParseAddr(ipPrefix)
+	if err != nil {
+		return false
+	}
+	now := rrl.now()
+	for _, p := range *pl {
+		if p.until > now && p.prefix.IsValid() && p.prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// penalizedName returns true if the lowercase SalientName is currently penalized.
+func (rrl *RRL) penalizedName(name string) bool {
+	pl := rrl.penalties.Load()
+	if pl == nil {
+		return false
+	}
+	name = strings.TrimSuffix(name, ".")
+	now := rrl.now()
+	for _, p := range *pl {
+		if p.until <= now || p.prefix.IsValid() {
+			continue
+		}
+		if p.suffix == "" || name == p.suffix ||
+			(strings.HasSuffix(name, p.suffix) && name[len(name)-len(p.suffix)-1] == '.') {
+			return true
+		}
+	}
+
+	return false
+}
+
+// penaltyAllowance returns an allowance which drives any account to -window, as balances
+// never exceed one second of credit.
+func penaltyAllowance(window int64) int64 {
+	return window + 2*second
+}
@@ -0,0 +1,136 @@
+package rrl
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestPenalize(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cfg := NewConfig()
+	cfg.SetValue("responses-per-second", "10")
+	cfg.SetValue("requests-per-second", "10")
+	cfg.SetValue("minimum-responses-per-second", "10")
+	cfg.SetValue("slip-ratio", "0")
+	cfg.SetNowFunc(func() time.Time { return now })
+	R := NewRRL(cfg)
+
+	src := newAddr("udp", "10.0.0.1:53")
+	neighbour := newAddr("udp", "10.0.1.1:53")
+	tuple := newTuple(1, 1, "example.com.", AllowanceAnswer)
+	if act, ipr, rtr := R.Debit(src, tuple); act != Send {
+		t.Fatal("Should be sent before penalty", act, ipr, rtr)
+	}
+
+	R.Penalize(netip.MustParsePrefix("10.0.0.99/32"), 10*time.Second) // Widened to the /24
+	if act, ipr, _ := R.Debit(src, tuple); act != Drop || ipr != IPRateLimit {
+		t.Error("Penalized network should be rate limited, not", act, ipr)
+	}
+	if act, _, _ := R.Debit(neighbour, tuple); act != Send {
+		t.Error("Neighbouring network should not be penalized", act)
+	}
+	if act := R.CheapCheck(src); act != Drop {
+		t.Error("CheapCheck should report Drop for penalized network")
+	}
+	if act, ipr, _ := R.Check(newAddr("udp", "10.0.0.200:53"), tuple); act != Drop || ipr != IPRateLimit {
+		t.Error("Check should report penalized network", act, ipr)
+	}
+	stats := R.GetStats(false)
+	if stats.IPReasons[IPRateLimit] != 1 || stats.IPReasons[IPMinimum] != 0 {
+		t.Error("Penalty should be counted as organic limiting", stats.String())
+	}
+
+	// The account is at -window when the penalty expires and recovers organically, with
+	// the minimum guarantee once again applying
+	now = now.Add(11 * time.Second)
+	if _, ipr, _ := R.Debit(src, tuple); ipr != IPMinimum {
+		t.Error("Account should still be recovering after penalty expiry", ipr)
+	}
+	now = now.Add(time.Duration(cfg.window))
+	if act, ipr, _ := R.Debit(src, tuple); act != Send || ipr != IPOk {
+		t.Error("Account should have recovered", act, ipr)
+	}
+
+	// Lifting a penalty
+	R.Penalize(netip.MustParsePrefix("10.0.1.0/24"), time.Minute)
+	R.Penalize(netip.MustParsePrefix("10.0.1.0/24"), 0)
+	if R.penalties.Load() != nil {
+		t.Error("Lifted penalty should be removed")
+	}
+	if act, _, _ := R.Debit(neighbour, tuple); act != Send {
+		t.Error("Lifted penalty should have no effect", act)
+	}
+}
+
+// An invalid prefix must not be mistaken for a name penalty which matches every name
+func TestPenalizeInvalid(t *testing.T) {
+	cfg := NewConfig()
+	cfg.SetValue("responses-per-second", "10")
+	cfg.SetNowFunc(func() time.Time { return time.Unix(1000, 0) })
+	R := NewRRL(cfg)
+
+	R.Penalize(netip.Prefix{}, time.Minute)
+	if R.penalties.Load() != nil {
+		t.Error("Invalid prefix should not be penalized")
+	}
+	if R.penalizedName("example.com") {
+		t.Error("Invalid prefix should not penalize names")
+	}
+	if act, _, rtr := R.Debit(newAddr("udp", "10.0.0.1:53"), newTuple(1, 1, "example.com.", AllowanceAnswer)); act != Send {
+		t.Error("Invalid prefix should have no effect", act, rtr)
+	}
+}
+
+// Response accounts are penalized even if requests are not limited
+func TestPenalizeResponses(t *testing.T) {
+	cfg := NewConfig()
+	cfg.SetValue("responses-per-second", "10")
+	cfg.SetNowFunc(func() time.Time { return time.Unix(1000, 0) })
+	R := NewRRL(cfg)
+
+	R.Penalize(netip.MustParsePrefix("2001:db8::/32"), time.Minute)
+	src := newAddr("udp", "[2001:db8::1]:53")
+	if act, ipr, rtr := R.Debit(src, newTuple(1, 1, "example.com.", AllowanceAnswer)); act == Send || rtr != RTRateLimit {
+		t.Error("Penalized response should be limited, not", act, ipr, rtr)
+	}
+	if act, _, _ := R.Debit(newAddr("tcp", "[2001:db8::1]:53"), newTuple(1, 1, "example.com.", AllowanceAnswer)); act != Send {
+		t.Error("Penalty only applies to configured limits", act)
+	}
+}
+
+func TestPenalizeName(t *testing.T) {
+	cfg := NewConfig()
+	cfg.SetValue("responses-per-second", "10")
+	cfg.SetValue("requests-per-second", "10")
+	cfg.SetNowFunc(func() time.Time { return time.Unix(1000, 0) })
+	R := NewRRL(cfg)
+
+	R.PenalizeName("Victim.EXAMPLE", time.Minute)
+	src := newAddr("udp", "10.0.0.1:53")
+	for _, tc := range []struct {
+		name    string
+		limited bool
+	}{
+		{"victim.example.", true},
+		{"www.VICTIM.example.", true},
+		{"notvictim.example.", false},
+		{"example.", false},
+	} {
+		act, ipr, rtr := R.Debit(src, newTuple(1, 1, tc.name, AllowanceAnswer))
+		if (rtr == RTRateLimit) != tc.limited {
+			t.Error(tc.name, "unexpected", act, ipr, rtr)
+		}
+		if ipr != IPOk {
+			t.Error(tc.name, "requests should not be penalized by name", ipr)
+		}
+	}
+	if act, _, _ := R.Check(src, newTuple(1, 1, "a.b.victim.example", AllowanceNXDomain)); act != Drop {
+		t.Error("Check should report the penalized name", act)
+	}
+
+	R.PenalizeName("victim.example.", 0)
+	if R.penalties.Load() != nil {
+		t.Error("Lifted name penalty should be removed")
+	}
+}
@@ -0,0 +1,317 @@
+package rrl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// PolicyVersion is the only version of the policy document schema understood by
+// [LoadPolicy].
+const PolicyVersion = 1
+
+// Policy is the declarative form of an RRL configuration, suitable for managing as a
+// reviewable file across a fleet of servers. It is read by [LoadPolicy], written by
+// [Policy.WriteTo] and captured from a running RRL by [RRL.Policy].
+//
+// The document is YAML with this schema:
+//
+//	# Comments are ignored
+//	version: 1
+//	settings:                        # Any Config.SetValue keyword and value
+//	  responses-per-second: 10
+//	  nxdomains-per-second: 5
+//	  error-suffixes: "example.com,example.net"
+//	disabled-categories:             # AllowanceCategory names, see RRL.DisableCategory
+//	  - AllowanceReferral
+//
+// Only the block mappings, block sequences and plain, single or double quoted scalars
+// shown above are understood; anchors, flow collections other than the empty {} and []
+// and multi-line scalars are rejected. As settings are passed to [Config.SetValue] in
+// document order, the usual interactions between keywords apply, e.g. a per-category
+// rate defaults to responses-per-second only if it does not appear.
+//
+// Secret keywords, such as "account-hash-key", may be present in a policy but are never
+// captured by [RRL.Policy] so they are best supplied separately.
+type Policy struct {
+	Settings           []PolicySetting
+	DisabledCategories []AllowanceCategory
+}
+
+// PolicySetting is a single [Config.SetValue] keyword and value.
+type PolicySetting struct {
+	Keyword string
+	Value   string
+}
+
+// secretKeywords are never captured by [RRL.Policy].
+var secretKeywords = map[string]bool{
+	"account-hash-key": true, "shard-hash-key": true, "state-transfer-key": true,
+}
+
+// LoadPolicy reads and validates a policy document from r. Errors identify the offending
+// line and wrap the [Config.SetValue] error, if any, so errors.Is(err, ErrSyntax) and
+// similar work as expected.
+func LoadPolicy(r io.Reader) (*Policy, error) {
+	p := &Policy{}
+	var section string
+	var sawVersion bool
+	settings := make(map[string]int) // Line of each keyword to detect duplicates
+
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := stripComment(scanner.Text())
+		trimmed := strings.TrimSpace(line)
+		if len(trimmed) == 0 || trimmed == "---" {
+			continue
+		}
+		lineErr := func(format string, a ...interface{}) error {
+			return fmt.Errorf("rrl: policy line %d: "+format, append([]interface{}{lineNo}, a...)...)
+		}
+		if strings.ContainsAny(trimmed[:1], "&*!|>{[") && trimmed != "{}" && trimmed != "[]" {
+			return nil, lineErr("unsupported YAML construct '%s'", trimmed)
+		}
+
+		indented := trimmed != line && line[0] == ' '
+		if !indented { // Top-level key
+			key, value, ok := splitMapping(trimmed)
+			if !ok {
+				return nil, lineErr("expected 'key: value', not '%s'", trimmed)
+			}
+			section = ""
+			switch key {
+			case "version":
+				v, err := strconv.Atoi(value)
+				if err != nil || v != PolicyVersion {
+					return nil, lineErr("unsupported version '%s'", value)
+				}
+				sawVersion = true
+			case "settings":
+				if value != "" && value != "{}" {
+					return nil, lineErr("settings must be a mapping")
+				}
+				section = key
+			case "disabled-categories":
+				if value != "" && value != "[]" {
+					return nil, lineErr("disabled-categories must be a sequence")
+				}
+				section = key
+			default:
+				return nil, lineErr("unknown key '%s'", key)
+			}
+			continue
+		}
+
+		switch section {
+		case "settings":
+			key, value, ok := splitMapping(trimmed)
+			if !ok || len(key) == 0 {
+				return nil, lineErr("expected 'keyword: value', not '%s'", trimmed)
+			}
+			value, err := unquoteScalar(value)
+			if err != nil {
+				return nil, lineErr("%s", err)
+			}
+			if prev, ok := settings[key]; ok {
+				return nil, lineErr("duplicate keyword '%s', first set on line %d", key, prev)
+			}
+			settings[key] = lineNo
+			if err := NewConfig().SetValue(key, value); err != nil {
+				return nil, lineErr("%w", err)
+			}
+			p.Settings = append(p.Settings, PolicySetting{key, value})
+
+		case "disabled-categories":
+			if !strings.HasPrefix(trimmed, "- ") {
+				return nil, lineErr("expected '- category', not '%s'", trimmed)
+			}
+			name, err := unquoteScalar(strings.TrimSpace(trimmed[2:]))
+			if err != nil {
+				return nil, lineErr("%s", err)
+			}
+			ac, ok := parseAllowanceCategory(name)
+			if !ok {
+				return nil, lineErr("unknown AllowanceCategory '%s'", name)
+			}
+			p.DisabledCategories = append(p.DisabledCategories, ac)
+
+		default:
+			return nil, lineErr("unexpected indentation")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !sawVersion {
+		return nil, fmt.Errorf("rrl: policy is missing 'version: %d'", PolicyVersion)
+	}
+
+	// Settings are valid individually but may still conflict with each other
+	cfg, err := p.Config()
+	if err == nil {
+		cfg.finalize()
+		err = cfg.check()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// Config returns a new Config with all the settings of the policy applied in order.
+func (p *Policy) Config() (*Config, error) {
+	cfg := NewConfig()
+	for _, s := range p.Settings {
+		if err := cfg.SetValue(s.Keyword, s.Value); err != nil {
+			return nil, fmt.Errorf("rrl: policy: %w", err)
+		}
+	}
+
+	return cfg, nil
+}
+
+// Apply applies the runtime state of the policy to rrl. Categories in DisabledCategories
+// are disabled and all others are enabled. Settings are not applied as an RRL
+// configuration cannot be changed once created; use [Policy.Config] to create a new RRL.
+func (p *Policy) Apply(rrl *RRL) {
+	var disabled [AllowanceLast]bool
+	for _, ac := range p.DisabledCategories {
+		if ac < AllowanceLast {
+			disabled[ac] = true
+		}
+	}
+	for ac := AllowanceAnswer; ac < AllowanceLast; ac++ {
+		if disabled[ac] {
+			rrl.DisableCategory(ac)
+		} else {
+			rrl.EnableCategory(ac)
+		}
+	}
+}
+
+// WriteTo writes the policy to w as a document which [LoadPolicy] reads back as an
+// identical Policy. It implements [io.WriterTo].
+func (p *Policy) WriteTo(w io.Writer) (int64, error) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "version: %d\n", PolicyVersion)
+	if len(p.Settings) > 0 {
+		sb.WriteString("settings:\n")
+		for _, s := range p.Settings {
+			fmt.Fprintf(&sb, "  %s: %s\n", s.Keyword, quoteScalar(s.Value))
+		}
+	}
+	if len(p.DisabledCategories) > 0 {
+		sb.WriteString("disabled-categories:\n")
+		for _, ac := range p.DisabledCategories {
+			fmt.Fprintf(&sb, "  - %s\n", ac)
+		}
+	}
+	n, err := io.WriteString(w, sb.String())
+
+	return int64(n), err
+}
+
+// Policy captures the configuration and runtime state of rrl as a Policy. Only settings
+// which differ from their defaults are included and secret keywords are omitted.
+//
+// Policy is concurrency safe.
+func (rrl *RRL) Policy() *Policy {
+	p := &Policy{}
+	for ix := range keywords {
+		kw := &keywords[ix]
+		if secretKeywords[kw.name] || kw.isDefault(&rrl.cfg) {
+			continue
+		}
+		p.Settings = append(p.Settings, PolicySetting{kw.name, kw.current(&rrl.cfg)})
+	}
+	for ac := AllowanceAnswer; ac < AllowanceLast; ac++ {
+		if rrl.CategoryDisabled(ac) {
+			p.DisabledCategories = append(p.DisabledCategories, ac)
+		}
+	}
+
+	return p
+}
+
+// isDefault returns true if the current value of the keyword is that of a new Config. A
+// default which names another keyword, such as "responses-per-second", is compared with
+// the current value of that keyword. Family keywords are only captured if set, as their
+// values are otherwise derived from the unqualified keywords.
+func (kw *keyword) isDefault(c *Config) bool {
+	if fam, ac, ok := familyKeyword(kw.name); ok {
+		return !c.familySet[fam][ac]
+	}
+	current := kw.current(c)
+	if current == kw.current(NewConfig()) {
+		return true
+	}
+	if other := lookupKeyword(kw.dflt); other != nil {
+		return current == other.current(c)
+	}
+
+	return false
+}
+
+// stripComment removes a trailing comment which is not within a quoted scalar.
+func stripComment(line string) string {
+	var quote byte
+	for ix := 0; ix < len(line); ix++ {
+		switch c := line[ix]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				ix++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (ix == 0 || line[ix-1] == ' ' || line[ix-1] == '\t'):
+			return strings.TrimRight(line[:ix], " \t")
+		}
+	}
+
+	return strings.TrimRight(line, " \t")
+}
+
+// splitMapping splits "key: value" or "key:" into its key and value.
+func splitMapping(s string) (key, value string, ok bool) {
+	if strings.HasSuffix(s, ":") {
+		return s[:len(s)-1], "", true
+	}
+	key, value, ok = strings.Cut(s, ": ")
+
+	return strings.TrimSpace(key), strings.TrimSpace(value), ok
+}
+
+// unquoteScalar returns the value of a plain, single or double quoted scalar.
+func unquoteScalar(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return "", fmt.Errorf("invalid double quoted scalar %s", s)
+		}
+		return v, nil
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return "", fmt.Errorf("invalid single quoted scalar %s", s)
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+
+	return s, nil
+}
+
+// quoteScalar returns s as a plain scalar if it is unambiguous, otherwise as a double
+// quoted scalar.
+func quoteScalar(s string) string {
+	if len(s) == 0 || strings.ContainsAny(s, "#:\"'\\\t\n") || strings.TrimSpace(s) != s ||
+		strings.ContainsAny(s[:1], "&*!|>{}[]-?,%@`") {
+		return strconv.Quote(s)
+	}
+
+	return s
+}
@@ -0,0 +1,142 @@
+package rrl
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testPolicy = `# Edge policy
+---
+version: 1
+settings:
+  responses-per-second: 10   # Trailing comment
+  nxdomains-per-second: '5'
+  error-suffixes: "example.com,example.net"
+  account-hash-key: "not # a comment"
+disabled-categories:
+  - AllowanceReferral
+`
+
+func TestLoadPolicy(t *testing.T) {
+	p, err := LoadPolicy(strings.NewReader(testPolicy))
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	exp := &Policy{
+		Settings: []PolicySetting{{"responses-per-second", "10"}, {"nxdomains-per-second", "5"},
+			{"error-suffixes", "example.com,example.net"}, {"account-hash-key", "not # a comment"}},
+		DisabledCategories: []AllowanceCategory{AllowanceReferral},
+	}
+	if !reflect.DeepEqual(p, exp) {
+		t.Errorf("Unexpected Policy\nGot: %+v\nExp: %+v", p, exp)
+	}
+
+	cfg, err := p.Config()
+	if err != nil {
+		t.Fatal("Unexpected Config error", err)
+	}
+	R := NewRRL(cfg)
+	p.Apply(R)
+	if !R.CategoryDisabled(AllowanceReferral) || R.CategoryDisabled(AllowanceAnswer) {
+		t.Error("Apply should have disabled only AllowanceReferral")
+	}
+	if R.cfg.nxdomainsInterval != second/5 {
+		t.Error("Settings were not applied", R.cfg.String())
+	}
+
+	testCases := []struct {
+		doc  string
+		emsg string
+	}{
+		{"settings:\n  window: 5\n", "missing 'version"},
+		{"version: 2\n", "unsupported version"},
+		{"version: 1\nlimits:\n", "unknown key"},
+		{"version: 1\n  window: 5\n", "unexpected indentation"},
+		{"version: 1\nsettings: 5\n", "must be a mapping"},
+		{"version: 1\nsettings:\n  window 5\n", "expected 'keyword: value'"},
+		{"version: 1\nsettings:\n  window: 5\n  window: 6\n", "duplicate keyword"},
+		{"version: 1\nsettings:\n  windows: 5\n", "line 3"},
+		{"version: 1\nsettings:\n  window: \"5\n", "invalid double quoted"},
+		{"version: 1\ndisabled-categories:\n  AllowanceAnswer\n", "expected '- category'"},
+		{"version: 1\ndisabled-categories:\n  - Answer\n", "unknown AllowanceCategory"},
+		{"version: 1\nsettings:\n  window: &w 5\n", "syntax"}, // Anchors in values reach SetValue
+		{"version: 1\n[window, 5]\n", "unsupported YAML"},
+		{"version: 1\nsettings:\n  - window\n", "expected 'keyword: value'"},
+		{"version: 1\nsettings: {}\ndisabled-categories: []\n", "<nil>"},
+		{"version: 1\nsettings:\n  responses-per-second: 0.01\n", "never recover"},
+	}
+	for ix, tc := range testCases {
+		_, err := LoadPolicy(strings.NewReader(tc.doc))
+		switch {
+		case tc.emsg == "<nil>":
+			if err != nil {
+				t.Error(ix, "Unexpected error", err)
+			}
+		case err == nil:
+			t.Error(ix, "Expected an error containing", tc.emsg)
+		case !strings.Contains(err.Error(), tc.emsg):
+			t.Error(ix, "Expected error containing", tc.emsg, "not", err)
+		}
+	}
+
+	_, err = LoadPolicy(strings.NewReader("version: 1\nsettings:\n  window: x\n"))
+	if !errors.Is(err, ErrSyntax) {
+		t.Error("SetValue errors should be wrapped", err)
+	}
+}
+
+func TestPolicyRoundTrip(t *testing.T) {
+	if p := NewRRL(NewConfig()).Policy(); len(p.Settings) != 0 || len(p.DisabledCategories) != 0 {
+		t.Error("Default RRL should have an empty Policy", p)
+	}
+
+	cfg := NewConfig()
+	for _, kv := range [][2]string{
+		{"window", "30"}, {"responses-per-second", "10"}, {"nodata-per-second", "10"},
+		{"errors-per-second", "2.5"}, {"nxdomains-recovery", "exponential"},
+		{"error-suffixes", "example.com,example.net"}, {"requests-by-transport", "true"},
+		{"account-hash-key", "secret"}, {"probation-count", "3"}, {"ipv6:responses-per-second", "4"},
+	} {
+		if err := cfg.SetValue(kv[0], kv[1]); err != nil {
+			t.Fatal(kv, err)
+		}
+	}
+	R := NewRRL(cfg)
+	R.DisableCategory(AllowanceNXDomain)
+	p := R.Policy()
+	for _, s := range p.Settings {
+		switch s.Keyword {
+		case "account-hash-key":
+			t.Error("Secret keywords should not be captured")
+		case "nodata-per-second":
+			t.Error("Setting equal to its defaulting keyword should not be captured")
+		case "ipv6:nodata-per-second", "ipv4:responses-per-second":
+			t.Error("Family keywords should only be captured if set", s.Keyword)
+		}
+	}
+
+	var buf bytes.Buffer
+	if _, err := p.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadPolicy(&buf)
+	if err != nil {
+		t.Fatal("Exported policy should load", err, buf.String())
+	}
+	if !reflect.DeepEqual(got, p) {
+		t.Errorf("Round trip mismatch\nGot: %+v\nExp: %+v", got, p)
+	}
+
+	cfg, err = got.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	R2 := NewRRL(cfg)
+	got.Apply(R2)
+	if p2 := R2.Policy(); !reflect.DeepEqual(p2, p) {
+		t.Errorf("Recreated RRL has a different Policy\nGot: %+v\nExp: %+v", p2, p)
+	}
+}
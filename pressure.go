@@ -0,0 +1,50 @@
+package rrl
+
+import (
+	"math"
+)
+
+// SetPressure allows the embedding server to signal its own overload level, such as CPU
+// utilization or socket backlog, so that rrl can shed load more aggressively.
+// A pressure level greater than zero scales down all configured allowances (other than
+// minimum-responses-per-second) proportionally. This is a generalized form of the ISC
+// qps-scale setting driven by a caller-defined measure.
+//
+// level is clamped to the range 0.0 to 1.0. A level of 0.0 applies the configured
+// allowances unchanged, a level of 0.5 halves them and a level of 1.0 reduces them to
+// zero which causes all accounts to drop.
+//
+// SetPressure is concurrency safe and can be called as often as needed.
+func (rrl *RRL) SetPressure(level float64) {
+	if level < 0 || math.IsNaN(level) {
+		level = 0
+	} else if level > 1 {
+		level = 1
+	}
+	rrl.pressure.Store(math.Float64bits(level))
+}
+
+// Pressure returns the current pressure level as set by [SetPressure].
+func (rrl *RRL) Pressure() float64 {
+	return math.Float64frombits(rrl.pressure.Load())
+}
+
+// applyPressure scales the interval by the current pressure level. Since an interval is
+// the inverse of an allowance, a reduced allowance results in a larger interval. The
+// interval is capped at the window as no single debit needs to exceed the most negative
+// balance an account can have.
+func (rrl *RRL) applyPressure(interval int64) int64 {
+	level := rrl.Pressure()
+	if level == 0 || interval == 0 {
+		return interval
+	}
+	if level >= 1 {
+		return rrl.cfg.window
+	}
+	scaled := float64(interval) / (1 - level)
+	if scaled >= float64(rrl.cfg.window) {
+		return rrl.cfg.window
+	}
+
+	return int64(scaled)
+}
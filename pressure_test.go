@@ -0,0 +1,61 @@
+package rrl
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPressure(t *testing.T) {
+	cfg := NewConfig()
+	cfg.SetValue("responses-per-second", "10")
+	R := NewRRL(cfg)
+
+	testCases := []struct {
+		level  float64
+		expect float64
+		scaled int64
+	}{
+		{0, 0, 100000000},
+		{-1, 0, 100000000},
+		{0.5, 0.5, 200000000},
+		{0.9, 0.9, 1000000000},
+		{1, 1, 15 * second},
+		{7, 1, 15 * second},
+	}
+
+	for ix, tc := range testCases {
+		R.SetPressure(tc.level)
+		if got := R.Pressure(); got != tc.expect {
+			t.Error(ix, "Pressure expected", tc.expect, "got", got)
+		}
+		got := R.applyPressure(R.allowanceForRtype(AllowanceAnswer, "10.0.0.0"))
+		if got < tc.scaled-1 || got > tc.scaled+1 { // Allow for float rounding
+			t.Error(ix, "Scaled interval expected", tc.scaled, "got", got)
+		}
+		if got := R.applyPressure(0); got != 0 {
+			t.Error(ix, "Unconfigured interval should remain zero, not", got)
+		}
+	}
+}
+
+func TestPressureDebit(t *testing.T) {
+	cfg := NewConfig()
+	cfg.SetValue("responses-per-second", "4")
+	cfg.SetValue("slip-ratio", "0")
+	cfg.SetNowFunc(func() time.Time {
+		return time.Time{}
+	})
+	R := NewRRL(cfg)
+	R.SetPressure(0.5) // Halves the allowance to 2 per second
+
+	src := newAddr("udp", "127.0.0.1:53")
+	tuple := newTuple(1, 1, "example.com.", AllowanceAnswer)
+	for ix := 0; ix < 2; ix++ {
+		if act, _, _ := R.Debit(src, tuple); act != Send {
+			t.Fatal(ix, "Expected Send under pressure, not", act)
+		}
+	}
+	if act, _, _ := R.Debit(src, tuple); act != Drop {
+		t.Error("Expected Drop once scaled allowance is exhausted, not", act)
+	}
+}
@@ -0,0 +1,71 @@
+package rrl
+
+import (
+	"hash/maphash"
+	"sync"
+	"sync/atomic"
+
+	"github.com/markdingo/rrl/cache"
+)
+
+const probationSlots = 1 << 14 // Must be a power of two
+
+// probation is a counting Bloom filter of account tokens without accounts. Each token
+// increments two counters selected by independent halves of its hash and the smaller of
+// the two is the, possibly over-estimated, number of times the token has been seen in
+// the current period. Counters are updated atomically so debits never wait on each other
+// and all counters are reset at the start of each period.
+type probation struct {
+	seed   maphash.Seed
+	count  uint32 // Sightings required before an account is created
+	period int64
+
+	mu       sync.Mutex // Protects resets
+	epoch    atomic.Int64
+	counters [probationSlots]atomic.Uint32
+}
+
+// initProbation creates the probation filter if probation-count is configured.
+func (rrl *RRL) initProbation() {
+	if rrl.cfg.probationCount < 2 {
+		return
+	}
+	rrl.probation = &probation{seed: maphash.MakeSeed(), count: uint32(rrl.cfg.probationCount),
+		period: rrl.cfg.probationPeriod}
+	rrl.probation.epoch.Store(rrl.now() / rrl.cfg.probationPeriod)
+}
+
+// admit records a sighting of token t and returns true once it has been seen
+// probation-count times in the current period.
+func (p *probation) admit(t string, now int64) bool {
+	if epoch := now / p.period; epoch != p.epoch.Load() {
+		p.mu.Lock()
+		if epoch != p.epoch.Load() {
+			for ix := range p.counters {
+				p.counters[ix].Store(0)
+			}
+			p.epoch.Store(epoch)
+		}
+		p.mu.Unlock()
+	}
+
+	h := maphash.String(p.seed, t)
+	c1 := p.counters[h&(probationSlots-1)].Add(1)
+	c2 := p.counters[(h>>32)&(probationSlots-1)].Add(1)
+	if c2 < c1 {
+		c1 = c2
+	}
+
+	return c1 >= p.count
+}
+
+// onProbation returns true if the account t does not exist and has not yet been seen
+// often enough to be created.
+func (rrl *RRL) onProbation(table *cache.Cache, t string) bool {
+	if rrl.probation == nil || rrl.probation.admit(t, rrl.now()) {
+		return false
+	}
+	_, found := table.Get(t)
+
+	return !found
+}
@@ -0,0 +1,84 @@
+package rrl
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestProbation(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cfg := NewConfig()
+	cfg.SetValue("responses-per-second", "1")
+	cfg.SetValue("slip-ratio", "0")
+	cfg.SetValue("probation-count", "3")
+	cfg.SetValue("probation-period", "100")
+	cfg.SetNowFunc(func() time.Time { return now })
+	R := NewRRL(cfg)
+
+	// One-off names never create an account
+	src := newAddr("udp", "10.0.0.1:53")
+	for ix := 0; ix < 100; ix++ {
+		tuple := newTuple(1, 1, fmt.Sprintf("r%d.example.com.", ix), AllowanceNXDomain)
+		if act, _, _ := R.Debit(src, tuple); act != Send {
+			t.Fatal("Account on probation should be sent", act)
+		}
+	}
+	if l := R.tableLen(); l != 0 {
+		t.Error("One-off names should not have created accounts", l)
+	}
+
+	// The third debit within the period creates the account
+	tuple := newTuple(1, 1, "example.com.", AllowanceAnswer)
+	R.Debit(src, tuple)
+	R.Debit(src, tuple)
+	if l := R.tableLen(); l != 0 {
+		t.Error("Account should still be on probation", l)
+	}
+	R.Debit(src, tuple)
+	if l := R.tableLen(); l != 1 {
+		t.Error("Account should have been created", l)
+	}
+
+	// Once created, the account is debited normally in later periods
+	if act, _, _ := R.Debit(src, tuple); act != Drop {
+		t.Error("Existing account should be debited", act)
+	}
+	now = now.Add(200 * time.Millisecond)
+	if act, _, _ := R.Debit(src, tuple); act != Drop {
+		t.Error("Existing account should be debited after reset", act)
+	}
+
+	// Sightings do not accumulate across periods
+	other := newTuple(1, 1, "example.net.", AllowanceAnswer)
+	for ix := 0; ix < 4; ix++ {
+		R.Debit(src, other)
+		now = now.Add(60 * time.Millisecond)
+	}
+	if l := R.tableLen(); l != 1 {
+		t.Error("Sightings in different periods should not create an account", l)
+	}
+
+	// A count of one disables probation
+	cfg.SetValue("probation-count", "1")
+	R = NewRRL(cfg)
+	if R.probation != nil {
+		t.Error("probation-count of one should not create a filter")
+	}
+}
+
+// At rates below one per second accounts on probation are still sent, as new accounts are
+func TestProbationSlowRate(t *testing.T) {
+	cfg := NewConfig()
+	cfg.SetValue("responses-per-second", "0.5")
+	cfg.SetValue("slip-ratio", "0")
+	cfg.SetValue("probation-count", "3")
+	cfg.SetNowFunc(func() time.Time { return time.Unix(1000, 0) })
+	R := NewRRL(cfg)
+
+	src := newAddr("udp", "10.0.0.1:53")
+	tuple := newTuple(1, 1, "example.com.", AllowanceAnswer)
+	if act, _, rtr := R.Debit(src, tuple); act != Send || rtr != RTOk {
+		t.Error("Account on probation should be sent", act, rtr)
+	}
+}
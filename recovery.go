@@ -0,0 +1,74 @@
+package rrl
+
+import (
+	"math"
+)
+
+// recoveryCurve determines how quickly a rate limited account recovers its credit.
+type recoveryCurve uint8
+
+const (
+	recoveryUnset       recoveryCurve = iota // Per-category curve defaults to recovery
+	recoveryLinear                           // Debt is repaid at one second per second
+	recoveryExponential                      // Debt is repaid more slowly the deeper it is
+)
+
+var recoveryNames = map[string]recoveryCurve{
+	"linear":      recoveryLinear,
+	"exponential": recoveryExponential,
+}
+
+func (rc recoveryCurve) String() string {
+	for name, curve := range recoveryNames {
+		if curve == rc {
+			return name
+		}
+	}
+
+	return "unset"
+}
+
+// recoveryFor returns the recovery curve applicable to response accounts of the
+// AllowanceCategory.
+func (rrl *RRL) recoveryFor(ac AllowanceCategory) recoveryCurve {
+	if ac < AllowanceLast {
+		return rrl.cfg.recoveries[ac]
+	}
+	return rrl.cfg.recovery
+}
+
+// maxTransformedDebt bounds the transformed debt of exponential recovery so that
+// allowTime cannot overflow, whatever balance is set.
+const maxTransformedDebt = math.MaxInt64 / 4
+
+// balance returns the balance of the account at now.
+//
+// For linear recovery, allowTime is simply now less the balance. For exponential
+// recovery, negative balances are stored in a transformed space in which the debt is
+// repaid linearly. The transformed debt, u, is scale*e^(debt/scale) - scale, which
+// corresponds to a recovery rate of e^(-debt/scale) seconds per second. Thus an account
+// with a debt of scale recovers at 1/e of the linear rate whereas an account with a small
+// debt recovers at close to the linear rate. Positive balances are never transformed.
+//
+// The scale is the window of the account, which is also its maximum debt, so an account
+// at the maximum debt always recovers at 1/e of the linear rate whatever its window.
+func (ra *responseAccount) balance(now int64) int64 {
+	b := now - ra.allowTime
+	if ra.curve != recoveryExponential || b >= 0 {
+		return b
+	}
+
+	return -int64(float64(ra.scale) * math.Log1p(float64(-b)/float64(ra.scale)))
+}
+
+// setBalance sets allowTime such that the account has balance b at now.
+func (ra *responseAccount) setBalance(now, b int64) {
+	if ra.curve == recoveryExponential && b < 0 {
+		u := float64(ra.scale) * math.Expm1(float64(-b)/float64(ra.scale))
+		if u > maxTransformedDebt || math.IsNaN(u) {
+			u = maxTransformedDebt
+		}
+		b = -int64(u)
+	}
+	ra.allowTime = now - b
+}
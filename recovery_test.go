@@ -0,0 +1,124 @@
+package rrl
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecoveryRoundTrip(t *testing.T) {
+	const scale = 15 * second
+	for _, curve := range []recoveryCurve{recoveryLinear, recoveryExponential} {
+		ra := &responseAccount{curve: curve, scale: scale}
+		for _, b := range []int64{second, 0, -1, -second, -scale, -4 * scale} {
+			ra.setBalance(1000*second, b)
+			if got := ra.balance(1000 * second); got < b-1 || got > b+1 {
+				t.Error(curve, "balance", b, "round tripped to", got)
+			}
+		}
+	}
+}
+
+// Exponential recovery repays deep debts more slowly than linear recovery whereas
+// shallow debts recover at close to the linear rate.
+func TestRecoveryCurves(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cfg := NewConfig()
+	cfg.SetValue("window", "10")
+	cfg.SetValue("responses-per-second", "1")
+	cfg.SetValue("nxdomains-recovery", "exponential")
+	cfg.SetNowFunc(func() time.Time { return now })
+	R := NewRRL(cfg)
+
+	src := newAddr("udp", "10.0.0.1:53")
+	answer := newTuple(1, 1, "example.com.", AllowanceAnswer)
+	nxdomain := newTuple(1, 1, "example.com.", AllowanceNXDomain)
+	for ix := 0; ix < 20; ix++ { // Both accounts are driven to -window
+		R.Debit(src, answer)
+		R.Debit(src, nxdomain)
+	}
+	balance := func(tuple *ResponseTuple) time.Duration {
+		t := R.accountToken("10.0.0.0", tuple.Class, tuple.Type, tuple.SalientName, tuple.AllowanceCategory)
+		b, _ := R.balance(R.tableFor(tuple.AllowanceCategory), 0, t)
+		return time.Duration(b)
+	}
+	if a, n := balance(answer), balance(nxdomain); a != -10*time.Second || n < -10*time.Second-1 || n > -10*time.Second+1 {
+		t.Fatal("Expected both accounts at -window", a, n)
+	}
+
+	now = now.Add(5 * time.Second)
+	linear := balance(answer)
+	exponential := balance(nxdomain)
+	if linear != -5*time.Second {
+		t.Error("Linear should have recovered 5s, not", linear)
+	}
+	if exponential > -7900*time.Millisecond || exponential < -8*time.Second { // -10*ln(e-0.5) ~= -7.97s
+		t.Error("Exponential should have recovered ~2s, not", exponential)
+	}
+
+	// Balances reported by walkAccounts are the real balances, not the transformed ones
+	R.walkAccounts(0, func(tok string, ra *responseAccount, b int64) bool {
+		if ra.curve == recoveryExponential && time.Duration(b) != exponential {
+			t.Error("walkAccounts reported", time.Duration(b), "expected", exponential)
+		}
+		return true
+	})
+}
+
+// Exponential recovery is scaled by the window of the category rather than the global
+// window, so deep debts in a much longer category window neither overflow nor take
+// hours to repay.
+func TestRecoveryCategoryWindow(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cfg := NewConfig()
+	cfg.SetValue("window", "15")
+	cfg.SetValue("nxdomains-window", "600")
+	cfg.SetValue("nxdomains-per-second", "1")
+	cfg.SetValue("nxdomains-recovery", "exponential")
+	cfg.SetNowFunc(func() time.Time { return now })
+	R := NewRRL(cfg)
+
+	src := newAddr("udp", "10.0.0.1:53")
+	tuple := newTuple(1, 1, "example.com.", AllowanceNXDomain)
+	tok := R.accountToken("10.0.0.0", tuple.Class, tuple.Type, tuple.SalientName, tuple.AllowanceCategory)
+	balance := func() time.Duration {
+		b, _ := R.balance(R.tableFor(tuple.AllowanceCategory), 0, tok)
+		return time.Duration(b)
+	}
+	for ix := 0; ix < 700; ix++ { // Driven to -window without overflowing
+		R.Debit(src, tuple)
+	}
+	if b := balance(); b < -600*time.Second-1 || b > -600*time.Second+1 {
+		t.Fatal("Expected the account at -nxdomains-window, not", b)
+	}
+
+	// At -window the account recovers at 1/e of the linear rate and fully recovers
+	// after window*(e-1) ~= 1031s
+	now = now.Add(300 * time.Second)
+	if b := balance(); b > -470*time.Second || b < -480*time.Second { // -600*ln(e-0.5) ~= -478s
+		t.Error("Exponential should have recovered ~122s, not", b)
+	}
+	now = now.Add(time.Hour)
+	if b := balance(); b < 0 {
+		t.Error("Account should have recovered after an hour, not", b)
+	}
+
+	// Imported accounts are also scaled by the window of their category
+	rec := xferRecord{token: R.accountToken("10.0.1.0", 1, 1, "example.com.", AllowanceNXDomain),
+		balance: -600 * second, limited: true}
+	if err := R.importAccount(&rec); err != nil {
+		t.Fatal("Unexpected importAccount error", err)
+	}
+	el, _ := R.tableFor(AllowanceNXDomain).Get(rec.token)
+	if ra := el.(*responseAccount); ra.scale != 600*second || ra.balance(R.now()) < -600*second-1 {
+		t.Error("Imported account should be scaled by nxdomains-window", ra.scale, ra.balance(R.now()))
+	}
+}
+
+// Transformed debts are clamped rather than overflowing allowTime
+func TestRecoveryOverflow(t *testing.T) {
+	ra := &responseAccount{curve: recoveryExponential, scale: second}
+	ra.setBalance(1000*second, -3600*second)
+	if b := ra.balance(1000 * second); b >= 0 {
+		t.Error("Clamped debt should remain negative, not", b)
+	}
+}
@@ -0,0 +1,78 @@
+package rrl
+
+import (
+	"strings"
+)
+
+const (
+	inAddrArpa = "in-addr.arpa."
+	ip6Arpa    = "ip6.arpa."
+
+	reverseV4Labels = 3  // Default ipv4 reverse origin is a /24, e.g. 2.0.192.in-addr.arpa.
+	reverseV6Labels = 12 // Default ipv6 reverse origin is a /48 of nibbles
+)
+
+// NewReverseTuple is a helper function which creates a ResponseTuple for responses from
+// reverse zones, i.e. names within in-addr.arpa and ip6.arpa.
+// Reverse zones are very often synthesized by the server and are thus subject to
+// SalientName Selection Rule 2 described by [ResponseTuple], but determining the
+// delegation origin is the hardest part of that rule to get right.
+//
+// origin is the reverse zone the server is authoritative for. If origin is an empty
+// string, NewReverseTuple derives a collapsed origin from qName covering a /24 for ipv4
+// and a /48 for ipv6, which are the smallest commonly delegated reverse zones.
+//
+// The SalientName is set as follows:
+//
+//   - AllowanceNXDomain and AllowanceReferral: the origin, which is the owner of the SOA
+//     or NS RRs in the Ns section of a reverse response.
+//   - qName equals origin: qName, since queries of the zone apex are not synthesized.
+//   - Otherwise: the origin prefixed with "*." as per Rule 2.
+//
+// All names are returned in lowercase with a trailing dot. NewReverseTuple returns false
+// if qName is not within in-addr.arpa or ip6.arpa, or is not within origin.
+func NewReverseTuple(qClass, qType uint16, qName, origin string, ac AllowanceCategory) (*ResponseTuple, bool) {
+	qName = canonicalName(qName)
+	var labels int
+	switch {
+	case strings.HasSuffix(qName, "."+inAddrArpa):
+		labels = reverseV4Labels
+	case strings.HasSuffix(qName, "."+ip6Arpa):
+		labels = reverseV6Labels
+	default:
+		return nil, false
+	}
+
+	if len(origin) == 0 {
+		origin = reverseOrigin(qName, labels)
+	} else {
+		origin = canonicalName(origin)
+		if qName != origin && !strings.HasSuffix(qName, "."+origin) {
+			return nil, false
+		}
+	}
+
+	return originTuple(qClass, qType, qName, origin, qName != origin, ac), true
+}
+
+// reverseOrigin returns the name formed by the rightmost address labels of qName plus the
+// reverse suffix. If qName has fewer address labels, qName is returned.
+func reverseOrigin(qName string, labels int) string {
+	all := strings.Split(strings.TrimSuffix(qName, "."), ".")
+	keep := labels + 2 // Plus the two suffix labels of in-addr.arpa or ip6.arpa
+	if len(all) <= keep {
+		return qName
+	}
+
+	return strings.Join(all[len(all)-keep:], ".") + "."
+}
+
+// canonicalName returns name in lowercase with a trailing dot.
+func canonicalName(name string) string {
+	name = strings.ToLower(name)
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+
+	return name
+}
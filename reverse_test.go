@@ -0,0 +1,50 @@
+package rrl_test
+
+import (
+	"testing"
+
+	"github.com/markdingo/rrl"
+)
+
+func TestNewReverseTuple(t *testing.T) {
+	const ptr = 12
+	v6 := "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.4.3.2.1.8.b.d.0.1.0.0.2.ip6.arpa."
+	testCases := []struct {
+		qName, origin string
+		ac            rrl.AllowanceCategory
+		ok            bool
+		exp           string
+	}{
+		{"1.2.0.192.in-addr.arpa.", "", rrl.AllowanceAnswer, true, "*.2.0.192.in-addr.arpa."},
+		{"1.2.0.192.IN-ADDR.ARPA", "", rrl.AllowanceAnswer, true, "*.2.0.192.in-addr.arpa."},
+		{"1.2.0.192.in-addr.arpa.", "", rrl.AllowanceNXDomain, true, "2.0.192.in-addr.arpa."},
+		{"1.2.0.192.in-addr.arpa.", "0.192.in-addr.arpa.", rrl.AllowanceAnswer, true, "*.0.192.in-addr.arpa."},
+		{"1.2.0.192.in-addr.arpa.", "0.192.in-addr.arpa.", rrl.AllowanceReferral, true, "0.192.in-addr.arpa."},
+		{"2.0.192.in-addr.arpa.", "", rrl.AllowanceNoData, true, "2.0.192.in-addr.arpa."},
+		{"192.in-addr.arpa.", "", rrl.AllowanceAnswer, true, "192.in-addr.arpa."},
+		{v6, "", rrl.AllowanceAnswer, true, "*.4.3.2.1.8.b.d.0.1.0.0.2.ip6.arpa."},
+		{v6, "8.b.d.0.1.0.0.2.ip6.arpa", rrl.AllowanceAnswer, true, "*.8.b.d.0.1.0.0.2.ip6.arpa."},
+
+		{"example.com.", "", rrl.AllowanceAnswer, false, ""},
+		{"in-addr.arpa.", "", rrl.AllowanceAnswer, false, ""},
+		{"1.2.0.192.in-addr.arpa.", "1.0.192.in-addr.arpa.", rrl.AllowanceAnswer, false, ""},
+		{"1.2.0.192.in-addr.arpa.", "10.192.in-addr.arpa.", rrl.AllowanceAnswer, false, ""},
+	}
+
+	for ix, tc := range testCases {
+		rt, ok := rrl.NewReverseTuple(1, ptr, tc.qName, tc.origin, tc.ac)
+		if ok != tc.ok {
+			t.Error(ix, "Expected ok", tc.ok, "got", ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if rt.SalientName != tc.exp {
+			t.Error(ix, "Expected SalientName", tc.exp, "got", rt.SalientName)
+		}
+		if rt.Class != 1 || rt.Type != ptr || rt.AllowanceCategory != tc.ac {
+			t.Error(ix, "Tuple fields not copied", rt)
+		}
+	}
+}
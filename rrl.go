@@ -1,11 +1,15 @@
 package rrl
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"net"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/markdingo/rrl/cache"
@@ -14,11 +18,55 @@ import (
 // RRL contains the configuration and "account" database.
 // An RRL is safe for concurrent use by multiple goroutines.
 type RRL struct {
-	cfg   Config
-	table *cache.Cache
+	cfg    Config
+	table  *cache.Cache
+	tables [AllowanceLast]*cache.Cache // Response account tables. May all be table
 
-	statsMu sync.Mutex
-	stats   Stats
+	calibration   *cache.Cache // Only present if calibrate is configured
+	errorSuffixes *ZoneTrie    // Only present if error-suffixes is configured
+	names         *cache.Cache // Only present if name-cache-size is configured
+
+	uniques      *uniques // Only present if track-uniques is configured
+	uniquesEpoch int64    // Window of the last uniques rotation
+
+	statsMu  sync.Mutex
+	stats    Stats
+	history  *history          // Only present if history-depth is configured. Protected by statsMu
+	tagStats map[string]*Stats // Stats of DebitTagged calls with non-empty tags. Protected by statsMu
+	families *familyStats      // Only present if family-stats is configured. Protected by statsMu
+	zeroed   Stats             // Sum of all stats zeroed by GetStats. Protected by statsMu
+	cursors  map[string]Stats  // Totals as at the last GetStatsSince of each cursor. Protected by statsMu
+
+	pressure atomic.Uint64 // float64 bits of the level set by SetPressure
+	disabled atomic.Uint32 // Bit mask of categories disabled by DisableCategory
+	slips    *TokenBucket  // Global max-slips-per-second limit
+	emits    *TokenBucket  // Global max-events-per-second limit
+
+	// forced holds the latches set by ForceAction. The element at AllowanceLast is the
+	// latch of all categories.
+	forced [AllowanceLast + 1]atomic.Pointer[forcedAction]
+
+	faults faults // Injected failures. Only active with the rrlfaults build tag
+
+	events atomic.Pointer[EventExporter] // Only present while ExportEvents is active
+
+	decisions     *decisionLog   // Only present if decision-log-size is configured
+	decisionCache *decisionCache // Only present if decision-cache-ttl is configured
+	probation     *probation     // Only present if probation-count is configured
+	sketch        *sketch        // Only present if sketch-width is configured
+	overload      *overload      // Only present if overload-drop-ratio is configured
+	validators    *cache.Cache   // Only present if validator-boost is configured
+
+	degradation degradation
+
+	penaltyMu sync.Mutex                  // Serializes updates of penalties
+	penalties atomic.Pointer[penaltyList] // Only present while penalties are imposed
+
+	inspection inspection
+
+	clock clock // All time readings are derived from this
+
+	sink atomic.Pointer[sinkRef] // Registered StatsSink. Nil means the internal sink
 }
 
 // NewRRL creates a new RRL struct which is ready for use.
@@ -26,24 +74,87 @@ type RRL struct {
 // All config default values are set by NewRRL and are visible in the Config
 // on return.
 // NewRRL takes a copy of Config so subsequent changes have no effect on the RRL.
+//
+// Because NewRRL finalizes the caller's Config, concurrent calls with the same Config are
+// only safe if the Config has first been frozen with [Config.Freeze].
 func NewRRL(cfg *Config) *RRL {
 	cfg.finalize()         // Finalize the caller's copy
 	rrl := &RRL{cfg: *cfg} // But make our own copy so caller cannot modify
+	rrl.faults.init(&rrl.cfg)
+	rrl.clock = newClock(rrl.cfg.nowFunc)
+	rrl.slips = newTokenBucket(rrl.cfg.slipInterval, rrl.clock)
+	rrl.emits = newTokenBucket(rrl.cfg.eventInterval, rrl.clock)
 	rrl.initTable()
+	rrl.initCalibration()
+	rrl.initNames()
+	rrl.initDegradation()
+	rrl.initDecisionCache()
+	rrl.initProbation()
+	rrl.initSketch()
+	rrl.initOverload()
+	rrl.initValidators()
+	if len(rrl.cfg.errorSuffixes) > 0 {
+		rrl.errorSuffixes = NewZoneTrie(rrl.cfg.errorSuffixes)
+	}
+	rrl.SetStatsSink(nil)
+	if rrl.cfg.trackUniques {
+		rrl.uniques = newUniques()
+	}
+	if rrl.cfg.familyStats {
+		rrl.families = newFamilyStats(&rrl.cfg)
+	}
+	if rrl.cfg.historyDepth > 0 {
+		rrl.history = newHistory(rrl.cfg.historyDepth)
+	}
+	if rrl.cfg.decisionLogSize > 0 {
+		rrl.decisions = &decisionLog{records: make([]DecisionRecord, rrl.cfg.decisionLogSize)}
+	}
 
 	return rrl
 }
 
+// NewRRLChecked is identical to [NewRRL] except that the finalized Config is first
+// checked for cross-field inconsistencies which NewRRL silently accepts, such as:
+//
+//   - an allowance of less than one response per window, as such accounts never recover
+//   - slip-ratio set without any response allowances, as slips only apply to responses
+//   - table sizes too small for the number of table shards, as they are rounded up
+//
+// If any are found, NewRRLChecked returns an error describing all of them and no RRL.
+// Like NewRRL, the caller's Config is finalized in either case.
+func NewRRLChecked(cfg *Config) (*RRL, error) {
+	cfg.finalize()
+	if err := cfg.check(); err != nil {
+		return nil, err
+	}
+
+	return NewRRL(cfg), nil
+}
+
+// now returns the current time in nanoseconds for all balance arithmetic.
+func (rrl *RRL) now() int64 {
+	return rrl.clock.now()
+}
+
 // responseAccount holds accounting for a category of response
 type responseAccount struct {
-	allowTime     int64 // Next response is allowed if current time >= allowTime
-	slipCountdown uint  // When at 1, a dropped response slips through instead of being dropped
+	allowTime     int64         // Next response is allowed if current time >= allowTime
+	slipCountdown uint          // When at 1, a dropped response slips through instead of being dropped
+	limited       bool          // Balance was negative after the most recent debit
+	curve         recoveryCurve // Determines how allowTime relates to the balance
+	limitedSince  int64         // When the account most recently went into debit, if limited
+	created       int64         // When the account was created
+	scale         int64         // Scale of exponential recovery, which is the window of the account
 }
 
 // allowanceForRtype returns the configured response interval for the indicated response
 // type.
-// Different response types have their own configuration limits.
-func (rrl *RRL) allowanceForRtype(rt AllowanceCategory) int64 {
+// Different response types have their own configuration limits, which may in turn differ
+// by the address family of the Client Network.
+func (rrl *RRL) allowanceForRtype(rt AllowanceCategory, ipPrefix string) int64 {
+	if rrl.cfg.familyConfigured && rt >= AllowanceAnswer && rt < AllowanceLast {
+		return rrl.cfg.familyAllowances[familyOf(ipPrefix)][rt]
+	}
 	switch rt {
 	case AllowanceAnswer:
 		return rrl.cfg.responsesInterval
@@ -55,162 +166,463 @@ func (rrl *RRL) allowanceForRtype(rt AllowanceCategory) int64 {
 		return rrl.cfg.referralsInterval
 	case AllowanceError:
 		return rrl.cfg.errorsInterval
+	case AllowanceTransfer:
+		return rrl.cfg.transfersInterval
 	}
 	return -1 // Unknown response - odd
 }
 
-// initTable creates a new cache table and sets the cache eviction function
+// requestsAllowance returns the configured requests interval for the Client Network.
+func (rrl *RRL) requestsAllowance(ipPrefix string) int64 {
+	if rrl.cfg.familyConfigured {
+		return rrl.cfg.familyAllowances[familyOf(ipPrefix)][AllowanceLast]
+	}
+
+	return rrl.cfg.requestsInterval
+}
+
+// initTable creates a new cache table and sets the cache eviction function. If any
+// categories are configured with their own table size, they are given their own
+// partitioned table, otherwise they share the main table.
 func (rrl *RRL) initTable() {
-	rrl.table = cache.New(rrl.cfg.maxTableSize)
-	// This eviction function returns true if the allowance is >= max value (window)
-	rrl.table.SetEvict(func(el interface{}) bool {
-		ra, ok := (el).(*responseAccount)
-		if !ok {
-			return true
+	rrl.table = rrl.newCache(rrl.cfg.maxTableSize)
+	rrl.table.SetEvict(rrl.evictable)
+	for ac := range rrl.tables {
+		rrl.tables[ac] = rrl.table
+		if size := rrl.cfg.tableSizes[ac]; size > 0 {
+			rrl.tables[ac] = rrl.newCache(size)
+			rrl.tables[ac].SetEvict(rrl.evictable)
 		}
-		evicted := rrl.cfg.nowFunc().UnixNano()-ra.allowTime >= rrl.cfg.window
-		if evicted {
-			rrl.incrementEviction()
+	}
+}
+
+// newCache creates a cache keyed with the shard-hash-key, if configured, otherwise the
+// cache uses its own random key.
+func (rrl *RRL) newCache(size int) *cache.Cache {
+	c := cache.New(size)
+	if len(rrl.cfg.shardHashKey) > 0 {
+		var key [16]byte
+		sum := sha256.Sum256([]byte(rrl.cfg.shardHashKey))
+		copy(key[:], sum[:])
+		c.SetHashKey(key)
+	}
+
+	return c
+}
+
+// evictable is the cache eviction function. It returns true if the account has been idle
+// for at least window, or for at least in-credit-eviction-age if configured and the
+// account was in credit at its most recent debit.
+func (rrl *RRL) evictable(el interface{}) bool {
+	ra, ok := (el).(*responseAccount)
+	if !ok {
+		return true
+	}
+	age := rrl.cfg.window
+	if !ra.limited && rrl.cfg.inCreditEvictionAge > 0 {
+		age = rrl.cfg.inCreditEvictionAge
+	}
+	evicted := ra.balance(rrl.now()) >= age
+	if evicted {
+		rrl.incrementEviction()
+	}
+	return evicted
+}
+
+// tableFor returns the table holding response accounts for the AllowanceCategory.
+func (rrl *RRL) tableFor(ac AllowanceCategory) *cache.Cache {
+	if ac < AllowanceLast {
+		return rrl.tables[ac]
+	}
+	return rrl.table
+}
+
+// tableLen returns the total number of accounts across all tables.
+func (rrl *RRL) tableLen() int {
+	l := rrl.table.Len()
+	for _, t := range rrl.tables {
+		if t != rrl.table {
+			l += t.Len()
 		}
-		return evicted
-	})
+	}
+	return l
 }
 
-// accountToken returns a token string for the query details and indicated AllowanceCategory
-func (rrl *RRL) accountToken(ipPrefix string, qType uint16, name string, rt AllowanceCategory) string {
-	return rrl.buildToken(rt, qType, strings.ToLower(name), ipPrefix)
+// TableSizes returns the configured max-table-size of the main account table and the
+// configured size of each per-category table partition. A zero partition size means
+// that the category shares the main table.
+func (rrl *RRL) TableSizes() (main int, partitions [AllowanceLast]int) {
+	return rrl.cfg.maxTableSize, rrl.cfg.tableSizes
 }
 
-// buildToken returns a token string for the given inputs
-func (rrl *RRL) buildToken(rt AllowanceCategory, qType uint16, name, ipPrefix string) string {
+// windowFor returns the window applicable to response accounts of the AllowanceCategory.
+func (rrl *RRL) windowFor(ac AllowanceCategory) int64 {
+	if ac < AllowanceLast {
+		return rrl.cfg.windows[ac]
+	}
+	return rrl.cfg.window
+}
+
+// accountToken returns a token string for the query details and indicated
+// AllowanceCategory. The query class only contributes to the token if include-class is
+// configured.
+func (rrl *RRL) accountToken(ipPrefix string, qClass, qType uint16, name string, rt AllowanceCategory) string {
+	if !rrl.cfg.includeClass {
+		qClass = 0
+	}
+	if rt == AllowanceError {
+		name = rrl.errorSuffix(name)
+	}
+	return rrl.buildToken(rt, qClass, qType, strings.ToLower(name), ipPrefix)
+}
+
+// requestsToken returns the token of the requests account of the Client Network for
+// requests arriving over the network transport.
+func (rrl *RRL) requestsToken(network, ipPrefix string) string {
+	if rrl.cfg.requestsByTransport && !strings.HasPrefix(network, "udp") {
+		return ipPrefix + "/tcp"
+	}
+
+	return ipPrefix
+}
+
+// errorSuffix returns the closest error-suffixes name at or above the SalientName of an
+// AllowanceError response, or an empty string if there is none.
+func (rrl *RRL) errorSuffix(name string) string {
+	if rrl.errorSuffixes == nil || len(name) == 0 {
+		return ""
+	}
+	suffix, _ := rrl.errorSuffixes.FindOrigin(canonicalName(name))
+
+	return suffix
+}
+
+// buildToken returns a token string for the given inputs. A qClass of ClassINET or zero
+// does not contribute to the token so that ClassINET tokens are the same regardless of
+// include-class. Any other qClass is appended to the qType field as ":class".
+func (rrl *RRL) buildToken(rt AllowanceCategory, qClass, qType uint16, name, ipPrefix string) string {
 	// "Per BIND" references below are copied from the BIND 9.11 Manual
 	// https://ftp.isc.org/isc/bind9/cur/9.11/doc/arm/Bv9ARM.pdf
 	rtypestr := strconv.FormatUint(uint64(rt), 10)
+	var classStr string
+	if qClass != 0 && qClass != classINET {
+		classStr = ":" + strconv.FormatUint(uint64(qClass), 10)
+	}
 	switch rt {
 	case AllowanceAnswer:
 		// Per BIND: All non-empty responses for a valid domain name (qname) and record type (qType) are identical
 		qTypeStr := strconv.FormatUint(uint64(qType), 10)
-		return strings.Join([]string{ipPrefix, rtypestr, qTypeStr, name}, "/")
+		return strings.Join([]string{ipPrefix, rtypestr, qTypeStr + classStr, name}, "/")
 	case AllowanceNoData:
 		// Per BIND: All empty (NODATA) responses for a valid domain, regardless of query type, are identical.
-		return strings.Join([]string{ipPrefix, rtypestr, "", name}, "/")
+		return strings.Join([]string{ipPrefix, rtypestr, classStr, name}, "/")
 	case AllowanceNXDomain:
 		// Per BIND: Requests for any and all undefined subdomains of a given valid domain result in NXDOMAIN errors
 		// and are identical regardless of query type.
-		return strings.Join([]string{ipPrefix, rtypestr, "", name}, "/")
+		return strings.Join([]string{ipPrefix, rtypestr, classStr, name}, "/")
 	case AllowanceReferral:
 		// Per BIND: Referrals or delegations to the server of a given domain are identical.
 		qTypeStr := strconv.FormatUint(uint64(qType), 10)
-		return strings.Join([]string{ipPrefix, rtypestr, qTypeStr, name}, "/")
+		return strings.Join([]string{ipPrefix, rtypestr, qTypeStr + classStr, name}, "/")
 	case AllowanceError:
 		// Per BIND: All requests that result in DNS errors other than NXDOMAIN, such as SERVFAIL and FORMERR, are
-		// identical regardless of requested name (qname) or record type (qType).
-		return strings.Join([]string{ipPrefix, rtypestr, "", ""}, "/")
+		// identical regardless of requested name (qname) or record type (qType). Unless
+		// error-suffixes is configured, in which case name is the closest suffix.
+		return strings.Join([]string{ipPrefix, rtypestr, classStr, name}, "/")
+	case AllowanceTransfer:
+		// All zone transfer probes over UDP are identical regardless of zone or qType.
+		return strings.Join([]string{ipPrefix, rtypestr, classStr, ""}, "/")
 	}
 	return ""
 }
 
 // debit updates an existing response account in the rrl table and recalculate the current
-// balance, or if the response account does not exist, it will add it.
+// balance, or if the response account does not exist, it will add it. The balance can be
+// no more negative than window.
+//
+// curve is the recovery curve of a new account, which is scaled by window if exponential,
+// and tag is the opaque tag of the debit which is only used for events.
 //
 // Return values are Balance, slip and error.
-func (rrl *RRL) debit(allowance int64, t string) (int64, bool, error) {
+func (rrl *RRL) debit(table *cache.Cache, allowance, window int64, curve recoveryCurve, t, tag string) (int64, bool, error) {
 
 	type balances struct {
-		balance int64
-		slip    bool
+		balance    int64
+		slip       bool
+		transition bool // Account changed between limited and in credit
 	}
 
-	result := rrl.table.UpdateAdd(t,
+	if err := rrl.faults.debit(); err != nil {
+		return 0, false, err
+	}
+
+	// An account on probation is treated as new without being created
+	if rrl.onProbation(table, t) {
+		return newBalance(allowance), false, nil
+	}
+
+	// As is an account which cannot yet have exhausted its initial credit
+	if balance, ok := rrl.sketched(table, allowance, t); ok {
+		return balance, false, nil
+	}
+
+	result := table.UpdateAdd(t,
 		// the 'update' function updates the account and returns the new balance
 		func(el interface{}) interface{} {
 			ra := (el).(*responseAccount)
 			if ra == nil {
 				return nil
 			}
-			now := rrl.cfg.nowFunc().UnixNano()
-			balance := now - ra.allowTime - allowance
+			now := rrl.now()
+			balance := ra.balance(now) - allowance
 			if balance >= int64(time.Second) {
 				// positive balance can't exceed 1 second
 				balance = int64(time.Second) - allowance
-			} else if balance < -rrl.cfg.window {
+			} else if balance < -window {
 				// balance can't be more negative than window
-				balance = -rrl.cfg.window
+				balance = -window
+			}
+			ra.setBalance(now, balance)
+			transition := ra.limited != (balance < 0)
+			ra.limited = balance < 0
+			if transition && ra.limited {
+				ra.limitedSince = now
 			}
-			ra.allowTime = now - balance
 			if balance > 0 || ra.slipCountdown == 0 {
-				return balances{balance, false}
+				return balances{balance, false, transition}
 			}
 			if ra.slipCountdown == 1 {
 				ra.slipCountdown = rrl.cfg.slipRatio
-				return balances{balance, true}
+				return balances{balance, true, transition}
 			}
 			ra.slipCountdown -= 1
-			return balances{balance, false}
+			return balances{balance, false, transition}
 
 		},
-		// The 'add' function create a new account for the token. allowTime is
+		// The 'add' function create a new account for the token. The account is
 		// given a credit of one second worth of queries less the allowance for
 		// the current query.
 		func() interface{} {
+			now := rrl.now()
 			ra := &responseAccount{
-				allowTime:     rrl.cfg.nowFunc().UnixNano() - int64(time.Second) + allowance,
 				slipCountdown: rrl.cfg.slipRatio,
+				curve:         curve,
+				scale:         window,
+				created:       now,
 			}
+			ra.setBalance(now, int64(time.Second)-allowance)
 			return ra
 		})
 
-	if result == nil {
-		return 0, false, nil
+	if result == nil { // A new account starts with one second of credit
+		rrl.emitEvent(EventCreate, t, tag, newBalance(allowance))
+		return newBalance(allowance), false, nil
 	}
 	if err, ok := result.(error); ok {
 		return 0, false, err
 	}
 	if b, ok := result.(balances); ok {
+		if b.transition {
+			if b.balance < 0 {
+				rrl.emitEvent(EventLimit, t, tag, b.balance)
+			} else {
+				rrl.emitEvent(EventRecover, t, tag, b.balance)
+			}
+		}
 		return b.balance, b.slip, nil
 	}
 	return 0, false, errors.New("unexpected result type")
 }
 
+// newBalance returns the balance reported for the first debit of a new account, which is
+// one second of credit less the allowance. It is never negative so the first response is
+// always sent, even when the allowance exceeds one second at rates below one per second.
+func newBalance(allowance int64) int64 {
+	if allowance > int64(time.Second) {
+		return 0
+	}
+
+	return int64(time.Second) - allowance
+}
+
+// AccountID returns a stable identifier for the response account which src and tuple are
+// debited against. The identifier is a keyed hash of the internal account token so
+// multiple servers and offline analysis tools configured with the same
+// "account-hash-key" produce identical identifiers without sharing raw names.
+//
+// AccountID returns an empty string if "account-hash-key" is not configured.
+func (rrl *RRL) AccountID(src net.Addr, tuple *ResponseTuple) string {
+	if len(rrl.cfg.accountHashKey) == 0 {
+		return ""
+	}
+	tuple = orMalformed(tuple)
+	ipPrefix := rrl.addrPrefix(src.String())
+	t := rrl.accountToken(ipPrefix, tuple.Class, tuple.Type, tuple.SalientName, categoryOf(src, tuple))
+
+	return rrl.hashToken(t)
+}
+
+// hashToken returns the keyed hash of an account token as a hex string. The hash is
+// truncated to 128 bits which is more than sufficient for identification purposes.
+func (rrl *RRL) hashToken(t string) string {
+	mac := hmac.New(sha256.New, []byte(rrl.cfg.accountHashKey))
+	mac.Write([]byte(t))
+
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// balance returns the balance an account would have if it were debited by allowance now,
+// without actually debiting the account. The account is only examined under the shard
+// read lock so balance never competes with the write path. The second return value is
+// false if the account does not exist.
+func (rrl *RRL) balance(table *cache.Cache, allowance int64, t string) (int64, bool) {
+	result, found := table.View(t, func(el interface{}) interface{} {
+		ra, ok := (el).(*responseAccount)
+		if !ok {
+			return nil
+		}
+		return ra.balance(rrl.now()) - allowance
+	})
+	if !found {
+		return 0, false
+	}
+	b, ok := result.(int64)
+
+	return b, ok
+}
+
 // addrPrefix returns the address prefix of the net.Addr style address string
 // (e.g. 1.2.3.4:1234 or [1:2::3:4]:1234) based on the configured prefix lengths.
 func (rrl *RRL) addrPrefix(addr string) string {
+	prefix, _ := rrl.addrPrefixes(addr)
+
+	return prefix
+}
+
+// addrPrefixes returns the address prefix of the net.Addr style address string as per
+// addrPrefix as well as the ipv6 aggregate prefix. The aggregate prefix is only returned
+// for ipv6 addresses when aggregate rate limiting is configured, otherwise it is an
+// empty string. The aggregate prefix includes the prefix length so that it can never
+// collide with a regular prefix.
+func (rrl *RRL) addrPrefixes(addr string) (prefix, aggregate string) {
 	i := strings.LastIndex(addr, ":")
 	if i < 4 { // Shortest valid index for "[::]:1" is 4
-		return ""
+		return
 	}
 	ip := net.ParseIP(addr[:i])
 	if ip.To4() != nil {
 		ip = ip.Mask(net.CIDRMask(rrl.cfg.ipv4PrefixLength, 32))
-		return ip.String()
+		prefix = ip.String()
+		return
 	}
 	ip = net.ParseIP(addr[1 : i-1]) // strip brackets from ipv6 e.g. [2001:db8::1]
-	ip = ip.Mask(net.CIDRMask(rrl.cfg.ipv6PrefixLength, 128))
+	prefix = ip.Mask(net.CIDRMask(rrl.cfg.ipv6PrefixLength, 128)).String()
+	if rrl.cfg.ipv6AggregateInterval != 0 && ip != nil {
+		ip = ip.Mask(net.CIDRMask(rrl.cfg.ipv6AggregatePrefixLength, 128))
+		aggregate = ip.String() + "/" + strconv.Itoa(rrl.cfg.ipv6AggregatePrefixLength)
+	}
 
-	return ip.String()
+	return
+}
+
+// ClientNetworks returns the Client Network and ipv6 aggregate network which src is
+// accounted against. The aggregate network is an empty string for ipv4 addresses or if
+// ipv6 aggregate rate limiting is not configured.
+// ClientNetworks is intended for diagnostic and logging purposes.
+func (rrl *RRL) ClientNetworks(src net.Addr) (network, aggregate string) {
+	return rrl.addrPrefixes(src.String())
 }
 
 // Args must be pass-by-reference because pass-by-value takes a copy at the time of the
 // defer call rather than at the executation point of the defer.
-func (rrl *RRL) incrementDebitStats(act *Action, ipr *IPReason, rtr *RTReason, ac AllowanceCategory) {
+func (rrl *RRL) incrementDebitStats(tag, ipPrefix string, act *Action, ipr *IPReason, rtr *RTReason, ac AllowanceCategory) {
+	rrl.recordAction(*act)
+	rrl.statsSink().OnDebit(DebitReport{Tag: tag, Network: ipPrefix, Decision: NewDecision(*act, *ipr, *rtr),
+		Category: ac})
+}
+
+func (rrl *RRL) incrementRequestStats(ipPrefix string, act Action, ipr IPReason) {
+	if act != Send { // Otherwise counted by DebitResponse
+		rrl.recordAction(act)
+	}
+	rrl.statsSink().OnDebit(DebitReport{Network: ipPrefix, Decision: NewDecision(act, ipr, RTNotReached),
+		Category: AllowanceLast, Request: true})
+}
+
+func (rrl *RRL) incrementResponseStats(ipPrefix string, act Action, rtr RTReason, ac AllowanceCategory) {
+	rrl.recordAction(act)
+	rrl.statsSink().OnDebit(DebitReport{Network: ipPrefix, Decision: NewDecision(act, IPNotReached, rtr),
+		Category: ac, Response: true})
+}
+
+func (rrl *RRL) incrementSlipDowngrade() {
 	rrl.statsMu.Lock()
-	rrl.stats.incrementDebit(*act, *ipr, *rtr, ac)
+	rrl.stats.SlipDowngrades++
+	if s := rrl.historySlice(); s != nil {
+		s.SlipDowngrades++
+	}
 	rrl.statsMu.Unlock()
 }
 
 func (rrl *RRL) incrementEviction() {
-	rrl.statsMu.Lock()
-	rrl.stats.Evictions++
-	rrl.statsMu.Unlock()
+	rrl.statsSink().OnEviction()
 }
 
-// GetStats returns the internal stats accumulated by the Debit call.
+// GetStats returns the internal stats accumulated by the Debit call. Debits are not
+// accumulated while a [StatsSink] is registered with [RRL.SetStatsSink].
 // The caller can optionally request that the stats be zeroed after the copy.
 func (rrl *RRL) GetStats(zeroAfter bool) (c Stats) {
 	rrl.statsMu.Lock()
 	c = rrl.stats.Copy(zeroAfter)
+	if zeroAfter {
+		rrl.zeroed.Add(&c) // So cursors are unaffected
+	}
 	rrl.statsMu.Unlock()
-	c.CacheLength = rrl.table.Len()
+	c.CacheLength = rrl.tableLen()
+	c.ClientNetworks, c.SalientNames = rrl.uniqueCounts()
 
 	return
 }
+
+// GetStatsSince returns the stats accumulated since the previous call of GetStatsSince
+// with the same cursor, or since the RRL was created for the first call with a cursor.
+// Each cursor is independent of all other cursors and of GetStats, including when
+// GetStats zeroes the stats, so multiple monitoring systems can each compute rates from
+// their own intervals.
+//
+// A cursor is retained for the life of the RRL, so cursors should be drawn from a small,
+// fixed set of names, such as one per monitoring system.
+//
+// GetStatsSince is concurrency safe.
+func (rrl *RRL) GetStatsSince(cursor string) (c Stats) {
+	rrl.statsMu.Lock()
+	c = rrl.zeroed
+	c.Add(&rrl.stats)
+	if rrl.cursors == nil {
+		rrl.cursors = make(map[string]Stats)
+	}
+	prev := rrl.cursors[cursor]
+	rrl.cursors[cursor] = c
+	rrl.statsMu.Unlock()
+	c.sub(&prev)
+	c.CacheLength = rrl.tableLen()
+	c.ClientNetworks, c.SalientNames = rrl.uniqueCounts()
+
+	return
+}
+
+// GetTagStats returns a copy of the stats accumulated by [RRL.DebitTagged] for each
+// non-empty tag. The gauges CacheLength, ClientNetworks and SalientNames are not
+// tracked per tag and are always zero.
+// The caller can optionally request that the stats be zeroed after the copy.
+func (rrl *RRL) GetTagStats(zeroAfter bool) map[string]Stats {
+	rrl.statsMu.Lock()
+	defer rrl.statsMu.Unlock()
+
+	ret := make(map[string]Stats, len(rrl.tagStats))
+	for tag, ts := range rrl.tagStats {
+		ret[tag] = ts.Copy(zeroAfter)
+	}
+
+	return ret
+}
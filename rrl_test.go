@@ -2,13 +2,14 @@ package rrl
 
 import (
 	"testing"
+	"time"
 )
 
 func TestAllowanceForRtype(t *testing.T) {
 	cfg := NewConfig()
 	cfg.SetValue("responses-per-second", "1")
 	R := NewRRL(cfg)
-	at := R.allowanceForRtype(AllowanceAnswer)
+	at := R.allowanceForRtype(AllowanceAnswer, "10.0.0.0")
 	if at != 1*second {
 		t.Error("AllowanceAnswer should be 1, not", at)
 	}
@@ -54,3 +55,92 @@ func TestAddrPrefix(t *testing.T) {
 		}
 	}
 }
+
+func TestAddrPrefixes(t *testing.T) {
+	cfg := NewConfig()
+	cfg.SetValue("ipv6-prefix-length", "64")
+	cfg.SetValue("ipv6-aggregate-requests-per-second", "10")
+	R := NewRRL(cfg)
+
+	testCases := []struct {
+		addr      string
+		prefix    string
+		aggregate string
+	}{
+		{"127.1.2.1:50", "127.1.2.0", ""},
+		{"[2001:db8:1:2:3::1]:53", "2001:db8:1:2::", "2001:db8:1::/48"},
+		{"[2001:db8::1]:53", "2001:db8::", "2001:db8::/48"},
+	}
+
+	for ix, tc := range testCases {
+		prefix, aggregate := R.ClientNetworks(newAddr("udp", tc.addr))
+		if prefix != tc.prefix || aggregate != tc.aggregate {
+			t.Error(ix, "ClientNetworks expected", tc.prefix, tc.aggregate, "got", prefix, aggregate)
+		}
+	}
+}
+
+func TestAccountID(t *testing.T) {
+	src := newAddr("udp", "127.0.0.1:53")
+	tuple := newTuple(1, 1, "example.com.", AllowanceAnswer)
+
+	R := NewRRL(NewConfig())
+	if id := R.AccountID(src, tuple); len(id) != 0 {
+		t.Error("AccountID should be empty when no key is configured, not", id)
+	}
+
+	cfg := NewConfig()
+	cfg.SetValue("account-hash-key", "secret")
+	R1 := NewRRL(cfg)
+	R2 := NewRRL(cfg)
+	id1 := R1.AccountID(src, tuple)
+	if len(id1) != 32 {
+		t.Fatal("AccountID should be 32 hex chars, not", id1)
+	}
+	if id2 := R2.AccountID(newAddr("udp", "127.0.0.2:4000"), newTuple(1, 1, "EXAMPLE.com.", AllowanceAnswer)); id1 != id2 {
+		t.Error("AccountID should be identical across instances and case", id1, id2)
+	}
+	if id3 := R1.AccountID(src, newTuple(1, 1, "example.net.", AllowanceAnswer)); id1 == id3 {
+		t.Error("AccountID should differ for different names", id1, id3)
+	}
+
+	cfg.SetValue("account-hash-key", "other")
+	if id4 := NewRRL(cfg).AccountID(src, tuple); id1 == id4 {
+		t.Error("AccountID should differ for different keys", id1, id4)
+	}
+}
+
+func TestEvictableInCreditAge(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cfg := NewConfig()
+	cfg.SetValue("in-credit-eviction-age", "2")
+	cfg.SetNowFunc(func() time.Time { return now })
+	R := NewRRL(cfg)
+
+	good := &responseAccount{allowTime: R.now()}
+	bad := &responseAccount{allowTime: R.now(), limited: true}
+	now = now.Add(3 * time.Second)
+	if !R.evictable(good) {
+		t.Error("In-credit account should be evictable after in-credit-eviction-age")
+	}
+	if R.evictable(bad) {
+		t.Error("Limited account should not be evictable before window")
+	}
+	now = now.Add(15 * time.Second)
+	if !R.evictable(bad) {
+		t.Error("Limited account should be evictable after window")
+	}
+
+	cfg = NewConfig()
+	cfg.SetValue("in-credit-eviction-age", "60")
+	cfg.SetNowFunc(func() time.Time { return now })
+	R = NewRRL(cfg)
+	good = &responseAccount{allowTime: R.now()}
+	now = now.Add(30 * time.Second)
+	if R.evictable(good) {
+		t.Error("In-credit account should be retained beyond window")
+	}
+	if R.GetStats(false).Evictions != 0 {
+		t.Error("Evictions should not be counted when not evictable")
+	}
+}
@@ -0,0 +1,92 @@
+package rrl
+
+import (
+	"math/rand"
+)
+
+// sampleScanFactor determines when SampleAccounts walks the tables rather than sampling
+// them. Walking a table holding fewer than sampleScanFactor accounts per requested
+// sample is cheaper than the rejected probes of a sparsely populated table.
+const sampleScanFactor = 16
+
+// SampleAccounts returns a uniform random sample of up to n distinct accounts in no
+// particular order. It is intended for monitoring which wants to estimate the composition
+// of the table, such as the share of rate limited accounts or the mix of categories,
+// without the cost of examining every account as [RRL.Snapshot] does.
+//
+// Each sample probes a random slot across the capacity of all tables, so the cost is
+// proportional to n and the emptiness of the tables rather than the number of accounts.
+// Fewer than n accounts are returned if the tables hold fewer than n accounts.
+//
+// SampleAccounts is concurrency safe.
+func (rrl *RRL) SampleAccounts(n int) []AccountInfo {
+	if n <= 0 {
+		return nil
+	}
+	tables := rrl.distinctTables()
+	total, capacity := 0, 0
+	for _, table := range tables {
+		total += table.Len()
+		capacity += table.Shards() * table.ShardCapacity()
+	}
+	if total <= n*sampleScanFactor {
+		return rrl.reservoirSample(n)
+	}
+
+	ret := make([]AccountInfo, 0, n)
+	seen := make(map[string]struct{}, n)
+	now := rrl.now()
+	var (
+		t  string
+		ra responseAccount
+	)
+	copyAccount := func(key string, el interface{}) {
+		t = ""
+		if p, ok := (el).(*responseAccount); ok {
+			t, ra = key, *p
+		}
+	}
+
+	// Probes fail in proportion to the emptiness of the tables and as samples repeat,
+	// so allow ample probes for the expected number before giving up.
+	for probes := 4*n*capacity/total + n; len(ret) < n && probes > 0; probes-- {
+		slot := rand.Intn(capacity)
+		for _, table := range tables {
+			tableCapacity := table.Shards() * table.ShardCapacity()
+			if slot >= tableCapacity {
+				slot -= tableCapacity
+				continue
+			}
+			if !table.ShardElement(slot/table.ShardCapacity(), slot%table.ShardCapacity(), copyAccount) {
+				break
+			}
+			if _, ok := seen[t]; ok || len(t) == 0 {
+				break
+			}
+			seen[t] = struct{}{}
+			ret = append(ret, newAccountInfo(t, &ra, ra.balance(now), now))
+			break
+		}
+	}
+
+	return ret
+}
+
+// reservoirSample returns a uniform random sample of up to n accounts by walking all
+// tables. It is used by SampleAccounts when the tables are small.
+func (rrl *RRL) reservoirSample(n int) []AccountInfo {
+	ret := make([]AccountInfo, 0, n)
+	seen := 0
+	now := rrl.now()
+	rrl.walkAccounts(0, func(t string, ra *responseAccount, balance int64) bool {
+		seen++
+		if len(ret) < n {
+			ret = append(ret, newAccountInfo(t, ra, balance, now))
+		} else if ix := rand.Intn(seen); ix < n {
+			ret[ix] = newAccountInfo(t, ra, balance, now)
+		}
+		return true
+	})
+
+	return ret
+}
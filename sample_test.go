@@ -0,0 +1,63 @@
+package rrl
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSampleAccounts(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cfg := NewConfig()
+	cfg.SetValue("responses-per-second", "1")
+	cfg.SetNowFunc(func() time.Time { return now })
+	R := NewRRL(cfg)
+
+	if s := R.SampleAccounts(10); len(s) != 0 {
+		t.Error("Empty table should return an empty sample", s)
+	}
+
+	tuple := newTuple(1, 1, "example.com.", AllowanceAnswer)
+	for ix := 0; ix < 5000; ix++ {
+		src := newAddr("udp", fmt.Sprintf("10.%d.%d.1:53", ix/256, ix%256))
+		R.Debit(src, tuple)
+		if ix%5 == 0 {
+			R.Debit(src, tuple) // One in five accounts is limited
+		}
+	}
+	now = now.Add(time.Second / 2) // Not long enough for limited accounts to recover
+
+	sample := R.SampleAccounts(200) // Large enough to probe rather than walk
+	if len(sample) != 200 {
+		t.Fatal("Expected 200 samples, not", len(sample))
+	}
+	seen := make(map[string]bool)
+	limited := 0
+	for _, ai := range sample {
+		key := ai.Key.String()
+		if seen[key] {
+			t.Error("Duplicate sample", key)
+		}
+		seen[key] = true
+		if ai.Balance < 0 {
+			limited++
+		}
+		if ai.Age != time.Second/2 {
+			t.Error("Expected an Age of 500ms, not", ai.Age)
+		}
+	}
+	if share := float64(limited) / float64(len(sample)); share < 0.08 || share > 0.32 {
+		t.Error("Sampled share of limited accounts is implausible", share)
+	}
+
+	small := NewRRL(cfg)
+	for ix := 0; ix < 10; ix++ {
+		small.Debit(newAddr("udp", fmt.Sprintf("10.0.%d.1:53", ix)), tuple)
+	}
+	if s := small.SampleAccounts(5); len(s) != 5 {
+		t.Error("Expected 5 samples of a small table, not", len(s))
+	}
+	if s := small.SampleAccounts(20); len(s) != 10 {
+		t.Error("Expected all 10 accounts of a small table, not", len(s))
+	}
+}
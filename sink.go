@@ -0,0 +1,134 @@
+package rrl
+
+// DebitReport describes the outcome of a single debit call. It is passed to
+// [StatsSink.OnDebit].
+//
+// [Debit] and [DebitTagged] report the complete Decision. [DebitRequest] reports with
+// Request set, an RTReason of RTNotReached and a Category of AllowanceLast, as the
+// response is not yet known. [DebitResponse] reports with Response set and an IPReason of
+// IPNotReached. Thus a request which proceeds from DebitRequest to DebitResponse is
+// reported twice, and sinks counting Actions should ignore Request reports with an
+// Action of Send.
+type DebitReport struct {
+	Tag     string // The tag passed to DebitTagged, if any
+	Network string // The Client Network of the source address
+	Decision
+	Category AllowanceCategory
+
+	Request  bool // Reported by DebitRequest
+	Response bool // Reported by DebitResponse
+}
+
+// StatsSink receives the statistical events of an RRL as they occur. It is registered
+// with [RRL.SetStatsSink].
+//
+// By default an RRL uses an internal sink which accumulates the counters returned by
+// [RRL.GetStats], [RRL.GetTagStats], [RRL.GetFamilyStats] and [RRL.History]. Registering
+// a different sink replaces the internal sink, so exporters which maintain their own
+// counters avoid the cost of, and the potential for double counting between, the two
+// sets of counters. The gauges in Stats and the SlipDowngrades counter are unaffected.
+//
+// All methods are called synchronously, possibly while an account table lock is held, and
+// concurrently from multiple goroutines. They must be concurrency safe, return quickly and
+// must not call any RRL methods.
+type StatsSink interface {
+	// OnDebit is called once for each debit call with the outcome of the call.
+	OnDebit(r DebitReport)
+
+	// OnEviction is called each time an idle account is evicted from the table.
+	OnEviction()
+
+	// OnStateChange is called for every account and RRL transition which [RRL.ExportEvents]
+	// exports. Unlike ExportEvents, no transitions are dropped by max-events-per-second.
+	OnStateChange(ev Event)
+}
+
+// sinkRef allows a StatsSink to be stored in an atomic.Pointer.
+type sinkRef struct {
+	StatsSink
+}
+
+// internalSink is the default StatsSink which accumulates the RRL's own counters.
+type internalSink struct {
+	rrl *RRL
+}
+
+func (is internalSink) OnDebit(r DebitReport) { is.rrl.onDebit(&r) }
+func (is internalSink) OnEviction()           { is.rrl.onEviction() }
+func (is internalSink) OnStateChange(Event)   {}
+
+// SetStatsSink replaces the internal sink with s. A nil s restores the internal sink,
+// whose counters resume from where they were when it was replaced.
+//
+// SetStatsSink is concurrency safe.
+func (rrl *RRL) SetStatsSink(s StatsSink) {
+	if s == nil {
+		s = internalSink{rrl}
+	}
+	rrl.sink.Store(&sinkRef{s})
+}
+
+// statsSink returns the registered StatsSink.
+func (rrl *RRL) statsSink() StatsSink {
+	if ref := rrl.sink.Load(); ref != nil {
+		return ref.StatsSink
+	}
+
+	return internalSink{rrl}
+}
+
+// stateChange reports a transition to the registered StatsSink. The internal sink does
+// not count transitions.
+func (rrl *RRL) stateChange(ev *Event) {
+	s := rrl.statsSink()
+	if _, ok := s.(internalSink); ok {
+		return
+	}
+	s.OnStateChange(*ev)
+}
+
+// onDebit is the internal sink's OnDebit.
+func (rrl *RRL) onDebit(r *DebitReport) {
+	d := &r.Decision
+	increment := func(s *Stats) {
+		switch {
+		case r.Request:
+			s.incrementRequest(d.Action, d.IPReason)
+		case r.Response:
+			s.incrementResponse(d.Action, d.RTReason, r.Category)
+		default:
+			s.incrementDebit(d.Action, d.IPReason, d.RTReason, r.Category)
+		}
+	}
+
+	rrl.statsMu.Lock()
+	defer rrl.statsMu.Unlock()
+	increment(&rrl.stats)
+	if len(r.Tag) > 0 {
+		ts := rrl.tagStats[r.Tag]
+		if ts == nil {
+			if rrl.tagStats == nil {
+				rrl.tagStats = make(map[string]*Stats)
+			}
+			ts = &Stats{}
+			rrl.tagStats[r.Tag] = ts
+		}
+		increment(ts)
+	}
+	if s := rrl.familySlice(r.Network); s != nil {
+		increment(s)
+	}
+	if s := rrl.historySlice(); s != nil {
+		increment(s)
+	}
+}
+
+// onEviction is the internal sink's OnEviction.
+func (rrl *RRL) onEviction() {
+	rrl.statsMu.Lock()
+	rrl.stats.Evictions++
+	if s := rrl.historySlice(); s != nil {
+		s.Evictions++
+	}
+	rrl.statsMu.Unlock()
+}
@@ -0,0 +1,109 @@
+package rrl_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/markdingo/rrl"
+)
+
+type testSink struct {
+	mu        sync.Mutex
+	reports   []rrl.DebitReport
+	evictions int
+	events    []rrl.EventKind
+}
+
+func (ts *testSink) OnDebit(r rrl.DebitReport) {
+	ts.mu.Lock()
+	ts.reports = append(ts.reports, r)
+	ts.mu.Unlock()
+}
+
+func (ts *testSink) OnEviction() {
+	ts.mu.Lock()
+	ts.evictions++
+	ts.mu.Unlock()
+}
+
+func (ts *testSink) OnStateChange(ev rrl.Event) {
+	ts.mu.Lock()
+	ts.events = append(ts.events, ev.Kind)
+	ts.mu.Unlock()
+}
+
+func TestStatsSink(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cfg := rrl.NewConfig()
+	cfg.SetValue("responses-per-second", "1")
+	cfg.SetValue("slip-ratio", "0")
+	cfg.SetNowFunc(func() time.Time { return now })
+	R := rrl.NewRRL(cfg)
+
+	src := newAddr("udp", "10.0.0.1:53")
+	tuple := newTuple(1, 1, "example.com.", rrl.AllowanceAnswer)
+	R.Debit(src, tuple)
+
+	ts := &testSink{}
+	R.SetStatsSink(ts)
+	R.DebitTagged("t1", src, tuple)
+	R.DebitTagged("t1", src, tuple) // Into debit
+	R.DebitRequest(src)
+	R.DebitResponse(src, tuple)
+
+	if len(ts.reports) != 4 {
+		t.Fatal("Expected four reports, not", len(ts.reports))
+	}
+	r := ts.reports[1]
+	if r.Tag != "t1" || r.Network != "10.0.0.0" ||
+		r.Decision != rrl.NewDecision(rrl.Drop, rrl.IPNotConfigured, rrl.RTRateLimit) ||
+		r.Category != rrl.AllowanceAnswer || r.Request || r.Response {
+		t.Error("Unexpected Debit report", r)
+	}
+	if r := ts.reports[2]; !r.Request || r.Decision.RTReason != rrl.RTNotReached ||
+		r.Category != rrl.AllowanceLast {
+		t.Error("Unexpected DebitRequest report", r)
+	}
+	if r := ts.reports[3]; !r.Response || r.Decision.IPReason != rrl.IPNotReached ||
+		r.Category != rrl.AllowanceAnswer {
+		t.Error("Unexpected DebitResponse report", r)
+	}
+	if len(ts.events) != 1 || ts.events[0] != rrl.EventLimit {
+		t.Error("Expected a single EventLimit, not", ts.events)
+	}
+
+	// The internal counters are bypassed while the sink is registered
+	if stats := R.GetStats(false); stats.RPS[rrl.AllowanceAnswer] != 1 {
+		t.Error("Internal counters should be bypassed", stats.String())
+	}
+	if tags := R.GetTagStats(false); len(tags) != 0 {
+		t.Error("Internal tag counters should be bypassed", tags)
+	}
+
+	// Evictions are reported to the sink. Evictions only occur once a shard is full
+	cfg = rrl.NewConfig()
+	cfg.SetValue("max-table-size", "4096")
+	cfg.SetValue("responses-per-second", "10")
+	cfg.SetNowFunc(func() time.Time { return now })
+	R = rrl.NewRRL(cfg)
+	R.SetStatsSink(ts)
+	for ix := 0; ix < 100*255 && ts.evictions == 0; ix++ {
+		R.Debit(newAddr("udp", fmt.Sprintf("10.%d.%d.1:53", ix/255, ix%255)), tuple)
+		now = now.Add(time.Second)
+	}
+	if ts.evictions == 0 {
+		t.Error("Eviction should have been reported to the sink")
+	}
+	if stats := R.GetStats(false); stats.Evictions != 0 {
+		t.Error("Internal eviction counter should be bypassed", stats.Evictions)
+	}
+
+	// Restoring the internal sink resumes the internal counters
+	R.SetStatsSink(nil)
+	R.Debit(src, tuple)
+	if stats := R.GetStats(false); stats.RPS[rrl.AllowanceAnswer] != 1 {
+		t.Error("Internal counters should have resumed", stats.String())
+	}
+}
@@ -0,0 +1,90 @@
+package rrl
+
+import (
+	"hash/maphash"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/markdingo/rrl/cache"
+)
+
+const sketchDepth = 4 // Rows in the count-min sketch
+
+// sketch is a count-min sketch of the time spent by each account token in the current
+// second. Each debit adds its allowance to one counter in each row and the smallest of
+// those counters is an over-estimate of the token's spend. A token whose estimated spend
+// is within the one second of credit a new account starts with cannot possibly be limited
+// so it needs no account. Only tokens which exceed that credit, i.e., suspected
+// offenders, have an exact account created. Thus memory is bounded by the sketch width
+// regardless of how many distinct tokens an attack generates.
+//
+// All counters are reset at the start of each second.
+type sketch struct {
+	seed  maphash.Seed
+	width uint64
+
+	mu    sync.Mutex // Protects resets
+	epoch atomic.Int64
+	rows  [sketchDepth][]atomic.Int64
+}
+
+// initSketch creates the sketch if sketch-width is configured.
+func (rrl *RRL) initSketch() {
+	if rrl.cfg.sketchWidth == 0 {
+		return
+	}
+	sk := &sketch{seed: maphash.MakeSeed(), width: uint64(rrl.cfg.sketchWidth)}
+	for ix := range sk.rows {
+		sk.rows[ix] = make([]atomic.Int64, sk.width)
+	}
+	sk.epoch.Store(rrl.now() / int64(time.Second))
+	rrl.sketch = sk
+}
+
+// add adds spend to the counters of token t and returns the estimated total spend of t in
+// the current second.
+func (sk *sketch) add(t string, spend, now int64) int64 {
+	if epoch := now / int64(time.Second); epoch != sk.epoch.Load() {
+		sk.mu.Lock()
+		if epoch != sk.epoch.Load() {
+			for _, row := range sk.rows {
+				for ix := range row {
+					row[ix].Store(0)
+				}
+			}
+			sk.epoch.Store(epoch)
+		}
+		sk.mu.Unlock()
+	}
+
+	// Derive each row's index from two halves of a single hash (Kirsch-Mitzenmacher)
+	h := maphash.String(sk.seed, t)
+	h1, h2 := h&0xffffffff, h>>32
+	estimate := int64(-1)
+	for ix := range sk.rows {
+		c := sk.rows[ix][(h1+uint64(ix)*h2)%sk.width].Add(spend)
+		if estimate < 0 || c < estimate {
+			estimate = c
+		}
+	}
+
+	return estimate
+}
+
+// sketched returns true and the estimated balance if the account t does not exist and
+// the sketch shows that it cannot yet be limited.
+func (rrl *RRL) sketched(table *cache.Cache, allowance int64, t string) (int64, bool) {
+	if rrl.sketch == nil {
+		return 0, false
+	}
+	spend := rrl.sketch.add(t, allowance, rrl.now())
+	if spend > int64(time.Second) {
+		return 0, false
+	}
+	if _, found := table.Get(t); found {
+		return 0, false
+	}
+
+	return int64(time.Second) - spend, true
+}
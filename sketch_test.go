@@ -0,0 +1,64 @@
+package rrl
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSketch(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cfg := NewConfig()
+	cfg.SetValue("responses-per-second", "10")
+	cfg.SetValue("slip-ratio", "0")
+	cfg.SetValue("sketch-width", "4096")
+	cfg.SetNowFunc(func() time.Time { return now })
+	R := NewRRL(cfg)
+
+	// A random subdomain flood creates no accounts
+	src := newAddr("udp", "10.0.0.1:53")
+	for ix := 0; ix < 1000; ix++ {
+		tuple := newTuple(1, 1, fmt.Sprintf("r%d.example.com.", ix), AllowanceAnswer)
+		if act, _, _ := R.Debit(src, tuple); act != Send {
+			t.Fatal("Sketched account should be sent", act)
+		}
+	}
+	if l := R.tableLen(); l != 0 {
+		t.Error("Sketched accounts should not be in the table", l)
+	}
+
+	// An exact account is only created once the initial credit is exhausted
+	now = now.Add(time.Second)
+	tuple := newTuple(1, 1, "example.com.", AllowanceAnswer)
+	for ix := 0; ix < 10; ix++ {
+		R.Debit(src, tuple)
+	}
+	if l := R.tableLen(); l != 0 {
+		t.Error("Account within its initial credit should not be created", l)
+	}
+	R.Debit(src, tuple)
+	if l := R.tableLen(); l != 1 {
+		t.Error("Suspected offender should have an exact account", l)
+	}
+
+	// From which point it is limited exactly, starting with one second of credit
+	var drops int
+	for ix := 0; ix < 20; ix++ {
+		if act, _, _ := R.Debit(src, tuple); act == Drop {
+			drops++
+		}
+	}
+	if drops != 11 {
+		t.Error("Expected the exact account to drop 11, not", drops)
+	}
+
+	// Counters reset each second so steady traffic within the allowance stays sketched
+	other := newTuple(1, 1, "example.net.", AllowanceAnswer)
+	for ix := 0; ix < 50; ix++ {
+		R.Debit(src, other)
+		now = now.Add(100 * time.Millisecond)
+	}
+	if l := R.tableLen(); l != 1 {
+		t.Error("Traffic within the allowance should not create an account", l)
+	}
+}
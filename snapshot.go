@@ -0,0 +1,84 @@
+package rrl
+
+import (
+	"sort"
+	"time"
+)
+
+// snapshotTopTalkers is the maximum number of accounts returned in Snapshot.TopTalkers
+const snapshotTopTalkers = 10
+
+// Snapshot is an immutable view of an RRL returned by [RRL.Snapshot]. It is intended for
+// admin handlers and dashboards which want a single coherent view rather than making
+// multiple reads of a busy RRL.
+type Snapshot struct {
+	Time   time.Time // When the Snapshot was taken
+	Stats  Stats     // As returned by GetStats(false)
+	Config *Config   // A private copy of the RRL Config
+
+	// Limited contains the sorted Client Networks (and ipv6 aggregate networks) which
+	// have at least one account with a negative balance.
+	Limited []string
+
+	// TopTalkers contains the accounts with the most negative balances, most negative
+	// first.
+	TopTalkers []AccountInfo
+
+	// Partial is true if inspection-budget was exhausted before all accounts were
+	// examined, in which case Limited and TopTalkers are incomplete.
+	Partial bool
+}
+
+// Snapshot returns a [Snapshot] of the RRL. All accounts are examined in a single pass so
+// Snapshot should be called sparingly on large tables. Snapshot is subject to
+// max-inspections-per-second and inspection-budget.
+//
+// Snapshot is concurrency safe.
+func (rrl *RRL) Snapshot() *Snapshot {
+	cfg := rrl.cfg
+	ss := &Snapshot{
+		Time:   rrl.clock.wall(),
+		Stats:  rrl.GetStats(false),
+		Config: &cfg,
+	}
+
+	limited := make(map[string]struct{})
+	var now int64 // Set on the first visit as inspect may delay the walk
+	complete := rrl.inspect(func(t string, ra *responseAccount, balance int64) bool {
+		if now == 0 {
+			now = rrl.now()
+		}
+		if balance >= 0 {
+			return true
+		}
+		ai := newAccountInfo(t, ra, balance, now)
+		limited[ai.Key.Network] = struct{}{}
+		ss.TopTalkers = insertTopTalker(ss.TopTalkers, ai, snapshotTopTalkers)
+		return true
+	})
+
+	ss.Partial = !complete
+
+	for network := range limited {
+		ss.Limited = append(ss.Limited, network)
+	}
+	sort.Strings(ss.Limited)
+
+	return ss
+}
+
+// insertTopTalker inserts ai into the sorted slice of most negative accounts, limiting
+// the slice to max entries.
+func insertTopTalker(top []AccountInfo, ai AccountInfo, max int) []AccountInfo {
+	ix := sort.Search(len(top), func(i int) bool { return top[i].Balance > ai.Balance })
+	if ix >= max {
+		return top
+	}
+	if len(top) < max {
+		top = append(top, AccountInfo{})
+	}
+	copy(top[ix+1:], top[ix:])
+	top[ix] = ai
+
+	return top
+}
@@ -0,0 +1,51 @@
+package rrl
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	cfg := NewConfig()
+	cfg.SetValue("responses-per-second", "1")
+	cfg.SetValue("requests-per-second", "100")
+	cfg.SetNowFunc(func() time.Time {
+		return time.Time{}
+	})
+	R := NewRRL(cfg)
+
+	// Client Network ix sends ix+1 queries so later networks are more negative
+	for ix := 0; ix < 20; ix++ {
+		src := newAddr("udp", fmt.Sprintf("10.0.%d.1:53", ix))
+		for q := 0; q <= ix; q++ {
+			R.Debit(src, newTuple(1, 1, "example.com.", AllowanceAnswer))
+		}
+	}
+
+	ss := R.Snapshot()
+	if ss.Stats.CacheLength != 40 {
+		t.Error("Snapshot stats should have 40 accounts, not", ss.Stats.String())
+	}
+	if ss.Config.String() != cfg.String() {
+		t.Error("Snapshot config differs", ss.Config.String(), cfg.String())
+	}
+	if len(ss.Limited) != 19 { // 10.0.0.0 sent one query and remains in credit
+		t.Fatal("Expected 19 limited networks, not", len(ss.Limited), ss.Limited)
+	}
+	if ss.Limited[0] != "10.0.1.0" {
+		t.Error("Limited should be sorted", ss.Limited)
+	}
+	if len(ss.TopTalkers) != snapshotTopTalkers {
+		t.Fatal("Expected", snapshotTopTalkers, "top talkers, not", len(ss.TopTalkers))
+	}
+	top := ss.TopTalkers[0] // Balances can't be more negative than the window
+	if top.Key.Kind != AccountResponse || top.Key.SalientName != "example.com." || top.Balance != -15*time.Second {
+		t.Error("Unexpected top talker", top.Key.String(), top.Balance)
+	}
+	for ix := 1; ix < len(ss.TopTalkers); ix++ {
+		if ss.TopTalkers[ix-1].Balance > ss.TopTalkers[ix].Balance {
+			t.Error("TopTalkers not sorted at", ix, ss.TopTalkers)
+		}
+	}
+}
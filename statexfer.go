@@ -0,0 +1,331 @@
+package rrl
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"time"
+)
+
+// State transfer protocol. All integers are big-endian.
+//
+//	Server -> Client: "RRL1" + 32 byte server challenge
+//	Client -> Server: HMAC("client" + server challenge) + 32 byte client challenge
+//	Server -> Client: HMAC("server" + client challenge)
+//	Server -> Client: records, each of:
+//	                    u16 token length, token, i64 balance, u32 slip countdown, u8 limited
+//	Server -> Client: u16 0xffff end marker + HMAC of all record bytes
+//
+// The trailing HMAC is keyed with HMAC("records" + server challenge + client challenge) so
+// that records cannot be replayed from, or spliced in from, another session. The client
+// only applies records once the trailing HMAC has been verified.
+const (
+	xferMagic     = "RRL1"
+	xferNonceLen  = 32
+	xferEnd       = 0xffff
+	xferTimeout   = 30 * time.Second
+	xferMaxTokens = 10000000 // Sanity limit on the number of records accepted
+	xferMaxConns  = 4        // Concurrent connections served. Inspections are serialized anyway
+)
+
+var (
+	errXferNoKey  = errors.New("rrl: state-transfer-key not configured")
+	errXferAuth   = errors.New("rrl: state transfer authentication failed")
+	errXferFormat = errors.New("rrl: malformed state transfer")
+)
+
+// xferRecord is a single transferred account. The balance is relative to the time the
+// record was created so that the peers need not have synchronized clocks.
+type xferRecord struct {
+	token         string
+	balance       int64
+	slipCountdown uint32
+	limited       bool
+}
+
+// ServeState accepts connections on l and serves the current rate-limited accounts to
+// peers calling [RRL.PrimeFrom]. Peers are authenticated with the "state-transfer-key"
+// shared secret. Only accounts with a negative balance are served as those are the
+// accounts which would otherwise be granted fresh credit by a restarted peer.
+//
+// The accounts are gathered in the same way as [RRL.Snapshot] so serving is paced by
+// max-inspections-per-second and bounded by inspection-budget, in which case a peer is
+// served a partial set of accounts.
+//
+// ServeState blocks until l.Accept returns an error, which ServeState then returns. Each
+// connection is served in its own goroutine, up to a small limit beyond which connections
+// are closed as soon as they are accepted. Callers wanting encryption in addition to
+// authentication can supply a [crypto/tls] listener.
+func (rrl *RRL) ServeState(l net.Listener) error {
+	if len(rrl.cfg.stateTransferKey) == 0 {
+		return errXferNoKey
+	}
+	active := make(chan struct{}, xferMaxConns)
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		select {
+		case active <- struct{}{}:
+		default:
+			conn.Close()
+			continue
+		}
+		go func() {
+			defer func() { <-active }()
+			defer conn.Close()
+			conn.SetDeadline(time.Now().Add(xferTimeout))
+			rrl.serveStateConn(conn)
+		}()
+	}
+}
+
+// serveStateConn authenticates the peer and writes all rate-limited accounts.
+func (rrl *RRL) serveStateConn(conn io.ReadWriter) error {
+	challenge := make([]byte, xferNonceLen)
+	if _, err := rand.Read(challenge); err != nil {
+		return err
+	}
+	if _, err := conn.Write(append([]byte(xferMagic), challenge...)); err != nil {
+		return err
+	}
+
+	reply := make([]byte, sha256.Size+xferNonceLen)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if !hmac.Equal(reply[:sha256.Size], rrl.xferMAC("client", challenge)) {
+		return errXferAuth
+	}
+
+	w := bufio.NewWriter(conn)
+	w.Write(rrl.xferMAC("server", reply[sha256.Size:]))
+
+	var records []xferRecord
+	rrl.inspect(func(t string, ra *responseAccount, balance int64) bool {
+		if balance < 0 && len(t) < xferEnd {
+			records = append(records, xferRecord{t, balance, uint32(ra.slipCountdown), ra.limited})
+		}
+		return true
+	})
+
+	mac := rrl.xferRecordMAC(challenge, reply[sha256.Size:])
+	out := io.MultiWriter(w, mac)
+	for _, r := range records {
+		writeXferRecord(out, &r)
+	}
+	binary.Write(w, binary.BigEndian, uint16(xferEnd))
+	w.Write(mac.Sum(nil))
+
+	return w.Flush()
+}
+
+// PrimeFrom connects to a peer running [RRL.ServeState] at addr and imports its
+// rate-limited accounts. It is intended to be called by a newly started instance before
+// it starts serving so that attackers are not granted fresh credit, for example when an
+// anycast node restarts.
+//
+// Both peers must be configured with the same "state-transfer-key". If the prefix lengths
+// differ, imported accounts are re-aggregated under the local prefix lengths, so a
+// replacement instance configured with shorter prefixes, perhaps to tighten aggregation
+// mid-attack, immediately applies the most negative balance of the peer's accounts to
+// each of its wider Client Networks rather than granting them fresh credit.
+// Imported accounts never increase the credit of an existing local account.
+// PrimeFrom returns the number of accounts imported.
+func (rrl *RRL) PrimeFrom(addr string) (int, error) {
+	if len(rrl.cfg.stateTransferKey) == 0 {
+		return 0, errXferNoKey
+	}
+	conn, err := net.DialTimeout("tcp", addr, xferTimeout)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(xferTimeout))
+
+	return rrl.primeFromConn(conn)
+}
+
+// primeFromConn authenticates the peer, reads and verifies all records, then imports them.
+func (rrl *RRL) primeFromConn(conn io.ReadWriter) (int, error) {
+	r := bufio.NewReader(conn)
+	hello := make([]byte, len(xferMagic)+xferNonceLen)
+	if _, err := io.ReadFull(r, hello); err != nil {
+		return 0, err
+	}
+	if string(hello[:len(xferMagic)]) != xferMagic {
+		return 0, errXferFormat
+	}
+
+	challenge := make([]byte, xferNonceLen)
+	if _, err := rand.Read(challenge); err != nil {
+		return 0, err
+	}
+	reply := append(rrl.xferMAC("client", hello[len(xferMagic):]), challenge...)
+	if _, err := conn.Write(reply); err != nil {
+		return 0, err
+	}
+
+	serverMAC := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(r, serverMAC); err != nil {
+		if err == io.EOF { // The server closes the connection if it rejects our reply
+			err = errXferAuth
+		}
+		return 0, err
+	}
+	if !hmac.Equal(serverMAC, rrl.xferMAC("server", challenge)) {
+		return 0, errXferAuth
+	}
+
+	mac := rrl.xferRecordMAC(hello[len(xferMagic):], challenge)
+	var records []xferRecord
+	for {
+		rec, end, err := readXferRecord(r, mac)
+		if err != nil {
+			return 0, err
+		}
+		if end {
+			break
+		}
+		if len(records) >= xferMaxTokens {
+			return 0, errXferFormat
+		}
+		records = append(records, rec)
+	}
+	trailer := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(r, trailer); err != nil {
+		return 0, err
+	}
+	if !hmac.Equal(trailer, mac.Sum(nil)) {
+		return 0, errXferAuth
+	}
+
+	for ix := range records {
+		if err := rrl.importAccount(&records[ix]); err != nil {
+			return ix, err
+		}
+	}
+
+	return len(records), nil
+}
+
+// importAccount adds the transferred account to the appropriate table after
+// re-aggregating it under the local prefix lengths. If the account already exists
+// locally, the lower of the two balances is retained, which also conservatively merges
+// multiple transferred accounts which re-aggregate to the same local account. The balance
+// is clamped to the local window of the account and the slip countdown of a new account
+// is clamped to the local slip-ratio.
+func (rrl *RRL) importAccount(rec *xferRecord) error {
+	if rec.balance >= 0 { // Never grant credit
+		return nil
+	}
+	token, ok := rrl.reaggregate(rec.token)
+	if !ok {
+		return nil
+	}
+	table := rrl.table
+	window, curve := rrl.cfg.window, rrl.cfg.recovery
+	switch key := parseAccountKey(token); key.Kind {
+	case AccountResponse:
+		table = rrl.tableFor(key.AllowanceCategory)
+		window, curve = rrl.windowFor(key.AllowanceCategory), rrl.recoveryFor(key.AllowanceCategory)
+	case AccountMinimum:
+		curve = recoveryLinear
+	}
+	balance := rec.balance
+	if balance < -window { // The peer may have a longer window
+		balance = -window
+	}
+	now := rrl.now()
+	result := table.UpdateAdd(token,
+		func(el interface{}) interface{} {
+			if ra, ok := (el).(*responseAccount); ok && ra.balance(now) > balance {
+				ra.setBalance(now, balance)
+				if rec.limited && !ra.limited {
+					ra.limitedSince = now
+				}
+				ra.limited = rec.limited
+			}
+			return nil
+		},
+		func() interface{} {
+			countdown := uint(rec.slipCountdown)
+			if countdown > rrl.cfg.slipRatio {
+				countdown = rrl.cfg.slipRatio
+			}
+			ra := &responseAccount{slipCountdown: countdown, limited: rec.limited,
+				limitedSince: now, created: now, curve: curve, scale: window}
+			ra.setBalance(now, balance)
+			return ra
+		})
+	if err, ok := result.(error); ok {
+		return err
+	}
+
+	return nil
+}
+
+// xferMAC returns the HMAC of the label and nonce keyed with the state-transfer-key.
+func (rrl *RRL) xferMAC(label string, nonce []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(rrl.cfg.stateTransferKey))
+	mac.Write([]byte(label))
+	mac.Write(nonce)
+
+	return mac.Sum(nil)
+}
+
+// xferRecordMAC returns the HMAC of the record stream of the session identified by the
+// server and client challenges.
+func (rrl *RRL) xferRecordMAC(serverNonce, clientNonce []byte) hash.Hash {
+	nonces := append(append([]byte(nil), serverNonce...), clientNonce...)
+
+	return hmac.New(sha256.New, rrl.xferMAC("records", nonces))
+}
+
+func writeXferRecord(w io.Writer, r *xferRecord) {
+	buf := make([]byte, 0, 2+len(r.token)+8+4+1)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(r.token)))
+	buf = append(buf, r.token...)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(r.balance))
+	buf = binary.BigEndian.AppendUint32(buf, r.slipCountdown)
+	limited := byte(0)
+	if r.limited {
+		limited = 1
+	}
+	w.Write(append(buf, limited))
+}
+
+// readXferRecord reads the next record from r and adds its bytes to mac. It returns true
+// if the end marker was read instead.
+func readXferRecord(r io.Reader, mac hash.Hash) (rec xferRecord, end bool, err error) {
+	var length uint16
+	if err = binary.Read(r, binary.BigEndian, &length); err != nil {
+		return
+	}
+	if length == xferEnd {
+		end = true
+		return
+	}
+	buf := make([]byte, int(length)+8+4+1)
+	if _, err = io.ReadFull(r, buf); err != nil {
+		err = fmt.Errorf("%w: %s", errXferFormat, err)
+		return
+	}
+	mac.Write(binary.BigEndian.AppendUint16(nil, length))
+	mac.Write(buf)
+
+	rec.token = string(buf[:length])
+	rec.balance = int64(binary.BigEndian.Uint64(buf[length:]))
+	rec.slipCountdown = binary.BigEndian.Uint32(buf[length+8:])
+	rec.limited = buf[length+12] != 0
+
+	return
+}
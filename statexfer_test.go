@@ -0,0 +1,256 @@
+package rrl
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func newXferRRL(key string) *RRL {
+	cfg := NewConfig()
+	cfg.SetValue("responses-per-second", "1")
+	cfg.SetValue("requests-per-second", "10")
+	cfg.SetValue("state-transfer-key", key)
+	cfg.SetNowFunc(func() time.Time { return time.Unix(1000, 0) })
+	return NewRRL(cfg)
+}
+
+func TestStateTransfer(t *testing.T) {
+	server := newXferRRL("secret")
+	src := newAddr("udp", "10.0.0.1:53")
+	tuple := newTuple(1, 1, "example.com.", AllowanceAnswer)
+	for ix := 0; ix < 5; ix++ {
+		server.Debit(src, tuple)
+	}
+	server.Debit(newAddr("udp", "10.0.1.1:53"), tuple) // In credit so not transferred
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("Cannot listen on loopback", err)
+	}
+	defer l.Close()
+	go server.ServeState(l)
+
+	client := newXferRRL("secret")
+	n, err := client.PrimeFrom(l.Addr().String())
+	if err != nil {
+		t.Fatal("Unexpected PrimeFrom error", err)
+	}
+	if n != 1 {
+		t.Error("Expected one account transferred, got", n)
+	}
+	if act, _, rtr := client.Debit(src, tuple); act == Send {
+		t.Error("Primed account should be rate limited", act, rtr)
+	}
+	if act, _, _ := client.Debit(newAddr("udp", "10.0.1.1:53"), tuple); act != Send {
+		t.Error("Unprimed account should Send", act)
+	}
+
+	wrong := newXferRRL("wrong")
+	if _, err := wrong.PrimeFrom(l.Addr().String()); !errors.Is(err, errXferAuth) {
+		t.Error("Expected authentication error, got", err)
+	}
+
+	none := newXferRRL("")
+	if _, err := none.PrimeFrom(l.Addr().String()); err != errXferNoKey {
+		t.Error("Expected no key error, got", err)
+	}
+	if err := none.ServeState(l); err != errXferNoKey {
+		t.Error("Expected no key error, got", err)
+	}
+}
+
+// A tampered record stream must be rejected
+func TestStateTransferTamper(t *testing.T) {
+	server := newXferRRL("secret")
+	src := newAddr("udp", "10.0.0.1:53")
+	tuple := newTuple(1, 1, "example.com.", AllowanceAnswer)
+	for ix := 0; ix < 5; ix++ {
+		server.Debit(src, tuple)
+	}
+
+	c1, c2 := net.Pipe()
+	go func() {
+		server.serveStateConn(&tamperConn{Conn: c1})
+		c1.Close()
+	}()
+	client := newXferRRL("secret")
+	if _, err := client.primeFromConn(c2); !errors.Is(err, errXferAuth) {
+		t.Error("Expected tampering to be detected, got", err)
+	}
+	if client.tableLen() != 0 {
+		t.Error("No accounts should be imported from a tampered stream")
+	}
+}
+
+// Record streams are bound to the challenges of their session
+func TestStateTransferSession(t *testing.T) {
+	R := newXferRRL("secret")
+	s1, s2, c1 := make([]byte, xferNonceLen), make([]byte, xferNonceLen), make([]byte, xferNonceLen)
+	s2[0], c1[0] = 1, 2
+	sum := func(serverNonce, clientNonce []byte) string {
+		mac := R.xferRecordMAC(serverNonce, clientNonce)
+		mac.Write([]byte("record"))
+		return string(mac.Sum(nil))
+	}
+	if sum(s1, c1) != sum(s1, c1) {
+		t.Error("Record MAC should be deterministic within a session")
+	}
+	if sum(s1, c1) == sum(s2, c1) || sum(s1, c1) == sum(s1, s2) || sum(s1, c1) == sum(c1, s1) {
+		t.Error("Record MAC should differ between sessions")
+	}
+}
+
+// Imported slip countdowns never exceed the local slip-ratio
+func TestStateTransferSlipCountdown(t *testing.T) {
+	R := newXferRRL("secret")
+	rec := xferRecord{token: "10.0.0.0/0/1/example.com.", balance: -second, slipCountdown: 1000000,
+		limited: true}
+	if err := R.importAccount(&rec); err != nil {
+		t.Fatal("Unexpected importAccount error", err)
+	}
+	el, found := R.table.Get(rec.token)
+	if !found {
+		t.Fatal("Imported account not found")
+	}
+	if sc := el.(*responseAccount).slipCountdown; sc != R.cfg.slipRatio {
+		t.Error("Imported slip countdown should be clamped to", R.cfg.slipRatio, "not", sc)
+	}
+}
+
+// Imported balances are never more negative than the local window of the account
+func TestStateTransferClampBalance(t *testing.T) {
+	cfg := NewConfig()
+	cfg.SetValue("window", "15")
+	cfg.SetValue("responses-per-second", "1")
+	cfg.SetValue("requests-per-second", "10")
+	cfg.SetValue("nxdomains-window", "30")
+	cfg.SetNowFunc(func() time.Time { return time.Unix(1000, 0) })
+	R := NewRRL(cfg)
+
+	testCases := []struct {
+		token string
+		ac    AllowanceCategory
+		exp   int64
+	}{
+		{R.accountToken("10.0.0.0", 1, 1, "example.com.", AllowanceAnswer), AllowanceAnswer, -15 * second},
+		{R.accountToken("10.0.0.0", 1, 1, "example.com.", AllowanceNXDomain), AllowanceNXDomain, -30 * second},
+		{"10.0.0.0", AllowanceLast, -15 * second},
+		{"10.0.0.0/min", AllowanceLast, -15 * second},
+	}
+	for ix, tc := range testCases {
+		rec := xferRecord{token: tc.token, balance: -3600 * second, limited: true}
+		if err := R.importAccount(&rec); err != nil {
+			t.Fatal(ix, "Unexpected importAccount error", err)
+		}
+		table := R.table
+		if tc.ac < AllowanceLast {
+			table = R.tableFor(tc.ac)
+		}
+		el, found := table.Get(tc.token)
+		if !found {
+			t.Fatal(ix, "Imported account not found", tc.token)
+		}
+		if b := el.(*responseAccount).balance(R.now()); b < tc.exp-1 || b > tc.exp+1 {
+			t.Error(ix, tc.token, "balance should be clamped to", tc.exp, "not", b)
+		}
+	}
+}
+
+// Connections beyond xferMaxConns are closed immediately
+func TestStateTransferMaxConns(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("Cannot listen on loopback", err)
+	}
+	defer l.Close()
+	go newXferRRL("secret").ServeState(l)
+
+	hello := make([]byte, len(xferMagic)+xferNonceLen)
+	for ix := 0; ix < xferMaxConns; ix++ { // Each stalls the server waiting for a reply
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatal("Unexpected Dial error", err)
+		}
+		defer conn.Close()
+		if _, err := io.ReadFull(conn, hello); err != nil {
+			t.Fatal(ix, "Expected hello, got", err)
+		}
+	}
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal("Unexpected Dial error", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.ReadFull(conn, hello); err == nil || errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Error("Excess connection should be closed, not", err)
+	}
+}
+
+// tamperConn flips a bit in the final byte of the record stream, which is the
+// limited flag of the last record.
+type tamperConn struct {
+	net.Conn
+	writes int
+}
+
+func (tc *tamperConn) Write(b []byte) (int, error) {
+	tc.writes++
+	if tc.writes == 2 && len(b) > 2*32+2 { // Second write carries the buffered records
+		b = append([]byte(nil), b...)
+		b[len(b)-32-2-1] ^= 1
+	}
+	return tc.Conn.Write(b)
+}
+
+// Accounts from a peer with different prefix lengths are re-aggregated under the local
+// prefix lengths, retaining the most negative balance of those which merge.
+func TestStateTransferReaggregate(t *testing.T) {
+	cfg := NewConfig()
+	cfg.SetValue("responses-per-second", "1")
+	cfg.SetValue("ipv4-prefix-length", "16")
+	cfg.SetValue("ipv6-prefix-length", "48")
+	cfg.SetValue("ipv6-aggregate-requests-per-second", "10")
+	cfg.SetValue("ipv6-aggregate-prefix-length", "32")
+	cfg.SetNowFunc(func() time.Time { return time.Unix(1000, 0) })
+	R := NewRRL(cfg)
+
+	testCases := []struct{ token, exp string }{
+		{"10.1.2.0", "10.1.0.0"},
+		{"10.1.2.0/min", "10.1.0.0/min"},
+		{"10.1.2.0/0/1/example.com.", "10.1.0.0/0/1/example.com."},
+		{"2001:db8:1:2::/3//example.net.", "2001:db8:1::/3//example.net."},
+		{"2001:db8:1::/48", "2001:db8::/32"},
+		{"bogus", ""},
+	}
+	for ix, tc := range testCases {
+		got, ok := R.reaggregate(tc.token)
+		if got != tc.exp || ok != (len(tc.exp) > 0) {
+			t.Error(ix, "reaggregate", tc.token, "expected", tc.exp, "got", got, ok)
+		}
+	}
+
+	for _, rec := range []xferRecord{
+		{token: "10.1.2.0/0/1/example.com.", balance: -2 * second, limited: true},
+		{token: "10.1.3.0/0/1/example.com.", balance: -5 * second, limited: true},
+		{token: "10.1.4.0/0/1/example.com.", balance: -3 * second, limited: true},
+	} {
+		if err := R.importAccount(&rec); err != nil {
+			t.Fatal("Unexpected importAccount error", err)
+		}
+	}
+	limited := R.DumpLimited(0)
+	if len(limited) != 1 || limited[0].Key.Network != "10.1.0.0" || limited[0].Balance != -5*time.Second {
+		t.Error("Expected a single merged account with the lowest balance", limited)
+	}
+
+	unaggregated := newXferRRL("secret")
+	if _, ok := unaggregated.reaggregate("2001:db8:1::/48"); ok {
+		t.Error("Aggregate accounts have no equivalent without ipv6 aggregation")
+	}
+}
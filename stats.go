@@ -14,8 +14,14 @@ type Stats struct {
 	IPReasons [IPLast]int64
 	RTReasons [RTLast]int64
 
-	CacheLength int   // Always current
-	Evictions   int64 // Since last zero
+	CacheLength    int   // Always current
+	Evictions      int64 // Since last zero
+	SlipDowngrades int64 // Since last zero. Slips downgraded to Drops by max-slips-per-second
+
+	// Approximate distinct counts in the current and previous windows. Always current
+	// and only populated if track-uniques is configured.
+	ClientNetworks int64
+	SalientNames   int64
 }
 
 var zero Stats
@@ -47,6 +53,27 @@ func (c *Stats) Add(from *Stats) {
 	}
 	c.CacheLength = from.CacheLength // Would max() or avg() be more useful?
 	c.Evictions += from.Evictions
+	c.SlipDowngrades += from.SlipDowngrades
+	c.ClientNetworks = from.ClientNetworks
+	c.SalientNames = from.SalientNames
+}
+
+// sub subtracts the counters of from. Gauges are left unchanged.
+func (c *Stats) sub(from *Stats) {
+	for ix, v := range from.RPS {
+		c.RPS[ix] -= v
+	}
+	for ix, v := range from.Actions {
+		c.Actions[ix] -= v
+	}
+	for ix, v := range from.IPReasons {
+		c.IPReasons[ix] -= v
+	}
+	for ix, v := range from.RTReasons {
+		c.RTReasons[ix] -= v
+	}
+	c.Evictions -= from.Evictions
+	c.SlipDowngrades -= from.SlipDowngrades
 }
 
 // IncrementDebit bumps all stats affected by a Debit call.
@@ -65,14 +92,41 @@ func (c *Stats) incrementDebit(act Action, ipr IPReason, rtr RTReason, ac Allowa
 	}
 }
 
+// incrementRequest bumps the stats affected by a DebitRequest call. Actions are only
+// counted for requests which will not proceed to DebitResponse.
+func (c *Stats) incrementRequest(act Action, ipr IPReason) {
+	if act != Send && act >= 0 && act < ActionLast {
+		c.Actions[act]++
+	}
+	if ipr >= 0 && ipr < IPLast {
+		c.IPReasons[ipr]++
+	}
+}
+
+// incrementResponse bumps the stats affected by a DebitResponse call.
+func (c *Stats) incrementResponse(act Action, rtr RTReason, ac AllowanceCategory) {
+	if act >= 0 && act < ActionLast {
+		c.Actions[act]++
+	}
+	if rtr >= 0 && rtr < RTLast {
+		c.RTReasons[rtr]++
+	}
+	if ac >= 0 && ac < AllowanceLast {
+		c.RPS[ac]++
+	}
+}
+
 func (c *Stats) String() string {
-	return fmt.Sprintf("RPS %d/%d/%d/%d/%d Actions %d/%d/%d IPR %d/%d/%d/%d/%d RTR %d/%d/%d/%d/%d/%d L=%d/%d",
+	return fmt.Sprintf("RPS %d/%d/%d/%d/%d/%d Actions %d/%d/%d IPR %d/%d/%d/%d/%d/%d/%d/%d RTR %d/%d/%d/%d/%d/%d/%d/%d/%d/%d L=%d/%d U=%d/%d SD=%d",
 		c.RPS[AllowanceAnswer], c.RPS[AllowanceReferral], c.RPS[AllowanceNoData], c.RPS[AllowanceNXDomain],
-		c.RPS[AllowanceError],
+		c.RPS[AllowanceError], c.RPS[AllowanceTransfer],
 		c.Actions[Send], c.Actions[Drop], c.Actions[Slip],
 		c.IPReasons[IPOk], c.IPReasons[IPNotConfigured], c.IPReasons[IPNotReached], c.IPReasons[IPRateLimit],
-		c.IPReasons[IPCacheFull],
+		c.IPReasons[IPCacheFull], c.IPReasons[IPSoftLimit], c.IPReasons[IPAggregateLimit],
+		c.IPReasons[IPMinimum],
 		c.RTReasons[RTOk], c.RTReasons[RTNotConfigured], c.RTReasons[RTNotReached], c.RTReasons[RTRateLimit],
-		c.RTReasons[RTNotUDP], c.RTReasons[RTCacheFull],
-		c.CacheLength, c.Evictions)
+		c.RTReasons[RTNotUDP], c.RTReasons[RTCacheFull], c.RTReasons[RTSoftLimit],
+		c.RTReasons[RTMinimum], c.RTReasons[RTDisabled], c.RTReasons[RTForced],
+		c.CacheLength, c.Evictions, c.ClientNetworks, c.SalientNames,
+		c.SlipDowngrades)
 }
@@ -26,21 +26,22 @@ func TestStatsBasics(t *testing.T) {
 	c := Stats{}
 
 	s := c.String()
-	exp := "RPS 0/0/0/0/0 Actions 0/0/0 IPR 0/0/0/0/0 RTR 0/0/0/0/0/0 L=0/0"
+	exp := "RPS 0/0/0/0/0/0 Actions 0/0/0 IPR 0/0/0/0/0/0/0/0 RTR 0/0/0/0/0/0/0/0/0/0 L=0/0 U=0/0 SD=0"
 	if s != exp {
 		t.Error("Zero stats expected", exp, "got", s)
 	}
 
 	c.incrementDebit(Send, IPOk, RTOk, AllowanceAnswer)
 	s = c.String()
-	exp = "RPS 1/0/0/0/0 Actions 1/0/0 IPR 1/0/0/0/0 RTR 1/0/0/0/0/0 L=0/0"
+	exp = "RPS 1/0/0/0/0/0 Actions 1/0/0 IPR 1/0/0/0/0/0/0/0 RTR 1/0/0/0/0/0/0/0/0/0 L=0/0 U=0/0 SD=0"
 	if s != exp {
 		t.Error("Non-zero stats expected", exp, "got", s)
 	}
 
 	c.incrementDebit(Slip, IPCacheFull, RTCacheFull, AllowanceError)
+	c.incrementDebit(Drop, IPOk, RTRateLimit, AllowanceTransfer)
 	s = c.String()
-	exp = "RPS 1/0/0/0/1 Actions 1/0/1 IPR 1/0/0/0/1 RTR 1/0/0/0/0/1 L=0/0"
+	exp = "RPS 1/0/0/0/1/1 Actions 1/1/1 IPR 2/0/0/0/1/0/0/0 RTR 1/0/0/1/0/1/0/0/0/0 L=0/0 U=0/0 SD=0"
 	if s != exp {
 		t.Error("Trailing non-zero stats expected", exp, "got", s)
 	}
@@ -57,7 +58,7 @@ func TestStatsBasics(t *testing.T) {
 
 	c.Copy(true)
 	s = c.String()
-	exp = "RPS 0/0/0/0/0 Actions 0/0/0 IPR 0/0/0/0/0 RTR 0/0/0/0/0/0 L=0/0"
+	exp = "RPS 0/0/0/0/0/0 Actions 0/0/0 IPR 0/0/0/0/0/0/0/0 RTR 0/0/0/0/0/0/0/0/0/0 L=0/0 U=0/0 SD=0"
 	if s != exp {
 		t.Error("Post-copy stats expected", exp, "got", s)
 	}
@@ -72,7 +73,7 @@ func TestStatsViaRRL(t *testing.T) {
 	R.Debit(src, newTuple(1, 1, "example.com.", AllowanceAnswer))
 	c := R.GetStats(true)
 	s := c.String()
-	exp := "RPS 1/0/0/0/0 Actions 1/0/0 IPR 1/0/0/0/0 RTR 1/0/0/0/0/0 L=2/0"
+	exp := "RPS 1/0/0/0/0/0 Actions 1/0/0 IPR 1/0/0/0/0/0/0/0 RTR 1/0/0/0/0/0/0/0/0/0 L=2/0 U=0/0 SD=0"
 	if s != exp {
 		t.Error("Non-zero stats expected", exp, "got", s)
 	}
@@ -81,7 +82,7 @@ func TestStatsViaRRL(t *testing.T) {
 	// always reflects the current value.
 	c = R.GetStats(true)
 	s = c.String()
-	exp = "RPS 0/0/0/0/0 Actions 0/0/0 IPR 0/0/0/0/0 RTR 0/0/0/0/0/0 L=2/0"
+	exp = "RPS 0/0/0/0/0/0 Actions 0/0/0 IPR 0/0/0/0/0/0/0/0 RTR 0/0/0/0/0/0/0/0/0/0 L=2/0 U=0/0 SD=0"
 	if s != exp {
 		t.Error("Zero stats expected", exp, "got", s)
 	}
@@ -128,13 +129,44 @@ func TestStatsAdd(t *testing.T) {
 	a.RTReasons[1] = 3
 	a.CacheLength = 4
 	a.Evictions = 5
+	a.SlipDowngrades = 8
 	b.Add(&a)
 	b.CacheLength = 0
 	b.Add(&a)
 
 	got := b.String()
-	exp := "RPS 2/0/0/0/0 Actions 0/12/14 IPR 0/0/4/0/0 RTR 0/6/0/0/0/0 L=4/10"
+	exp := "RPS 2/0/0/0/0/0 Actions 0/12/14 IPR 0/0/4/0/0/0/0/0 RTR 0/6/0/0/0/0/0/0/0/0 L=4/10 U=0/0 SD=16"
 	if got != exp {
 		t.Error("Exp", exp, "Got", got)
 	}
 }
+
+func TestGetStatsSince(t *testing.T) {
+	cfg := NewConfig()
+	cfg.SetValue("responses-per-second", "10")
+	R := NewRRL(cfg)
+	src := newAddr("udp", "10.0.0.1:53")
+	tuple := newTuple(1, 1, "example.com.", AllowanceAnswer)
+
+	R.Debit(src, tuple)
+	R.Debit(src, tuple)
+	if c := R.GetStatsSince("a"); c.RPS[AllowanceAnswer] != 2 || c.CacheLength != 1 {
+		t.Error("First call should return stats since creation", c.String())
+	}
+
+	R.Debit(src, tuple)
+	R.GetStats(true) // Must not disturb the cursors
+	R.Debit(src, tuple)
+	if c := R.GetStatsSince("a"); c.RPS[AllowanceAnswer] != 2 || c.Actions[Send] != 2 {
+		t.Error("Cursor a should see the two debits since its last call", c.String())
+	}
+	if c := R.GetStatsSince("b"); c.RPS[AllowanceAnswer] != 4 {
+		t.Error("Cursor b should be independent of cursor a", c.String())
+	}
+	if c := R.GetStatsSince("a"); c.RPS[AllowanceAnswer] != 0 {
+		t.Error("No debits since the last call of cursor a", c.String())
+	}
+	if c := R.GetStats(false); c.RPS[AllowanceAnswer] != 1 {
+		t.Error("GetStats should be unaffected by cursors", c.String())
+	}
+}
@@ -0,0 +1,160 @@
+package rrl
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// statsdPacketSize is the largest statsd datagram sent. It fits within the common
+// Ethernet MTU after IP and UDP headers.
+const statsdPacketSize = 1432
+
+// StatsdEmitter periodically sends the core Stats counters to a statsd server as UDP
+// datagrams. It is created by [RRL.EmitStatsd].
+type StatsdEmitter struct {
+	rrl    *RRL
+	conn   net.Conn
+	prefix string
+	cursor string // GetStatsSince cursor so emitted counters are deltas
+
+	stop   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+	errors atomic.Uint64
+}
+
+// EmitStatsd starts sending the Stats counters to the statsd server at addr, e.g.
+// "127.0.0.1:8125", every interval. Each send contains the change in each non-zero
+// counter since the previous send, as statsd counters ("|c"), and the current
+// CacheLength, ClientNetworks and SalientNames, as statsd gauges ("|g"). Metric names are
+// prefix, if not empty, followed by the counter and, for enumerated counters, the
+// String() value, e.g.:
+//
+//	rrl.actions.Drop:42|c
+//	rrl.responses.AllowanceNXDomain:17|c
+//	rrl.cache_length:1500|g
+//
+// Counters are obtained with [RRL.GetStatsSince] with a cursor private to the emitter so
+// other consumers of GetStats and GetStatsSince are unaffected. Counters are zero while a
+// [StatsSink] is registered. Multiple emitters may be active at the same time.
+//
+// An error is returned if addr cannot be resolved. As statsd is fire-and-forget, send
+// errors, such as no server listening, are only counted. The caller must call
+// [StatsdEmitter.Close] to stop the emitter.
+func (rrl *RRL) EmitStatsd(addr, prefix string, interval time.Duration) (*StatsdEmitter, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("rrl: statsd interval %s must be positive", interval)
+	}
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	se := &StatsdEmitter{rrl: rrl, conn: conn, prefix: prefix, stop: make(chan struct{}),
+		done: make(chan struct{})}
+	se.cursor = fmt.Sprintf("statsd/%p", se)
+	rrl.GetStatsSince(se.cursor) // Only counters from now on are sent
+	go se.run(interval)
+
+	return se, nil
+}
+
+// run sends the stats every interval until stopped, with a final send on the way out.
+func (se *StatsdEmitter) run(interval time.Duration) {
+	defer close(se.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			se.send()
+		case <-se.stop:
+			se.send()
+			return
+		}
+	}
+}
+
+// send sends the stats since the previous send packed into as few datagrams as possible.
+func (se *StatsdEmitter) send() {
+	stats := se.rrl.GetStatsSince(se.cursor)
+	var packet []byte
+	flush := func() {
+		if len(packet) > 0 {
+			if _, err := se.conn.Write(packet[:len(packet)-1]); err != nil { // Less trailing newline
+				se.errors.Add(1)
+			}
+			packet = packet[:0]
+		}
+	}
+	for _, line := range statsdLines(&stats, se.prefix) {
+		if len(packet)+len(line)+1 > statsdPacketSize {
+			flush()
+		}
+		packet = append(append(packet, line...), '\n')
+	}
+	flush()
+}
+
+// Errors returns the number of datagrams which could not be sent.
+func (se *StatsdEmitter) Errors() uint64 {
+	return se.errors.Load()
+}
+
+// Close stops the emitter after a final send of the counters accumulated since the
+// previous send. Close can be called multiple times.
+func (se *StatsdEmitter) Close() error {
+	var err error
+	se.once.Do(func() {
+		close(se.stop)
+		<-se.done
+		err = se.conn.Close()
+		se.rrl.statsMu.Lock()
+		delete(se.rrl.cursors, se.cursor)
+		se.rrl.statsMu.Unlock()
+	})
+
+	return err
+}
+
+// statsdLines returns the statsd lines of the non-zero counters and all gauges of c.
+func statsdLines(c *Stats, prefix string) (lines []string) {
+	if len(prefix) > 0 {
+		prefix += "."
+	}
+	counter := func(name string, value fmt.Stringer, v int64) {
+		if v == 0 {
+			return
+		}
+		if value != nil {
+			name += "." + value.String()
+		}
+		lines = append(lines, prefix+name+":"+strconv.FormatInt(v, 10)+"|c")
+	}
+	gauge := func(name string, v int64) {
+		lines = append(lines, prefix+name+":"+strconv.FormatInt(v, 10)+"|g")
+	}
+
+	for ac, v := range c.RPS {
+		counter("responses", AllowanceCategory(ac), v)
+	}
+	for act, v := range c.Actions {
+		counter("actions", Action(act), v)
+	}
+	for ipr, v := range c.IPReasons {
+		counter("ip_reasons", IPReason(ipr), v)
+	}
+	for rtr, v := range c.RTReasons {
+		counter("rt_reasons", RTReason(rtr), v)
+	}
+	counter("evictions", nil, c.Evictions)
+	counter("slip_downgrades", nil, c.SlipDowngrades)
+	gauge("cache_length", int64(c.CacheLength))
+	gauge("client_networks", c.ClientNetworks)
+	gauge("salient_names", c.SalientNames)
+
+	return
+}
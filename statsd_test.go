@@ -0,0 +1,73 @@
+package rrl
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEmitStatsd(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("Cannot listen on UDP", err)
+	}
+	defer pc.Close()
+
+	cfg := NewConfig()
+	cfg.SetValue("responses-per-second", "1")
+	cfg.SetValue("slip-ratio", "0")
+	R := NewRRL(cfg)
+	src := newAddr("udp", "10.0.0.1:53")
+	tuple := newTuple(1, 1, "example.com.", AllowanceAnswer)
+	R.Debit(src, tuple) // Before the emitter starts so not sent
+
+	if _, err := R.EmitStatsd(pc.LocalAddr().String(), "rrl", 0); err == nil {
+		t.Error("Expected an error for a zero interval")
+	}
+	se, err := R.EmitStatsd(pc.LocalAddr().String(), "rrl", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	R.Debit(src, tuple)
+	R.Debit(src, tuple)
+	if err := se.Close(); err != nil { // Sends the final counters
+		t.Error("Unexpected Close error", err)
+	}
+	se.Close()
+
+	buf := make([]byte, statsdPacketSize)
+	pc.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal("Expected a statsd packet", err)
+	}
+	lines := strings.Split(string(buf[:n]), "\n")
+	sort.Strings(lines)
+	exp := "rrl.actions.Drop:2|c rrl.cache_length:1|g rrl.client_networks:0|g " +
+		"rrl.ip_reasons.IPNotConfigured:2|c rrl.responses.AllowanceAnswer:2|c " +
+		"rrl.rt_reasons.RTRateLimit:2|c rrl.salient_names:0|g"
+	if got := strings.Join(lines, " "); got != exp {
+		t.Error("Unexpected statsd packet\nGot:", got, "\nExp:", exp)
+	}
+	if se.Errors() != 0 {
+		t.Error("Unexpected send errors", se.Errors())
+	}
+	if len(R.cursors) != 0 {
+		t.Error("Close should remove the cursor", R.cursors)
+	}
+
+	// Only non-zero counters are sent, but gauges always are
+	c := Stats{}
+	for ix := range c.RTReasons {
+		c.RTReasons[ix] = 1
+	}
+	lines = statsdLines(&c, "rrl")
+	if len(lines) != int(RTLast)+3 {
+		t.Error("Expected a line for each non-zero counter and gauge", lines)
+	}
+	if lines = statsdLines(&Stats{}, ""); lines[0] != "cache_length:0|g" {
+		t.Error("Empty prefix should not add a separator", lines)
+	}
+}
@@ -16,11 +16,33 @@ func (ac AllowanceCategory) String() string {
 		return "AllowanceNXDomain"
 	case AllowanceError:
 		return "AllowanceError"
+	case AllowanceTransfer:
+		return "AllowanceTransfer"
 	}
 
 	return fmt.Sprintf("Unstringable AllowanceCategory %d", ac)
 }
 
+// keyword returns the configuration keyword associated with the AllowanceCategory
+func (ac AllowanceCategory) keyword() string {
+	switch ac {
+	case AllowanceAnswer:
+		return "responses-per-second"
+	case AllowanceReferral:
+		return "referrals-per-second"
+	case AllowanceNoData:
+		return "nodata-per-second"
+	case AllowanceNXDomain:
+		return "nxdomains-per-second"
+	case AllowanceError:
+		return "errors-per-second"
+	case AllowanceTransfer:
+		return "transfers-per-second"
+	}
+
+	return fmt.Sprintf("unknown-category-%d", ac)
+}
+
 func (act Action) String() string {
 	switch act {
 	case Send:
@@ -46,6 +68,12 @@ func (ipr IPReason) String() string {
 		return "IPRateLimit"
 	case IPCacheFull:
 		return "IPCacheFull"
+	case IPSoftLimit:
+		return "IPSoftLimit"
+	case IPAggregateLimit:
+		return "IPAggregateLimit"
+	case IPMinimum:
+		return "IPMinimum"
 	}
 
 	return fmt.Sprintf("UnStringable IPReason %d", ipr)
@@ -65,6 +93,14 @@ func (rtr RTReason) String() string {
 		return "RTNotUDP"
 	case RTCacheFull:
 		return "RTCacheFull"
+	case RTSoftLimit:
+		return "RTSoftLimit"
+	case RTMinimum:
+		return "RTMinimum"
+	case RTDisabled:
+		return "RTDisabled"
+	case RTForced:
+		return "RTForced"
 	}
 
 	return fmt.Sprintf("UnStringable RTReason %d", rtr)
@@ -74,3 +110,58 @@ func (rt *ResponseTuple) String() string {
 	return fmt.Sprintf("%d/%d %s sn=%s",
 		rt.Class, rt.Type, rt.AllowanceCategory.String(), rt.SalientName)
 }
+
+func (kind AccountKind) String() string {
+	switch kind {
+	case AccountResponse:
+		return "AccountResponse"
+	case AccountRequests:
+		return "AccountRequests"
+	case AccountAggregate:
+		return "AccountAggregate"
+	case AccountMinimum:
+		return "AccountMinimum"
+	case AccountTCPRequests:
+		return "AccountTCPRequests"
+	}
+
+	return fmt.Sprintf("UnStringable AccountKind %d", kind)
+}
+
+func (kind EventKind) String() string {
+	switch kind {
+	case EventCreate:
+		return "EventCreate"
+	case EventLimit:
+		return "EventLimit"
+	case EventRecover:
+		return "EventRecover"
+	case EventDegraded:
+		return "EventDegraded"
+	case EventNormal:
+		return "EventNormal"
+	case EventOverloaded:
+		return "EventOverloaded"
+	case EventRelieved:
+		return "EventRelieved"
+	case EventForced:
+		return "EventForced"
+	case EventReleased:
+		return "EventReleased"
+	}
+
+	return fmt.Sprintf("UnStringable EventKind %d", kind)
+}
+
+func (key *AccountKey) String() string {
+	if key.Kind != AccountResponse {
+		return fmt.Sprintf("%s %s", key.Kind.String(), key.Network)
+	}
+	if key.Class != 0 {
+		return fmt.Sprintf("%s %s %d/%d %s sn=%s",
+			key.Kind.String(), key.Network, key.Class, key.Type, key.AllowanceCategory.String(),
+			key.SalientName)
+	}
+	return fmt.Sprintf("%s %s %d %s sn=%s",
+		key.Kind.String(), key.Network, key.Type, key.AllowanceCategory.String(), key.SalientName)
+}
@@ -0,0 +1,146 @@
+package rrl
+
+import (
+	"hash/maphash"
+	"math"
+	"math/bits"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	hllPrecision = 12 // 4096 registers gives a standard error of about 1.6%
+	hllRegisters = 1 << hllPrecision
+)
+
+// hll is a HyperLogLog cardinality estimator. Registers are updated atomically so adds
+// can occur concurrently without a lock.
+type hll struct {
+	regs [hllRegisters]uint32
+}
+
+// add records the hash of an item.
+func (h *hll) add(x uint64) {
+	ix := x >> (64 - hllPrecision)
+	rank := uint32(bits.LeadingZeros64(x<<hllPrecision|1<<(hllPrecision-1)) + 1)
+	reg := &h.regs[ix]
+	for {
+		old := atomic.LoadUint32(reg)
+		if rank <= old || atomic.CompareAndSwapUint32(reg, old, rank) {
+			return
+		}
+	}
+}
+
+// estimate returns the approximate cardinality of the union of all the supplied hlls.
+func estimate(hs ...*hll) int64 {
+	const m = float64(hllRegisters)
+	alpha := 0.7213 / (1 + 1.079/m)
+	sum := 0.0
+	zeros := 0
+	for ix := 0; ix < hllRegisters; ix++ {
+		var r uint32
+		for _, h := range hs {
+			if v := atomic.LoadUint32(&h.regs[ix]); v > r {
+				r = v
+			}
+		}
+		if r == 0 {
+			zeros++
+		}
+		sum += math.Ldexp(1, -int(r))
+	}
+	e := alpha * m * m / sum
+	if e <= 2.5*m && zeros > 0 { // Small range correction
+		e = m * math.Log(m/float64(zeros))
+	}
+
+	return int64(e + 0.5)
+}
+
+// uniques tracks the approximate number of distinct Client Networks and SalientNames seen
+// by Debit. Counts are kept for the current and previous windows so that estimates do
+// not fall to zero each time a new window starts.
+type uniques struct {
+	seed maphash.Seed
+
+	mu       sync.Mutex // Protects rotation
+	epoch    int64      // Window number of the current hlls
+	networks [2]atomic.Pointer[hll]
+	names    [2]atomic.Pointer[hll]
+}
+
+func newUniques() *uniques {
+	u := &uniques{seed: maphash.MakeSeed()}
+	for ix := range u.networks {
+		u.networks[ix].Store(&hll{})
+		u.names[ix].Store(&hll{})
+	}
+
+	return u
+}
+
+// rotate starts new hlls if the window has changed since the last rotation.
+func (u *uniques) rotate(epoch int64) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if epoch == u.epoch {
+		return
+	}
+	if epoch == u.epoch+1 {
+		u.networks[1].Store(u.networks[0].Load())
+		u.names[1].Store(u.names[0].Load())
+	} else {
+		u.networks[1].Store(&hll{}) // Previous window saw nothing
+		u.names[1].Store(&hll{})
+	}
+	u.networks[0].Store(&hll{})
+	u.names[0].Store(&hll{})
+	u.epoch = epoch
+}
+
+// epochOf returns the window number of now.
+func (rrl *RRL) epochOf(now int64) int64 {
+	return now / rrl.cfg.window
+}
+
+// addNetwork records a Client Network if unique tracking is configured.
+func (rrl *RRL) addNetwork(ipPrefix string) {
+	if rrl.uniques == nil {
+		return
+	}
+	rrl.rotateUniques()
+	rrl.uniques.networks[0].Load().add(maphash.String(rrl.uniques.seed, ipPrefix))
+}
+
+// addName records a SalientName if unique tracking is configured.
+func (rrl *RRL) addName(name string) {
+	if rrl.uniques == nil {
+		return
+	}
+	rrl.rotateUniques()
+	name = rrl.lowerName(name)
+	rrl.uniques.names[0].Load().add(maphash.String(rrl.uniques.seed, name))
+}
+
+func (rrl *RRL) rotateUniques() {
+	epoch := rrl.epochOf(rrl.now())
+	if epoch != atomic.LoadInt64(&rrl.uniquesEpoch) {
+		rrl.uniques.rotate(epoch)
+		atomic.StoreInt64(&rrl.uniquesEpoch, epoch)
+	}
+}
+
+// uniqueCounts returns the estimated number of distinct Client Networks and SalientNames
+// seen in the current and previous windows.
+func (rrl *RRL) uniqueCounts() (networks, names int64) {
+	if rrl.uniques == nil {
+		return
+	}
+	rrl.rotateUniques()
+	u := rrl.uniques
+	networks = estimate(u.networks[0].Load(), u.networks[1].Load())
+	names = estimate(u.names[0].Load(), u.names[1].Load())
+
+	return
+}
@@ -0,0 +1,59 @@
+package rrl
+
+import (
+	"fmt"
+	"hash/maphash"
+	"testing"
+	"time"
+)
+
+func TestHLLEstimate(t *testing.T) {
+	seed := maphash.MakeSeed()
+	for _, n := range []int{0, 1, 10, 1000, 100000} {
+		h := &hll{}
+		for ix := 0; ix < n; ix++ {
+			h.add(maphash.String(seed, fmt.Sprintf("item-%d", ix)))
+			h.add(maphash.String(seed, fmt.Sprintf("item-%d", ix))) // Duplicates shouldn't count
+		}
+		e := estimate(h)
+		diff := float64(e-int64(n)) / float64(n+1)
+		if (diff < -0.05 || diff > 0.05) && (e < int64(n)-1 || e > int64(n)+1) { // Small counts may be off by one
+			t.Error("Estimate of", n, "is more than 5% out", e)
+		}
+	}
+}
+
+func TestUniquesViaRRL(t *testing.T) {
+	cfg := NewConfig()
+	cfg.SetValue("track-uniques", "true")
+	clock := time.Now()
+	cfg.SetNowFunc(func() time.Time {
+		return clock
+	})
+	R := NewRRL(cfg)
+
+	for ix := 0; ix < 1000; ix++ {
+		src := newAddr("udp", fmt.Sprintf("10.%d.%d.1:53", ix/256, ix%256))
+		R.Debit(src, newTuple(1, 1, fmt.Sprintf("%d.EXAMPLE.com.", ix%500), AllowanceAnswer))
+		R.Debit(src, newTuple(1, 1, fmt.Sprintf("%d.example.com.", ix%500), AllowanceAnswer))
+	}
+	c := R.GetStats(false)
+	if c.ClientNetworks < 950 || c.ClientNetworks > 1050 {
+		t.Error("Expected about 1000 client networks, got", c.ClientNetworks)
+	}
+	if c.SalientNames < 475 || c.SalientNames > 525 {
+		t.Error("Expected about 500 salient names, got", c.SalientNames)
+	}
+
+	clock = clock.Add(15 * time.Second) // Previous window should still count
+	c = R.GetStats(false)
+	if c.ClientNetworks < 950 {
+		t.Error("Expected previous window to still count, got", c.ClientNetworks)
+	}
+
+	clock = clock.Add(30 * time.Second) // But not once two windows have passed
+	c = R.GetStats(false)
+	if c.ClientNetworks != 0 || c.SalientNames != 0 {
+		t.Error("Expected gauges to return to zero, got", c.String())
+	}
+}
@@ -0,0 +1,85 @@
+package rrl
+
+import (
+	"net"
+	"net/netip"
+	"sync/atomic"
+)
+
+// initValidators creates the table of validator reputations if validator-boost is
+// configured. The table is sized to match the account table as there is at most one
+// reputation per Client Network.
+func (rrl *RRL) initValidators() {
+	if rrl.cfg.validatorBoost <= 1 {
+		return
+	}
+	rrl.validators = rrl.newCache(rrl.cfg.maxTableSize)
+	rrl.validators.SetEvict(func(el interface{}) bool { return el.(*atomic.Int64).Load() <= rrl.now() })
+}
+
+// ReportValidator grants the Client Network of src a validator reputation for
+// validator-reputation-age, during which its request and response rates are multiplied
+// by validator-boost. Each report renews the reputation.
+//
+// Callers should report a client once it has completed the DNSKEY and DS follow-up
+// queries which a DNSSEC validating resolver makes after receiving a signed answer. Such
+// behaviour is strong evidence of a real resolver rather than a spoofed source being
+// used as a reflector, as reflection attacks gain nothing from the follow-up queries.
+// As the follow-up queries can themselves be spoofed, only follow-ups which demonstrate
+// a round trip, such as those over TCP or with a valid server cookie, should be
+// reported.
+//
+// Reputations have no effect on penalized Client Networks and never apply to the ipv6
+// aggregate network as it is shared with other Client Networks.
+//
+// ReportValidator returns false if validator-boost is not configured, src is not an IP
+// address or the table of reputations is full of current reputations. ReportValidator is
+// concurrency safe.
+func (rrl *RRL) ReportValidator(src net.Addr) bool {
+	if rrl.validators == nil {
+		return false
+	}
+	ipPrefix := rrl.addrPrefix(src.String())
+	if _, err := netip.ParseAddr(ipPrefix); err != nil {
+		return false
+	}
+	until := rrl.now() + rrl.cfg.validatorAge
+	err := rrl.validators.UpdateAdd(ipPrefix,
+		func(el interface{}) interface{} {
+			el.(*atomic.Int64).Store(until)
+			return nil
+		},
+		func() interface{} {
+			v := &atomic.Int64{}
+			v.Store(until)
+			return v
+		})
+
+	return err == nil
+}
+
+// IsValidator returns true if the Client Network of src currently has a validator
+// reputation granted by [RRL.ReportValidator].
+func (rrl *RRL) IsValidator(src net.Addr) bool {
+	return rrl.validator(rrl.addrPrefix(src.String()))
+}
+
+// validator returns true if the Client Network has a current validator reputation.
+func (rrl *RRL) validator(ipPrefix string) bool {
+	if rrl.validators == nil {
+		return false
+	}
+	el, found := rrl.validators.Get(ipPrefix)
+
+	return found && rrl.now() < el.(*atomic.Int64).Load()
+}
+
+// applyReputation returns the allowance reduced by validator-boost if the Client Network
+// has a validator reputation.
+func (rrl *RRL) applyReputation(allowance int64, ipPrefix string) int64 {
+	if !rrl.validator(ipPrefix) {
+		return allowance
+	}
+
+	return int64(float64(allowance) / rrl.cfg.validatorBoost)
+}
@@ -0,0 +1,98 @@
+package rrl
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestReportValidator(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cfg := NewConfig()
+	cfg.SetValue("responses-per-second", "1")
+	cfg.SetValue("requests-per-second", "2")
+	cfg.SetValue("slip-ratio", "0")
+	cfg.SetNowFunc(func() time.Time { return now })
+	src := newAddr("udp", "10.0.0.1:53")
+	if R := NewRRL(cfg); R.ReportValidator(src) || R.IsValidator(src) {
+		t.Error("Reputations should not be granted when not configured")
+	}
+
+	cfg.SetValue("validator-boost", "4")
+	cfg.SetValue("validator-reputation-age", "10")
+	R := NewRRL(cfg)
+	if R.ReportValidator(newAddr("unix", "/tmp/socket")) {
+		t.Error("Non-IP sources should not be granted a reputation")
+	}
+	if !R.ReportValidator(newAddr("tcp", "10.0.0.2:53")) {
+		t.Fatal("Reputation should be granted")
+	}
+	if !R.IsValidator(src) || R.IsValidator(newAddr("udp", "10.0.1.1:53")) {
+		t.Error("Only the reported Client Network should have a reputation")
+	}
+
+	// Four times the rate within the same second
+	sent := func(src *addr) (count int) {
+		tuple := newTuple(1, 1, "example.com.", AllowanceAnswer)
+		for ix := 0; ix < 10; ix++ {
+			if act, _, _ := R.Debit(src, tuple); act == Send {
+				count++
+			}
+		}
+		return
+	}
+	if count := sent(src); count != 4 {
+		t.Error("Validator should be sent 4 responses, not", count)
+	}
+	if count := sent(newAddr("udp", "10.0.1.1:53")); count != 1 {
+		t.Error("Other networks should be sent 1 response, not", count)
+	}
+
+	// Penalties take precedence
+	R.Penalize(netip.MustParsePrefix("10.0.0.0/24"), time.Minute)
+	now = now.Add(2 * time.Second)
+	if count := sent(src); count != 0 {
+		t.Error("Penalized validator should be sent nothing, not", count)
+	}
+	R.Penalize(netip.MustParsePrefix("10.0.0.0/24"), 0)
+
+	// Reputations expire. Penalized accounts recover from -window
+	now = now.Add(20 * time.Second)
+	if R.IsValidator(src) {
+		t.Error("Reputation should have expired")
+	}
+	if count := sent(src); count != 1 {
+		t.Error("Expired validator should be sent 1 response, not", count)
+	}
+}
+
+// CheapCheck and Check apply reputations in the same way as Debit
+func TestValidatorCheapCheck(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cfg := NewConfig()
+	cfg.SetValue("responses-per-second", "100")
+	cfg.SetValue("requests-per-second", "1")
+	cfg.SetValue("validator-boost", "4")
+	cfg.SetNowFunc(func() time.Time { return now })
+	R := NewRRL(cfg)
+
+	src := newAddr("udp", "10.0.0.1:53")
+	other := newAddr("udp", "10.0.1.1:53")
+	if !R.ReportValidator(src) {
+		t.Fatal("Reputation should be granted")
+	}
+	tuple := newTuple(1, 1, "example.com.", AllowanceAnswer)
+	for ix := 0; ix < 3; ix++ { // Beyond the unboosted allowance
+		R.Debit(src, tuple)
+		R.Debit(other, tuple)
+	}
+	if act := R.CheapCheck(src); act != Send {
+		t.Error("CheapCheck should apply the reputation of the validator", act)
+	}
+	if act, ipr, _ := R.Check(src, tuple); act != Send {
+		t.Error("Check should apply the reputation of the validator", act, ipr)
+	}
+	if act := R.CheapCheck(other); act != Drop {
+		t.Error("CheapCheck should drop other networks", act)
+	}
+}
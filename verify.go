@@ -0,0 +1,116 @@
+package rrl
+
+import (
+	"fmt"
+	"hash/fnv"
+	"time"
+)
+
+// verifySlack allows for rounding in the exponential recovery curve when comparing a
+// balance with its bounds.
+const verifySlack = int64(time.Millisecond)
+
+// Verification is the result of [RRL.Verify].
+type Verification struct {
+	Accounts  int       // Number of accounts examined
+	Anomalies []Anomaly // Accounts which violate an invariant
+
+	// Checksum is an order independent hash of the tokens of all accounts examined. Two
+	// RRLs holding the same set of accounts, such as before and after a state transfer,
+	// have the same Checksum.
+	Checksum uint64
+
+	// Partial is true if inspection-budget was exhausted before all accounts were
+	// examined.
+	Partial bool
+}
+
+// Anomaly describes an account which violates an invariant of the account table.
+type Anomaly struct {
+	Key     AccountKey
+	Balance time.Duration
+	Problem string
+}
+
+// Verify walks all accounts checking that:
+//
+//   - the internal token of each account is well-formed and consistent with its
+//     AccountKey and AllowanceCategory, and the account is in the table for its category
+//   - the balance is no more negative than the window of the account
+//   - an account which was in credit at its most recent debit still has a positive balance
+//   - the slip countdown does not exceed slip-ratio
+//   - the recovery curve is the configured curve for the account
+//   - the account was not created in the future
+//
+// Verify is intended for use after a state transfer with [RRL.PrimeFrom], live migration
+// or when memory corruption is suspected on long-lived servers. An RRL which has only
+// been updated by debits never has anomalies. Verify is subject to
+// max-inspections-per-second and inspection-budget.
+//
+// Verify is concurrency safe.
+func (rrl *RRL) Verify() *Verification {
+	v := &Verification{}
+	var now int64
+	complete := rrl.inspect(func(t string, ra *responseAccount, balance int64) bool {
+		if now == 0 {
+			now = rrl.now()
+		}
+		v.Accounts++
+		h := fnv.New64a()
+		h.Write([]byte(t))
+		v.Checksum += h.Sum64()
+
+		key := parseAccountKey(t)
+		for _, problem := range rrl.verifyAccount(t, &key, ra, balance, now) {
+			v.Anomalies = append(v.Anomalies, Anomaly{Key: key, Balance: time.Duration(balance), Problem: problem})
+		}
+		return true
+	})
+	v.Partial = !complete
+
+	return v
+}
+
+// verifyAccount returns the invariants violated by the account.
+func (rrl *RRL) verifyAccount(t string, key *AccountKey, ra *responseAccount, balance, now int64) (problems []string) {
+	window, curve := rrl.cfg.window, rrl.cfg.recovery
+	table := rrl.table
+	switch key.Kind {
+	case AccountResponse:
+		ac := key.AllowanceCategory
+		if ac >= AllowanceLast {
+			return []string{fmt.Sprintf("invalid AllowanceCategory %d", ac)}
+		}
+		window, curve, table = rrl.windowFor(ac), rrl.recoveryFor(ac), rrl.tableFor(ac)
+	case AccountMinimum:
+		curve = recoveryLinear
+	}
+
+	if key.token(rrl) != t {
+		problems = append(problems, fmt.Sprintf("token '%s' is inconsistent with its key", t))
+	}
+	for _, other := range rrl.distinctTables() {
+		if _, found := other.Get(t); found && other != table {
+			problems = append(problems, "account is not in the table of its category")
+		}
+	}
+	if balance < -window-verifySlack {
+		problems = append(problems, fmt.Sprintf("balance is more negative than the window of %s",
+			time.Duration(window)))
+	}
+	if !ra.limited && balance < -verifySlack {
+		problems = append(problems, "account in credit at its last debit has a negative balance")
+	}
+	if ra.slipCountdown > rrl.cfg.slipRatio {
+		problems = append(problems, fmt.Sprintf("slip countdown %d exceeds slip-ratio %d",
+			ra.slipCountdown, rrl.cfg.slipRatio))
+	}
+	if ra.curve != curve {
+		problems = append(problems, fmt.Sprintf("recovery curve is %s not %s", ra.curve, curve))
+	}
+	if ra.created > now {
+		problems = append(problems, "account was created in the future")
+	}
+
+	return
+}
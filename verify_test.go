@@ -0,0 +1,95 @@
+package rrl
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1000, 0)
+	newR := func() *RRL {
+		cfg := NewConfig()
+		cfg.SetValue("responses-per-second", "1")
+		cfg.SetValue("requests-per-second", "1")
+		cfg.SetValue("minimum-responses-per-second", "0.5")
+		cfg.SetValue("nxdomains-recovery", "exponential")
+		cfg.SetValue("nxdomains-table-size", "4096")
+		cfg.SetNowFunc(func() time.Time { return now })
+		return NewRRL(cfg)
+	}
+
+	R1, R2 := newR(), newR()
+	src := newAddr("udp", "10.0.0.1:53")
+	for _, R := range []*RRL{R1, R2} {
+		for ix := 0; ix < 40; ix++ {
+			R.Debit(src, newTuple(1, 1, "example.com.", AllowanceAnswer))
+			R.Debit(src, newTuple(1, 1, "nx.example.com.", AllowanceNXDomain))
+			R.Debit(src, newTuple(3, 16, "ch.example.com.", AllowanceError))
+		}
+	}
+	v := R1.Verify()
+	if v.Accounts != R1.tableLen() || len(v.Anomalies) != 0 || v.Partial {
+		t.Fatal("Debited accounts should have no anomalies", v)
+	}
+	if v2 := R2.Verify(); v2.Checksum != v.Checksum {
+		t.Error("Identical tables should have identical checksums", v.Checksum, v2.Checksum)
+	}
+	R2.Debit(newAddr("udp", "10.0.1.1:53"), newTuple(1, 1, "example.com.", AllowanceAnswer))
+	if v2 := R2.Verify(); v2.Checksum == v.Checksum {
+		t.Error("Different tables should have different checksums")
+	}
+
+	// Corrupt accounts directly
+	window := R1.cfg.window
+	account := func(table interface {
+		Get(string) (interface{}, bool)
+	}, tok string) *responseAccount {
+		el, found := table.Get(tok)
+		if !found {
+			t.Fatal("Account should exist", tok)
+		}
+		return el.(*responseAccount)
+	}
+	nowNS := now.UnixNano()
+	answer := account(R1.table, R1.accountToken("10.0.0.0", 1, 1, "example.com.", AllowanceAnswer))
+	answer.slipCountdown = 99
+	answer.allowTime = nowNS + 2*window
+	answer.limited = true
+	requests := account(R1.table, "10.0.0.0")
+	requests.curve = recoveryExponential
+	requests.created = nowNS + 1
+	minimum := account(R1.table, "10.0.0.0/min")
+	minimum.limited = false
+	minimum.allowTime = nowNS + int64(time.Second)
+
+	// And put an NXDomain account in the main table along with a malformed token
+	R1.table.UpdateAdd(R1.accountToken("10.0.0.0", 1, 1, "other.example.com.", AllowanceNXDomain),
+		nil, func() interface{} { return &responseAccount{allowTime: nowNS, curve: recoveryExponential} })
+	R1.table.UpdateAdd("10.0.0.0/9/1/x.", nil, func() interface{} { return &responseAccount{allowTime: nowNS} })
+
+	var got []string
+	for _, a := range R1.Verify().Anomalies {
+		got = append(got, a.Key.String()+" "+a.Problem)
+	}
+	for _, exp := range []string{
+		"slip countdown 99 exceeds slip-ratio 2",
+		"balance is more negative than the window",
+		"recovery curve is exponential not linear",
+		"created in the future",
+		"in credit at its last debit has a negative balance",
+		"not in the table of its category",
+		"invalid AllowanceCategory 9",
+	} {
+		found := false
+		for _, g := range got {
+			found = found || strings.Contains(g, exp)
+		}
+		if !found {
+			t.Error("Expected anomaly", exp, "in", got)
+		}
+	}
+	if len(got) != 7 {
+		t.Error("Expected exactly 7 anomalies, not", len(got), got)
+	}
+}
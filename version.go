@@ -0,0 +1,54 @@
+package rrl
+
+import (
+	"runtime/debug"
+	"sort"
+)
+
+const modulePath = "github.com/markdingo/rrl"
+
+// releaseVersion is the most recent release in ChangeLog.md. It is only reported if the
+// build does not record the module version, such as when built within this module.
+const releaseVersion = "v1.0.0"
+
+// features lists the optional subsystems compiled into this build. Each is appended by
+// an init function in the file which implements it.
+var features []string
+
+// Version returns the version of this package as recorded by the go tool when the
+// importing binary was built, e.g. "v1.2.3" or a pseudo-version. If the build does not
+// record a version, the most recent release version is returned.
+func Version() string {
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		mods := append([]*debug.Module{&bi.Main}, bi.Deps...)
+		for _, m := range mods {
+			if m.Path != modulePath {
+				continue
+			}
+			if m.Replace != nil {
+				m = m.Replace
+			}
+			if m.Version != "" && m.Version != "(devel)" {
+				return m.Version
+			}
+		}
+	}
+
+	return releaseVersion
+}
+
+// Features returns the sorted names of the optional subsystems compiled into this build
+// of the package. Fleet tooling can compare Features across servers built with different
+// build tags or toolchains to verify that they offer the same capabilities. The possible
+// features are:
+//
+//   - "faults" if built with the "rrlfaults" build tag, which provides InjectFaults
+//   - "iterators" if built with Go 1.23 or later, see [RRL.Accounts]
+//
+// Subsystems which are always present, such as OpenMetrics output, are not listed.
+func Features() []string {
+	fs := append([]string{}, features...)
+	sort.Strings(fs)
+
+	return fs
+}
@@ -0,0 +1,26 @@
+package rrl_test
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/markdingo/rrl"
+)
+
+func TestVersion(t *testing.T) {
+	if v := rrl.Version(); !strings.HasPrefix(v, "v") {
+		t.Error("Version should be a module version, not", v)
+	}
+
+	fs := rrl.Features()
+	if !sort.StringsAreSorted(fs) {
+		t.Error("Features should be sorted", fs)
+	}
+	if len(fs) > 0 {
+		fs[0] = "modified"
+		if rrl.Features()[0] == "modified" {
+			t.Error("Features should return a copy")
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package xdp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"os/exec"
+)
+
+// KeyFunc converts a network into the key bytes of the operator's eBPF map. It returns
+// false if the network is not applicable to the map, such as an ipv6 network for a map
+// which only holds ipv4 keys.
+type KeyFunc func(p netip.Prefix) ([]byte, bool)
+
+// LPMv4 is a KeyFunc for BPF_MAP_TYPE_LPM_TRIE maps with a struct bpf_lpm_trie_key
+// holding an ipv4 address. The prefix length is in little-endian (host) order and the
+// address is in network order.
+func LPMv4(p netip.Prefix) ([]byte, bool) {
+	if !p.Addr().Is4() {
+		return nil, false
+	}
+	return lpmKey(p), true
+}
+
+// LPMv6 is a KeyFunc for BPF_MAP_TYPE_LPM_TRIE maps with a struct bpf_lpm_trie_key
+// holding an ipv6 address. The prefix length is in little-endian (host) order and the
+// address is in network order.
+func LPMv6(p netip.Prefix) ([]byte, bool) {
+	if !p.Addr().Is6() {
+		return nil, false
+	}
+	return lpmKey(p), true
+}
+
+func lpmKey(p netip.Prefix) []byte {
+	key := binary.LittleEndian.AppendUint32(nil, uint32(p.Bits()))
+	return append(key, p.Masked().Addr().AsSlice()...)
+}
+
+// BpftoolWriter is a [MapWriter] which updates a pinned eBPF map by running bpftool.
+type BpftoolWriter struct {
+	Path    string  // Path of the pinned map, e.g. /sys/fs/bpf/rrl_drop
+	Key     KeyFunc // Converts networks to map keys, e.g. LPMv4
+	Value   []byte  // Value stored for each key. Defaults to a single 1 byte
+	Command string  // Defaults to "bpftool"
+}
+
+// Update inserts or replaces p in the map. Networks not applicable to the map are
+// silently ignored.
+func (bw *BpftoolWriter) Update(p netip.Prefix) error {
+	args, ok := bw.args("update", p)
+	if !ok {
+		return nil
+	}
+	return bw.run(args)
+}
+
+// Delete removes p from the map. Networks not applicable to the map are silently
+// ignored.
+func (bw *BpftoolWriter) Delete(p netip.Prefix) error {
+	args, ok := bw.args("delete", p)
+	if !ok {
+		return nil
+	}
+	return bw.run(args)
+}
+
+// args returns the bpftool arguments for the map operation on p.
+func (bw *BpftoolWriter) args(op string, p netip.Prefix) ([]string, bool) {
+	key, ok := bw.Key(p)
+	if !ok {
+		return nil, false
+	}
+	args := []string{"map", op, "pinned", bw.Path, "key", "hex"}
+	args = appendHex(args, key)
+	if op == "update" {
+		value := bw.Value
+		if len(value) == 0 {
+			value = []byte{1}
+		}
+		args = append(args, "value", "hex")
+		args = appendHex(args, value)
+	}
+
+	return args, true
+}
+
+func (bw *BpftoolWriter) run(args []string) error {
+	command := bw.Command
+	if len(command) == 0 {
+		command = "bpftool"
+	}
+	out, err := exec.Command(command, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %v: %w: %s", command, args, err, out)
+	}
+
+	return nil
+}
+
+func appendHex(args []string, b []byte) []string {
+	for _, c := range b {
+		args = append(args, fmt.Sprintf("%02x", c))
+	}
+	return args
+}
@@ -0,0 +1,167 @@
+/*
+Package xdp exports heavily rate-limited Client Networks from an [rrl.RRL] into a pinned
+eBPF map so that an XDP program can drop floods before they reach userspace.
+
+The [Exporter] periodically asks the RRL for heavily limited networks and passes new
+networks to a [MapWriter] for insertion into the map. Networks remain in the map for a
+hold-down period after they were last seen heavily limited and are then automatically
+removed.
+
+This package does not load or attach any eBPF programs, nor does it make any bpf(2)
+system calls itself. The default [BpftoolWriter] invokes the operator's bpftool with the
+pinned map path and key format supplied by the operator. Operators who prefer a native
+eBPF library can supply their own [MapWriter].
+*/
+package xdp
+
+import (
+	"context"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/markdingo/rrl"
+)
+
+// MapWriter is implemented by the writers of the eBPF map.
+type MapWriter interface {
+	Update(p netip.Prefix) error // Insert or replace p in the map
+	Delete(p netip.Prefix) error // Remove p from the map
+}
+
+// Config contains the settings for an [Exporter]. Zero values are replaced with the
+// defaults.
+type Config struct {
+	// Threshold is how far below zero an account balance must be for its network to
+	// be exported. Default 5s.
+	Threshold time.Duration
+
+	// HoldDown is how long a network remains in the map after it was last seen heavily
+	// limited. Default 60s.
+	HoldDown time.Duration
+
+	// Interval is how often [Exporter.Run] calls [Exporter.Sync]. Default 1s.
+	Interval time.Duration
+}
+
+const (
+	defaultThreshold = 5 * time.Second
+	defaultHoldDown  = 60 * time.Second
+	defaultInterval  = time.Second
+)
+
+// Exporter synchronizes the heavily limited networks of an [rrl.RRL] with a [MapWriter].
+// An Exporter is safe for concurrent use by multiple goroutines.
+type Exporter struct {
+	r   *rrl.RRL
+	w   MapWriter
+	cfg Config
+
+	mu       sync.Mutex
+	exported map[netip.Prefix]time.Time // When each network was last seen heavily limited
+	nowFunc  func() time.Time
+}
+
+// New creates an Exporter which writes the heavily limited networks of r to w.
+func New(r *rrl.RRL, w MapWriter, cfg Config) *Exporter {
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = defaultThreshold
+	}
+	if cfg.HoldDown <= 0 {
+		cfg.HoldDown = defaultHoldDown
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+
+	return &Exporter{r: r, w: w, cfg: cfg,
+		exported: make(map[netip.Prefix]time.Time),
+		nowFunc:  time.Now,
+	}
+}
+
+// Exported returns the number of networks currently exported to the map.
+func (e *Exporter) Exported() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return len(e.exported)
+}
+
+// Sync performs a single synchronization pass. Newly limited networks are added to the
+// map and networks which have not been heavily limited for the hold-down period are
+// removed. The first error returned by the MapWriter is returned after the pass
+// completes. Networks which fail to be added are retried on the next pass.
+func (e *Exporter) Sync() error {
+	limited := e.r.LimitedNetworks(e.cfg.Threshold)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := e.nowFunc()
+
+	var firstErr error
+	for _, p := range limited {
+		if _, ok := e.exported[p]; !ok {
+			if err := e.w.Update(p); err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+		}
+		e.exported[p] = now
+	}
+
+	for p, last := range e.exported {
+		if now.Sub(last) < e.cfg.HoldDown {
+			continue
+		}
+		if err := e.w.Delete(p); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		delete(e.exported, p)
+	}
+
+	return firstErr
+}
+
+// Flush removes all exported networks from the map regardless of hold-down. It is
+// normally called when the embedding server shuts down.
+func (e *Exporter) Flush() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var firstErr error
+	for p := range e.exported {
+		if err := e.w.Delete(p); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		delete(e.exported, p)
+	}
+
+	return firstErr
+}
+
+// Run calls [Exporter.Sync] every Config.Interval until ctx is done, at which point
+// [Exporter.Flush] is called. Errors from Sync are passed to errFn if it is not nil,
+// otherwise they are ignored. Run returns the result of Flush.
+func (e *Exporter) Run(ctx context.Context, errFn func(error)) error {
+	ticker := time.NewTicker(e.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return e.Flush()
+		case <-ticker.C:
+			if err := e.Sync(); err != nil && errFn != nil {
+				errFn(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package xdp
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/markdingo/rrl"
+)
+
+// fakeWriter is a MapWriter which records the map contents
+type fakeWriter struct {
+	m      map[netip.Prefix]bool
+	fail   bool
+	writes int
+}
+
+func (fw *fakeWriter) Update(p netip.Prefix) error {
+	fw.writes++
+	if fw.fail {
+		return errors.New("update failed")
+	}
+	fw.m[p] = true
+	return nil
+}
+
+func (fw *fakeWriter) Delete(p netip.Prefix) error {
+	fw.writes++
+	if fw.fail {
+		return errors.New("delete failed")
+	}
+	delete(fw.m, p)
+	return nil
+}
+
+func TestExporter(t *testing.T) {
+	cfg := rrl.NewConfig()
+	cfg.SetValue("responses-per-second", "1")
+	cfg.SetNowFunc(func() time.Time { return time.Time{} })
+	R := rrl.NewRRL(cfg)
+
+	tuple := &rrl.ResponseTuple{Class: 1, Type: 1, AllowanceCategory: rrl.AllowanceAnswer,
+		SalientName: "example.com."}
+	src := &net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 53}
+	for ix := 0; ix < 10; ix++ {
+		R.Debit(src, tuple)
+	}
+
+	fw := &fakeWriter{m: make(map[netip.Prefix]bool)}
+	e := New(R, fw, Config{Threshold: 3 * time.Second, HoldDown: 10 * time.Second})
+	now := time.Unix(1000, 0)
+	e.nowFunc = func() time.Time { return now }
+
+	fw.fail = true
+	if err := e.Sync(); err == nil {
+		t.Error("Expected Sync to return MapWriter error")
+	}
+	if e.Exported() != 0 {
+		t.Error("Failed update should not be exported", e.Exported())
+	}
+
+	fw.fail = false
+	if err := e.Sync(); err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	exp := netip.MustParsePrefix("192.0.2.0/24")
+	if !fw.m[exp] || len(fw.m) != 1 || e.Exported() != 1 {
+		t.Fatal("Expected", exp, "in map, got", fw.m)
+	}
+
+	writes := fw.writes
+	now = now.Add(time.Second)
+	e.Sync() // Still limited so no new writes
+	if fw.writes != writes {
+		t.Error("Expected no writes for already exported network", fw.writes, writes)
+	}
+
+	// Network remains limited because the RRL clock is frozen, so the hold-down
+	// timer keeps being refreshed. Switch to a higher threshold so it no longer qualifies.
+	e.cfg.Threshold = time.Minute
+	now = now.Add(9 * time.Second)
+	e.Sync()
+	if len(fw.m) != 1 {
+		t.Error("Network removed before hold-down expired", fw.m)
+	}
+	now = now.Add(2 * time.Second)
+	e.Sync()
+	if len(fw.m) != 0 || e.Exported() != 0 {
+		t.Error("Network not removed after hold-down expired", fw.m)
+	}
+}
+
+func TestExporterFlush(t *testing.T) {
+	cfg := rrl.NewConfig()
+	cfg.SetValue("responses-per-second", "1")
+	cfg.SetNowFunc(func() time.Time { return time.Time{} })
+	R := rrl.NewRRL(cfg)
+
+	tuple := &rrl.ResponseTuple{Class: 1, Type: 1, AllowanceCategory: rrl.AllowanceAnswer,
+		SalientName: "example.com."}
+	src := &net.UDPAddr{IP: net.ParseIP("2001:db8::1"), Port: 53}
+	for ix := 0; ix < 10; ix++ {
+		R.Debit(src, tuple)
+	}
+
+	fw := &fakeWriter{m: make(map[netip.Prefix]bool)}
+	e := New(R, fw, Config{})
+	e.Sync()
+	if len(fw.m) != 1 {
+		t.Fatal("Expected one exported network, got", fw.m)
+	}
+	if err := e.Flush(); err != nil {
+		t.Error("Unexpected Flush error", err)
+	}
+	if len(fw.m) != 0 || e.Exported() != 0 {
+		t.Error("Flush did not remove all networks", fw.m)
+	}
+}
+
+func TestLPM(t *testing.T) {
+	v4 := netip.MustParsePrefix("192.0.2.0/24")
+	v6 := netip.MustParsePrefix("2001:db8::/48")
+
+	key, ok := LPMv4(v4)
+	if !ok || len(key) != 8 || key[0] != 24 || key[4] != 192 || key[6] != 2 {
+		t.Error("LPMv4 gave wrong key", key, ok)
+	}
+	if _, ok = LPMv4(v6); ok {
+		t.Error("LPMv4 should reject ipv6")
+	}
+	key, ok = LPMv6(v6)
+	if !ok || len(key) != 20 || key[0] != 48 || key[4] != 0x20 || key[5] != 0x01 {
+		t.Error("LPMv6 gave wrong key", key, ok)
+	}
+	if _, ok = LPMv6(v4); ok {
+		t.Error("LPMv6 should reject ipv4")
+	}
+}
+
+func TestBpftoolArgs(t *testing.T) {
+	bw := &BpftoolWriter{Path: "/sys/fs/bpf/rrl", Key: LPMv4}
+	p := netip.MustParsePrefix("192.0.2.0/24")
+
+	args, ok := bw.args("update", p)
+	got := strings.Join(args, " ")
+	exp := "map update pinned /sys/fs/bpf/rrl key hex 18 00 00 00 c0 00 02 00 value hex 01"
+	if !ok || got != exp {
+		t.Error("Update args expected", exp, "got", got)
+	}
+
+	args, ok = bw.args("delete", p)
+	got = strings.Join(args, " ")
+	exp = "map delete pinned /sys/fs/bpf/rrl key hex 18 00 00 00 c0 00 02 00"
+	if !ok || got != exp {
+		t.Error("Delete args expected", exp, "got", got)
+	}
+
+	if err := bw.Update(netip.MustParsePrefix("2001:db8::/48")); err != nil {
+		t.Error("Inapplicable network should be ignored", err)
+	}
+
+	bw.Command = "/nonexistent/bpftool"
+	if err := bw.Update(p); err == nil {
+		t.Error("Expected error from missing command")
+	}
+}
@@ -0,0 +1,144 @@
+package rrl
+
+import (
+	"strings"
+)
+
+// ZoneFinder maps query names to the zone data which determines their SalientName as
+// described by the SalientName Selection Rules of [ResponseTuple]. Servers with
+// authoritative zone data can implement ZoneFinder directly on that data, otherwise
+// [NewZoneTrie] provides an implementation fed from a list of zones.
+//
+// FindOrigin returns the origin which qName falls under and whether responses for qName
+// are dynamically synthesized, typically from a wildcard. If synthesized, origin is the
+// name covered by the wildcard, otherwise it is the origin of the zone containing qName.
+// FindOrigin returns an empty origin if qName is not within any known zone.
+//
+// qName is always in lowercase with a trailing dot, and origin should be likewise.
+// Implementations must be concurrency safe.
+type ZoneFinder interface {
+	FindOrigin(qName string) (origin string, wildcard bool)
+}
+
+// NewZoneTuple is a helper function which creates a ResponseTuple for responses from
+// zones known to zf by applying the SalientName Selection Rules of [ResponseTuple]:
+//
+//   - AllowanceNXDomain and AllowanceReferral: the origin, which is the owner of the SOA
+//     or NS RRs in the Ns section provided zf knows of delegations as well as zones.
+//   - A synthesized response: the origin prefixed with "*." as per Rule 2.
+//   - Otherwise: qName.
+//
+// All names are returned in lowercase with a trailing dot. NewZoneTuple returns false if
+// zf does not know of a zone containing qName.
+func NewZoneTuple(qClass, qType uint16, qName string, ac AllowanceCategory, zf ZoneFinder) (*ResponseTuple, bool) {
+	qName = canonicalName(qName)
+	origin, wildcard := zf.FindOrigin(qName)
+	if len(origin) == 0 {
+		return nil, false
+	}
+
+	return originTuple(qClass, qType, qName, origin, wildcard, ac), true
+}
+
+// originTuple returns the ResponseTuple for qName once its origin has been determined.
+func originTuple(qClass, qType uint16, qName, origin string, wildcard bool, ac AllowanceCategory) *ResponseTuple {
+	rt := &ResponseTuple{Class: qClass, Type: qType, AllowanceCategory: ac}
+	switch {
+	case ac == AllowanceNXDomain || ac == AllowanceReferral:
+		rt.SalientName = origin
+	case wildcard:
+		rt.SalientName = "*." + origin
+	default:
+		rt.SalientName = qName
+	}
+
+	return rt
+}
+
+// ZoneTrie is the default [ZoneFinder]. It is a suffix trie of zone origins and wildcard
+// owners created by [NewZoneTrie]. A ZoneTrie is immutable and thus concurrency safe.
+type ZoneTrie struct {
+	root zoneNode
+}
+
+type zoneNode struct {
+	name     string // Canonical name of this node
+	zone     bool   // Node is a zone origin
+	wildcard bool   // Node owns a wildcard so names below it are synthesized
+	children map[string]*zoneNode
+}
+
+// NewZoneTrie returns a ZoneTrie populated from a list of names. Each name is either a
+// zone origin, such as "example.com", or a wildcard owner, such as "*.example.com",
+// which marks all names below "example.com" as synthesized. Names are case-insensitive
+// and the trailing dot is optional.
+//
+// As per RFC4592, a wildcard does not apply to names at or below any other listed name,
+// so a delegation or zone listed beneath a wildcard is found in preference.
+func NewZoneTrie(names []string) *ZoneTrie {
+	zt := &ZoneTrie{root: zoneNode{name: "."}}
+	for _, name := range names {
+		name = canonicalName(name)
+		wildcard := strings.HasPrefix(name, "*.")
+		if wildcard {
+			name = name[2:]
+		}
+		node := &zt.root
+		forEachLabel(name, func(label, suffix string) bool {
+			child := node.children[label]
+			if child == nil {
+				if node.children == nil {
+					node.children = make(map[string]*zoneNode)
+				}
+				child = &zoneNode{name: suffix}
+				node.children[label] = child
+			}
+			node = child
+			return true
+		})
+		if wildcard {
+			node.wildcard = true
+		} else {
+			node.zone = true
+		}
+	}
+
+	return zt
+}
+
+// FindOrigin implements [ZoneFinder].
+func (zt *ZoneTrie) FindOrigin(qName string) (origin string, wildcard bool) {
+	node := &zt.root
+	if node.zone {
+		origin = node.name
+	}
+	forEachLabel(qName, func(label, suffix string) bool {
+		child := node.children[label]
+		if child == nil {
+			if node.wildcard {
+				origin, wildcard = node.name, true
+			}
+			return false
+		}
+		node = child
+		if node.zone {
+			origin = node.name
+		}
+		return true
+	})
+
+	return
+}
+
+// forEachLabel calls fn for each label of the canonical name from the right along with
+// the suffix of name starting at that label, until fn returns false.
+func forEachLabel(name string, fn func(label, suffix string) bool) {
+	end := len(name) - 1 // Exclude trailing dot
+	for end > 0 {
+		start := strings.LastIndexByte(name[:end], '.') + 1
+		if !fn(name[start:end], name[start:]) {
+			return
+		}
+		end = start - 1
+	}
+}
@@ -0,0 +1,69 @@
+package rrl_test
+
+import (
+	"testing"
+
+	"github.com/markdingo/rrl"
+)
+
+func TestZoneTrie(t *testing.T) {
+	zt := rrl.NewZoneTrie([]string{"Example.COM", "*.dyn.example.com.", "static.dyn.example.com",
+		"*.wild.example.", "sub.wild.example.", "*."})
+	testCases := []struct {
+		qName    string
+		origin   string
+		wildcard bool
+	}{
+		{"example.com.", "example.com.", false},
+		{"www.example.com.", "example.com.", false},
+		{"dyn.example.com.", "example.com.", false},
+		{"a.dyn.example.com.", "dyn.example.com.", true},
+		{"a.b.dyn.example.com.", "dyn.example.com.", true},
+		{"static.dyn.example.com.", "static.dyn.example.com.", false},
+		{"a.static.dyn.example.com.", "static.dyn.example.com.", false},
+		{"x.wild.example.", "wild.example.", true},
+		{"sub.wild.example.", "sub.wild.example.", false},
+		{"x.sub.wild.example.", "sub.wild.example.", false},
+		{"example.net.", ".", true},
+		{".", "", false},
+	}
+
+	for ix, tc := range testCases {
+		origin, wildcard := zt.FindOrigin(tc.qName)
+		if origin != tc.origin || wildcard != tc.wildcard {
+			t.Error(ix, tc.qName, "Expected", tc.origin, tc.wildcard, "got", origin, wildcard)
+		}
+	}
+
+	if origin, _ := rrl.NewZoneTrie(nil).FindOrigin("example.com."); origin != "" {
+		t.Error("Empty ZoneTrie should not find", origin)
+	}
+}
+
+func TestNewZoneTuple(t *testing.T) {
+	zt := rrl.NewZoneTrie([]string{"example.com", "*.dyn.example.com"})
+	testCases := []struct {
+		qName string
+		ac    rrl.AllowanceCategory
+		ok    bool
+		exp   string
+	}{
+		{"WWW.example.com", rrl.AllowanceAnswer, true, "www.example.com."},
+		{"a.dyn.example.com.", rrl.AllowanceAnswer, true, "*.dyn.example.com."},
+		{"a.dyn.example.com.", rrl.AllowanceNoData, true, "*.dyn.example.com."},
+		{"a.dyn.example.com.", rrl.AllowanceNXDomain, true, "dyn.example.com."},
+		{"missing.example.com.", rrl.AllowanceNXDomain, true, "example.com."},
+		{"example.net.", rrl.AllowanceAnswer, false, ""},
+	}
+
+	for ix, tc := range testCases {
+		rt, ok := rrl.NewZoneTuple(1, 1, tc.qName, tc.ac, zt)
+		if ok != tc.ok {
+			t.Error(ix, "Expected ok", tc.ok, "got", ok)
+			continue
+		}
+		if ok && (rt.SalientName != tc.exp || rt.AllowanceCategory != tc.ac || rt.Type != 1) {
+			t.Error(ix, "Expected", tc.exp, "got", rt.String())
+		}
+	}
+}